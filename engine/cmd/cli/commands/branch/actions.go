@@ -0,0 +1,135 @@
+/*
+2022 © Postgres.ai
+*/
+
+// Package branch provides branch management commands.
+package branch
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/urfave/cli/v2"
+
+	"gitlab.com/postgres-ai/database-lab/v3/cmd/cli/commands"
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/client/dblabapi/types"
+)
+
+// list runs a request to list branches of an instance.
+func list(cliCtx *cli.Context) error {
+	dblabClient, err := commands.ClientByCLIContext(cliCtx)
+	if err != nil {
+		return err
+	}
+
+	branches, err := dblabClient.ListBranches(cliCtx.Context)
+	if err != nil {
+		return err
+	}
+
+	return printJSON(cliCtx, branches)
+}
+
+// create runs a request to create a new branch.
+func create(cliCtx *cli.Context) error {
+	dblabClient, err := commands.ClientByCLIContext(cliCtx)
+	if err != nil {
+		return err
+	}
+
+	branchRequest := types.BranchCreateRequest{
+		Name:       cliCtx.Args().First(),
+		BaseBranch: cliCtx.String("from-branch"),
+		SnapshotID: cliCtx.String("snapshot-id"),
+	}
+
+	branch, err := dblabClient.CreateBranch(cliCtx.Context, branchRequest)
+	if err != nil {
+		return err
+	}
+
+	return printJSON(cliCtx, branch)
+}
+
+// deleteBranch runs a request to delete the branch.
+func deleteBranch(cliCtx *cli.Context) error {
+	dblabClient, err := commands.ClientByCLIContext(cliCtx)
+	if err != nil {
+		return err
+	}
+
+	branchName := cliCtx.Args().First()
+
+	if err := dblabClient.DeleteBranch(cliCtx.Context, branchName); err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(cliCtx.App.Writer, "The branch has been successfully deleted: %s\n", branchName)
+
+	return err
+}
+
+// log runs a request to display the snapshot chain of the branch.
+func log(cliCtx *cli.Context) error {
+	dblabClient, err := commands.ClientByCLIContext(cliCtx)
+	if err != nil {
+		return err
+	}
+
+	snapshots, err := dblabClient.BranchLog(cliCtx.Context, cliCtx.Args().First())
+	if err != nil {
+		return err
+	}
+
+	return printJSON(cliCtx, snapshots)
+}
+
+// commit runs a request to commit the clone state to the branch.
+func commit(cliCtx *cli.Context) error {
+	dblabClient, err := commands.ClientByCLIContext(cliCtx)
+	if err != nil {
+		return err
+	}
+
+	commitRequest := types.BranchCommitRequest{
+		CloneID: cliCtx.String("clone-id"),
+		Message: cliCtx.String("message"),
+	}
+
+	snapshot, err := dblabClient.CommitBranch(cliCtx.Context, cliCtx.Args().First(), commitRequest)
+	if err != nil {
+		return err
+	}
+
+	return printJSON(cliCtx, snapshot)
+}
+
+// reset runs a request to move the branch head.
+func reset(cliCtx *cli.Context) error {
+	dblabClient, err := commands.ClientByCLIContext(cliCtx)
+	if err != nil {
+		return err
+	}
+
+	resetRequest := types.BranchResetRequest{
+		SnapshotID: cliCtx.String("snapshot-id"),
+	}
+
+	branch, err := dblabClient.ResetBranch(cliCtx.Context, cliCtx.Args().First(), resetRequest)
+	if err != nil {
+		return err
+	}
+
+	return printJSON(cliCtx, branch)
+}
+
+func printJSON(cliCtx *cli.Context, data interface{}) error {
+	commandResponse, err := json.MarshalIndent(data, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(cliCtx.App.Writer, string(commandResponse))
+
+	return err
+}
@@ -0,0 +1,100 @@
+/*
+2022 © Postgres.ai
+*/
+
+package branch
+
+import (
+	"github.com/urfave/cli/v2"
+
+	"gitlab.com/postgres-ai/database-lab/v3/cmd/cli/commands"
+)
+
+// CommandList returns available commands for a branch management.
+func CommandList() []*cli.Command {
+	return []*cli.Command{
+		{
+			Name:  "branch",
+			Usage: "manage branches",
+			Subcommands: []*cli.Command{
+				{
+					Name:   "list",
+					Usage:  "list all existing branches",
+					Action: list,
+				},
+				{
+					Name:      "create",
+					Usage:     "create a new branch",
+					ArgsUsage: "BRANCH_NAME",
+					Before:    checkBranchNameBefore,
+					Action:    create,
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:  "from-branch",
+							Usage: "branch whose head is used as the start point of the new branch (optional)",
+						},
+						&cli.StringFlag{
+							Name:  "snapshot-id",
+							Usage: "snapshot ID used as the start point of the new branch (optional)",
+						},
+					},
+				},
+				{
+					Name:      "delete",
+					Usage:     "delete the branch",
+					ArgsUsage: "BRANCH_NAME",
+					Before:    checkBranchNameBefore,
+					Action:    deleteBranch,
+				},
+				{
+					Name:      "log",
+					Usage:     "display snapshots of the branch starting from its head",
+					ArgsUsage: "BRANCH_NAME",
+					Before:    checkBranchNameBefore,
+					Action:    log,
+				},
+				{
+					Name:      "commit",
+					Usage:     "save the clone state as a new snapshot and move the branch head to it",
+					ArgsUsage: "BRANCH_NAME",
+					Before:    checkBranchNameBefore,
+					Action:    commit,
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:     "clone-id",
+							Usage:    "ID of the clone to commit",
+							Required: true,
+						},
+						&cli.StringFlag{
+							Name:    "message",
+							Usage:   "commit message",
+							Aliases: []string{"m"},
+						},
+					},
+				},
+				{
+					Name:      "reset",
+					Usage:     "move the branch head to the snapshot",
+					ArgsUsage: "BRANCH_NAME",
+					Before:    checkBranchNameBefore,
+					Action:    reset,
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:     "snapshot-id",
+							Usage:    "snapshot ID of the new branch head",
+							Required: true,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func checkBranchNameBefore(c *cli.Context) error {
+	if c.NArg() == 0 {
+		return commands.NewActionError("BRANCH_NAME argument is required")
+	}
+
+	return nil
+}
@@ -9,6 +9,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"net/url"
 	"os"
 	"path"
@@ -20,6 +21,7 @@ import (
 
 	"gitlab.com/postgres-ai/database-lab/v3/cmd/cli/commands"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/observer"
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/client/dblabapi"
 	"gitlab.com/postgres-ai/database-lab/v3/pkg/client/dblabapi/types"
 	"gitlab.com/postgres-ai/database-lab/v3/pkg/log"
 	"gitlab.com/postgres-ai/database-lab/v3/pkg/models"
@@ -32,6 +34,10 @@ func list(cliCtx *cli.Context) error {
 		return err
 	}
 
+	if cliCtx.IsSet("selector") {
+		return listBySelector(cliCtx, dblabClient, cliCtx.String("selector"))
+	}
+
 	body, err := dblabClient.ListClonesRaw(cliCtx.Context)
 	if err != nil {
 		return err
@@ -59,6 +65,30 @@ func list(cliCtx *cli.Context) error {
 	return err
 }
 
+func listBySelector(cliCtx *cli.Context, dblabClient *dblabapi.Client, selector string) error {
+	body, err := dblabClient.ListClonesBySelectorRaw(cliCtx.Context, selector)
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = body.Close() }()
+
+	viewClones := make([]*models.CloneView, 0)
+
+	if err := json.NewDecoder(body).Decode(&viewClones); err != nil {
+		return err
+	}
+
+	commandResponse, err := json.MarshalIndent(viewClones, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(cliCtx.App.Writer, string(commandResponse))
+
+	return err
+}
+
 // status runs a request to get clone info.
 func status(cliCtx *cli.Context) error {
 	dblabClient, err := commands.ClientByCLIContext(cliCtx)
@@ -89,6 +119,28 @@ func status(cliCtx *cli.Context) error {
 	return err
 }
 
+// history runs a request to get the history of clone events.
+func history(cliCtx *cli.Context) error {
+	dblabClient, err := commands.ClientByCLIContext(cliCtx)
+	if err != nil {
+		return err
+	}
+
+	events, err := dblabClient.CloneEvents(cliCtx.Context, cliCtx.Args().First())
+	if err != nil {
+		return err
+	}
+
+	commandResponse, err := json.MarshalIndent(events, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(cliCtx.App.Writer, string(commandResponse))
+
+	return err
+}
+
 // create runs a request to create a new clone.
 func create(cliCtx *cli.Context) error {
 	dblabClient, err := commands.ClientByCLIContext(cliCtx)
@@ -111,8 +163,30 @@ func create(cliCtx *cli.Context) error {
 		cloneRequest.Snapshot = &types.SnapshotCloneFieldRequest{ID: cliCtx.String("snapshot-id")}
 	}
 
+	cloneRequest.Branch = cliCtx.String("branch")
+
 	cloneRequest.ExtraConf = splitFlags(cliCtx.StringSlice("extra-config"))
 
+	cloneRequest.TTL = cliCtx.String("ttl")
+	cloneRequest.ExpiresAt = cliCtx.Timestamp("expires-at")
+
+	if cloneRequest.Labels, err = parseLabels(cliCtx.StringSlice("label")); err != nil {
+		return err
+	}
+
+	if cliCtx.Uint("blkio-weight") > math.MaxUint16 {
+		return errors.New("block IO weight is out of range")
+	}
+
+	if cliCtx.IsSet("cpus") || cliCtx.IsSet("memory") || cliCtx.IsSet("shm-size") || cliCtx.IsSet("blkio-weight") {
+		cloneRequest.Resources = &types.ResourcesRequest{
+			CPUs:        cliCtx.Float64("cpus"),
+			Memory:      cliCtx.String("memory"),
+			ShmSize:     cliCtx.String("shm-size"),
+			BlkioWeight: uint16(cliCtx.Uint("blkio-weight")),
+		}
+	}
+
 	var clone *models.Clone
 
 	if cliCtx.Bool("async") {
@@ -148,7 +222,19 @@ func update(cliCtx *cli.Context) error {
 	}
 
 	updateRequest := types.CloneUpdateRequest{
-		Protected: cliCtx.Bool("protected"),
+		TTL:       cliCtx.String("ttl"),
+		ExpiresAt: cliCtx.Timestamp("expires-at"),
+	}
+
+	if cliCtx.IsSet("protected") {
+		protected := cliCtx.Bool("protected")
+		updateRequest.Protected = &protected
+	}
+
+	if cliCtx.IsSet("label") {
+		if updateRequest.Labels, err = parseLabels(cliCtx.StringSlice("label")); err != nil {
+			return err
+		}
 	}
 
 	cloneID := cliCtx.Args().First()
@@ -215,6 +301,30 @@ func reset(cliCtx *cli.Context) error {
 	return err
 }
 
+// snapshot runs a request to create a snapshot from clone.
+func snapshot(cliCtx *cli.Context) error {
+	dblabClient, err := commands.ClientByCLIContext(cliCtx)
+	if err != nil {
+		return err
+	}
+
+	cloneID := cliCtx.Args().First()
+
+	cloneSnapshot, err := dblabClient.CreateSnapshotFromClone(cliCtx.Context, cloneID)
+	if err != nil {
+		return err
+	}
+
+	commandResponse, err := json.MarshalIndent(cloneSnapshot, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(cliCtx.App.Writer, string(commandResponse))
+
+	return err
+}
+
 // destroy runs a request to destroy clone.
 func destroy(cliCtx *cli.Context) error {
 	dblabClient, err := commands.ClientByCLIContext(cliCtx)
@@ -222,6 +332,10 @@ func destroy(cliCtx *cli.Context) error {
 		return err
 	}
 
+	if cliCtx.IsSet("selector") {
+		return destroyBySelector(cliCtx, dblabClient, cliCtx.String("selector"))
+	}
+
 	cloneID := cliCtx.Args().First()
 
 	if cliCtx.Bool("async") {
@@ -239,6 +353,28 @@ func destroy(cliCtx *cli.Context) error {
 	return err
 }
 
+func destroyBySelector(cliCtx *cli.Context, dblabClient *dblabapi.Client, selector string) error {
+	result, err := dblabClient.DestroyClonesBySelector(cliCtx.Context, selector)
+	if err != nil {
+		return err
+	}
+
+	commandResponse, err := json.MarshalIndent(result, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	if _, err = fmt.Fprintln(cliCtx.App.Writer, string(commandResponse)); err != nil {
+		return err
+	}
+
+	if len(result.Failed) > 0 {
+		return errors.Errorf("failed to destroy %d clone(s)", len(result.Failed))
+	}
+
+	return nil
+}
+
 // startObservation runs a request to startObservation clone.
 func startObservation(cliCtx *cli.Context) error {
 	dblabClient, err := commands.ClientByCLIContext(cliCtx)
@@ -454,6 +590,23 @@ func retrieveClonePort(cliCtx *cli.Context, wg *sync.WaitGroup, remoteHost *url.
 	return clone.DB.Port, nil
 }
 
+func parseLabels(flags []string) (map[string]string, error) {
+	const maxSplitParts = 2
+
+	labels := make(map[string]string, len(flags))
+
+	for _, flag := range flags {
+		parsed := strings.SplitN(flag, "=", maxSplitParts)
+		if len(parsed) != maxSplitParts {
+			return nil, errors.Errorf("invalid label %q: expected key=value", flag)
+		}
+
+		labels[parsed[0]] = parsed[1]
+	}
+
+	return labels, nil
+}
+
 func splitFlags(flags []string) map[string]string {
 	const maxSplitParts = 2
 
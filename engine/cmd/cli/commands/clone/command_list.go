@@ -5,6 +5,8 @@
 package clone
 
 import (
+	"time"
+
 	"github.com/urfave/cli/v2"
 
 	"gitlab.com/postgres-ai/database-lab/v3/cmd/cli/commands"
@@ -25,6 +27,12 @@ func CommandList() []*cli.Command {
 				Name:   "list",
 				Usage:  "list all existing clones",
 				Action: list,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "selector",
+						Usage: "list only clones having the specified labels, e.g. pipeline=123,team=db (optional)",
+					},
+				},
 			},
 			{
 				Name:      "status",
@@ -33,6 +41,13 @@ func CommandList() []*cli.Command {
 				Before:    checkCloneIDBefore,
 				Action:    status,
 			},
+			{
+				Name:      "history",
+				Usage:     "display the history of clone events, including recently destroyed clones",
+				ArgsUsage: "CLONE_ID",
+				Before:    checkCloneIDBefore,
+				Action:    history,
+			},
 			{
 				Name:   "create",
 				Usage:  "create new clone",
@@ -64,6 +79,10 @@ func CommandList() []*cli.Command {
 						Name:  "snapshot-id",
 						Usage: "snapshot ID (optional)",
 					},
+					&cli.StringFlag{
+						Name:  "branch",
+						Usage: "branch whose head is used to create the clone (optional)",
+					},
 					&cli.BoolFlag{
 						Name:    "protected",
 						Usage:   "mark instance as protected from deletion",
@@ -78,6 +97,35 @@ func CommandList() []*cli.Command {
 						Name:  "extra-config",
 						Usage: "set an extra database configuration for the clone. An example: statement_timeout='1s'",
 					},
+					&cli.Float64Flag{
+						Name:  "cpus",
+						Usage: "number of CPUs available to the clone container (optional)",
+					},
+					&cli.StringFlag{
+						Name:  "memory",
+						Usage: "memory limit of the clone container, e.g. 2g (optional)",
+					},
+					&cli.StringFlag{
+						Name:  "shm-size",
+						Usage: "size of /dev/shm of the clone container, e.g. 512m (optional)",
+					},
+					&cli.UintFlag{
+						Name:  "blkio-weight",
+						Usage: "relative block IO weight of the clone container, between 10 and 1000 (optional)",
+					},
+					&cli.StringFlag{
+						Name:  "ttl",
+						Usage: "delete the clone after the specified duration, e.g. 2h (optional)",
+					},
+					&cli.TimestampFlag{
+						Name:   "expires-at",
+						Usage:  "delete the clone at the specified time in RFC 3339 format, e.g. 2022-01-27T15:00:00Z (optional)",
+						Layout: time.RFC3339,
+					},
+					&cli.StringSliceFlag{
+						Name:  "label",
+						Usage: "set a label for the clone. An example: pipeline=123",
+					},
 				},
 			},
 			{
@@ -89,9 +137,22 @@ func CommandList() []*cli.Command {
 				Flags: []cli.Flag{
 					&cli.BoolFlag{
 						Name:    "protected",
-						Usage:   "mark instance as protected from deletion",
+						Usage:   "mark instance as protected from deletion, use --protected=false to remove the protection (optional)",
 						Aliases: []string{"p"},
 					},
+					&cli.StringFlag{
+						Name:  "ttl",
+						Usage: "reschedule the clone deletion after the specified duration from now, 0 cancels the scheduled deletion",
+					},
+					&cli.TimestampFlag{
+						Name:   "expires-at",
+						Usage:  "reschedule the clone deletion at the specified time in RFC 3339 format",
+						Layout: time.RFC3339,
+					},
+					&cli.StringSliceFlag{
+						Name:  "label",
+						Usage: "replace the clone labels. An example: pipeline=123",
+					},
 				},
 			},
 			{
@@ -116,18 +177,35 @@ func CommandList() []*cli.Command {
 					},
 				},
 			},
+			{
+				Name:      "snapshot",
+				Usage:     "create a snapshot from clone's state",
+				ArgsUsage: "CLONE_ID",
+				Before:    checkCloneIDBefore,
+				Action:    snapshot,
+			},
 			{
 				Name:      "destroy",
 				Usage:     "destroy clone",
 				ArgsUsage: "CLONE_ID",
-				Before:    checkCloneIDBefore,
-				Action:    destroy,
+				Before: func(ctxCli *cli.Context) error {
+					if ctxCli.IsSet("selector") {
+						return nil
+					}
+
+					return checkCloneIDBefore(ctxCli)
+				},
+				Action: destroy,
 				Flags: []cli.Flag{
 					&cli.BoolFlag{
 						Name:    "async",
 						Usage:   "run the command asynchronously",
 						Aliases: []string{"a"},
 					},
+					&cli.StringFlag{
+						Name:  "selector",
+						Usage: "destroy all clones having the specified labels instead of CLONE_ID, e.g. pipeline=123",
+					},
 				},
 			},
 			{
@@ -12,6 +12,7 @@ import (
 	"github.com/urfave/cli/v2"
 
 	"gitlab.com/postgres-ai/database-lab/v3/cmd/cli/commands"
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/client/dblabapi/types"
 	"gitlab.com/postgres-ai/database-lab/v3/pkg/models"
 )
 
@@ -44,3 +45,107 @@ func list(cliCtx *cli.Context) error {
 
 	return err
 }
+
+// create runs a request to take a new snapshot.
+func create(cliCtx *cli.Context) error {
+	dblabClient, err := commands.ClientByCLIContext(cliCtx)
+	if err != nil {
+		return err
+	}
+
+	snapshotRequest := types.SnapshotCreateRequest{
+		PoolName: cliCtx.String("pool"),
+	}
+
+	response, err := dblabClient.CreateSnapshot(cliCtx.Context, snapshotRequest)
+	if err != nil {
+		return err
+	}
+
+	return printTakeResponse(cliCtx, response)
+}
+
+// createPITR runs a request to take a new snapshot of the data recovered to a point in time.
+func createPITR(cliCtx *cli.Context) error {
+	dblabClient, err := commands.ClientByCLIContext(cliCtx)
+	if err != nil {
+		return err
+	}
+
+	pitrRequest := types.SnapshotPITRRequest{
+		TargetTime: cliCtx.String("time"),
+		Name:       cliCtx.String("name"),
+	}
+
+	response, err := dblabClient.CreatePITRSnapshot(cliCtx.Context, pitrRequest)
+	if err != nil {
+		return err
+	}
+
+	return printTakeResponse(cliCtx, response)
+}
+
+// destroy runs a request to destroy a snapshot.
+func destroy(cliCtx *cli.Context) error {
+	dblabClient, err := commands.ClientByCLIContext(cliCtx)
+	if err != nil {
+		return err
+	}
+
+	snapshotID := cliCtx.Args().First()
+
+	if err := dblabClient.DestroySnapshot(cliCtx.Context, snapshotID); err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(cliCtx.App.Writer, "The snapshot has been successfully destroyed: %s\n", snapshotID)
+
+	return err
+}
+
+// protect runs a request to change the protection of a snapshot.
+func protect(cliCtx *cli.Context) error {
+	dblabClient, err := commands.ClientByCLIContext(cliCtx)
+	if err != nil {
+		return err
+	}
+
+	updateRequest := types.SnapshotUpdateRequest{
+		Protected: !cliCtx.Bool("off"),
+	}
+
+	snapshot, err := dblabClient.UpdateSnapshot(cliCtx.Context, cliCtx.Args().First(), updateRequest)
+	if err != nil {
+		return err
+	}
+
+	return printSnapshot(cliCtx, snapshot)
+}
+
+func printTakeResponse(cliCtx *cli.Context, response *types.SnapshotTakeResponse) error {
+	commandResponse, err := json.MarshalIndent(response, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(cliCtx.App.Writer, string(commandResponse))
+
+	return err
+}
+
+func printSnapshot(cliCtx *cli.Context, snapshot *models.Snapshot) error {
+	snapshotView := &models.SnapshotView{
+		Snapshot:     snapshot,
+		PhysicalSize: models.Size(snapshot.PhysicalSize),
+		LogicalSize:  models.Size(snapshot.LogicalSize),
+	}
+
+	commandResponse, err := json.MarshalIndent(snapshotView, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(cliCtx.App.Writer, string(commandResponse))
+
+	return err
+}
@@ -6,6 +6,8 @@ package snapshot
 
 import (
 	"github.com/urfave/cli/v2"
+
+	"gitlab.com/postgres-ai/database-lab/v3/cmd/cli/commands"
 )
 
 // CommandList returns available commands for a snapshot management.
@@ -20,7 +22,62 @@ func CommandList() []*cli.Command {
 					Usage:  "list all existing snapshots",
 					Action: list,
 				},
+				{
+					Name:   "create",
+					Usage:  "start taking a new snapshot of the pool data in the background",
+					Action: create,
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:  "pool",
+							Usage: "pool name to take a snapshot of (default: the active pool)",
+						},
+					},
+				},
+				{
+					Name:   "pitr",
+					Usage:  "take a new snapshot of the data recovered to a point in time from the WAL archive",
+					Action: createPITR,
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:     "time",
+							Usage:    "recovery target time in RFC 3339 format, for example, 2022-10-12T14:03:27Z",
+							Required: true,
+						},
+						&cli.StringFlag{
+							Name:  "name",
+							Usage: "name of the snapshot",
+						},
+					},
+				},
+				{
+					Name:      "destroy",
+					Usage:     "destroy snapshot",
+					ArgsUsage: "SNAPSHOT_ID",
+					Before:    checkSnapshotIDBefore,
+					Action:    destroy,
+				},
+				{
+					Name:      "protect",
+					Usage:     "protect snapshot from the retention",
+					ArgsUsage: "SNAPSHOT_ID",
+					Before:    checkSnapshotIDBefore,
+					Action:    protect,
+					Flags: []cli.Flag{
+						&cli.BoolFlag{
+							Name:  "off",
+							Usage: "remove the protection",
+						},
+					},
+				},
 			},
 		},
 	}
 }
+
+func checkSnapshotIDBefore(c *cli.Context) error {
+	if c.NArg() == 0 {
+		return commands.NewActionError("SNAPSHOT_ID argument is required")
+	}
+
+	return nil
+}
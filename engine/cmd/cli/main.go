@@ -9,6 +9,7 @@ import (
 	"github.com/urfave/cli/v2"
 
 	"gitlab.com/postgres-ai/database-lab/v3/cmd/cli/commands"
+	"gitlab.com/postgres-ai/database-lab/v3/cmd/cli/commands/branch"
 	"gitlab.com/postgres-ai/database-lab/v3/cmd/cli/commands/clone"
 	"gitlab.com/postgres-ai/database-lab/v3/cmd/cli/commands/config"
 	"gitlab.com/postgres-ai/database-lab/v3/cmd/cli/commands/global"
@@ -34,6 +35,7 @@ func main() {
 			clone.CommandList(),
 			instance.CommandList(),
 			snapshot.CommandList(),
+			branch.CommandList(),
 
 			// CLI config.
 			config.CommandList(),
@@ -20,11 +20,14 @@ import (
 	"github.com/docker/docker/client"
 	"github.com/pkg/errors"
 
+	"gitlab.com/postgres-ai/database-lab/v3/internal/audit"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/cloning"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/diagnostic"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/embeddedui"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/estimator"
+	"gitlab.com/postgres-ai/database-lab/v3/internal/events"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/observer"
+	"gitlab.com/postgres-ai/database-lab/v3/internal/pgproxy"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/platform"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/provision"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/provision/pool"
@@ -33,8 +36,11 @@ import (
 	"gitlab.com/postgres-ai/database-lab/v3/internal/retrieval"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/retrieval/engine/postgres/tools/cont"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/srv"
+	"gitlab.com/postgres-ai/database-lab/v3/internal/srv/mw"
+	"gitlab.com/postgres-ai/database-lab/v3/internal/srv/oidc"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/srv/ws"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/telemetry"
+	"gitlab.com/postgres-ai/database-lab/v3/internal/webhooks"
 	"gitlab.com/postgres-ai/database-lab/v3/pkg/config"
 	"gitlab.com/postgres-ai/database-lab/v3/pkg/config/global"
 	"gitlab.com/postgres-ai/database-lab/v3/pkg/log"
@@ -79,10 +85,20 @@ func main() {
 	log.Msg("Database Lab Instance ID:", engProps.InstanceID)
 	log.Msg("Database Lab Engine version:", version.GetVersion())
 
-	if cfg.Server.VerificationToken == "" {
+	if cfg.Server.VerificationToken == "" && len(cfg.Server.Tokens) == 0 {
 		log.Warn("Verification Token is empty. Database Lab Engine is insecure")
 	}
 
+	if err := mw.ValidateTokens(cfg.Server.Tokens); err != nil {
+		log.Errf(err.Error())
+		return
+	}
+
+	if err := oidc.ValidateConfig(cfg.Server.OIDC); err != nil {
+		log.Errf(errors.WithMessage(err, "invalid OpenID Connect configuration").Error())
+		return
+	}
+
 	runner := runners.NewLocalRunner(cfg.Provision.UseSudo)
 
 	internalNetworkID, err := networks.Setup(ctx, docker, engProps.InstanceID, engProps.ContainerName)
@@ -111,13 +127,31 @@ func main() {
 		return
 	}
 
+	wh, err := webhooks.NewService(cfg.Webhooks, engProps.InstanceID)
+	if err != nil {
+		log.Errf(errors.WithMessage(err, "failed to initialize a webhooks service").Error())
+		return
+	}
+
+	go wh.Run(ctx)
+
+	broker := events.NewBroker()
+
+	auditLog, err := audit.NewLogger(cfg.Diagnostic)
+	if err != nil {
+		log.Errf(errors.WithMessage(err, "failed to initialize an audit log").Error())
+		return
+	}
+
+	go auditLog.Run(ctx)
+
 	pm := pool.NewPoolManager(&cfg.PoolManager, runner)
 	if err = pm.ReloadPools(); err != nil {
 		log.Err(err.Error())
 	}
 
 	// Create a new retrieval service to prepare a data directory and start snapshotting.
-	retrievalSvc, err := retrieval.New(cfg, engProps, docker, pm, tm, runner)
+	retrievalSvc, err := retrieval.New(cfg, engProps, docker, pm, tm, wh, broker, runner)
 	if err != nil {
 		log.Errf(errors.WithMessage(err, `error in the "retrieval" section of the config`).Error())
 		return
@@ -144,7 +178,21 @@ func main() {
 		shutdownDatabaseLabEngine(context.Background(), docker, &cfg.Global.Database, engProps.InstanceID, pm.First())
 	}
 
-	cloningSvc := cloning.NewBase(&cfg.Cloning, provisioner, tm, observingChan)
+	stateStore, err := cloning.OpenStateStore()
+	if err != nil {
+		log.Err("Failed to open the state store of clones:", err)
+		emergencyShutdown()
+
+		return
+	}
+
+	defer func() {
+		if err := stateStore.Close(); err != nil {
+			log.Err("Failed to close the state store of clones:", err)
+		}
+	}()
+
+	cloningSvc := cloning.NewBase(&cfg.Cloning, provisioner, tm, wh, broker, stateStore, observingChan)
 	if err = cloningSvc.Run(ctx); err != nil {
 		log.Err(err)
 		emergencyShutdown()
@@ -152,6 +200,10 @@ func main() {
 		return
 	}
 
+	if cfg.Proxy.Enabled {
+		runPostgresProxy(ctx, cfg.Proxy, cloningSvc)
+	}
+
 	obs := observer.NewObserver(docker, &cfg.Observer, pm)
 	est := estimator.NewEstimator(&cfg.Estimator)
 
@@ -173,6 +225,8 @@ func main() {
 			ctx,
 			provisioner,
 			tm,
+			wh,
+			auditLog,
 			retrievalSvc,
 			pm,
 			cloningSvc,
@@ -185,10 +239,10 @@ func main() {
 	}
 
 	server := srv.NewServer(&cfg.Server, &cfg.Global, engProps, docker, cloningSvc, provisioner, retrievalSvc, platformSvc,
-		obs, est, pm, tm, tokenHolder, embeddedUI, reloadConfigFn)
+		obs, est, pm, tm, wh, broker, auditLog, tokenHolder, embeddedUI, reloadConfigFn)
 	shutdownCh := setShutdownListener()
 
-	go setReloadListener(ctx, provisioner, tm, retrievalSvc, pm, cloningSvc, platformSvc, est, embeddedUI, server, logCleaner)
+	go setReloadListener(ctx, provisioner, tm, wh, auditLog, retrievalSvc, pm, cloningSvc, platformSvc, est, embeddedUI, server, logCleaner)
 
 	server.InitHandlers()
 
@@ -236,6 +290,21 @@ func main() {
 	tm.SendEvent(ctxBackground, telemetry.EngineStoppedEvent, telemetry.EngineStopped{Uptime: server.Uptime()})
 }
 
+// runPostgresProxy starts the Postgres protocol proxy routing connections to clones.
+func runPostgresProxy(ctx context.Context, cfg pgproxy.Config, cloningSvc *cloning.Base) {
+	pgProxy, err := pgproxy.New(cfg, cloningSvc)
+	if err != nil {
+		log.Err("Failed to initialize the Postgres proxy:", err)
+		return
+	}
+
+	go func() {
+		if err := pgProxy.Run(ctx); err != nil {
+			log.Err(err)
+		}
+	}()
+}
+
 func getEngineProperties(ctx context.Context, dockerCLI *client.Client, cfg *config.Config) (global.EngineProps, error) {
 	hostname := os.Getenv("HOSTNAME")
 	if hostname == "" {
@@ -267,9 +336,9 @@ func getEngineProperties(ctx context.Context, dockerCLI *client.Client, cfg *con
 	return engProps, nil
 }
 
-func reloadConfig(ctx context.Context, provisionSvc *provision.Provisioner, tm *telemetry.Agent,
-	retrievalSvc *retrieval.Retrieval, pm *pool.Manager, cloningSvc *cloning.Base, platformSvc *platform.Service,
-	est *estimator.Estimator, embeddedUI *embeddedui.UIManager, server *srv.Server, cleaner *diagnostic.Cleaner) error {
+func reloadConfig(ctx context.Context, provisionSvc *provision.Provisioner, tm *telemetry.Agent, wh *webhooks.Service,
+	auditLog *audit.Logger, retrievalSvc *retrieval.Retrieval, pm *pool.Manager, cloningSvc *cloning.Base,
+	platformSvc *platform.Service, est *estimator.Estimator, embeddedUI *embeddedui.UIManager, server *srv.Server, cleaner *diagnostic.Cleaner) error {
 	cfg, err := config.LoadConfiguration()
 	if err != nil {
 		return err
@@ -281,6 +350,14 @@ func reloadConfig(ctx context.Context, provisionSvc *provision.Provisioner, tm *
 		return err
 	}
 
+	if err := mw.ValidateTokens(cfg.Server.Tokens); err != nil {
+		return err
+	}
+
+	if err := oidc.ValidateConfig(cfg.Server.OIDC); err != nil {
+		return err
+	}
+
 	newRetrievalConfig, err := retrieval.ValidateConfig(&cfg.Retrieval)
 	if err != nil {
 		return err
@@ -310,6 +387,8 @@ func reloadConfig(ctx context.Context, provisionSvc *provision.Provisioner, tm *
 
 	provisionSvc.Reload(cfg.Provision, dbCfg)
 	tm.Reload(cfg.Global)
+	wh.Reload(cfg.Webhooks)
+	auditLog.Reload(cfg.Diagnostic)
 	retrievalSvc.Reload(ctx, newRetrievalConfig)
 	cloningSvc.Reload(cfg.Cloning)
 	platformSvc.Reload(newPlatformSvc)
@@ -319,16 +398,16 @@ func reloadConfig(ctx context.Context, provisionSvc *provision.Provisioner, tm *
 	return nil
 }
 
-func setReloadListener(ctx context.Context, provisionSvc *provision.Provisioner, tm *telemetry.Agent,
-	retrievalSvc *retrieval.Retrieval, pm *pool.Manager, cloningSvc *cloning.Base, platformSvc *platform.Service,
-	est *estimator.Estimator, embeddedUI *embeddedui.UIManager, server *srv.Server, cleaner *diagnostic.Cleaner) {
+func setReloadListener(ctx context.Context, provisionSvc *provision.Provisioner, tm *telemetry.Agent, wh *webhooks.Service,
+	auditLog *audit.Logger, retrievalSvc *retrieval.Retrieval, pm *pool.Manager, cloningSvc *cloning.Base,
+	platformSvc *platform.Service, est *estimator.Estimator, embeddedUI *embeddedui.UIManager, server *srv.Server, cleaner *diagnostic.Cleaner) {
 	reloadCh := make(chan os.Signal, 1)
 	signal.Notify(reloadCh, syscall.SIGHUP)
 
 	for range reloadCh {
 		log.Msg("Reloading configuration")
 
-		if err := reloadConfig(ctx, provisionSvc, tm, retrievalSvc, pm, cloningSvc, platformSvc, est, embeddedUI, server, cleaner); err != nil {
+		if err := reloadConfig(ctx, provisionSvc, tm, wh, auditLog, retrievalSvc, pm, cloningSvc, platformSvc, est, embeddedUI, server, cleaner); err != nil {
 			log.Err("Failed to reload configuration", err)
 		}
 
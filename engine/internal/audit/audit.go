@@ -0,0 +1,279 @@
+/*
+2022 © Postgres.ai
+*/
+
+// Package audit records API mutations to an append-only log.
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gitlab.com/postgres-ai/database-lab/v3/internal/diagnostic"
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/log"
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/util"
+)
+
+const (
+	auditDir        = "audit"
+	filePrefix      = "audit-"
+	fileExt         = ".jsonl"
+	fileDateFormat  = "20060102"
+	cleanupInterval = time.Hour
+
+	// ResultSuccess marks requests completed successfully.
+	ResultSuccess = "success"
+	// ResultFailure marks rejected or failed requests.
+	ResultFailure = "failure"
+)
+
+// Entry describes a single API mutation.
+type Entry struct {
+	Time       time.Time `json:"time"`
+	Actor      string    `json:"actor"`
+	Role       string    `json:"role"`
+	RemoteAddr string    `json:"remoteAddr"`
+	Method     string    `json:"method"`
+	Route      string    `json:"route"`
+	CloneID    string    `json:"cloneId,omitempty"`
+	SnapshotID string    `json:"snapshotId,omitempty"`
+	Branch     string    `json:"branch,omitempty"`
+	Status     int       `json:"status"`
+	Result     string    `json:"result"`
+}
+
+// Filter defines conditions to search audit entries.
+type Filter struct {
+	From  time.Time
+	To    time.Time
+	Actor string
+}
+
+// matches checks if the entry satisfies the filter.
+func (f Filter) matches(entry Entry) bool {
+	if !f.From.IsZero() && entry.Time.Before(f.From) {
+		return false
+	}
+
+	if !f.To.IsZero() && entry.Time.After(f.To) {
+		return false
+	}
+
+	if f.Actor != "" && entry.Actor != f.Actor {
+		return false
+	}
+
+	return true
+}
+
+// Logger writes audit entries to daily JSON lines files and removes files older than the retention period.
+type Logger struct {
+	mu            sync.Mutex
+	dir           string
+	retentionDays int
+}
+
+// NewLogger creates a new audit logger.
+func NewLogger(cfg diagnostic.Config) (*Logger, error) {
+	dir, err := util.GetMetaPath(auditDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get path of an audit directory: %w", err)
+	}
+
+	return newLogger(dir, cfg.RetentionDays())
+}
+
+func newLogger(dir string, retentionDays int) (*Logger, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create an audit directory: %w", err)
+	}
+
+	return &Logger{dir: dir, retentionDays: retentionDays}, nil
+}
+
+// Reload updates the retention period of the audit log.
+func (l *Logger) Reload(cfg diagnostic.Config) {
+	l.mu.Lock()
+	l.retentionDays = cfg.RetentionDays()
+	l.mu.Unlock()
+}
+
+// Run removes expired audit files until the context is canceled.
+func (l *Logger) Run(ctx context.Context) {
+	ticker := time.NewTicker(cleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		l.cleanup(time.Now())
+
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-ticker.C:
+		}
+	}
+}
+
+// Record appends the entry to the audit file of the entry date.
+func (l *Logger) Record(entry Entry) error {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+
+	entry.Time = entry.Time.UTC()
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal an audit entry: %w", err)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	f, err := os.OpenFile(l.filePath(entry.Time), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open an audit file: %w", err)
+	}
+
+	defer func() {
+		if err := f.Close(); err != nil {
+			log.Err("Failed to close an audit file:", err)
+		}
+	}()
+
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write an audit entry: %w", err)
+	}
+
+	return f.Sync()
+}
+
+// Find returns audit entries matching the filter in chronological order.
+func (l *Logger) Find(filter Filter) ([]Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	dates, err := l.listDates()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []Entry{}
+
+	for _, date := range dates {
+		if !filter.From.IsZero() && date.AddDate(0, 0, 1).Before(filter.From) {
+			continue
+		}
+
+		if !filter.To.IsZero() && date.After(filter.To) {
+			continue
+		}
+
+		fileEntries, err := readEntries(l.filePath(date), filter)
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, fileEntries...)
+	}
+
+	return entries, nil
+}
+
+func (l *Logger) cleanup(now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	dates, err := l.listDates()
+	if err != nil {
+		log.Err("Failed to list audit files:", err)
+		return
+	}
+
+	timeMark := now.UTC().AddDate(0, 0, -1*l.retentionDays)
+
+	for _, date := range dates {
+		if date.AddDate(0, 0, 1).After(timeMark) {
+			continue
+		}
+
+		log.Dbg("Removing expired audit file", date.Format(fileDateFormat))
+
+		if err := os.Remove(l.filePath(date)); err != nil {
+			log.Err("Failed to remove an audit file:", err)
+		}
+	}
+}
+
+func (l *Logger) filePath(date time.Time) string {
+	return path.Join(l.dir, filePrefix+date.UTC().Format(fileDateFormat)+fileExt)
+}
+
+// listDates returns dates of the existing audit files in ascending order.
+func (l *Logger) listDates() ([]time.Time, error) {
+	files, err := os.ReadDir(l.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read an audit directory: %w", err)
+	}
+
+	dates := make([]time.Time, 0, len(files))
+
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || !strings.HasPrefix(name, filePrefix) || !strings.HasSuffix(name, fileExt) {
+			continue
+		}
+
+		date, err := time.Parse(fileDateFormat, strings.TrimSuffix(strings.TrimPrefix(name, filePrefix), fileExt))
+		if err != nil {
+			log.Warn("Skip unknown audit file", name)
+			continue
+		}
+
+		dates = append(dates, date)
+	}
+
+	sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+
+	return dates, nil
+}
+
+func readEntries(filePath string, filter Filter) ([]Entry, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open an audit file: %w", err)
+	}
+
+	defer func() { _ = f.Close() }()
+
+	entries := []Entry{}
+	scanner := bufio.NewScanner(f)
+
+	for scanner.Scan() {
+		var entry Entry
+
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			log.Warn("Skip malformed audit entry in", filePath, err)
+			continue
+		}
+
+		if filter.matches(entry) {
+			entries = append(entries, entry)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read an audit file: %w", err)
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,80 @@
+package audit
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordAndFind(t *testing.T) {
+	logger, err := newLogger(t.TempDir(), 7)
+	require.NoError(t, err)
+
+	day := time.Date(2022, 5, 10, 12, 0, 0, 0, time.UTC)
+
+	entries := []Entry{
+		{Time: day.AddDate(0, 0, -1), Actor: "ci", Route: "/clone", CloneID: "c1", Status: 201, Result: ResultSuccess},
+		{Time: day, Actor: "ops", Route: "/clone/{id}", CloneID: "c1", Status: 200, Result: ResultSuccess},
+		{Time: day.Add(time.Hour), Actor: "ci", Route: "/clone/{id}/reset", CloneID: "c2", Status: 404, Result: ResultFailure},
+	}
+
+	for _, entry := range entries {
+		require.NoError(t, logger.Record(entry))
+	}
+
+	files, err := os.ReadDir(logger.dir)
+	require.NoError(t, err)
+	assert.Len(t, files, 2)
+
+	all, err := logger.Find(Filter{})
+	require.NoError(t, err)
+	assert.Len(t, all, 3)
+	assert.Equal(t, "c1", all[0].CloneID)
+
+	byActor, err := logger.Find(Filter{Actor: "ci"})
+	require.NoError(t, err)
+	require.Len(t, byActor, 2)
+	assert.Equal(t, ResultFailure, byActor[1].Result)
+
+	byTime, err := logger.Find(Filter{From: day.Add(-time.Minute), To: day.Add(time.Minute)})
+	require.NoError(t, err)
+	require.Len(t, byTime, 1)
+	assert.Equal(t, "ops", byTime[0].Actor)
+}
+
+func TestCleanup(t *testing.T) {
+	logger, err := newLogger(t.TempDir(), 2)
+	require.NoError(t, err)
+
+	now := time.Date(2022, 5, 10, 12, 0, 0, 0, time.UTC)
+
+	for days := 0; days < 5; days++ {
+		require.NoError(t, logger.Record(Entry{Time: now.AddDate(0, 0, -days), Actor: "ci"}))
+	}
+
+	logger.cleanup(now)
+
+	dates, err := logger.listDates()
+	require.NoError(t, err)
+	require.Len(t, dates, 3)
+	assert.Equal(t, "20220508", dates[0].Format(fileDateFormat))
+}
+
+func TestContextTarget(t *testing.T) {
+	SetCloneID(context.Background(), "c1")
+
+	entry := &Entry{}
+	ctx := WithEntry(context.Background(), entry)
+
+	SetCloneID(ctx, "c1")
+	SetSnapshotID(ctx, "pool@snapshot")
+	SetBranch(ctx, "main")
+
+	assert.Equal(t, "c1", entry.CloneID)
+	assert.Equal(t, "pool@snapshot", entry.SnapshotID)
+	assert.Equal(t, "main", entry.Branch)
+}
@@ -0,0 +1,45 @@
+/*
+2022 © Postgres.ai
+*/
+
+package audit
+
+import (
+	"context"
+)
+
+type entryKey struct{}
+
+// WithEntry attaches the pending entry to the request context, so handlers can specify the target of the request.
+func WithEntry(ctx context.Context, entry *Entry) context.Context {
+	return context.WithValue(ctx, entryKey{}, entry)
+}
+
+// SetActor sets the authenticated actor of the request if the request is audited.
+func SetActor(ctx context.Context, actor, role string) {
+	if entry, ok := ctx.Value(entryKey{}).(*Entry); ok {
+		entry.Actor = actor
+		entry.Role = role
+	}
+}
+
+// SetCloneID sets the clone affected by the request if the request is audited.
+func SetCloneID(ctx context.Context, cloneID string) {
+	if entry, ok := ctx.Value(entryKey{}).(*Entry); ok {
+		entry.CloneID = cloneID
+	}
+}
+
+// SetSnapshotID sets the snapshot affected by the request if the request is audited.
+func SetSnapshotID(ctx context.Context, snapshotID string) {
+	if entry, ok := ctx.Value(entryKey{}).(*Entry); ok {
+		entry.SnapshotID = snapshotID
+	}
+}
+
+// SetBranch sets the branch affected by the request if the request is audited.
+func SetBranch(ctx context.Context, branch string) {
+	if entry, ok := ctx.Value(entryKey{}).(*Entry); ok {
+		entry.Branch = branch
+	}
+}
@@ -12,17 +12,22 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/docker/go-units"
 	"github.com/jackc/pgtype/pgxtype"
 	"github.com/jackc/pgx/v4"
 	_ "github.com/lib/pq" // Register Postgres database driver.
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/rs/xid"
 
+	"gitlab.com/postgres-ai/database-lab/v3/internal/events"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/provision"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/provision/resources"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/telemetry"
+	"gitlab.com/postgres-ai/database-lab/v3/internal/webhooks"
 	"gitlab.com/postgres-ai/database-lab/v3/pkg/client/dblabapi/types"
 	"gitlab.com/postgres-ai/database-lab/v3/pkg/log"
 	"gitlab.com/postgres-ai/database-lab/v3/pkg/models"
@@ -40,6 +45,7 @@ const (
 type Config struct {
 	MaxIdleMinutes uint   `yaml:"maxIdleMinutes"`
 	AccessHost     string `yaml:"accessHost"`
+	Quotas         Quotas `yaml:"quotas"`
 }
 
 // Base provides cloning service.
@@ -48,22 +54,35 @@ type Base struct {
 	cloneMutex  sync.RWMutex
 	clones      map[string]*CloneWrapper
 	snapshotBox SnapshotBox
+	branchMutex sync.RWMutex
+	branches    map[string]*models.Branch
 	provision   *provision.Provisioner
 	tm          *telemetry.Agent
+	wh          *webhooks.Service
+	broker      *events.Broker
+	store       StateStore
 	observingCh chan string
+
+	cloningDuration prometheus.Histogram
 }
 
 // NewBase instances a new Base service.
-func NewBase(cfg *Config, provision *provision.Provisioner, tm *telemetry.Agent, observingCh chan string) *Base {
+func NewBase(cfg *Config, provision *provision.Provisioner, tm *telemetry.Agent, wh *webhooks.Service, broker *events.Broker,
+	store StateStore, observingCh chan string) *Base {
 	return &Base{
 		config:      cfg,
 		clones:      make(map[string]*CloneWrapper),
+		branches:    make(map[string]*models.Branch),
 		provision:   provision,
 		tm:          tm,
+		wh:          wh,
+		broker:      broker,
+		store:       store,
 		observingCh: observingCh,
 		snapshotBox: SnapshotBox{
 			items: make(map[string]*models.Snapshot),
 		},
+		cloningDuration: newCloningDurationHistogram(),
 	}
 }
 
@@ -74,10 +93,22 @@ func (c *Base) Reload(cfg Config) {
 
 // Run initializes and runs cloning component.
 func (c *Base) Run(ctx context.Context) error {
+	if _, err := c.config.Quotas.maxTotalCloneDiffSize(); err != nil {
+		return err
+	}
+
 	if err := c.provision.Init(); err != nil {
 		return errors.Wrap(err, "failed to run cloning service")
 	}
 
+	if err := c.RestoreSnapshotsState(); err != nil {
+		log.Err("Failed to load stored snapshots:", err)
+	}
+
+	if err := c.RestoreBranchesState(); err != nil {
+		log.Err("Failed to load stored branches:", err)
+	}
+
 	if _, err := c.GetSnapshots(); err != nil {
 		log.Err("No available snapshots: ", err)
 	}
@@ -90,6 +121,8 @@ func (c *Base) Run(ctx context.Context) error {
 
 	c.filterRunningClones(ctx)
 
+	c.SaveClonesState()
+
 	if err := c.cleanupInvalidClones(); err != nil {
 		return fmt.Errorf("failed to cleanup invalid clones: %w", err)
 	}
@@ -100,6 +133,10 @@ func (c *Base) Run(ctx context.Context) error {
 
 	go c.runIdleCheck(ctx)
 
+	go c.runExpiryCheck(ctx)
+
+	go c.runEventsCleanup(ctx)
+
 	return nil
 }
 
@@ -123,21 +160,44 @@ func (c *Base) cleanupInvalidClones() error {
 	return nil
 }
 
-// CreateClone creates a new clone.
-func (c *Base) CreateClone(cloneRequest *types.CloneCreateRequest) (*models.Clone, error) {
+// CreateClone creates a new clone on behalf of the access token and records its owner.
+func (c *Base) CreateClone(cloneRequest *types.CloneCreateRequest, owner string) (*models.Clone, error) {
 	cloneRequest.ID = strings.TrimSpace(cloneRequest.ID)
 
 	if _, ok := c.findWrapper(cloneRequest.ID); ok {
 		return nil, models.New(models.ErrCodeBadRequest, "clone with such ID already exists")
 	}
 
+	if cloneRequest.Snapshot != nil && cloneRequest.Snapshot.ID != "" && cloneRequest.Branch != "" {
+		return nil, models.New(models.ErrCodeBadRequest, "branch and snapshot ID must not be specified together")
+	}
+
 	if cloneRequest.ID == "" {
 		cloneRequest.ID = xid.New().String()
 	}
 
+	requestedResources, err := provision.ParseResourcesRequest(cloneRequest.Resources)
+	if err != nil {
+		return nil, models.New(models.ErrCodeBadRequest, err.Error())
+	}
+
+	containerResources, err := c.provision.ApplyResourceLimits(requestedResources)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateLabels(cloneRequest.Labels); err != nil {
+		return nil, err
+	}
+
 	createdAt := time.Now()
 
-	err := c.fetchSnapshots()
+	deleteAt, _, err := expirationTime(cloneRequest.TTL, cloneRequest.ExpiresAt, createdAt)
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.fetchSnapshots()
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to fetch snapshots")
 	}
@@ -154,10 +214,19 @@ func (c *Base) CreateClone(cloneRequest *types.CloneCreateRequest) (*models.Clon
 		}
 	}
 
+	if cloneRequest.Branch != "" {
+		snapshot, err = c.getBranchHead(cloneRequest.Branch)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	clone := &models.Clone{
 		ID:        cloneRequest.ID,
 		Snapshot:  snapshot,
+		Branch:    cloneRequest.Branch,
 		Protected: cloneRequest.Protected,
+		DeleteAt:  deleteAt,
 		CreatedAt: models.NewLocalTime(createdAt),
 		Status: models.Status{
 			Code:    models.StatusCreating,
@@ -167,12 +236,22 @@ func (c *Base) CreateClone(cloneRequest *types.CloneCreateRequest) (*models.Clon
 			Username: cloneRequest.DB.Username,
 			DBName:   cloneRequest.DB.DBName,
 		},
+		Labels:    cloneRequest.Labels,
+		Owner:     owner,
+		Resources: resourcesModel(containerResources),
 	}
 
 	w := NewCloneWrapper(clone, createdAt)
 	cloneID := clone.ID
 
-	c.setWrapper(clone.ID, w)
+	if err := c.addCloneWithinQuotas(w); err != nil {
+		return nil, err
+	}
+
+	c.publishClone(models.StreamCloneStatus, clone)
+	createdEvent := newCloneEvent(cloneID, models.CloneEventCreated, snapshot.ID)
+	createdEvent.Actor = owner
+	c.saveClone(cloneID, createdEvent)
 
 	ephemeralUser := resources.EphemeralUser{
 		Name:        cloneRequest.DB.Username,
@@ -184,7 +263,7 @@ func (c *Base) CreateClone(cloneRequest *types.CloneCreateRequest) (*models.Clon
 	c.incrementCloneNumber(clone.Snapshot.ID)
 
 	go func() {
-		session, err := c.provision.StartSession(clone.Snapshot.ID, ephemeralUser, cloneRequest.ExtraConf)
+		session, err := c.provision.StartSession(clone.Snapshot.ID, ephemeralUser, cloneRequest.ExtraConf, containerResources)
 		if err != nil {
 			// TODO(anatoly): Empty room case.
 			log.Errf("Failed to start session: %v.", err)
@@ -200,7 +279,7 @@ func (c *Base) CreateClone(cloneRequest *types.CloneCreateRequest) (*models.Clon
 		}
 
 		c.fillCloneSession(cloneID, session)
-		c.SaveClonesState()
+		c.saveClone(cloneID)
 	}()
 
 	return clone, nil
@@ -225,6 +304,22 @@ func (c *Base) fillCloneSession(cloneID string, session *resources.Session) {
 		Message: models.CloneMessageOK,
 	}
 
+	c.fillConnectionInfo(clone, session)
+
+	clone.Metadata = models.CloneMetadata{
+		CloningTime:    w.TimeStartedAt.Sub(w.TimeCreatedAt).Seconds(),
+		MaxIdleMinutes: c.config.MaxIdleMinutes,
+	}
+
+	if c.cloningDuration != nil {
+		c.cloningDuration.Observe(clone.Metadata.CloningTime)
+	}
+
+	c.publishClone(models.StreamCloneStatus, clone)
+}
+
+// fillConnectionInfo sets the connection details of the clone including the sslmode hint.
+func (c *Base) fillConnectionInfo(clone *models.Clone, session *resources.Session) {
 	dbName := clone.DB.DBName
 	if dbName == "" {
 		dbName = defaultDatabaseName
@@ -232,12 +327,12 @@ func (c *Base) fillCloneSession(cloneID string, session *resources.Session) {
 
 	clone.DB.Port = strconv.FormatUint(uint64(session.Port), 10)
 	clone.DB.Host = c.config.AccessHost
+	clone.DB.SSLMode = session.SSLMode
 	clone.DB.ConnStr = fmt.Sprintf("host=%s port=%s user=%s dbname=%s",
 		clone.DB.Host, clone.DB.Port, clone.DB.Username, dbName)
 
-	clone.Metadata = models.CloneMetadata{
-		CloningTime:    w.TimeStartedAt.Sub(w.TimeCreatedAt).Seconds(),
-		MaxIdleMinutes: c.config.MaxIdleMinutes,
+	if clone.DB.SSLMode != "" {
+		clone.DB.ConnStr += " sslmode=" + clone.DB.SSLMode
 	}
 }
 
@@ -275,6 +370,10 @@ func (c *Base) DestroyClone(cloneID string) error {
 		return models.New(models.ErrCodeBadRequest, "clone is protected")
 	}
 
+	if c.hasDerivedSnapshots(cloneID) {
+		return models.New(models.ErrCodeBadRequest, "clone has dependent snapshots")
+	}
+
 	if err := c.UpdateCloneStatus(cloneID, models.Status{
 		Code:    models.StatusDeleting,
 		Message: models.CloneMessageDeleting,
@@ -312,8 +411,6 @@ func (c *Base) DestroyClone(cloneID string) error {
 			c.decrementCloneNumber(w.Clone.Snapshot.ID)
 		}
 		c.observingCh <- cloneID
-
-		c.SaveClonesState()
 	}()
 
 	return nil
@@ -347,6 +444,7 @@ func (c *Base) refreshCloneMetadata(w *CloneWrapper) {
 
 	w.Clone.Metadata.CloneDiffSize = sessionState.CloneDiffSize
 	w.Clone.Metadata.LogicalSize = sessionState.LogicalReferenced
+	w.Clone.Metadata.ProxyConnections = atomic.LoadInt64(&w.proxyConnections)
 }
 
 // UpdateClone updates clone.
@@ -356,20 +454,40 @@ func (c *Base) UpdateClone(id string, patch types.CloneUpdateRequest) (*models.C
 		return nil, models.New(models.ErrCodeNotFound, "clone not found")
 	}
 
+	deleteAt, rescheduled, err := expirationTime(patch.TTL, patch.ExpiresAt, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateLabels(patch.Labels); err != nil {
+		return nil, err
+	}
+
 	var clone *models.Clone
 
 	// Set fields.
 	c.cloneMutex.Lock()
-	w.Clone.Protected = patch.Protected
+	if patch.Protected != nil {
+		w.Clone.Protected = *patch.Protected
+	}
+
+	if rescheduled {
+		w.Clone.DeleteAt = deleteAt
+	}
+
+	if patch.Labels != nil {
+		w.Clone.Labels = patch.Labels
+	}
+
 	clone = w.Clone
 	c.cloneMutex.Unlock()
 
-	c.SaveClonesState()
+	c.saveClone(id)
 
 	return clone, nil
 }
 
-// UpdateCloneStatus updates the clone status.
+// UpdateCloneStatus updates the clone status and records the transition in the clone history.
 func (c *Base) UpdateCloneStatus(cloneID string, status models.Status) error {
 	c.cloneMutex.Lock()
 	defer c.cloneMutex.Unlock()
@@ -379,8 +497,14 @@ func (c *Base) UpdateCloneStatus(cloneID string, status models.Status) error {
 		return errors.Errorf("clone %q not found", cloneID)
 	}
 
+	previousCode := w.Clone.Status.Code
 	w.Clone.Status = status
 
+	if previousCode != status.Code {
+		c.storeClone(w, statusEvent(cloneID, status))
+		c.publishClone(models.StreamCloneStatus, w.Clone)
+	}
+
 	return nil
 }
 
@@ -395,6 +519,10 @@ func (c *Base) ResetClone(cloneID string, resetOptions types.ResetCloneRequest)
 		return models.New(models.ErrCodeNotFound, "clone is not started yet")
 	}
 
+	if c.hasDerivedSnapshots(cloneID) {
+		return models.New(models.ErrCodeBadRequest, "clone has dependent snapshots")
+	}
+
 	var snapshotID string
 
 	if resetOptions.SnapshotID != "" {
@@ -441,6 +569,7 @@ func (c *Base) ResetClone(cloneID string, resetOptions types.ResetCloneRequest)
 
 		c.cloneMutex.Lock()
 		w.Clone.Snapshot = snapshot
+		c.fillConnectionInfo(w.Clone, w.Session)
 		c.cloneMutex.Unlock()
 		c.decrementCloneNumber(originalSnapshotID)
 		c.incrementCloneNumber(snapshot.ID)
@@ -452,18 +581,71 @@ func (c *Base) ResetClone(cloneID string, resetOptions types.ResetCloneRequest)
 			log.Errf("failed to update clone status: %v", err)
 		}
 
-		c.SaveClonesState()
+		resetEvent := newCloneEvent(cloneID, models.CloneEventReset, snapshot.ID)
+		resetEvent.PreviousSnapshotID = originalSnapshotID
+		c.saveClone(cloneID, resetEvent)
 
 		c.tm.SendEvent(context.Background(), telemetry.CloneResetEvent, telemetry.CloneCreated{
 			ID:          util.HashID(w.Clone.ID),
 			CloningTime: w.Clone.Metadata.CloningTime,
 			DSADiff:     util.GetDataFreshness(snapshot.DataStateAt.Time),
 		})
+		c.wh.Emit(webhooks.CloneResetEvent, webhooks.NewCloneEvent(w.Clone, ""))
 	}()
 
 	return nil
 }
 
+// CreateSnapshotFromClone checkpoints the clone database and saves its current state as a new snapshot.
+func (c *Base) CreateSnapshotFromClone(cloneID string) (*models.Snapshot, error) {
+	w, ok := c.findWrapper(cloneID)
+	if !ok {
+		return nil, models.New(models.ErrCodeNotFound, "clone not found")
+	}
+
+	return c.snapshotClone(w, "")
+}
+
+func (c *Base) snapshotClone(w *CloneWrapper, message string) (*models.Snapshot, error) {
+	if w.Session == nil || w.Clone == nil || w.Clone.Snapshot == nil || w.Clone.Status.Code != models.StatusOK {
+		return nil, models.New(models.ErrCodeBadRequest, "clone is not ready")
+	}
+
+	parentID := c.cloneHead(w)
+
+	entry, err := c.provision.SnapshotSession(w.Session)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to snapshot the clone")
+	}
+
+	snapshot := &models.Snapshot{
+		ID:           entry.ID,
+		CreatedAt:    models.NewLocalTime(entry.CreatedAt),
+		DataStateAt:  models.NewLocalTime(entry.DataStateAt),
+		PhysicalSize: entry.Used,
+		LogicalSize:  entry.LogicalReferenced,
+		Pool:         entry.Pool,
+		Parent:       parentID,
+		CloneID:      w.Clone.ID,
+		Message:      message,
+	}
+
+	c.addSnapshot(snapshot)
+	c.SaveSnapshotsState()
+
+	return snapshot, nil
+}
+
+// cloneHead returns the ID of the snapshot that the current clone state is based on:
+// the latest snapshot taken from the clone or the snapshot the clone has been created from.
+func (c *Base) cloneHead(w *CloneWrapper) string {
+	if derived := c.latestDerivedSnapshot(w.Clone.ID); derived != nil {
+		return derived.ID
+	}
+
+	return w.Clone.Snapshot.ID
+}
+
 // GetCloningState returns the current state of instance.
 func (c *Base) GetCloningState() models.Cloning {
 	clones := c.GetClones()
@@ -532,11 +714,32 @@ func (c *Base) setWrapper(id string, wrapper *CloneWrapper) {
 	c.cloneMutex.Unlock()
 }
 
-// deleteClone removes the clone by ID.
+// deleteClone removes the clone by ID and records its destruction.
 func (c *Base) deleteClone(cloneID string) {
 	c.cloneMutex.Lock()
+	defer c.cloneMutex.Unlock()
+
+	w, ok := c.clones[cloneID]
+	if !ok {
+		return
+	}
+
 	delete(c.clones, cloneID)
-	c.cloneMutex.Unlock()
+	c.publishClone(models.StreamCloneDestroyed, w.Clone)
+
+	if c.store == nil {
+		return
+	}
+
+	var snapshotID string
+
+	if w.Clone != nil && w.Clone.Snapshot != nil {
+		snapshotID = w.Clone.Snapshot.ID
+	}
+
+	if err := c.store.DeleteClone(cloneID, newCloneEvent(cloneID, models.CloneEventDestroyed, snapshotID)); err != nil {
+		log.Err(fmt.Sprintf("Failed to delete clone %s from the state store:", cloneID), err)
+	}
 }
 
 // lenClones returns the number of clones.
@@ -596,16 +799,28 @@ func (c *Base) destroyIdleClones(ctx context.Context) {
 			isIdleClone, err := c.isIdleClone(cloneWrapper)
 			if err != nil {
 				log.Errf("Failed to check the idleness of clone %s: %v.", cloneWrapper.Clone.ID, err)
+				c.recordCloneError(cloneWrapper.Clone.ID, fmt.Errorf("failed to check the idleness: %w", err))
+
 				continue
 			}
 
 			if isIdleClone {
 				log.Msg(fmt.Sprintf("Idle clone %q is going to be removed.", cloneWrapper.Clone.ID))
 
+				c.RecordCloneEvent(models.CloneEvent{
+					CloneID: cloneWrapper.Clone.ID,
+					Event:   models.CloneEventIdleDestroyed,
+					Message: fmt.Sprintf("no activity for more than %d minutes", c.config.MaxIdleMinutes),
+				})
+
 				if err = c.DestroyClone(cloneWrapper.Clone.ID); err != nil {
 					log.Errf("Failed to destroy clone: %+v.", err)
+					c.recordCloneError(cloneWrapper.Clone.ID, fmt.Errorf("failed to destroy the idle clone: %w", err))
+
 					continue
 				}
+
+				c.wh.Emit(webhooks.CloneIdleDestroyedEvent, webhooks.NewCloneEvent(cloneWrapper.Clone, "idle"))
 			}
 		}
 	}
@@ -622,6 +837,16 @@ func (c *Base) isIdleClone(wrapper *CloneWrapper) (bool, error) {
 		return false, nil
 	}
 
+	// Clones with dependent snapshots cannot be destroyed.
+	if c.hasDerivedSnapshots(wrapper.Clone.ID) {
+		return false, nil
+	}
+
+	// Connections through the proxy keep the clone busy.
+	if atomic.LoadInt64(&wrapper.proxyConnections) > 0 {
+		return false, nil
+	}
+
 	session := wrapper.Session
 
 	if session == nil {
@@ -683,3 +908,25 @@ func checkActiveQueryNotExists(db *sql.DB) (bool, error) {
 
 	return isRunningQueryNotExists, err
 }
+
+// resourcesModel describes the resources of a clone container.
+func resourcesModel(containerResources *resources.ContainerResources) *models.Resources {
+	if containerResources == nil {
+		return nil
+	}
+
+	model := &models.Resources{
+		CPUs:        containerResources.CPUs,
+		BlkioWeight: containerResources.BlkioWeight,
+	}
+
+	if containerResources.Memory > 0 {
+		model.Memory = units.BytesSize(float64(containerResources.Memory))
+	}
+
+	if containerResources.ShmSize > 0 {
+		model.ShmSize = units.BytesSize(float64(containerResources.ShmSize))
+	}
+
+	return model
+}
@@ -29,6 +29,7 @@ func (s *BaseCloningSuite) SetupSuite() {
 	cloning := &Base{
 		clones:      make(map[string]*CloneWrapper),
 		snapshotBox: SnapshotBox{items: make(map[string]*models.Snapshot)},
+		branches:    make(map[string]*models.Branch),
 	}
 
 	s.cloning = cloning
@@ -37,6 +38,7 @@ func (s *BaseCloningSuite) SetupSuite() {
 func (s *BaseCloningSuite) TearDownTest() {
 	s.cloning.clones = make(map[string]*CloneWrapper)
 	s.cloning.snapshotBox = SnapshotBox{items: make(map[string]*models.Snapshot)}
+	s.cloning.branches = make(map[string]*models.Branch)
 }
 
 func (s *BaseCloningSuite) TestFindWrapper() {
@@ -133,3 +135,19 @@ func (s *BaseCloningSuite) TestLenClones() {
 	lenClones = s.cloning.lenClones()
 	assert.Equal(s.T(), 1, lenClones)
 }
+
+func (s *BaseCloningSuite) TestDestroyCloneWithDerivedSnapshots() {
+	s.cloning.setWrapper("testCloneID", &CloneWrapper{Clone: &models.Clone{ID: "testCloneID"}})
+	s.cloning.addSnapshot(&models.Snapshot{
+		ID:      "pool/dblab_clone_6000@snapshot_20200221000000",
+		Parent:  "pool@snapshot_20200219000000",
+		CloneID: "testCloneID",
+	})
+
+	err := s.cloning.DestroyClone("testCloneID")
+	require.EqualError(s.T(), err, "clone has dependent snapshots")
+
+	wrapper, ok := s.cloning.findWrapper("testCloneID")
+	require.True(s.T(), ok)
+	assert.Equal(s.T(), models.StatusCode(""), wrapper.Clone.Status.Code)
+}
@@ -0,0 +1,297 @@
+/*
+2022 © Postgres.ai
+*/
+
+package cloning
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/client/dblabapi/types"
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/models"
+)
+
+const maxBranchNameLength = 64
+
+var branchNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]*$`)
+
+// CreateBranch creates a new branch.
+// The branch head points to the requested snapshot, to the head of the base branch, or to the latest snapshot.
+func (c *Base) CreateBranch(branchRequest types.BranchCreateRequest) (*models.Branch, error) {
+	if err := validateBranchName(branchRequest.Name); err != nil {
+		return nil, err
+	}
+
+	if branchRequest.BaseBranch != "" && branchRequest.SnapshotID != "" {
+		return nil, models.New(models.ErrCodeBadRequest, "base branch and snapshot ID must not be specified together")
+	}
+
+	if err := c.fetchSnapshots(); err != nil {
+		return nil, errors.Wrap(err, "failed to fetch snapshots")
+	}
+
+	var (
+		snapshot *models.Snapshot
+		err      error
+	)
+
+	switch {
+	case branchRequest.SnapshotID != "":
+		snapshot, err = c.getSnapshotByID(branchRequest.SnapshotID)
+		if err != nil {
+			return nil, models.New(models.ErrCodeBadRequest, fmt.Sprintf("snapshot %q not found", branchRequest.SnapshotID))
+		}
+
+	case branchRequest.BaseBranch != "":
+		snapshot, err = c.getBranchHead(branchRequest.BaseBranch)
+		if err != nil {
+			return nil, err
+		}
+
+	default:
+		snapshot, err = c.getLatestSnapshot()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to find the latest snapshot")
+		}
+	}
+
+	c.branchMutex.Lock()
+
+	if _, ok := c.branches[branchRequest.Name]; ok {
+		c.branchMutex.Unlock()
+		return nil, models.New(models.ErrCodeBadRequest, "branch with such name already exists")
+	}
+
+	branch := &models.Branch{
+		Name:       branchRequest.Name,
+		SnapshotID: snapshot.ID,
+		CreatedAt:  models.NewLocalTime(time.Now()),
+	}
+
+	c.branches[branch.Name] = branch
+	result := *branch
+
+	c.branchMutex.Unlock()
+
+	c.SaveBranchesState()
+
+	return &result, nil
+}
+
+func validateBranchName(name string) error {
+	if name == "" {
+		return models.New(models.ErrCodeBadRequest, "branch name must not be empty")
+	}
+
+	if len(name) > maxBranchNameLength || !branchNameRegexp.MatchString(name) {
+		return models.New(models.ErrCodeBadRequest, fmt.Sprintf("invalid branch name %q", name))
+	}
+
+	return nil
+}
+
+// GetBranches returns the list of branches ordered by name.
+func (c *Base) GetBranches() []models.Branch {
+	c.branchMutex.RLock()
+
+	branches := make([]models.Branch, 0, len(c.branches))
+
+	for _, branch := range c.branches {
+		branches = append(branches, *branch)
+	}
+
+	c.branchMutex.RUnlock()
+
+	sort.Slice(branches, func(i, j int) bool {
+		return branches[i].Name < branches[j].Name
+	})
+
+	return branches
+}
+
+// DeleteBranch deletes the branch. Snapshots of the branch are kept.
+func (c *Base) DeleteBranch(name string) error {
+	c.branchMutex.Lock()
+
+	if _, ok := c.branches[name]; !ok {
+		c.branchMutex.Unlock()
+		return models.New(models.ErrCodeNotFound, "branch not found")
+	}
+
+	delete(c.branches, name)
+
+	c.branchMutex.Unlock()
+
+	c.SaveBranchesState()
+
+	return nil
+}
+
+// GetBranchLog returns the chain of snapshots of the branch starting from its head.
+func (c *Base) GetBranchLog(name string) ([]models.Snapshot, error) {
+	if err := c.fetchSnapshots(); err != nil {
+		return nil, errors.Wrap(err, "failed to fetch snapshots")
+	}
+
+	head, err := c.getBranchHead(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.snapshotChain(head), nil
+}
+
+// snapshotChain follows parent links from the snapshot until the root snapshot.
+func (c *Base) snapshotChain(head *models.Snapshot) []models.Snapshot {
+	chain := []models.Snapshot{}
+	visited := make(map[string]struct{})
+
+	for snapshot := head; snapshot != nil; {
+		if _, ok := visited[snapshot.ID]; ok {
+			break
+		}
+
+		visited[snapshot.ID] = struct{}{}
+
+		chain = append(chain, *snapshot)
+
+		if snapshot.Parent == "" {
+			break
+		}
+
+		parent, err := c.getSnapshotByID(snapshot.Parent)
+		if err != nil {
+			// The parent snapshot has been destroyed, so the history ends here.
+			break
+		}
+
+		snapshot = parent
+	}
+
+	return chain
+}
+
+// CommitBranch saves the clone state as a new snapshot and moves the branch head to it.
+// The clone has to be based on the current branch head.
+func (c *Base) CommitBranch(name string, commitRequest types.BranchCommitRequest) (*models.Snapshot, error) {
+	w, ok := c.findWrapper(commitRequest.CloneID)
+	if !ok {
+		return nil, models.New(models.ErrCodeNotFound, "clone not found")
+	}
+
+	c.branchMutex.RLock()
+	branch, ok := c.branches[name]
+
+	var headID string
+	if ok {
+		headID = branch.SnapshotID
+	}
+	c.branchMutex.RUnlock()
+
+	if !ok {
+		return nil, models.New(models.ErrCodeNotFound, "branch not found")
+	}
+
+	if w.Clone.Snapshot == nil || c.cloneHead(w) != headID {
+		return nil, models.New(models.ErrCodeBadRequest, "clone is not based on the branch head")
+	}
+
+	// Snapshotting runs a checkpoint and filesystem commands, so branches are not locked meanwhile.
+	snapshot, err := c.snapshotClone(w, commitRequest.Message)
+	if err != nil {
+		return nil, err
+	}
+
+	c.branchMutex.Lock()
+
+	branch, ok = c.branches[name]
+	if !ok || branch.SnapshotID != headID {
+		c.branchMutex.Unlock()
+		return nil, models.New(models.ErrCodeBadRequest,
+			fmt.Sprintf("branch has been changed during the commit, the clone state is kept in snapshot %s", snapshot.ID))
+	}
+
+	branch.SnapshotID = snapshot.ID
+
+	c.branchMutex.Unlock()
+
+	c.SaveBranchesState()
+
+	return snapshot, nil
+}
+
+// ResetBranch moves the branch head to the requested snapshot.
+func (c *Base) ResetBranch(name string, resetRequest types.BranchResetRequest) (*models.Branch, error) {
+	if resetRequest.SnapshotID == "" {
+		return nil, models.New(models.ErrCodeBadRequest, "snapshot ID must not be empty")
+	}
+
+	if err := c.fetchSnapshots(); err != nil {
+		return nil, errors.Wrap(err, "failed to fetch snapshots")
+	}
+
+	snapshot, err := c.getSnapshotByID(resetRequest.SnapshotID)
+	if err != nil {
+		return nil, models.New(models.ErrCodeBadRequest, fmt.Sprintf("snapshot %q not found", resetRequest.SnapshotID))
+	}
+
+	c.branchMutex.Lock()
+
+	branch, ok := c.branches[name]
+	if !ok {
+		c.branchMutex.Unlock()
+		return nil, models.New(models.ErrCodeNotFound, "branch not found")
+	}
+
+	branch.SnapshotID = snapshot.ID
+	result := *branch
+
+	c.branchMutex.Unlock()
+
+	c.SaveBranchesState()
+
+	return &result, nil
+}
+
+// findBranchByHead returns the name of a branch that points to the snapshot.
+func (c *Base) findBranchByHead(snapshotID string) (string, bool) {
+	c.branchMutex.RLock()
+	defer c.branchMutex.RUnlock()
+
+	for name, branch := range c.branches {
+		if branch.SnapshotID == snapshotID {
+			return name, true
+		}
+	}
+
+	return "", false
+}
+
+// getBranchHead returns the snapshot the branch head points to.
+func (c *Base) getBranchHead(name string) (*models.Snapshot, error) {
+	c.branchMutex.RLock()
+	branch, ok := c.branches[name]
+
+	var headID string
+	if ok {
+		// Branch heads are moved under the lock, so the ID is copied before releasing it.
+		headID = branch.SnapshotID
+	}
+	c.branchMutex.RUnlock()
+
+	if !ok {
+		return nil, models.New(models.ErrCodeNotFound, fmt.Sprintf("branch %q not found", name))
+	}
+
+	snapshot, err := c.getSnapshotByID(headID)
+	if err != nil {
+		return nil, models.New(models.ErrCodeNotFound, fmt.Sprintf("head snapshot of branch %q not found", name))
+	}
+
+	return snapshot, nil
+}
@@ -0,0 +1,114 @@
+/*
+2022 © Postgres.ai
+*/
+
+package cloning
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/client/dblabapi/types"
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/models"
+)
+
+func (s *BaseCloningSuite) TestBranchHead() {
+	snapshot := &models.Snapshot{ID: "pool@snapshot_20200219000000"}
+	s.cloning.addSnapshot(snapshot)
+
+	s.cloning.branches["main"] = &models.Branch{Name: "main", SnapshotID: snapshot.ID}
+	s.cloning.branches["stale"] = &models.Branch{Name: "stale", SnapshotID: "pool@snapshot_20200101000000"}
+
+	head, err := s.cloning.getBranchHead("main")
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), snapshot, head)
+
+	_, err = s.cloning.getBranchHead("stale")
+	require.EqualError(s.T(), err, `head snapshot of branch "stale" not found`)
+
+	_, err = s.cloning.getBranchHead("unknown")
+	require.EqualError(s.T(), err, `branch "unknown" not found`)
+}
+
+func (s *BaseCloningSuite) TestCreateCloneFromBranchAndSnapshot() {
+	_, err := s.cloning.CreateClone(&types.CloneCreateRequest{
+		Branch:   "main",
+		Snapshot: &types.SnapshotCloneFieldRequest{ID: "pool@snapshot_20200219000000"},
+	}, "")
+	require.EqualError(s.T(), err, "branch and snapshot ID must not be specified together")
+}
+
+func (s *BaseCloningSuite) TestBranchList() {
+	s.cloning.branches["main"] = &models.Branch{Name: "main", SnapshotID: "snapshot1"}
+	s.cloning.branches["feature-x"] = &models.Branch{Name: "feature-x", SnapshotID: "snapshot2"}
+
+	branches := s.cloning.GetBranches()
+	require.Len(s.T(), branches, 2)
+	assert.Equal(s.T(), "feature-x", branches[0].Name)
+	assert.Equal(s.T(), "main", branches[1].Name)
+}
+
+func (s *BaseCloningSuite) TestSnapshotChain() {
+	root := &models.Snapshot{
+		ID:        "pool@snapshot_20200219000000",
+		CreatedAt: &models.LocalTime{Time: time.Date(2020, 02, 19, 0, 0, 0, 0, time.UTC)},
+	}
+	commit1 := &models.Snapshot{
+		ID:        "pool/dblab_clone_6000@snapshot_20200220000000",
+		CreatedAt: &models.LocalTime{Time: time.Date(2020, 02, 20, 0, 0, 0, 0, time.UTC)},
+		Parent:    root.ID,
+		CloneID:   "testCloneID",
+	}
+	commit2 := &models.Snapshot{
+		ID:        "pool/dblab_clone_6000@snapshot_20200221000000",
+		CreatedAt: &models.LocalTime{Time: time.Date(2020, 02, 21, 0, 0, 0, 0, time.UTC)},
+		Parent:    commit1.ID,
+		CloneID:   "testCloneID",
+	}
+
+	s.cloning.addSnapshot(root)
+	s.cloning.addSnapshot(commit1)
+	s.cloning.addSnapshot(commit2)
+
+	chain := s.cloning.snapshotChain(commit2)
+	require.Len(s.T(), chain, 3)
+	assert.Equal(s.T(), []string{commit2.ID, commit1.ID, root.ID}, []string{chain[0].ID, chain[1].ID, chain[2].ID})
+
+	assert.Equal(s.T(), commit2, s.cloning.latestDerivedSnapshot("testCloneID"))
+	assert.Equal(s.T(), commit2.ID, s.cloning.cloneHead(&CloneWrapper{Clone: &models.Clone{ID: "testCloneID", Snapshot: root}}))
+	assert.Equal(s.T(), root.ID, s.cloning.cloneHead(&CloneWrapper{Clone: &models.Clone{ID: "otherCloneID", Snapshot: root}}))
+}
+
+func (s *BaseCloningSuite) TestDeleteBranch() {
+	s.cloning.branches["main"] = &models.Branch{Name: "main", SnapshotID: "snapshot1"}
+
+	err := s.cloning.DeleteBranch("unknown")
+	require.EqualError(s.T(), err, "branch not found")
+
+	err = s.cloning.DeleteBranch("main")
+	require.NoError(s.T(), err)
+	assert.Empty(s.T(), s.cloning.branches)
+}
+
+func TestValidateBranchName(t *testing.T) {
+	testCases := []struct {
+		name  string
+		valid bool
+	}{
+		{name: "main", valid: true},
+		{name: "feature-x", valid: true},
+		{name: "release_1.2", valid: true},
+		{name: "", valid: false},
+		{name: "-feature", valid: false},
+		{name: "feature/x", valid: false},
+		{name: "feature x", valid: false},
+	}
+
+	for _, tc := range testCases {
+		err := validateBranchName(tc.name)
+		assert.Equal(t, tc.valid, err == nil, tc.name)
+	}
+}
@@ -0,0 +1,104 @@
+/*
+2022 © Postgres.ai
+*/
+
+package cloning
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/log"
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/models"
+)
+
+const (
+	// eventsRetention defines how long the history of destroyed clones is kept.
+	eventsRetention = 7 * 24 * time.Hour
+
+	eventsCleanupInterval = time.Hour
+)
+
+// RecordCloneEvent appends the event to the history of the clone.
+func (c *Base) RecordCloneEvent(event models.CloneEvent) {
+	if c.store == nil {
+		return
+	}
+
+	if event.Time == nil {
+		event.Time = models.NewLocalTime(time.Now())
+	}
+
+	if err := c.store.AddEvents(event); err != nil {
+		log.Err(fmt.Sprintf("Failed to record an event of clone %s:", event.CloneID), err)
+	}
+}
+
+// GetCloneEvents returns the history of the clone including recently destroyed ones.
+func (c *Base) GetCloneEvents(cloneID string) ([]models.CloneEvent, error) {
+	if c.store == nil {
+		return []models.CloneEvent{}, nil
+	}
+
+	return c.store.Events(cloneID)
+}
+
+// recordCloneError appends an error that has not changed the clone status to the history of the clone.
+func (c *Base) recordCloneError(cloneID string, err error) {
+	c.RecordCloneEvent(models.CloneEvent{
+		CloneID: cloneID,
+		Event:   models.CloneEventError,
+		Message: err.Error(),
+	})
+}
+
+// statusEvent describes the transition of the clone to the status. Transitions to the fatal status are recorded as errors.
+func statusEvent(cloneID string, status models.Status) models.CloneEvent {
+	event := newCloneEvent(cloneID, models.CloneEventStatusChanged, "")
+	event.Status = status.Code
+	event.Message = status.Message
+
+	if status.Code == models.StatusFatal {
+		event.Event = models.CloneEventError
+	}
+
+	return event
+}
+
+// publishClone sends a copy of the clone to the event stream. The caller must hold the clone mutex if the clone is registered.
+func (c *Base) publishClone(eventType string, clone *models.Clone) {
+	if c.broker == nil || clone == nil {
+		return
+	}
+
+	cloneCopy := *clone
+
+	if clone.Snapshot != nil {
+		snapshot := *clone.Snapshot
+		cloneCopy.Snapshot = &snapshot
+	}
+
+	c.broker.Publish(models.StreamEvent{Type: eventType, Clone: &cloneCopy})
+}
+
+func (c *Base) runEventsCleanup(ctx context.Context) {
+	if c.store == nil {
+		return
+	}
+
+	ticker := time.NewTicker(eventsCleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := c.store.CleanupEvents(time.Now().Add(-eventsRetention)); err != nil {
+				log.Err("Failed to clean up the history of destroyed clones:", err)
+			}
+
+		case <-ctx.Done():
+			return
+		}
+	}
+}
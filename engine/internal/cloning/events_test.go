@@ -0,0 +1,33 @@
+package cloning
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/models"
+)
+
+func TestStatusEvents(t *testing.T) {
+	s := &Base{store: newTestStore(t), clones: make(map[string]*CloneWrapper)}
+	s.setWrapper("clone1", &CloneWrapper{Clone: &models.Clone{ID: "clone1", Status: models.Status{Code: models.StatusOK}}})
+
+	require.NoError(t, s.UpdateCloneStatus("clone1", models.Status{Code: models.StatusOK}))
+	require.NoError(t, s.UpdateCloneStatus("clone1", models.Status{Code: models.StatusResetting, Message: models.CloneMessageResetting}))
+	require.NoError(t, s.UpdateCloneStatus("clone1", models.Status{Code: models.StatusFatal, Message: "no space left on device"}))
+
+	events, err := s.GetCloneEvents("clone1")
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+
+	assert.Equal(t, models.CloneEventStatusChanged, events[0].Event)
+	assert.Equal(t, models.StatusResetting, events[0].Status)
+	assert.Equal(t, models.CloneEventError, events[1].Event)
+	assert.Equal(t, models.StatusFatal, events[1].Status)
+	assert.Equal(t, "no space left on device", events[1].Message)
+
+	clones, err := s.store.LoadClones()
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusFatal, clones["clone1"].Clone.Status.Code)
+}
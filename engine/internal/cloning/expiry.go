@@ -0,0 +1,139 @@
+/*
+2022 © Postgres.ai
+*/
+
+package cloning
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gitlab.com/postgres-ai/database-lab/v3/internal/webhooks"
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/log"
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/models"
+)
+
+const expiryCheckDuration = time.Minute
+
+// expirationTime calculates the time of scheduled clone deletion from either the TTL or the expiration time.
+// It reports false if none of them is specified. Zero TTL means that the scheduled deletion has to be canceled.
+func expirationTime(ttl string, expiresAt *time.Time, now time.Time) (*models.LocalTime, bool, error) {
+	if ttl != "" && expiresAt != nil {
+		return nil, false, models.New(models.ErrCodeBadRequest, "ttl and expires_at must not be specified together")
+	}
+
+	if expiresAt != nil {
+		if !expiresAt.After(now) {
+			return nil, false, models.New(models.ErrCodeBadRequest, "expires_at must be in the future")
+		}
+
+		return models.NewLocalTime(*expiresAt), true, nil
+	}
+
+	if ttl == "" {
+		return nil, false, nil
+	}
+
+	duration, err := time.ParseDuration(ttl)
+	if err != nil {
+		return nil, false, models.New(models.ErrCodeBadRequest, fmt.Sprintf("invalid ttl %q: use a duration, for example, 90m or 2h", ttl))
+	}
+
+	if duration < 0 {
+		return nil, false, models.New(models.ErrCodeBadRequest, "ttl must not be negative")
+	}
+
+	if duration == 0 {
+		return nil, true, nil
+	}
+
+	return models.NewLocalTime(now.Add(duration)), true, nil
+}
+
+// runExpiryCheck destroys clones once their scheduled deletion time comes.
+// The deletion time is kept in the clone state, so the schedule survives restarts.
+func (c *Base) runExpiryCheck(ctx context.Context) {
+	expiryTicker := time.NewTicker(expiryCheckDuration)
+	defer expiryTicker.Stop()
+
+	c.destroyExpiredClones(ctx)
+
+	for {
+		select {
+		case <-expiryTicker.C:
+			c.destroyExpiredClones(ctx)
+
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (c *Base) destroyExpiredClones(ctx context.Context) {
+	for _, cloneID := range c.expiredClones(time.Now()) {
+		w, ok := c.findWrapper(cloneID)
+		if !ok {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		log.Msg(fmt.Sprintf("Clone %q has expired and is going to be removed.", cloneID))
+
+		c.RecordCloneEvent(models.CloneEvent{
+			CloneID: cloneID,
+			Event:   models.CloneEventExpired,
+			Message: fmt.Sprintf("the clone expired at %s", w.Clone.DeleteAt.Format(time.RFC3339)),
+		})
+
+		if err := c.DestroyClone(cloneID); err != nil {
+			log.Errf("Failed to destroy expired clone %s: %v.", cloneID, err)
+			c.recordCloneError(cloneID, fmt.Errorf("failed to destroy the expired clone: %w", err))
+
+			continue
+		}
+
+		c.wh.Emit(webhooks.CloneDestroyedEvent, webhooks.NewCloneEvent(w.Clone, "expired"))
+	}
+}
+
+// expiredClones returns IDs of clones whose deletion time has come.
+// Protected clones and clones with derived snapshots cannot be destroyed, so they are kept until the refusal reason is gone.
+func (c *Base) expiredClones(now time.Time) []string {
+	c.cloneMutex.RLock()
+
+	candidates := []string{}
+
+	for cloneID, w := range c.clones {
+		if w.Clone == nil || w.Clone.DeleteAt == nil || w.Clone.DeleteAt.IsZero() || w.Clone.DeleteAt.After(now) {
+			continue
+		}
+
+		if w.Clone.Status.Code == models.StatusDeleting {
+			continue
+		}
+
+		if w.Clone.Protected && w.Clone.Status.Code != models.StatusFatal {
+			continue
+		}
+
+		candidates = append(candidates, cloneID)
+	}
+
+	c.cloneMutex.RUnlock()
+
+	cloneIDs := []string{}
+
+	for _, cloneID := range candidates {
+		if !c.hasDerivedSnapshots(cloneID) {
+			cloneIDs = append(cloneIDs, cloneID)
+		}
+	}
+
+	return cloneIDs
+}
@@ -0,0 +1,78 @@
+package cloning
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/models"
+)
+
+func TestExpirationTime(t *testing.T) {
+	now := time.Date(2022, 1, 27, 10, 0, 0, 0, time.UTC)
+	expiresAt := now.Add(time.Hour)
+	past := now.Add(-time.Hour)
+
+	deleteAt, ok, err := expirationTime("", nil, now)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.Nil(t, deleteAt)
+
+	deleteAt, ok, err = expirationTime("90m", nil, now)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, models.NewLocalTime(now.Add(90*time.Minute)), deleteAt)
+
+	deleteAt, ok, err = expirationTime("", &expiresAt, now)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, models.NewLocalTime(expiresAt), deleteAt)
+
+	deleteAt, ok, err = expirationTime("0", nil, now)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Nil(t, deleteAt)
+
+	invalidCases := []struct {
+		ttl       string
+		expiresAt *time.Time
+	}{
+		{ttl: "1h", expiresAt: &expiresAt},
+		{ttl: "one hour"},
+		{ttl: "-1h"},
+		{expiresAt: &past},
+	}
+
+	for _, tc := range invalidCases {
+		_, _, err := expirationTime(tc.ttl, tc.expiresAt, now)
+		require.Error(t, err)
+
+		_, ok := err.(*models.Error)
+		assert.True(t, ok)
+	}
+}
+
+func (s *BaseCloningSuite) TestExpiredClones() {
+	now := time.Now()
+
+	s.cloning.setWrapper("expired", &CloneWrapper{Clone: &models.Clone{ID: "expired", DeleteAt: models.NewLocalTime(now.Add(-time.Minute))}})
+	s.cloning.setWrapper("active", &CloneWrapper{Clone: &models.Clone{ID: "active", DeleteAt: models.NewLocalTime(now.Add(time.Minute))}})
+	s.cloning.setWrapper("permanent", &CloneWrapper{Clone: &models.Clone{ID: "permanent"}})
+	s.cloning.setWrapper("deleting", &CloneWrapper{Clone: &models.Clone{
+		ID:       "deleting",
+		DeleteAt: models.NewLocalTime(now.Add(-time.Minute)),
+		Status:   models.Status{Code: models.StatusDeleting},
+	}})
+
+	s.cloning.setWrapper("protected", &CloneWrapper{Clone: &models.Clone{
+		ID:        "protected",
+		DeleteAt:  models.NewLocalTime(now.Add(-time.Minute)),
+		Protected: true,
+	}})
+	s.cloning.setWrapper("derived", &CloneWrapper{Clone: &models.Clone{ID: "derived", DeleteAt: models.NewLocalTime(now.Add(-time.Minute))}})
+	s.cloning.addSnapshot(&models.Snapshot{ID: "pool/derived@snapshot_20221012100000", CloneID: "derived"})
+
+	assert.Equal(s.T(), []string{"expired"}, s.cloning.expiredClones(now))
+}
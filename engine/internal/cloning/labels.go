@@ -0,0 +1,151 @@
+/*
+2022 © Postgres.ai
+*/
+
+package cloning
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/log"
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/models"
+)
+
+const (
+	labelSeparator    = ","
+	labelKeyDelimiter = "="
+	maxLabelKeyLength = 63
+)
+
+// validateLabels checks that labels can be used in label selectors.
+func validateLabels(labels map[string]string) error {
+	for key, value := range labels {
+		if key == "" {
+			return models.New(models.ErrCodeBadRequest, "label key must not be empty")
+		}
+
+		if len(key) > maxLabelKeyLength {
+			return models.New(models.ErrCodeBadRequest, fmt.Sprintf("label key %q is longer than %d characters", key, maxLabelKeyLength))
+		}
+
+		if strings.ContainsAny(key, labelSeparator+labelKeyDelimiter+" ") {
+			return models.New(models.ErrCodeBadRequest, fmt.Sprintf("label key %q must not contain commas, equal signs or spaces", key))
+		}
+
+		if strings.Contains(value, labelSeparator) {
+			return models.New(models.ErrCodeBadRequest, fmt.Sprintf("value of label %q must not contain commas", key))
+		}
+	}
+
+	return nil
+}
+
+// ParseLabelSelector parses label selectors in the format "key1=value1,key2=value2".
+// A clone matches the resulting selector if it has all of the listed labels.
+func ParseLabelSelector(selectors []string) (map[string]string, error) {
+	selector := make(map[string]string)
+
+	for _, rawSelector := range selectors {
+		for _, requirement := range strings.Split(rawSelector, labelSeparator) {
+			requirement = strings.TrimSpace(requirement)
+			if requirement == "" {
+				continue
+			}
+
+			key, value, found := strings.Cut(requirement, labelKeyDelimiter)
+			if !found || key == "" {
+				return nil, models.New(models.ErrCodeBadRequest, fmt.Sprintf("invalid label selector %q: expected key=value", requirement))
+			}
+
+			selector[key] = value
+		}
+	}
+
+	return selector, nil
+}
+
+// matchLabels checks if labels contain all pairs of the selector.
+func matchLabels(labels, selector map[string]string) bool {
+	for key, value := range selector {
+		labelValue, ok := labels[key]
+		if !ok || labelValue != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+// GetClonesBySelector returns the list of clones matching the label selector descend ordered by creation time.
+func (c *Base) GetClonesBySelector(selector map[string]string) []*models.Clone {
+	clones := c.GetClones()
+
+	if len(selector) == 0 {
+		return clones
+	}
+
+	filtered := make([]*models.Clone, 0, len(clones))
+
+	for _, clone := range clones {
+		if matchLabels(clone.Labels, selector) {
+			filtered = append(filtered, clone)
+		}
+	}
+
+	return filtered
+}
+
+// DestroyClonesBySelector destroys all clones matching the label selector.
+// Clones that cannot be destroyed, for example, protected ones, are reported in the result.
+// A non-empty owner restricts the destruction to the clones of the owner.
+func (c *Base) DestroyClonesBySelector(selector map[string]string, owner string) (*models.ClonesDestroyResult, error) {
+	if len(selector) == 0 {
+		return nil, models.New(models.ErrCodeBadRequest, "label selector must not be empty")
+	}
+
+	result := &models.ClonesDestroyResult{
+		Destroyed: []string{},
+		Failed:    make(map[string]string),
+	}
+
+	for _, cloneID := range c.cloneIDsBySelector(selector, owner) {
+		if err := c.DestroyClone(cloneID); err != nil {
+			log.Errf("Failed to destroy clone %q: %v", cloneID, err)
+
+			result.Failed[cloneID] = err.Error()
+
+			continue
+		}
+
+		result.Destroyed = append(result.Destroyed, cloneID)
+	}
+
+	return result, nil
+}
+
+func (c *Base) cloneIDsBySelector(selector map[string]string, owner string) []string {
+	cloneIDs := []string{}
+
+	c.cloneMutex.RLock()
+	defer c.cloneMutex.RUnlock()
+
+	for cloneID, w := range c.clones {
+		if w.Clone == nil || w.Clone.Status.Code == models.StatusDeleting {
+			continue
+		}
+
+		if owner != "" && w.Clone.Owner != owner {
+			continue
+		}
+
+		if matchLabels(w.Clone.Labels, selector) {
+			cloneIDs = append(cloneIDs, cloneID)
+		}
+	}
+
+	sort.Strings(cloneIDs)
+
+	return cloneIDs
+}
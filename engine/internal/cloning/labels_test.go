@@ -0,0 +1,88 @@
+package cloning
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/models"
+)
+
+func TestValidateLabels(t *testing.T) {
+	require.NoError(t, validateLabels(nil))
+	require.NoError(t, validateLabels(map[string]string{"pipeline": "123", "ticket": ""}))
+
+	invalidLabels := []map[string]string{
+		{"": "123"},
+		{"pipe=line": "123"},
+		{"pipe,line": "123"},
+		{"pipe line": "123"},
+		{"pipeline": "1,2"},
+		{"pipeline_pipeline_pipeline_pipeline_pipeline_pipeline_pipeline_x": "123"},
+	}
+
+	for _, labels := range invalidLabels {
+		assert.Error(t, validateLabels(labels), labels)
+	}
+}
+
+func TestParseLabelSelector(t *testing.T) {
+	selector, err := ParseLabelSelector([]string{"pipeline=123, team=db", "ticket="})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"pipeline": "123", "team": "db", "ticket": ""}, selector)
+
+	selector, err = ParseLabelSelector(nil)
+	require.NoError(t, err)
+	assert.Empty(t, selector)
+
+	for _, rawSelector := range []string{"pipeline", "=123", "pipeline=123,team"} {
+		_, err := ParseLabelSelector([]string{rawSelector})
+		assert.Error(t, err, rawSelector)
+	}
+}
+
+func TestMatchLabels(t *testing.T) {
+	labels := map[string]string{"pipeline": "123", "team": "db"}
+
+	assert.True(t, matchLabels(labels, nil))
+	assert.True(t, matchLabels(labels, map[string]string{"pipeline": "123"}))
+	assert.True(t, matchLabels(labels, map[string]string{"pipeline": "123", "team": "db"}))
+	assert.False(t, matchLabels(labels, map[string]string{"pipeline": "124"}))
+	assert.False(t, matchLabels(labels, map[string]string{"ticket": ""}))
+	assert.False(t, matchLabels(nil, map[string]string{"pipeline": "123"}))
+}
+
+func (s *BaseCloningSuite) TestClonesBySelector() {
+	s.cloning.setWrapper("c1", &CloneWrapper{Clone: &models.Clone{
+		ID:        "c1",
+		CreatedAt: &models.LocalTime{},
+		Labels:    map[string]string{"pipeline": "123"},
+		Owner:     "ci",
+	}})
+	s.cloning.setWrapper("c2", &CloneWrapper{Clone: &models.Clone{
+		ID:        "c2",
+		CreatedAt: &models.LocalTime{},
+		Labels:    map[string]string{"pipeline": "124"},
+	}})
+	s.cloning.setWrapper("c3", &CloneWrapper{Clone: &models.Clone{
+		ID:        "c3",
+		CreatedAt: &models.LocalTime{},
+		Labels:    map[string]string{"pipeline": "123"},
+		Protected: true,
+	}})
+
+	clones := s.cloning.GetClonesBySelector(map[string]string{"pipeline": "123"})
+	require.Len(s.T(), clones, 2)
+	assert.Len(s.T(), s.cloning.GetClonesBySelector(nil), 3)
+	assert.Equal(s.T(), []string{"c1", "c3"}, s.cloning.cloneIDsBySelector(map[string]string{"pipeline": "123"}, ""))
+	assert.Equal(s.T(), []string{"c1"}, s.cloning.cloneIDsBySelector(map[string]string{"pipeline": "123"}, "ci"))
+
+	_, err := s.cloning.DestroyClonesBySelector(nil, "")
+	assert.Error(s.T(), err)
+
+	result, err := s.cloning.DestroyClonesBySelector(map[string]string{"pipeline": "124", "team": "db"}, "")
+	require.NoError(s.T(), err)
+	assert.Empty(s.T(), result.Destroyed)
+	assert.Empty(s.T(), result.Failed)
+}
@@ -0,0 +1,26 @@
+/*
+2022 © Postgres.ai
+*/
+
+package cloning
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// cloningDurationBuckets covers clone creation latency from a second to a quarter of an hour.
+var cloningDurationBuckets = []float64{1, 2.5, 5, 10, 20, 30, 60, 120, 300, 900}
+
+func newCloningDurationHistogram() prometheus.Histogram {
+	return prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "dblab",
+		Name:      "clone_creation_duration_seconds",
+		Help:      "Time from a clone creation request until the clone is ready to accept connections.",
+		Buckets:   cloningDurationBuckets,
+	})
+}
+
+// CloningDuration returns the histogram of clone creation latency.
+func (c *Base) CloningDuration() prometheus.Collector {
+	return c.cloningDuration
+}
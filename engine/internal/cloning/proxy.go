@@ -0,0 +1,42 @@
+/*
+2022 © Postgres.ai
+*/
+
+package cloning
+
+import (
+	"sync/atomic"
+
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/models"
+)
+
+// CloneProxyPort returns the port of a running clone to route proxy connections to.
+func (c *Base) CloneProxyPort(cloneID string) (uint, error) {
+	c.cloneMutex.RLock()
+	defer c.cloneMutex.RUnlock()
+
+	w, ok := c.clones[cloneID]
+	if !ok {
+		return 0, models.New(models.ErrCodeNotFound, "clone not found")
+	}
+
+	if w.Session == nil || w.Clone.Status.Code != models.StatusOK {
+		return 0, models.New(models.ErrCodeBadRequest, "clone is not ready")
+	}
+
+	return w.Session.Port, nil
+}
+
+// ProxyConnectionOpened counts a new proxy connection to the clone.
+func (c *Base) ProxyConnectionOpened(cloneID string) {
+	if w, ok := c.findWrapper(cloneID); ok {
+		atomic.AddInt64(&w.proxyConnections, 1)
+	}
+}
+
+// ProxyConnectionClosed counts a closed proxy connection to the clone.
+func (c *Base) ProxyConnectionClosed(cloneID string) {
+	if w, ok := c.findWrapper(cloneID); ok {
+		atomic.AddInt64(&w.proxyConnections, -1)
+	}
+}
@@ -0,0 +1,162 @@
+/*
+2022 © Postgres.ai
+*/
+
+package cloning
+
+import (
+	"fmt"
+
+	"github.com/docker/go-units"
+
+	"gitlab.com/postgres-ai/database-lab/v3/internal/provision/resources"
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/log"
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/models"
+)
+
+// Quotas defines limits of clone creation. Zero values mean no limits.
+type Quotas struct {
+	MaxClonesPerToken     uint64 `yaml:"maxClonesPerToken"`
+	MaxClonesPerSnapshot  uint64 `yaml:"maxClonesPerSnapshot"`
+	MaxTotalCloneDiffSize string `yaml:"maxTotalCloneDiffSize"`
+}
+
+// maxTotalCloneDiffSize returns the limit of the total size of clone diffs in bytes.
+func (q Quotas) maxTotalCloneDiffSize() (uint64, error) {
+	if q.MaxTotalCloneDiffSize == "" {
+		return 0, nil
+	}
+
+	size, err := units.RAMInBytes(q.MaxTotalCloneDiffSize)
+	if err != nil {
+		return 0, fmt.Errorf(`invalid "quotas.maxTotalCloneDiffSize": %w`, err)
+	}
+
+	return uint64(size), nil
+}
+
+// addCloneWithinQuotas adds the clone if the quotas allow to create it.
+// Clones are counted and added under the same lock, so concurrent requests cannot exceed the quotas.
+func (c *Base) addCloneWithinQuotas(w *CloneWrapper) error {
+	quotas := c.config.Quotas
+
+	maxDiffSize, err := quotas.maxTotalCloneDiffSize()
+	if err != nil {
+		return err
+	}
+
+	if maxDiffSize > 0 && c.totalCloneDiffSize() >= maxDiffSize {
+		return models.New(models.ErrCodeBadRequest,
+			fmt.Sprintf("quota exceeded: the total size of clone changes has reached the limit of %s", quotas.MaxTotalCloneDiffSize))
+	}
+
+	c.cloneMutex.Lock()
+	defer c.cloneMutex.Unlock()
+
+	if _, ok := c.clones[w.Clone.ID]; ok {
+		return models.New(models.ErrCodeBadRequest, "clone with such ID already exists")
+	}
+
+	usage := c.cloneCounts(w.Clone.Owner)
+
+	if quotas.MaxClonesPerToken > 0 && usage.ownerClones >= quotas.MaxClonesPerToken {
+		return models.New(models.ErrCodeBadRequest,
+			fmt.Sprintf("quota exceeded: the maximum number of clones per token is %d", quotas.MaxClonesPerToken))
+	}
+
+	if quotas.MaxClonesPerSnapshot > 0 && usage.snapshotClones[w.Clone.Snapshot.ID] >= quotas.MaxClonesPerSnapshot {
+		return models.New(models.ErrCodeBadRequest,
+			fmt.Sprintf("quota exceeded: the maximum number of clones per snapshot is %d", quotas.MaxClonesPerSnapshot))
+	}
+
+	c.clones[w.Clone.ID] = w
+
+	return nil
+}
+
+type quotaUsage struct {
+	ownerClones    uint64
+	snapshotClones map[string]uint64
+}
+
+// cloneCounts counts clones of the owner and of snapshots. The caller must hold the clone lock.
+// Owners are the authenticated identities, so tokens, OpenID Connect users, and admins have separate quotas.
+func (c *Base) cloneCounts(owner string) quotaUsage {
+	usage := quotaUsage{snapshotClones: make(map[string]uint64)}
+
+	for _, w := range c.clones {
+		if w.Clone == nil {
+			continue
+		}
+
+		if w.Clone.Owner == owner {
+			usage.ownerClones++
+		}
+
+		if w.Clone.Snapshot != nil {
+			usage.snapshotClones[w.Clone.Snapshot.ID]++
+		}
+	}
+
+	return usage
+}
+
+// totalCloneDiffSize sums the sizes of clone changes.
+// Session states are requested without holding the clone lock because it takes running commands on the host.
+func (c *Base) totalCloneDiffSize() uint64 {
+	type cloneDiff struct {
+		session *resources.Session
+		size    uint64
+	}
+
+	c.cloneMutex.RLock()
+
+	diffs := make([]cloneDiff, 0, len(c.clones))
+
+	for _, w := range c.clones {
+		if w.Clone != nil {
+			diffs = append(diffs, cloneDiff{session: w.Session, size: w.Clone.Metadata.CloneDiffSize})
+		}
+	}
+
+	c.cloneMutex.RUnlock()
+
+	var total uint64
+
+	for _, diff := range diffs {
+		if diff.session != nil {
+			sessionState, err := c.provision.GetSessionState(diff.session)
+			if err != nil {
+				log.Err(fmt.Errorf("failed to get a session state: %w", err))
+			} else {
+				diff.size = sessionState.CloneDiffSize
+			}
+		}
+
+		total += diff.size
+	}
+
+	return total
+}
+
+// GetQuotas returns configured quotas and their usage by the owner.
+func (c *Base) GetQuotas(owner string) *models.Quotas {
+	quotas := c.config.Quotas
+
+	maxDiffSize, err := quotas.maxTotalCloneDiffSize()
+	if err != nil {
+		maxDiffSize = 0
+	}
+
+	c.cloneMutex.RLock()
+	usage := c.cloneCounts(owner)
+	c.cloneMutex.RUnlock()
+
+	return &models.Quotas{
+		MaxClonesPerToken:     quotas.MaxClonesPerToken,
+		TokenClones:           usage.ownerClones,
+		MaxClonesPerSnapshot:  quotas.MaxClonesPerSnapshot,
+		MaxTotalCloneDiffSize: maxDiffSize,
+		TotalCloneDiffSize:    c.totalCloneDiffSize(),
+	}
+}
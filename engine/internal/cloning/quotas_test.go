@@ -0,0 +1,110 @@
+package cloning
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/models"
+)
+
+func newQuotaWrapper(cloneID, snapshotID, owner string) *CloneWrapper {
+	return &CloneWrapper{Clone: &models.Clone{ID: cloneID, Snapshot: &models.Snapshot{ID: snapshotID}, Owner: owner}}
+}
+
+func (s *BaseCloningSuite) TestCloneQuotas() {
+	s.cloning.config = &Config{Quotas: Quotas{MaxClonesPerToken: 2, MaxClonesPerSnapshot: 2, MaxTotalCloneDiffSize: "1m"}}
+	defer func() { s.cloning.config = nil }()
+
+	snapshot := &models.Snapshot{ID: "snapshot1"}
+
+	s.cloning.setWrapper("clone1", &CloneWrapper{
+		Clone: &models.Clone{ID: "clone1", Snapshot: snapshot, Owner: "ci", Metadata: models.CloneMetadata{CloneDiffSize: 1024}},
+	})
+
+	require.NoError(s.T(), s.cloning.addCloneWithinQuotas(newQuotaWrapper("clone2", "snapshot2", "ci")))
+
+	err := s.cloning.addCloneWithinQuotas(newQuotaWrapper("clone5", "snapshot2", "ci"))
+	require.Error(s.T(), err)
+	assert.Contains(s.T(), err.Error(), "per token")
+
+	var modelErr *models.Error
+	require.True(s.T(), errors.As(err, &modelErr))
+	assert.Equal(s.T(), models.ErrCodeBadRequest, modelErr.Code)
+
+	_, ok := s.cloning.findWrapper("clone5")
+	assert.False(s.T(), ok)
+
+	s.cloning.setWrapper("clone3", &CloneWrapper{Clone: &models.Clone{ID: "clone3", Snapshot: snapshot, Owner: "dashboard"}})
+
+	err = s.cloning.addCloneWithinQuotas(newQuotaWrapper("clone5", "snapshot1", "oidc:jdoe"))
+	require.Error(s.T(), err)
+	assert.Contains(s.T(), err.Error(), "per snapshot")
+
+	require.NoError(s.T(), s.cloning.addCloneWithinQuotas(newQuotaWrapper("clone5", "snapshot3", "oidc:jdoe")))
+
+	err = s.cloning.addCloneWithinQuotas(newQuotaWrapper("clone5", "snapshot4", "oidc:jroe"))
+	require.Error(s.T(), err)
+	assert.Contains(s.T(), err.Error(), "already exists")
+
+	s.cloning.setWrapper("clone4", &CloneWrapper{
+		Clone: &models.Clone{ID: "clone4", Snapshot: &models.Snapshot{ID: "snapshot3"}, Owner: "dashboard",
+			Metadata: models.CloneMetadata{CloneDiffSize: 1 << 20}},
+	})
+
+	err = s.cloning.addCloneWithinQuotas(newQuotaWrapper("clone6", "snapshot4", "oidc:jroe"))
+	require.Error(s.T(), err)
+	assert.Contains(s.T(), err.Error(), "total size")
+
+	assert.Equal(s.T(), &models.Quotas{
+		MaxClonesPerToken:     2,
+		TokenClones:           2,
+		MaxClonesPerSnapshot:  2,
+		MaxTotalCloneDiffSize: 1 << 20,
+		TotalCloneDiffSize:    1<<20 + 1024,
+	}, s.cloning.GetQuotas("dashboard"))
+}
+
+func (s *BaseCloningSuite) TestConcurrentCloneQuotas() {
+	s.cloning.config = &Config{Quotas: Quotas{MaxClonesPerToken: 1}}
+	defer func() { s.cloning.config = nil }()
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		created int
+	)
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			if err := s.cloning.addCloneWithinQuotas(newQuotaWrapper(fmt.Sprintf("clone%d", i), "snapshot1", "ci")); err == nil {
+				mu.Lock()
+				created++
+				mu.Unlock()
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	assert.Equal(s.T(), 1, created)
+}
+
+func (s *BaseCloningSuite) TestNoCloneQuotas() {
+	s.cloning.config = &Config{}
+	defer func() { s.cloning.config = nil }()
+
+	s.cloning.setWrapper("clone1", &CloneWrapper{Clone: &models.Clone{ID: "clone1"}})
+
+	require.NoError(s.T(), s.cloning.addCloneWithinQuotas(newQuotaWrapper("clone2", "snapshot1", "")))
+
+	s.cloning.config.Quotas.MaxTotalCloneDiffSize = "a lot"
+	require.Error(s.T(), s.cloning.addCloneWithinQuotas(newQuotaWrapper("clone3", "snapshot1", "")))
+}
@@ -5,6 +5,7 @@
 package cloning
 
 import (
+	"fmt"
 	"sort"
 	"sync"
 
@@ -19,6 +20,14 @@ type SnapshotBox struct {
 	snapshotMutex  sync.RWMutex
 	items          map[string]*models.Snapshot
 	latestSnapshot *models.Snapshot
+	origins        map[string]snapshotOrigin
+}
+
+// snapshotOrigin describes the clone and the parent snapshot that a derived snapshot has been taken from.
+type snapshotOrigin struct {
+	Parent  string `json:"parent"`
+	CloneID string `json:"cloneId"`
+	Message string `json:"message,omitempty"`
 }
 
 func (c *Base) fetchSnapshots() error {
@@ -31,6 +40,10 @@ func (c *Base) fetchSnapshots() error {
 
 	snapshots := make(map[string]*models.Snapshot, len(entries))
 
+	c.snapshotBox.snapshotMutex.RLock()
+	origins := c.snapshotBox.origins
+	c.snapshotBox.snapshotMutex.RUnlock()
+
 	for _, entry := range entries {
 		numClones := 0
 
@@ -49,10 +62,20 @@ func (c *Base) fetchSnapshots() error {
 			LogicalSize:  entry.LogicalReferenced,
 			Pool:         entry.Pool,
 			NumClones:    numClones,
+			Protected:    entry.Protected,
+		}
+
+		if origin, ok := origins[entry.ID]; ok {
+			currentSnapshot.Parent = origin.Parent
+			currentSnapshot.CloneID = origin.CloneID
+			currentSnapshot.Message = origin.Message
 		}
 
 		snapshots[entry.ID] = currentSnapshot
-		latestSnapshot = defineLatestSnapshot(latestSnapshot, currentSnapshot)
+
+		if !isDerivedSnapshot(currentSnapshot) {
+			latestSnapshot = defineLatestSnapshot(latestSnapshot, currentSnapshot)
+		}
 
 		log.Dbg("snapshot:", *currentSnapshot)
 	}
@@ -74,9 +97,32 @@ func (c *Base) addSnapshot(snapshot *models.Snapshot) {
 	c.snapshotBox.snapshotMutex.Lock()
 
 	c.snapshotBox.items[snapshot.ID] = snapshot
-	c.snapshotBox.latestSnapshot = defineLatestSnapshot(c.snapshotBox.latestSnapshot, snapshot)
+
+	if isDerivedSnapshot(snapshot) {
+		if c.snapshotBox.origins == nil {
+			c.snapshotBox.origins = make(map[string]snapshotOrigin)
+		}
+
+		c.snapshotBox.origins[snapshot.ID] = snapshotOrigin{
+			Parent:  snapshot.Parent,
+			CloneID: snapshot.CloneID,
+			Message: snapshot.Message,
+		}
+	} else {
+		c.snapshotBox.latestSnapshot = defineLatestSnapshot(c.snapshotBox.latestSnapshot, snapshot)
+	}
+
+	snapshotCopy := *snapshot
 
 	c.snapshotBox.snapshotMutex.Unlock()
+
+	c.broker.Publish(models.StreamEvent{Type: models.StreamSnapshotAdded, Snapshot: &snapshotCopy})
+}
+
+// isDerivedSnapshot checks if the snapshot has been taken from a clone.
+// Derived snapshots are never chosen as the latest one, so new clones still start from the fresh data by default.
+func isDerivedSnapshot(snapshot *models.Snapshot) bool {
+	return snapshot.Parent != ""
 }
 
 // defineLatestSnapshot compares two snapshots and defines the latest one.
@@ -144,6 +190,40 @@ func (c *Base) decrementCloneNumber(snapshotID string) {
 	snapshot.NumClones--
 }
 
+// hasDerivedSnapshots checks if there are snapshots taken from the clone.
+func (c *Base) hasDerivedSnapshots(cloneID string) bool {
+	c.snapshotBox.snapshotMutex.RLock()
+	defer c.snapshotBox.snapshotMutex.RUnlock()
+
+	for _, snapshot := range c.snapshotBox.items {
+		if snapshot != nil && snapshot.CloneID == cloneID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// latestDerivedSnapshot returns the most recent snapshot taken from the clone.
+func (c *Base) latestDerivedSnapshot(cloneID string) *models.Snapshot {
+	c.snapshotBox.snapshotMutex.RLock()
+	defer c.snapshotBox.snapshotMutex.RUnlock()
+
+	var latest *models.Snapshot
+
+	for _, snapshot := range c.snapshotBox.items {
+		if snapshot == nil || snapshot.CloneID != cloneID {
+			continue
+		}
+
+		if latest == nil || latest.CreatedAt.Before(snapshot.CreatedAt.Time) {
+			latest = snapshot
+		}
+	}
+
+	return latest
+}
+
 func (c *Base) getSnapshotList() []models.Snapshot {
 	c.snapshotBox.snapshotMutex.RLock()
 	defer c.snapshotBox.snapshotMutex.RUnlock()
@@ -166,3 +246,121 @@ func (c *Base) getSnapshotList() []models.Snapshot {
 
 	return snapshots
 }
+
+// DestroySnapshot destroys the snapshot.
+// Snapshots that have clones, are protected, or are heads of branches cannot be destroyed.
+// Dependent clones are never destroyed with the snapshot, so a clone created after the checks makes the deletion fail.
+func (c *Base) DestroySnapshot(snapshotID string) error {
+	if err := c.fetchSnapshots(); err != nil {
+		return errors.Wrap(err, "failed to fetch snapshots")
+	}
+
+	snapshot, err := c.getSnapshotByID(snapshotID)
+	if err != nil {
+		return models.New(models.ErrCodeNotFound, "snapshot not found")
+	}
+
+	if err := c.checkSnapshotDestroyable(snapshot); err != nil {
+		return err
+	}
+
+	if err := c.provision.DestroySnapshot(snapshotID); err != nil {
+		return errors.Wrap(err, "failed to destroy snapshot")
+	}
+
+	c.snapshotBox.snapshotMutex.Lock()
+	delete(c.snapshotBox.origins, snapshotID)
+	c.snapshotBox.snapshotMutex.Unlock()
+
+	c.SaveSnapshotsState()
+
+	if err := c.fetchSnapshots(); err != nil {
+		return errors.Wrap(err, "failed to fetch snapshots")
+	}
+
+	return nil
+}
+
+func (c *Base) checkSnapshotDestroyable(snapshot *models.Snapshot) error {
+	if snapshot.NumClones > 0 {
+		return models.New(models.ErrCodeBadRequest, "snapshot has dependent clones")
+	}
+
+	if snapshot.Protected {
+		return models.New(models.ErrCodeBadRequest, "snapshot is protected")
+	}
+
+	if branchName, ok := c.findBranchByHead(snapshot.ID); ok {
+		return models.New(models.ErrCodeBadRequest, fmt.Sprintf("snapshot is the head of branch %q", branchName))
+	}
+
+	return nil
+}
+
+// ProtectSnapshot changes the protection of the snapshot from the retention.
+func (c *Base) ProtectSnapshot(snapshotID string, protected bool) (*models.Snapshot, error) {
+	if err := c.fetchSnapshots(); err != nil {
+		return nil, errors.Wrap(err, "failed to fetch snapshots")
+	}
+
+	if _, err := c.getSnapshotByID(snapshotID); err != nil {
+		return nil, models.New(models.ErrCodeNotFound, "snapshot not found")
+	}
+
+	if err := c.provision.ProtectSnapshot(snapshotID, protected); err != nil {
+		return nil, errors.Wrap(err, "failed to protect snapshot")
+	}
+
+	if err := c.fetchSnapshots(); err != nil {
+		return nil, errors.Wrap(err, "failed to fetch snapshots")
+	}
+
+	snapshot, err := c.getSnapshotByID(snapshotID)
+	if err != nil {
+		return nil, models.New(models.ErrCodeNotFound, "snapshot not found")
+	}
+
+	result := *snapshot
+
+	return &result, nil
+}
+
+// SetSnapshotOrigin records the snapshot that the given snapshot has been derived from and describes it with the message.
+// Derived snapshots are not chosen as the latest one, so new clones still start from the fresh data by default.
+func (c *Base) SetSnapshotOrigin(snapshotID, parentID, message string) (*models.Snapshot, error) {
+	if err := c.fetchSnapshots(); err != nil {
+		return nil, errors.Wrap(err, "failed to fetch snapshots")
+	}
+
+	if _, err := c.getSnapshotByID(snapshotID); err != nil {
+		return nil, models.New(models.ErrCodeNotFound, "snapshot not found")
+	}
+
+	c.snapshotBox.snapshotMutex.Lock()
+
+	if c.snapshotBox.origins == nil {
+		c.snapshotBox.origins = make(map[string]snapshotOrigin)
+	}
+
+	c.snapshotBox.origins[snapshotID] = snapshotOrigin{
+		Parent:  parentID,
+		Message: message,
+	}
+
+	c.snapshotBox.snapshotMutex.Unlock()
+
+	c.SaveSnapshotsState()
+
+	if err := c.fetchSnapshots(); err != nil {
+		return nil, errors.Wrap(err, "failed to fetch snapshots")
+	}
+
+	snapshot, err := c.getSnapshotByID(snapshotID)
+	if err != nil {
+		return nil, models.New(models.ErrCodeNotFound, "snapshot not found")
+	}
+
+	result := *snapshot
+
+	return &result, nil
+}
@@ -161,3 +161,50 @@ func TestLatestSnapshots(t *testing.T) {
 		require.Equal(t, tc.result, defineLatestSnapshot(tc.latest, tc.challenger))
 	}
 }
+
+func (s *BaseCloningSuite) TestDerivedSnapshot() {
+	s.cloning.resetSnapshots(make(map[string]*models.Snapshot), nil)
+
+	baseSnapshot := &models.Snapshot{
+		ID:          "pool@snapshot_20200219000000",
+		CreatedAt:   &models.LocalTime{Time: time.Date(2020, 02, 20, 01, 23, 45, 0, time.UTC)},
+		DataStateAt: &models.LocalTime{Time: time.Date(2020, 02, 19, 0, 0, 0, 0, time.UTC)},
+	}
+
+	derivedSnapshot := &models.Snapshot{
+		ID:          "pool/dblab_clone_6000@snapshot_20200221000000",
+		CreatedAt:   &models.LocalTime{Time: time.Date(2020, 02, 21, 0, 0, 0, 0, time.UTC)},
+		DataStateAt: &models.LocalTime{Time: time.Date(2020, 02, 21, 0, 0, 0, 0, time.UTC)},
+		Parent:      baseSnapshot.ID,
+		CloneID:     "testCloneID",
+	}
+
+	s.cloning.addSnapshot(baseSnapshot)
+	s.cloning.addSnapshot(derivedSnapshot)
+
+	latestSnapshot, err := s.cloning.getLatestSnapshot()
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), baseSnapshot, latestSnapshot)
+
+	require.True(s.T(), s.cloning.hasDerivedSnapshots("testCloneID"))
+	require.False(s.T(), s.cloning.hasDerivedSnapshots("otherCloneID"))
+	require.Equal(s.T(), snapshotOrigin{Parent: baseSnapshot.ID, CloneID: "testCloneID"},
+		s.cloning.snapshotBox.origins[derivedSnapshot.ID])
+}
+
+func (s *BaseCloningSuite) TestSnapshotDestroyable() {
+	snapshot := &models.Snapshot{ID: "pool@snapshot_20200219000000"}
+
+	require.NoError(s.T(), s.cloning.checkSnapshotDestroyable(snapshot))
+
+	snapshot.NumClones = 1
+	require.EqualError(s.T(), s.cloning.checkSnapshotDestroyable(snapshot), "snapshot has dependent clones")
+
+	snapshot.NumClones = 0
+	snapshot.Protected = true
+	require.EqualError(s.T(), s.cloning.checkSnapshotDestroyable(snapshot), "snapshot is protected")
+
+	snapshot.Protected = false
+	s.cloning.branches["main"] = &models.Branch{Name: "main", SnapshotID: snapshot.ID}
+	require.EqualError(s.T(), s.cloning.checkSnapshotDestroyable(snapshot), `snapshot is the head of branch "main"`)
+}
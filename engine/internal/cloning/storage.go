@@ -15,37 +15,61 @@ import (
 	"gitlab.com/postgres-ai/database-lab/v3/pkg/util"
 )
 
-const sessionsFilename = "sessions.json"
+const (
+	sessionsFilename   = "sessions.json"
+	stateStoreFilename = "clones.db"
+	snapshotsFilename  = "snapshots.json"
+	branchesFilename   = "branches.json"
+)
+
+// OpenStateStore opens the state store of clones in the metadata directory
+// and imports clones from the sessions file of previous versions.
+func OpenStateStore() (*BoltStore, error) {
+	storePath, err := util.GetMetaPath(stateStoreFilename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get path of a state store: %w", err)
+	}
+
+	store, err := NewBoltStore(storePath)
+	if err != nil {
+		return nil, err
+	}
 
-// RestoreClonesState restores clones data from disk.
-func (c *Base) RestoreClonesState() error {
 	sessionsPath, err := util.GetMetaPath(sessionsFilename)
 	if err != nil {
-		return fmt.Errorf("failed to get path of a sessions file: %w", err)
+		_ = store.Close()
+		return nil, fmt.Errorf("failed to get path of a sessions file: %w", err)
 	}
 
-	return c.loadSessionState(sessionsPath)
+	// The engine can work without the clones of previous versions, so a failed import does not prevent the start.
+	if err := store.MigrateSessions(sessionsPath); err != nil {
+		log.Err("Failed to import stored sessions:", err)
+	}
+
+	return store, nil
 }
 
-// loadSessionState loads and decodes sessions data.
-func (c *Base) loadSessionState(sessionsPath string) error {
+// RestoreClonesState restores clones data from the state store.
+func (c *Base) RestoreClonesState() error {
 	c.cloneMutex.Lock()
 	defer c.cloneMutex.Unlock()
 
 	c.clones = make(map[string]*CloneWrapper)
 
-	data, err := os.ReadFile(sessionsPath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			// no sessions data, ignore
-			return nil
-		}
+	if c.store == nil {
+		return nil
+	}
 
-		return fmt.Errorf("failed to read sessions data: %w", err)
+	clones, err := c.store.LoadClones()
+	if err != nil {
+		return fmt.Errorf("failed to load clones: %w", err)
 	}
 
-	return json.Unmarshal(data, &c.clones)
+	c.clones = clones
+
+	return nil
 }
+
 func (c *Base) restartCloneContainers(ctx context.Context) {
 	c.cloneMutex.Lock()
 	defer c.cloneMutex.Unlock()
@@ -110,27 +134,162 @@ func (c *Base) filterRunningClones(ctx context.Context) {
 	}
 }
 
-// SaveClonesState writes clones state to disk.
+// SaveClonesState writes the state of all clones to the state store.
 func (c *Base) SaveClonesState() {
-	sessionsPath, err := util.GetMetaPath(sessionsFilename)
-	if err != nil {
-		log.Err("failed to get path of a sessions file", err)
+	if c.store == nil {
+		return
 	}
 
-	if err := c.saveClonesState(sessionsPath); err != nil {
+	c.cloneMutex.RLock()
+	defer c.cloneMutex.RUnlock()
+
+	if err := c.store.ReplaceClones(c.clones); err != nil {
 		log.Err("Failed to save the state of running clones", err)
 	}
 }
 
-// saveClonesState tries to write clones state to disk and returns an error on failure.
-func (c *Base) saveClonesState(sessionsPath string) error {
-	c.cloneMutex.Lock()
-	defer c.cloneMutex.Unlock()
+// saveClone writes the state of the clone to the state store and appends the events to its history.
+func (c *Base) saveClone(cloneID string, events ...models.CloneEvent) {
+	c.cloneMutex.RLock()
+	defer c.cloneMutex.RUnlock()
+
+	w, ok := c.clones[cloneID]
+	if !ok {
+		return
+	}
+
+	c.storeClone(w, events...)
+}
+
+// storeClone writes the state of the clone to the state store. The caller must hold the clone mutex.
+func (c *Base) storeClone(w *CloneWrapper, events ...models.CloneEvent) {
+	if c.store == nil {
+		return
+	}
+
+	if err := c.store.SaveClone(w, events...); err != nil {
+		log.Err(fmt.Sprintf("Failed to save the state of clone %s:", w.Clone.ID), err)
+	}
+}
+
+// RestoreSnapshotsState restores the origins of derived snapshots from disk.
+func (c *Base) RestoreSnapshotsState() error {
+	snapshotsPath, err := util.GetMetaPath(snapshotsFilename)
+	if err != nil {
+		return fmt.Errorf("failed to get path of a snapshots file: %w", err)
+	}
+
+	return c.loadSnapshotsState(snapshotsPath)
+}
+
+// loadSnapshotsState loads and decodes the origins of derived snapshots.
+func (c *Base) loadSnapshotsState(snapshotsPath string) error {
+	c.snapshotBox.snapshotMutex.Lock()
+	defer c.snapshotBox.snapshotMutex.Unlock()
+
+	c.snapshotBox.origins = make(map[string]snapshotOrigin)
+
+	data, err := os.ReadFile(snapshotsPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			// no snapshots data, ignore
+			return nil
+		}
+
+		return fmt.Errorf("failed to read snapshots data: %w", err)
+	}
+
+	return json.Unmarshal(data, &c.snapshotBox.origins)
+}
+
+// SaveSnapshotsState writes the origins of derived snapshots to disk.
+func (c *Base) SaveSnapshotsState() {
+	snapshotsPath, err := util.GetMetaPath(snapshotsFilename)
+	if err != nil {
+		log.Err("failed to get path of a snapshots file", err)
+		return
+	}
+
+	if err := c.saveSnapshotsState(snapshotsPath); err != nil {
+		log.Err("Failed to save the state of snapshots", err)
+	}
+}
+
+// saveSnapshotsState tries to write the origins of derived snapshots to disk and returns an error on failure.
+func (c *Base) saveSnapshotsState(snapshotsPath string) error {
+	c.snapshotBox.snapshotMutex.RLock()
+	defer c.snapshotBox.snapshotMutex.RUnlock()
+
+	origins := c.snapshotBox.origins
+	if origins == nil {
+		origins = make(map[string]snapshotOrigin)
+	}
+
+	data, err := json.Marshal(origins)
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshots data: %w", err)
+	}
+
+	return os.WriteFile(snapshotsPath, data, 0600)
+}
+
+// RestoreBranchesState restores branches from disk.
+func (c *Base) RestoreBranchesState() error {
+	branchesPath, err := util.GetMetaPath(branchesFilename)
+	if err != nil {
+		return fmt.Errorf("failed to get path of a branches file: %w", err)
+	}
+
+	return c.loadBranchesState(branchesPath)
+}
+
+// loadBranchesState loads and decodes branches data.
+func (c *Base) loadBranchesState(branchesPath string) error {
+	c.branchMutex.Lock()
+	defer c.branchMutex.Unlock()
+
+	c.branches = make(map[string]*models.Branch)
+
+	data, err := os.ReadFile(branchesPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			// no branches data, ignore
+			return nil
+		}
+
+		return fmt.Errorf("failed to read branches data: %w", err)
+	}
+
+	return json.Unmarshal(data, &c.branches)
+}
+
+// SaveBranchesState writes branches to disk.
+func (c *Base) SaveBranchesState() {
+	branchesPath, err := util.GetMetaPath(branchesFilename)
+	if err != nil {
+		log.Err("failed to get path of a branches file", err)
+		return
+	}
+
+	if err := c.saveBranchesState(branchesPath); err != nil {
+		log.Err("Failed to save the state of branches", err)
+	}
+}
+
+// saveBranchesState tries to write branches to disk and returns an error on failure.
+func (c *Base) saveBranchesState(branchesPath string) error {
+	c.branchMutex.RLock()
+	defer c.branchMutex.RUnlock()
+
+	branches := c.branches
+	if branches == nil {
+		branches = make(map[string]*models.Branch)
+	}
 
-	data, err := json.Marshal(c.clones)
+	data, err := json.Marshal(branches)
 	if err != nil {
-		return fmt.Errorf("failed to encode session data: %w", err)
+		return fmt.Errorf("failed to encode branches data: %w", err)
 	}
 
-	return os.WriteFile(sessionsPath, data, 0600)
+	return os.WriteFile(branchesPath, data, 0600)
 }
@@ -4,11 +4,14 @@ import (
 	"context"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"gitlab.com/postgres-ai/database-lab/v3/internal/provision"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/telemetry"
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/models"
 )
 
 const (
@@ -87,20 +90,24 @@ func newProvisioner() (*provision.Provisioner, error) {
 }
 
 func TestLoadingSessionState(t *testing.T) {
-	t.Run("it shouldn't panic if a state file is absent", func(t *testing.T) {
+	t.Run("it shouldn't fail if a state store is absent", func(t *testing.T) {
 		s := &Base{}
-		err := s.loadSessionState("/tmp/absent_session_file.json")
+		err := s.RestoreClonesState()
 		assert.NoError(t, err)
+		assert.Empty(t, s.clones)
 	})
 
-	t.Run("it loads sessions.json", func(t *testing.T) {
+	t.Run("it imports sessions.json", func(t *testing.T) {
 		filepath, err := prepareStateFile(testingCloneState)
-		assert.NoError(t, err)
+		require.NoError(t, err)
 
-		defer func() { _ = os.Remove(filepath) }()
+		defer func() { _ = os.Remove(filepath + migratedSuffix) }()
 
-		s := &Base{}
-		err = s.loadSessionState(filepath)
+		store := newTestStore(t)
+		require.NoError(t, store.MigrateSessions(filepath))
+
+		s := &Base{store: store}
+		err = s.RestoreClonesState()
 		assert.NoError(t, err)
 
 		t.Run("it should restore valid clone's data", func(t *testing.T) {
@@ -110,26 +117,114 @@ func TestLoadingSessionState(t *testing.T) {
 			assert.Equal(t, "east5", s.clones["c5bfsk0hmvjd7kau71jg"].Session.Pool)
 			assert.Equal(t, uint(6003), s.clones["c5bfsk0hmvjd7kau71jg"].Session.Port)
 		})
+
+		t.Run("it should rename the imported file", func(t *testing.T) {
+			assert.NoFileExists(t, filepath)
+			assert.FileExists(t, filepath+migratedSuffix)
+		})
 	})
 }
 
 func TestSavingSessionState(t *testing.T) {
 	t.Run("it should save even if a clone list is empty", func(t *testing.T) {
-		f, err := os.CreateTemp("", "dblab-clone-state-test-*.json")
+		prov, err := newProvisioner()
+		assert.NoError(t, err)
+
+		store := newTestStore(t)
+		require.NoError(t, store.SaveClone(&CloneWrapper{Clone: &models.Clone{ID: "obsolete"}}))
+
+		s := NewBase(nil, prov, &telemetry.Agent{}, nil, nil, store, nil)
+		s.SaveClonesState()
+
+		clones, err := store.LoadClones()
+		assert.NoError(t, err)
+		assert.Empty(t, clones)
+	})
+
+	t.Run("it should record the history of a destroyed clone", func(t *testing.T) {
+		store := newTestStore(t)
+		s := &Base{store: store, clones: make(map[string]*CloneWrapper)}
+
+		s.setWrapper("c5bfsk0hmvjd7kau71jg", &CloneWrapper{Clone: &models.Clone{
+			ID:       "c5bfsk0hmvjd7kau71jg",
+			Snapshot: &models.Snapshot{ID: "east5@snapshot_20211001112229"},
+		}})
+		s.saveClone("c5bfsk0hmvjd7kau71jg", newCloneEvent("c5bfsk0hmvjd7kau71jg", models.CloneEventCreated, "east5@snapshot_20211001112229"))
+		s.deleteClone("c5bfsk0hmvjd7kau71jg")
+
+		clones, err := store.LoadClones()
+		assert.NoError(t, err)
+		assert.Empty(t, clones)
+
+		events, err := s.GetCloneEvents("c5bfsk0hmvjd7kau71jg")
+		assert.NoError(t, err)
+		require.Len(t, events, 2)
+		assert.Equal(t, models.CloneEventCreated, events[0].Event)
+		assert.Equal(t, models.CloneEventDestroyed, events[1].Event)
+		assert.Equal(t, "east5@snapshot_20211001112229", events[1].SnapshotID)
+	})
+}
+
+func TestSnapshotsState(t *testing.T) {
+	t.Run("it shouldn't panic if a state file is absent", func(t *testing.T) {
+		s := &Base{}
+		err := s.loadSnapshotsState("/tmp/absent_snapshots_file.json")
+		assert.NoError(t, err)
+		assert.Empty(t, s.snapshotBox.origins)
+	})
+
+	t.Run("it should save and restore snapshot origins", func(t *testing.T) {
+		f, err := os.CreateTemp("", "dblab-snapshots-state-test-*.json")
 		assert.NoError(t, err)
 		defer func() { _ = os.Remove(f.Name()) }()
 
-		prov, err := newProvisioner()
+		s := &Base{}
+		s.snapshotBox.origins = map[string]snapshotOrigin{
+			"east5/dblab_clone_6003@snapshot_20211001120000": {
+				Parent:  "east5@snapshot_20211001112229",
+				CloneID: "c5bfsk0hmvjd7kau71jg",
+			},
+		}
+
+		err = s.saveSnapshotsState(f.Name())
+		assert.NoError(t, err)
+
+		restored := &Base{}
+		err = restored.loadSnapshotsState(f.Name())
+		assert.NoError(t, err)
+		assert.Equal(t, s.snapshotBox.origins, restored.snapshotBox.origins)
+	})
+}
+
+func TestBranchesState(t *testing.T) {
+	t.Run("it shouldn't panic if a state file is absent", func(t *testing.T) {
+		s := &Base{}
+		err := s.loadBranchesState("/tmp/absent_branches_file.json")
 		assert.NoError(t, err)
+		assert.Empty(t, s.branches)
+	})
 
-		s := NewBase(nil, prov, &telemetry.Agent{}, nil)
-		err = s.saveClonesState(f.Name())
+	t.Run("it should save and restore branches", func(t *testing.T) {
+		f, err := os.CreateTemp("", "dblab-branches-state-test-*.json")
 		assert.NoError(t, err)
+		defer func() { _ = os.Remove(f.Name()) }()
 
-		data, err := os.ReadFile(f.Name())
+		s := &Base{}
+		s.branches = map[string]*models.Branch{
+			"main": {
+				Name:       "main",
+				SnapshotID: "east5@snapshot_20211001112229",
+				CreatedAt:  &models.LocalTime{Time: time.Date(2021, 10, 1, 12, 0, 0, 0, time.UTC)},
+			},
+		}
+
+		err = s.saveBranchesState(f.Name())
 		assert.NoError(t, err)
 
-		assert.Equal(t, "{}", string(data))
+		restored := &Base{}
+		err = restored.loadBranchesState(f.Name())
+		assert.NoError(t, err)
+		assert.Equal(t, s.branches, restored.branches)
 	})
 }
 
@@ -166,7 +261,7 @@ func TestFilter(t *testing.T) {
 				assert.NoError(t, err)
 				defer func() { _ = os.Remove(filepath) }()
 
-				s := NewBase(nil, prov, &telemetry.Agent{}, nil)
+				s := NewBase(nil, prov, &telemetry.Agent{}, nil, nil, nil, nil)
 
 				s.filterRunningClones(context.Background())
 				assert.Equal(t, 0, len(s.clones))
@@ -0,0 +1,49 @@
+/*
+2022 © Postgres.ai
+*/
+
+package cloning
+
+import (
+	"time"
+
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/models"
+)
+
+// StateStore persists the state of clones and the history of their events.
+//
+// Every method is atomic: either all changes are stored, or none of them.
+type StateStore interface {
+	// LoadClones returns all stored clones.
+	LoadClones() (map[string]*CloneWrapper, error)
+
+	// SaveClone stores the clone and appends the events to its history.
+	SaveClone(w *CloneWrapper, events ...models.CloneEvent) error
+
+	// DeleteClone removes the clone and appends the events to its history.
+	DeleteClone(cloneID string, events ...models.CloneEvent) error
+
+	// ReplaceClones stores the clones and removes all others.
+	ReplaceClones(clones map[string]*CloneWrapper) error
+
+	// AddEvents appends the events to the history of clones.
+	AddEvents(events ...models.CloneEvent) error
+
+	// Events returns the history of the clone in chronological order.
+	Events(cloneID string) ([]models.CloneEvent, error)
+
+	// CleanupEvents removes the history of destroyed clones that has not changed since the given time.
+	CleanupEvents(before time.Time) error
+
+	// Close releases the store.
+	Close() error
+}
+
+func newCloneEvent(cloneID, event, snapshotID string) models.CloneEvent {
+	return models.CloneEvent{
+		CloneID:    cloneID,
+		Event:      event,
+		Time:       models.NewLocalTime(time.Now()),
+		SnapshotID: snapshotID,
+	}
+}
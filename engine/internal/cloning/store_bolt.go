@@ -0,0 +1,363 @@
+/*
+2022 © Postgres.ai
+*/
+
+package cloning
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/log"
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/models"
+)
+
+const (
+	// openTimeout limits waiting for the lock of the database file held by another process.
+	openTimeout = 5 * time.Second
+
+	migratedSuffix = ".migrated"
+
+	// maxCloneEvents limits the history of a clone, older events are removed first.
+	maxCloneEvents = 200
+)
+
+var (
+	clonesBucket  = []byte("clones")
+	historyBucket = []byte("history")
+	metaBucket    = []byte("meta")
+
+	sessionsMigratedKey = []byte("sessions_migrated")
+)
+
+// BoltStore keeps the state of clones in an embedded transactional database file.
+type BoltStore struct {
+	db *bolt.DB
+}
+
+var _ StateStore = (*BoltStore)(nil)
+
+// NewBoltStore opens the database file and creates it if needed.
+// The file is locked, so the state cannot be changed by several processes at the same time.
+func NewBoltStore(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: openTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the state database %s: %w", path, err)
+	}
+
+	if err := db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{clonesBucket, historyBucket, metaBucket} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("failed to initialize the state database: %w", err)
+	}
+
+	return &BoltStore{db: db}, nil
+}
+
+// MigrateSessions imports clones from the JSON file used by previous versions and renames the file.
+func (s *BoltStore) MigrateSessions(sessionsPath string) error {
+	data, err := os.ReadFile(sessionsPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		return fmt.Errorf("failed to read sessions data: %w", err)
+	}
+
+	clones := make(map[string]*CloneWrapper)
+
+	if err := json.Unmarshal(data, &clones); err != nil {
+		return fmt.Errorf("failed to decode sessions data: %w", err)
+	}
+
+	if err := s.db.Update(func(tx *bolt.Tx) error {
+		meta := tx.Bucket(metaBucket)
+
+		// The file may have been left after the import if the engine stopped before renaming it.
+		if meta.Get(sessionsMigratedKey) != nil {
+			return nil
+		}
+
+		for cloneID, w := range clones {
+			if w == nil {
+				continue
+			}
+
+			var snapshotID string
+
+			if w.Clone != nil && w.Clone.Snapshot != nil {
+				snapshotID = w.Clone.Snapshot.ID
+			}
+
+			event := models.CloneEvent{
+				CloneID:    cloneID,
+				Event:      models.CloneEventCreated,
+				Time:       models.NewLocalTime(w.TimeCreatedAt),
+				SnapshotID: snapshotID,
+			}
+
+			if err := putClone(tx, cloneID, w); err != nil {
+				return err
+			}
+
+			if err := appendEvents(tx, event); err != nil {
+				return err
+			}
+		}
+
+		return meta.Put(sessionsMigratedKey, []byte(time.Now().Format(time.RFC3339)))
+	}); err != nil {
+		return fmt.Errorf("failed to import sessions data: %w", err)
+	}
+
+	if err := os.Rename(sessionsPath, sessionsPath+migratedSuffix); err != nil {
+		return fmt.Errorf("failed to rename the imported sessions file: %w", err)
+	}
+
+	log.Msg(fmt.Sprintf("Imported %d clones from %s", len(clones), sessionsPath))
+
+	return nil
+}
+
+// LoadClones returns all stored clones.
+func (s *BoltStore) LoadClones() (map[string]*CloneWrapper, error) {
+	clones := make(map[string]*CloneWrapper)
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(clonesBucket).ForEach(func(key, value []byte) error {
+			w := &CloneWrapper{}
+
+			if err := json.Unmarshal(value, w); err != nil {
+				return fmt.Errorf("failed to decode clone %s: %w", key, err)
+			}
+
+			clones[string(key)] = w
+
+			return nil
+		})
+	})
+
+	return clones, err
+}
+
+// SaveClone stores the clone and appends the events to its history.
+func (s *BoltStore) SaveClone(w *CloneWrapper, events ...models.CloneEvent) error {
+	if w == nil || w.Clone == nil {
+		return errors.New("clone is empty")
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := putClone(tx, w.Clone.ID, w); err != nil {
+			return err
+		}
+
+		return appendEvents(tx, events...)
+	})
+}
+
+// DeleteClone removes the clone and appends the events to its history.
+func (s *BoltStore) DeleteClone(cloneID string, events ...models.CloneEvent) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(clonesBucket).Delete([]byte(cloneID)); err != nil {
+			return err
+		}
+
+		return appendEvents(tx, events...)
+	})
+}
+
+// ReplaceClones stores the clones and removes all others.
+func (s *BoltStore) ReplaceClones(clones map[string]*CloneWrapper) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(clonesBucket)
+
+		var obsolete [][]byte
+
+		if err := bucket.ForEach(func(key, _ []byte) error {
+			if _, ok := clones[string(key)]; !ok {
+				obsolete = append(obsolete, key)
+			}
+
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		for _, key := range obsolete {
+			if err := bucket.Delete(key); err != nil {
+				return err
+			}
+		}
+
+		for cloneID, w := range clones {
+			if err := putClone(tx, cloneID, w); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// AddEvents appends the events to the history of clones.
+func (s *BoltStore) AddEvents(events ...models.CloneEvent) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return appendEvents(tx, events...)
+	})
+}
+
+// Events returns the history of the clone in chronological order.
+func (s *BoltStore) Events(cloneID string) ([]models.CloneEvent, error) {
+	events := []models.CloneEvent{}
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(historyBucket).Bucket([]byte(cloneID))
+		if bucket == nil {
+			return nil
+		}
+
+		return bucket.ForEach(func(_, value []byte) error {
+			var event models.CloneEvent
+
+			if err := json.Unmarshal(value, &event); err != nil {
+				return fmt.Errorf("failed to decode a clone event: %w", err)
+			}
+
+			events = append(events, event)
+
+			return nil
+		})
+	})
+
+	return events, err
+}
+
+// CleanupEvents removes the history of destroyed clones that has not changed since the given time.
+func (s *BoltStore) CleanupEvents(before time.Time) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		clones := tx.Bucket(clonesBucket)
+		history := tx.Bucket(historyBucket)
+
+		var obsolete [][]byte
+
+		if err := history.ForEach(func(cloneID, _ []byte) error {
+			if clones.Get(cloneID) != nil {
+				return nil
+			}
+
+			_, value := history.Bucket(cloneID).Cursor().Last()
+			if value == nil {
+				obsolete = append(obsolete, cloneID)
+				return nil
+			}
+
+			var event models.CloneEvent
+
+			if err := json.Unmarshal(value, &event); err != nil {
+				return fmt.Errorf("failed to decode a clone event: %w", err)
+			}
+
+			if event.Time == nil || event.Time.Before(before) {
+				obsolete = append(obsolete, cloneID)
+			}
+
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		for _, cloneID := range obsolete {
+			if err := history.DeleteBucket(cloneID); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// Close closes the database file.
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
+
+func putClone(tx *bolt.Tx, cloneID string, w *CloneWrapper) error {
+	data, err := json.Marshal(w)
+	if err != nil {
+		return fmt.Errorf("failed to encode clone %s: %w", cloneID, err)
+	}
+
+	return tx.Bucket(clonesBucket).Put([]byte(cloneID), data)
+}
+
+func appendEvents(tx *bolt.Tx, events ...models.CloneEvent) error {
+	for _, event := range events {
+		bucket, err := tx.Bucket(historyBucket).CreateBucketIfNotExists([]byte(event.CloneID))
+		if err != nil {
+			return err
+		}
+
+		seq, err := bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+
+		data, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("failed to encode a clone event: %w", err)
+		}
+
+		// Big-endian keys keep events in the order of insertion.
+		key := make([]byte, 8)
+		binary.BigEndian.PutUint64(key, seq)
+
+		if err := bucket.Put(key, data); err != nil {
+			return err
+		}
+
+		if err := trimEvents(bucket, seq); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// trimEvents removes the oldest events exceeding the limit of the clone history.
+func trimEvents(bucket *bolt.Bucket, lastSeq uint64) error {
+	if lastSeq <= maxCloneEvents {
+		return nil
+	}
+
+	threshold := lastSeq - maxCloneEvents
+
+	var obsolete [][]byte
+
+	cursor := bucket.Cursor()
+
+	for key, _ := cursor.First(); key != nil && binary.BigEndian.Uint64(key) <= threshold; key, _ = cursor.Next() {
+		obsolete = append(obsolete, key)
+	}
+
+	for _, key := range obsolete {
+		if err := bucket.Delete(key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
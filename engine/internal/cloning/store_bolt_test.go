@@ -0,0 +1,155 @@
+package cloning
+
+import (
+	"os"
+	"path"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/models"
+)
+
+func newTestStore(t *testing.T) *BoltStore {
+	t.Helper()
+
+	store, err := NewBoltStore(path.Join(t.TempDir(), stateStoreFilename))
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = store.Close() })
+
+	return store
+}
+
+func TestBoltStore(t *testing.T) {
+	store := newTestStore(t)
+
+	clone := &CloneWrapper{
+		Clone:         &models.Clone{ID: "clone1", Snapshot: &models.Snapshot{ID: "pool@snapshot_1"}},
+		TimeCreatedAt: time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC),
+	}
+
+	require.NoError(t, store.SaveClone(clone, newCloneEvent("clone1", models.CloneEventCreated, "pool@snapshot_1")))
+
+	clone.Clone.Snapshot = &models.Snapshot{ID: "pool@snapshot_2"}
+	require.NoError(t, store.SaveClone(clone, newCloneEvent("clone1", models.CloneEventReset, "pool@snapshot_2")))
+	require.NoError(t, store.SaveClone(&CloneWrapper{Clone: &models.Clone{ID: "clone2"}}))
+
+	clones, err := store.LoadClones()
+	require.NoError(t, err)
+	require.Len(t, clones, 2)
+	assert.Equal(t, "pool@snapshot_2", clones["clone1"].Clone.Snapshot.ID)
+	assert.Equal(t, clone.TimeCreatedAt, clones["clone1"].TimeCreatedAt)
+
+	require.NoError(t, store.DeleteClone("clone1", newCloneEvent("clone1", models.CloneEventDestroyed, "")))
+
+	history, err := store.Events("clone1")
+	require.NoError(t, err)
+	require.Len(t, history, 3)
+	assert.Equal(t, []string{models.CloneEventCreated, models.CloneEventReset, models.CloneEventDestroyed},
+		[]string{history[0].Event, history[1].Event, history[2].Event})
+
+	history, err = store.Events("unknown")
+	require.NoError(t, err)
+	assert.Empty(t, history)
+
+	require.NoError(t, store.ReplaceClones(map[string]*CloneWrapper{"clone3": {Clone: &models.Clone{ID: "clone3"}}}))
+
+	clones, err = store.LoadClones()
+	require.NoError(t, err)
+	assert.Len(t, clones, 1)
+	assert.Contains(t, clones, "clone3")
+
+	assert.Error(t, store.SaveClone(&CloneWrapper{}))
+}
+
+func TestBoltStoreEvents(t *testing.T) {
+	store := newTestStore(t)
+
+	t.Run("it should keep a bounded history", func(t *testing.T) {
+		for i := 0; i < maxCloneEvents+10; i++ {
+			event := newCloneEvent("clone1", models.CloneEventStatusChanged, "")
+			event.Message = strconv.Itoa(i)
+
+			require.NoError(t, store.AddEvents(event))
+		}
+
+		events, err := store.Events("clone1")
+		require.NoError(t, err)
+		require.Len(t, events, maxCloneEvents)
+		assert.Equal(t, "10", events[0].Message)
+		assert.Equal(t, strconv.Itoa(maxCloneEvents+9), events[maxCloneEvents-1].Message)
+	})
+
+	t.Run("it should clean up the history of destroyed clones", func(t *testing.T) {
+		outdated := newCloneEvent("destroyed", models.CloneEventDestroyed, "")
+		outdated.Time = models.NewLocalTime(time.Now().Add(-time.Hour))
+
+		require.NoError(t, store.SaveClone(&CloneWrapper{Clone: &models.Clone{ID: "running"}}, models.CloneEvent{
+			CloneID: "running",
+			Event:   models.CloneEventCreated,
+			Time:    outdated.Time,
+		}))
+		require.NoError(t, store.AddEvents(outdated, newCloneEvent("recent", models.CloneEventDestroyed, "")))
+
+		require.NoError(t, store.CleanupEvents(time.Now().Add(-time.Minute)))
+
+		for cloneID, expected := range map[string]int{"running": 1, "recent": 1, "destroyed": 0} {
+			events, err := store.Events(cloneID)
+			require.NoError(t, err)
+			assert.Len(t, events, expected, cloneID)
+		}
+	})
+}
+
+func TestBoltStoreMigration(t *testing.T) {
+	store := newTestStore(t)
+
+	t.Run("it shouldn't fail if a sessions file is absent", func(t *testing.T) {
+		assert.NoError(t, store.MigrateSessions(path.Join(t.TempDir(), sessionsFilename)))
+	})
+
+	t.Run("it should import sessions only once", func(t *testing.T) {
+		sessionsPath := path.Join(t.TempDir(), sessionsFilename)
+
+		require.NoError(t, os.WriteFile(sessionsPath, []byte(testingCloneState), 0600))
+		require.NoError(t, store.MigrateSessions(sessionsPath))
+		require.NoError(t, store.DeleteClone("c5bfsk0hmvjd7kau71jg"))
+
+		// A sessions file left by a crash after the import must not bring destroyed clones back.
+		require.NoError(t, os.WriteFile(sessionsPath, []byte(testingCloneState), 0600))
+		require.NoError(t, store.MigrateSessions(sessionsPath))
+
+		clones, err := store.LoadClones()
+		require.NoError(t, err)
+		assert.Empty(t, clones)
+
+		history, err := store.Events("c5bfsk0hmvjd7kau71jg")
+		require.NoError(t, err)
+		require.Len(t, history, 1)
+		assert.Equal(t, models.CloneEventCreated, history[0].Event)
+		assert.Equal(t, "east5@snapshot_20211001112229", history[0].SnapshotID)
+	})
+
+	t.Run("it should fail if a sessions file is malformed", func(t *testing.T) {
+		sessionsPath := path.Join(t.TempDir(), sessionsFilename)
+
+		require.NoError(t, os.WriteFile(sessionsPath, []byte("{"), 0600))
+		assert.Error(t, store.MigrateSessions(sessionsPath))
+	})
+
+	t.Run("it should not open a locked store", func(t *testing.T) {
+		storePath := path.Join(t.TempDir(), stateStoreFilename)
+
+		first, err := NewBoltStore(storePath)
+		require.NoError(t, err)
+
+		defer func() { _ = first.Close() }()
+
+		_, err = NewBoltStore(storePath)
+		assert.Error(t, err)
+	})
+}
@@ -20,6 +20,9 @@ type CloneWrapper struct {
 
 	TimeCreatedAt time.Time `json:"time_created_at"`
 	TimeStartedAt time.Time `json:"time_started_at"`
+
+	// proxyConnections counts open connections routed to the clone by the Postgres proxy.
+	proxyConnections int64
 }
 
 // NewCloneWrapper constructs a new CloneWrapper.
@@ -30,6 +30,15 @@ type Config struct {
 	LogsRetentionDays int `yaml:"logsRetentionDays"`
 }
 
+// RetentionDays returns the number of days to keep logs.
+func (c Config) RetentionDays() int {
+	if c.LogsRetentionDays <= 0 {
+		return defaultLogsRetentionDays
+	}
+
+	return c.LogsRetentionDays
+}
+
 // Cleaner logs cleanup job.
 type Cleaner struct {
 	cleanerCron *cron.Cron
@@ -162,15 +171,11 @@ func (d *Cleaner) ScheduleLogCleanupJob(config Config) error {
 		return err
 	}
 
-	var logRetentionDays = config.LogsRetentionDays
-
-	if logRetentionDays < 0 {
-		return fmt.Errorf("invalid value for logsRetentionDays %d", logRetentionDays)
+	if config.LogsRetentionDays < 0 {
+		return fmt.Errorf("invalid value for logsRetentionDays %d", config.LogsRetentionDays)
 	}
 
-	if logRetentionDays == 0 {
-		logRetentionDays = defaultLogsRetentionDays
-	}
+	logRetentionDays := config.RetentionDays()
 
 	d.StopLogCleanupJob()
 
@@ -0,0 +1,87 @@
+/*
+2022 © Postgres.ai
+*/
+
+// Package events broadcasts changes of the instance state to the subscribers of the event stream.
+package events
+
+import (
+	"sync"
+	"time"
+
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/models"
+)
+
+// subscriptionBuffer defines how many events a subscriber may fall behind before it is dropped.
+const subscriptionBuffer = 64
+
+// Broker delivers published events to all subscribers.
+type Broker struct {
+	mu          sync.Mutex
+	subscribers map[*Subscription]struct{}
+}
+
+// Subscription receives events published after its creation.
+type Subscription struct {
+	ch chan models.StreamEvent
+}
+
+// Events returns the channel of events. The channel is closed when the subscriber is dropped or unsubscribed.
+func (s *Subscription) Events() <-chan models.StreamEvent {
+	return s.ch
+}
+
+// NewBroker creates a new event broker.
+func NewBroker() *Broker {
+	return &Broker{subscribers: make(map[*Subscription]struct{})}
+}
+
+// Publish delivers the event to subscribers without blocking. It is safe to call on a nil broker.
+//
+// A subscriber that does not keep up with the stream is dropped,
+// so it can reconnect and fetch the current state instead of receiving a gapped stream.
+func (b *Broker) Publish(event models.StreamEvent) {
+	if b == nil {
+		return
+	}
+
+	if event.Time == nil {
+		event.Time = models.NewLocalTime(time.Now())
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for sub := range b.subscribers {
+		select {
+		case sub.ch <- event:
+		default:
+			delete(b.subscribers, sub)
+			close(sub.ch)
+		}
+	}
+}
+
+// Subscribe registers a new subscriber.
+func (b *Broker) Subscribe() *Subscription {
+	sub := &Subscription{ch: make(chan models.StreamEvent, subscriptionBuffer)}
+
+	b.mu.Lock()
+	b.subscribers[sub] = struct{}{}
+	b.mu.Unlock()
+
+	return sub
+}
+
+// Unsubscribe removes the subscriber and closes its channel.
+func (b *Broker) Unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.subscribers[sub]; !ok {
+		return
+	}
+
+	delete(b.subscribers, sub)
+	close(sub.ch)
+}
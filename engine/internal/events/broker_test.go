@@ -0,0 +1,68 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/models"
+)
+
+func TestBrokerPublish(t *testing.T) {
+	broker := NewBroker()
+
+	first := broker.Subscribe()
+	second := broker.Subscribe()
+
+	broker.Publish(models.StreamEvent{Type: models.StreamCloneStatus, Clone: &models.Clone{ID: "clone1"}})
+
+	for _, sub := range []*Subscription{first, second} {
+		event := <-sub.Events()
+		assert.Equal(t, models.StreamCloneStatus, event.Type)
+		assert.Equal(t, "clone1", event.Clone.ID)
+		assert.NotNil(t, event.Time)
+	}
+
+	broker.Unsubscribe(first)
+
+	_, ok := <-first.Events()
+	assert.False(t, ok)
+
+	// Unsubscribing twice must not panic.
+	broker.Unsubscribe(first)
+
+	broker.Publish(models.StreamEvent{Type: models.StreamSnapshotAdded})
+
+	event := <-second.Events()
+	assert.Equal(t, models.StreamSnapshotAdded, event.Type)
+}
+
+func TestBrokerDropsSlowSubscriber(t *testing.T) {
+	broker := NewBroker()
+	sub := broker.Subscribe()
+
+	for i := 0; i <= subscriptionBuffer; i++ {
+		broker.Publish(models.StreamEvent{Type: models.StreamRetrievalStatus})
+	}
+
+	received := 0
+
+	for range sub.Events() {
+		received++
+	}
+
+	assert.Equal(t, subscriptionBuffer, received)
+
+	broker.mu.Lock()
+	require.Empty(t, broker.subscribers)
+	broker.mu.Unlock()
+
+	broker.Unsubscribe(sub)
+}
+
+func TestNilBroker(t *testing.T) {
+	var broker *Broker
+
+	assert.NotPanics(t, func() { broker.Publish(models.StreamEvent{Type: models.StreamCloneStatus}) })
+}
@@ -0,0 +1,219 @@
+/*
+2022 © Postgres.ai
+*/
+
+package pgproxy
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	// protocolVersion3 defines the code of the startup message of the protocol version 3.0.
+	protocolVersion3 = 196608
+
+	// sslRequestCode defines the code of SSLRequest.
+	sslRequestCode = 80877103
+
+	// gssEncRequestCode defines the code of GSSENCRequest.
+	gssEncRequestCode = 80877104
+
+	// cancelRequestCode defines the code of CancelRequest.
+	cancelRequestCode = 80877102
+
+	// maxStartupPacketLength limits the size of startup packets like Postgres does.
+	maxStartupPacketLength = 10000
+
+	// maxMessageLength limits the size of backend messages read before the connection is ready.
+	maxMessageLength = 1 << 24
+
+	backendKeyDataType = 'K'
+	readyForQueryType  = 'Z'
+	errorResponseType  = 'E'
+	sslAllowed         = 'S'
+	sslNotAllowed      = 'N'
+)
+
+// startupPacket represents an untyped packet sent by a client at the start of a connection.
+type startupPacket struct {
+	code    uint32
+	payload []byte
+}
+
+// readStartupPacket reads a length-prefixed startup packet.
+func readStartupPacket(r io.Reader) (*startupPacket, error) {
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+
+	length := binary.BigEndian.Uint32(header[:4])
+	if length < 8 || length > maxStartupPacketLength {
+		return nil, fmt.Errorf("invalid startup packet length: %d", length)
+	}
+
+	payload := make([]byte, length-8)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+
+	return &startupPacket{code: binary.BigEndian.Uint32(header[4:8]), payload: payload}, nil
+}
+
+// encode builds the wire representation of the packet.
+func (p *startupPacket) encode() []byte {
+	packet := make([]byte, 8, 8+len(p.payload))
+	binary.BigEndian.PutUint32(packet[:4], uint32(8+len(p.payload)))
+	binary.BigEndian.PutUint32(packet[4:8], p.code)
+
+	return append(packet, p.payload...)
+}
+
+// startupParams keeps parameters of a startup message in their original order.
+type startupParams struct {
+	keys   []string
+	values map[string]string
+}
+
+// parseStartupParams parses null-terminated name-value pairs of a startup message.
+func parseStartupParams(payload []byte) (*startupParams, error) {
+	params := &startupParams{values: make(map[string]string)}
+	fields := bytes.Split(payload, []byte{0})
+
+	// The list of parameters is terminated by an empty name, so the last two fields are empty.
+	if len(fields) < 2 || len(fields[len(fields)-1]) != 0 || len(fields[len(fields)-2]) != 0 {
+		return nil, errors.New("malformed startup message")
+	}
+
+	fields = fields[:len(fields)-2]
+
+	if len(fields)%2 != 0 {
+		return nil, errors.New("malformed startup message parameters")
+	}
+
+	for i := 0; i < len(fields); i += 2 {
+		params.set(string(fields[i]), string(fields[i+1]))
+	}
+
+	return params, nil
+}
+
+func (p *startupParams) get(key string) string {
+	return p.values[key]
+}
+
+func (p *startupParams) set(key, value string) {
+	if _, ok := p.values[key]; !ok {
+		p.keys = append(p.keys, key)
+	}
+
+	p.values[key] = value
+}
+
+func (p *startupParams) remove(key string) {
+	if _, ok := p.values[key]; !ok {
+		return
+	}
+
+	delete(p.values, key)
+
+	for i, k := range p.keys {
+		if k == key {
+			p.keys = append(p.keys[:i], p.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+func (p *startupParams) encode() []byte {
+	var buf bytes.Buffer
+
+	for _, key := range p.keys {
+		buf.WriteString(key)
+		buf.WriteByte(0)
+		buf.WriteString(p.values[key])
+		buf.WriteByte(0)
+	}
+
+	buf.WriteByte(0)
+
+	return buf.Bytes()
+}
+
+// message represents a typed protocol message.
+type message struct {
+	msgType byte
+	body    []byte
+}
+
+// readMessage reads a typed message sent by a backend.
+func readMessage(r io.Reader) (*message, error) {
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+
+	length := binary.BigEndian.Uint32(header[1:5])
+	if length < 4 || length > maxMessageLength {
+		return nil, fmt.Errorf("invalid message length: %d", length)
+	}
+
+	body := make([]byte, length-4)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+
+	return &message{msgType: header[0], body: body}, nil
+}
+
+func (m *message) encode() []byte {
+	packet := make([]byte, 5, 5+len(m.body))
+	packet[0] = m.msgType
+	binary.BigEndian.PutUint32(packet[1:5], uint32(4+len(m.body)))
+
+	return append(packet, m.body...)
+}
+
+// errorResponse builds a fatal ErrorResponse message.
+func errorResponse(code, text string) []byte {
+	var body bytes.Buffer
+
+	for _, field := range []struct {
+		name  byte
+		value string
+	}{
+		{name: 'S', value: "FATAL"},
+		{name: 'V', value: "FATAL"},
+		{name: 'C', value: code},
+		{name: 'M', value: text},
+	} {
+		body.WriteByte(field.name)
+		body.WriteString(field.value)
+		body.WriteByte(0)
+	}
+
+	body.WriteByte(0)
+
+	return (&message{msgType: errorResponseType, body: body.Bytes()}).encode()
+}
+
+// cancelKey identifies a backend process for cancel requests.
+type cancelKey struct {
+	processID uint32
+	secretKey uint32
+}
+
+func parseCancelKey(payload []byte) (cancelKey, error) {
+	if len(payload) != 8 {
+		return cancelKey{}, errors.New("malformed cancel key")
+	}
+
+	return cancelKey{
+		processID: binary.BigEndian.Uint32(payload[:4]),
+		secretKey: binary.BigEndian.Uint32(payload[4:8]),
+	}, nil
+}
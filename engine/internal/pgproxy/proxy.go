@@ -0,0 +1,334 @@
+/*
+2022 © Postgres.ai
+*/
+
+// Package pgproxy provides a Postgres protocol proxy that routes connections from a single port to clones.
+package pgproxy
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/log"
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/util"
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/util/tlsutil"
+)
+
+const (
+	startupTimeout = 30 * time.Second
+	dialTimeout    = 10 * time.Second
+
+	acceptRetryInterval = time.Second
+
+	defaultPort = 6432
+
+	// Error codes of Postgres sent to clients when a connection cannot be established.
+	codeConnectionRejected = "08004"
+	codeProtocolViolation  = "08P01"
+)
+
+// Config defines the configuration of the Postgres protocol proxy.
+type Config struct {
+	Enabled bool   `yaml:"enabled"`
+	Host    string `yaml:"host"`
+	Port    uint   `yaml:"port"`
+	// BackendHost overrides the host used to reach clone containers.
+	// By default, clones are reached by container names in the internal network of the engine.
+	BackendHost string `yaml:"backendHost"`
+	CertFile    string `yaml:"certFile"`
+	KeyFile     string `yaml:"keyFile"`
+}
+
+// CloneRegistry provides clones to route connections to and collects connection counts.
+type CloneRegistry interface {
+	CloneProxyPort(cloneID string) (uint, error)
+	ProxyConnectionOpened(cloneID string)
+	ProxyConnectionClosed(cloneID string)
+}
+
+// Proxy accepts Postgres connections on a single port and routes them to clones.
+type Proxy struct {
+	cfg        Config
+	registry   CloneRegistry
+	tlsConfig  *tls.Config
+	mu         sync.Mutex
+	cancelKeys map[cancelKey]string
+}
+
+// New creates a new Postgres protocol proxy.
+func New(cfg Config, registry CloneRegistry) (*Proxy, error) {
+	if cfg.Port == 0 {
+		cfg.Port = defaultPort
+	}
+
+	p := &Proxy{
+		cfg:        cfg,
+		registry:   registry,
+		cancelKeys: make(map[cancelKey]string),
+	}
+
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		cert, err := tlsutil.LoadKeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load a proxy certificate: %w", err)
+		}
+
+		p.tlsConfig = &tls.Config{MinVersion: tls.VersionTLS12, Certificates: []tls.Certificate{*cert}}
+	}
+
+	return p, nil
+}
+
+// Run listens on the configured address until the context is canceled.
+func (p *Proxy) Run(ctx context.Context) error {
+	listener, err := net.Listen("tcp", net.JoinHostPort(p.cfg.Host, strconv.FormatUint(uint64(p.cfg.Port), 10)))
+	if err != nil {
+		return fmt.Errorf("failed to start the Postgres proxy: %w", err)
+	}
+
+	log.Msg("Postgres proxy started listening on", listener.Addr().String())
+
+	return p.serve(ctx, listener)
+}
+
+func (p *Proxy) serve(ctx context.Context, listener net.Listener) error {
+	go func() {
+		<-ctx.Done()
+
+		if err := listener.Close(); err != nil {
+			log.Err("Failed to close the Postgres proxy listener:", err)
+		}
+	}()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+
+			log.Err("Failed to accept a Postgres connection:", err)
+			time.Sleep(acceptRetryInterval)
+
+			continue
+		}
+
+		go p.handleConn(conn)
+	}
+}
+
+func (p *Proxy) handleConn(conn net.Conn) {
+	var client net.Conn = conn
+
+	defer func() { _ = client.Close() }()
+
+	if err := conn.SetDeadline(time.Now().Add(startupTimeout)); err != nil {
+		log.Dbg("Failed to set a startup deadline:", err)
+		return
+	}
+
+	for {
+		packet, err := readStartupPacket(client)
+		if err != nil {
+			log.Dbg("Failed to read a startup packet:", err)
+			return
+		}
+
+		switch packet.code {
+		case sslRequestCode:
+			if _, isTLS := client.(*tls.Conn); isTLS || p.tlsConfig == nil {
+				if _, err := client.Write([]byte{sslNotAllowed}); err != nil {
+					return
+				}
+
+				continue
+			}
+
+			if _, err := client.Write([]byte{sslAllowed}); err != nil {
+				return
+			}
+
+			client = tls.Server(conn, p.tlsConfig)
+
+		case gssEncRequestCode:
+			if _, err := client.Write([]byte{sslNotAllowed}); err != nil {
+				return
+			}
+
+		case cancelRequestCode:
+			p.forwardCancel(packet)
+			return
+
+		case protocolVersion3:
+			if err := conn.SetDeadline(time.Time{}); err != nil {
+				return
+			}
+
+			p.proxyConnection(client, packet)
+
+			return
+
+		default:
+			_, _ = client.Write(errorResponse(codeProtocolViolation, "unsupported frontend protocol"))
+			return
+		}
+	}
+}
+
+// proxyConnection connects the client to the requested clone and relays traffic until one of the sides disconnects.
+func (p *Proxy) proxyConnection(client net.Conn, packet *startupPacket) {
+	params, err := parseStartupParams(packet.payload)
+	if err != nil {
+		_, _ = client.Write(errorResponse(codeProtocolViolation, err.Error()))
+		return
+	}
+
+	cloneID, err := routeStartup(params)
+	if err != nil {
+		_, _ = client.Write(errorResponse(codeConnectionRejected, err.Error()))
+		return
+	}
+
+	port, err := p.registry.CloneProxyPort(cloneID)
+	if err != nil {
+		_, _ = client.Write(errorResponse(codeConnectionRejected, fmt.Sprintf("clone %q is not available: %v", cloneID, err)))
+		return
+	}
+
+	backendAddr := p.backendAddr(port)
+
+	backend, err := net.DialTimeout("tcp", backendAddr, dialTimeout)
+	if err != nil {
+		log.Err(fmt.Sprintf("Failed to connect to clone %q: %v", cloneID, err))
+		_, _ = client.Write(errorResponse(codeConnectionRejected, fmt.Sprintf("failed to connect to clone %q", cloneID)))
+
+		return
+	}
+
+	defer func() { _ = backend.Close() }()
+
+	startup := &startupPacket{code: packet.code, payload: params.encode()}
+	if _, err := backend.Write(startup.encode()); err != nil {
+		_, _ = client.Write(errorResponse(codeConnectionRejected, fmt.Sprintf("failed to connect to clone %q", cloneID)))
+		return
+	}
+
+	p.registry.ProxyConnectionOpened(cloneID)
+	defer p.registry.ProxyConnectionClosed(cloneID)
+
+	clientDone := make(chan struct{})
+
+	go func() {
+		defer close(clientDone)
+
+		_, _ = io.Copy(backend, client)
+		_ = backend.Close()
+	}()
+
+	key, ready := p.relayStartup(client, backend)
+
+	if key != nil {
+		p.setCancelKey(*key, backendAddr)
+		defer p.removeCancelKey(*key)
+	}
+
+	if ready {
+		_, _ = io.Copy(client, backend)
+	}
+
+	_ = client.Close()
+
+	<-clientDone
+}
+
+// relayStartup forwards backend messages to the client until the backend is ready for queries
+// and catches the key data used to cancel queries.
+func (p *Proxy) relayStartup(client io.Writer, backend net.Conn) (*cancelKey, bool) {
+	var key *cancelKey
+
+	if err := backend.SetReadDeadline(time.Now().Add(startupTimeout)); err != nil {
+		return nil, false
+	}
+
+	for {
+		msg, err := readMessage(backend)
+		if err != nil {
+			return key, false
+		}
+
+		if msg.msgType == backendKeyDataType {
+			if parsed, err := parseCancelKey(msg.body); err == nil {
+				key = &parsed
+			}
+		}
+
+		if _, err := client.Write(msg.encode()); err != nil {
+			return key, false
+		}
+
+		switch msg.msgType {
+		case readyForQueryType:
+			return key, backend.SetReadDeadline(time.Time{}) == nil
+
+		case errorResponseType:
+			return key, false
+		}
+	}
+}
+
+// forwardCancel sends the cancel request to the backend that issued the key.
+func (p *Proxy) forwardCancel(packet *startupPacket) {
+	key, err := parseCancelKey(packet.payload)
+	if err != nil {
+		return
+	}
+
+	p.mu.Lock()
+	backendAddr, ok := p.cancelKeys[key]
+	p.mu.Unlock()
+
+	if !ok {
+		log.Dbg("Unknown cancel key, process ID:", key.processID)
+		return
+	}
+
+	backend, err := net.DialTimeout("tcp", backendAddr, dialTimeout)
+	if err != nil {
+		log.Err("Failed to forward a cancel request:", err)
+		return
+	}
+
+	defer func() { _ = backend.Close() }()
+
+	if _, err := backend.Write(packet.encode()); err != nil {
+		log.Err("Failed to forward a cancel request:", err)
+	}
+}
+
+func (p *Proxy) backendAddr(port uint) string {
+	host := p.cfg.BackendHost
+	if host == "" {
+		host = util.GetCloneName(port)
+	}
+
+	return net.JoinHostPort(host, strconv.FormatUint(uint64(port), 10))
+}
+
+func (p *Proxy) setCancelKey(key cancelKey, backendAddr string) {
+	p.mu.Lock()
+	p.cancelKeys[key] = backendAddr
+	p.mu.Unlock()
+}
+
+func (p *Proxy) removeCancelKey(key cancelKey) {
+	p.mu.Lock()
+	delete(p.cancelKeys, key)
+	p.mu.Unlock()
+}
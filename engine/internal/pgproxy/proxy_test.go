@@ -0,0 +1,311 @@
+package pgproxy
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouteStartup(t *testing.T) {
+	testCases := []struct {
+		params   map[string]string
+		cloneID  string
+		user     string
+		database string
+		options  string
+	}{
+		{
+			params:  map[string]string{"user": "john@clone1", "database": "test"},
+			cloneID: "clone1", user: "john", database: "test",
+		},
+		{
+			params:  map[string]string{"user": "john@example.com@clone1", "database": "test"},
+			cloneID: "clone1", user: "john@example.com", database: "test",
+		},
+		{
+			params:  map[string]string{"user": "john", "database": "test@clone2"},
+			cloneID: "clone2", user: "john", database: "test",
+		},
+		{
+			params:  map[string]string{"user": "john@other", "options": "-c dblab.clone=clone3 -c work_mem=64MB"},
+			cloneID: "clone3", user: "john@other", options: "-c work_mem=64MB",
+		},
+		{
+			params:  map[string]string{"user": "john", "options": "--dblab.clone=clone4"},
+			cloneID: "clone4", user: "john",
+		},
+	}
+
+	for _, tc := range testCases {
+		params := &startupParams{values: make(map[string]string)}
+		for _, key := range []string{"user", "database", "options"} {
+			if value, ok := tc.params[key]; ok {
+				params.set(key, value)
+			}
+		}
+
+		cloneID, err := routeStartup(params)
+		require.NoError(t, err)
+		assert.Equal(t, tc.cloneID, cloneID)
+		assert.Equal(t, tc.user, params.get("user"))
+		assert.Equal(t, tc.database, params.get("database"))
+		assert.Equal(t, tc.options, params.get("options"))
+	}
+
+	_, err := routeStartup(&startupParams{values: map[string]string{"user": "john"}})
+	assert.ErrorIs(t, err, errCloneNotSpecified)
+
+	_, err = routeStartup(&startupParams{values: map[string]string{"user": "john@"}})
+	assert.ErrorIs(t, err, errCloneNotSpecified)
+}
+
+func TestStartupParams(t *testing.T) {
+	params, err := parseStartupParams([]byte("user\x00john\x00database\x00test\x00\x00"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user", "database"}, params.keys)
+
+	params.set("user", "admin")
+	params.remove("database")
+	assert.Equal(t, []byte("user\x00admin\x00\x00"), params.encode())
+
+	_, err = parseStartupParams([]byte("user\x00john\x00"))
+	assert.Error(t, err)
+}
+
+type testRegistry struct {
+	mu          sync.Mutex
+	port        uint
+	connections map[string]int
+}
+
+func (r *testRegistry) CloneProxyPort(cloneID string) (uint, error) {
+	if cloneID != "clone1" {
+		return 0, errors.New("clone not found")
+	}
+
+	return r.port, nil
+}
+
+func (r *testRegistry) ProxyConnectionOpened(cloneID string) {
+	r.mu.Lock()
+	r.connections[cloneID]++
+	r.mu.Unlock()
+}
+
+func (r *testRegistry) ProxyConnectionClosed(cloneID string) {
+	r.mu.Lock()
+	r.connections[cloneID]--
+	r.mu.Unlock()
+}
+
+func (r *testRegistry) count(cloneID string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.connections[cloneID]
+}
+
+// fakeBackend imitates a Postgres server that trusts all users and answers every query with CommandComplete.
+type fakeBackend struct {
+	listener net.Listener
+	users    chan string
+	cancels  chan cancelKey
+}
+
+func newFakeBackend(t *testing.T) *fakeBackend {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = listener.Close() })
+
+	b := &fakeBackend{listener: listener, users: make(chan string, 1), cancels: make(chan cancelKey, 1)}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go b.handle(conn)
+		}
+	}()
+
+	return b
+}
+
+func (b *fakeBackend) port() uint {
+	return uint(b.listener.Addr().(*net.TCPAddr).Port)
+}
+
+func (b *fakeBackend) handle(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+
+	packet, err := readStartupPacket(conn)
+	if err != nil {
+		return
+	}
+
+	if packet.code == cancelRequestCode {
+		if key, err := parseCancelKey(packet.payload); err == nil {
+			b.cancels <- key
+		}
+
+		return
+	}
+
+	params, err := parseStartupParams(packet.payload)
+	if err != nil {
+		return
+	}
+
+	b.users <- params.get("user")
+
+	keyData := make([]byte, 8)
+	binary.BigEndian.PutUint32(keyData[:4], 42)
+	binary.BigEndian.PutUint32(keyData[4:], 7)
+
+	for _, msg := range []*message{
+		{msgType: 'R', body: []byte{0, 0, 0, 0}},
+		{msgType: backendKeyDataType, body: keyData},
+		{msgType: readyForQueryType, body: []byte{'I'}},
+	} {
+		if _, err := conn.Write(msg.encode()); err != nil {
+			return
+		}
+	}
+
+	for {
+		if _, err := readMessage(conn); err != nil {
+			return
+		}
+
+		if _, err := conn.Write((&message{msgType: 'C', body: []byte("SELECT 1\x00")}).encode()); err != nil {
+			return
+		}
+	}
+}
+
+func startTestProxy(t *testing.T, registry CloneRegistry) string {
+	t.Helper()
+
+	proxy, err := New(Config{BackendHost: "127.0.0.1"}, registry)
+	require.NoError(t, err)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	go func() { _ = proxy.serve(ctx, listener) }()
+
+	return listener.Addr().String()
+}
+
+func connectStartup(t *testing.T, addr, user string) net.Conn {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = conn.Close() })
+
+	require.NoError(t, conn.SetDeadline(time.Now().Add(5*time.Second)))
+
+	// Clients usually ask for SSL first.
+	_, err = conn.Write((&startupPacket{code: sslRequestCode}).encode())
+	require.NoError(t, err)
+
+	answer := make([]byte, 1)
+	_, err = conn.Read(answer)
+	require.NoError(t, err)
+	assert.Equal(t, byte(sslNotAllowed), answer[0])
+
+	params := &startupParams{values: make(map[string]string)}
+	params.set("user", user)
+	params.set("database", "test")
+
+	_, err = conn.Write((&startupPacket{code: protocolVersion3, payload: params.encode()}).encode())
+	require.NoError(t, err)
+
+	return conn
+}
+
+func TestProxy(t *testing.T) {
+	backend := newFakeBackend(t)
+	registry := &testRegistry{port: backend.port(), connections: make(map[string]int)}
+	addr := startTestProxy(t, registry)
+
+	conn := connectStartup(t, addr, "john@clone1")
+	assert.Equal(t, "john", <-backend.users)
+
+	var key cancelKey
+
+	for {
+		msg, err := readMessage(conn)
+		require.NoError(t, err)
+
+		if msg.msgType == backendKeyDataType {
+			key, err = parseCancelKey(msg.body)
+			require.NoError(t, err)
+		}
+
+		if msg.msgType == readyForQueryType {
+			break
+		}
+	}
+
+	assert.Equal(t, 1, registry.count("clone1"))
+
+	_, err := conn.Write((&message{msgType: 'Q', body: []byte("select 1\x00")}).encode())
+	require.NoError(t, err)
+
+	msg, err := readMessage(conn)
+	require.NoError(t, err)
+	assert.Equal(t, byte('C'), msg.msgType)
+
+	cancelConn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+
+	payload := make([]byte, 8)
+	binary.BigEndian.PutUint32(payload[:4], key.processID)
+	binary.BigEndian.PutUint32(payload[4:], key.secretKey)
+
+	_, err = cancelConn.Write((&startupPacket{code: cancelRequestCode, payload: payload}).encode())
+	require.NoError(t, err)
+	require.NoError(t, cancelConn.Close())
+
+	select {
+	case received := <-backend.cancels:
+		assert.Equal(t, cancelKey{processID: 42, secretKey: 7}, received)
+
+	case <-time.After(5 * time.Second):
+		t.Fatal("cancel request has not been forwarded")
+	}
+
+	require.NoError(t, conn.Close())
+
+	assert.Eventually(t, func() bool { return registry.count("clone1") == 0 }, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestProxyUnknownClone(t *testing.T) {
+	registry := &testRegistry{connections: make(map[string]int)}
+	addr := startTestProxy(t, registry)
+
+	conn := connectStartup(t, addr, "john@unknown")
+
+	msg, err := readMessage(conn)
+	require.NoError(t, err)
+	assert.Equal(t, byte(errorResponseType), msg.msgType)
+	assert.Contains(t, string(msg.body), codeConnectionRejected)
+}
@@ -0,0 +1,89 @@
+/*
+2022 © Postgres.ai
+*/
+
+package pgproxy
+
+import (
+	"errors"
+	"strings"
+)
+
+const (
+	// cloneOption defines the run-time option that selects a clone, e.g. "options=-c dblab.clone=my_clone".
+	cloneOption = "dblab.clone"
+
+	cloneSeparator = "@"
+)
+
+var errCloneNotSpecified = errors.New(`clone is not specified: use "user@clone_id", "dbname@clone_id" or "-c dblab.clone=clone_id"`)
+
+// routeStartup detects the clone requested in the startup parameters and removes routing hints from them.
+func routeStartup(params *startupParams) (string, error) {
+	if options, cloneID := extractCloneOption(params.get("options")); cloneID != "" {
+		if options == "" {
+			params.remove("options")
+		} else {
+			params.set("options", options)
+		}
+
+		return cloneID, nil
+	}
+
+	for _, key := range []string{"user", "database"} {
+		value := params.get(key)
+
+		idx := strings.LastIndex(value, cloneSeparator)
+		if idx <= 0 || idx == len(value)-1 {
+			continue
+		}
+
+		params.set(key, value[:idx])
+
+		return value[idx+1:], nil
+	}
+
+	return "", errCloneNotSpecified
+}
+
+// extractCloneOption finds the clone option in the command-line options and returns the options without it.
+// Both "-c dblab.clone=ID" and "--dblab.clone=ID" forms are supported.
+func extractCloneOption(options string) (string, string) {
+	fields := strings.Fields(options)
+	rest := make([]string, 0, len(fields))
+	cloneID := ""
+
+	for i := 0; i < len(fields); i++ {
+		field := fields[i]
+
+		if field == "-c" && i+1 < len(fields) {
+			if value, ok := cutCloneOption(fields[i+1]); ok {
+				cloneID = value
+				i++
+
+				continue
+			}
+		}
+
+		if strings.HasPrefix(field, "--") {
+			if value, ok := cutCloneOption(strings.TrimPrefix(field, "--")); ok {
+				cloneID = value
+				continue
+			}
+		}
+
+		rest = append(rest, field)
+	}
+
+	return strings.Join(rest, " "), cloneID
+}
+
+func cutCloneOption(option string) (string, bool) {
+	prefix := cloneOption + "="
+
+	if !strings.HasPrefix(option, prefix) {
+		return "", false
+	}
+
+	return strings.TrimPrefix(option, prefix), true
+}
@@ -0,0 +1,111 @@
+/*
+2022 © Postgres.ai
+*/
+
+package provision
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"gitlab.com/postgres-ai/database-lab/v3/internal/provision/resources"
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/log"
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/util"
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/util/tlsutil"
+)
+
+const (
+	cloneTLSDir          = "clone_tls"
+	generatedCertName    = "server.crt"
+	generatedKeyName     = "server.key"
+	generatedCertTTL     = 10 * 365 * 24 * time.Hour
+	generatedCertSSLMode = "require"
+	injectedCertSSLMode  = "verify-ca"
+)
+
+// CloneTLS defines TLS settings of clone containers.
+// If certificate files are not specified, the engine generates a self-signed certificate once and reuses it for all clones.
+type CloneTLS struct {
+	Enabled  bool   `yaml:"enabled"`
+	CertFile string `yaml:"certFile"`
+	KeyFile  string `yaml:"keyFile"`
+}
+
+func isValidCloneTLS(cfg CloneTLS) error {
+	if (cfg.CertFile == "") != (cfg.KeyFile == "") {
+		return errors.New(`both "cloneTLS.certFile" and "cloneTLS.keyFile" must be defined`)
+	}
+
+	return nil
+}
+
+// SSLMode returns the sslmode hint for clients connecting to clones.
+func (cfg CloneTLS) SSLMode() string {
+	switch {
+	case !cfg.Enabled:
+		return ""
+
+	case cfg.CertFile != "":
+		return injectedCertSSLMode
+
+	default:
+		return generatedCertSSLMode
+	}
+}
+
+// cloneTLSFiles returns the certificate files to install into clones, generating them if needed.
+func (p *Provisioner) cloneTLSFiles() (*resources.TLSFiles, error) {
+	cfg := p.config.CloneTLS
+
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	if cfg.CertFile != "" {
+		return &resources.TLSFiles{CertFile: cfg.CertFile, KeyFile: cfg.KeyFile}, nil
+	}
+
+	dir, err := util.GetMetaPath(cloneTLSDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get path of a clone certificate directory: %w", err)
+	}
+
+	return ensureGeneratedCert(dir, p.instanceID)
+}
+
+// ensureGeneratedCert generates a self-signed certificate in the directory unless it already exists.
+func ensureGeneratedCert(dir, commonName string) (*resources.TLSFiles, error) {
+	tlsFiles := &resources.TLSFiles{
+		CertFile: path.Join(dir, generatedCertName),
+		KeyFile:  path.Join(dir, generatedKeyName),
+	}
+
+	if _, err := tlsutil.LoadKeyPair(tlsFiles.CertFile, tlsFiles.KeyFile); err == nil {
+		return tlsFiles, nil
+	}
+
+	log.Msg("Generating a self-signed certificate for clones")
+
+	certPEM, keyPEM, err := tlsutil.GenerateSelfSigned(commonName, []string{"localhost", "127.0.0.1"}, generatedCertTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create a clone certificate directory: %w", err)
+	}
+
+	if err := os.WriteFile(tlsFiles.KeyFile, keyPEM, 0600); err != nil {
+		return nil, fmt.Errorf("failed to write a private key: %w", err)
+	}
+
+	if err := os.WriteFile(tlsFiles.CertFile, certPEM, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write a certificate: %w", err)
+	}
+
+	return tlsFiles, nil
+}
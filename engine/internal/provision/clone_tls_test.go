@@ -0,0 +1,39 @@
+package provision
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCloneTLSSSLMode(t *testing.T) {
+	assert.Empty(t, CloneTLS{}.SSLMode())
+	assert.Equal(t, "require", CloneTLS{Enabled: true}.SSLMode())
+	assert.Equal(t, "verify-ca", CloneTLS{Enabled: true, CertFile: "server.crt", KeyFile: "server.key"}.SSLMode())
+
+	assert.NoError(t, isValidCloneTLS(CloneTLS{Enabled: true}))
+	assert.Error(t, isValidCloneTLS(CloneTLS{Enabled: true, CertFile: "server.crt"}))
+}
+
+func TestEnsureGeneratedCert(t *testing.T) {
+	dir := t.TempDir()
+
+	tlsFiles, err := ensureGeneratedCert(dir, "instance")
+	require.NoError(t, err)
+
+	certPEM, err := os.ReadFile(tlsFiles.CertFile)
+	require.NoError(t, err)
+
+	keyInfo, err := os.Stat(tlsFiles.KeyFile)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), keyInfo.Mode().Perm())
+
+	again, err := ensureGeneratedCert(dir, "instance")
+	require.NoError(t, err)
+
+	regenerated, err := os.ReadFile(again.CertFile)
+	require.NoError(t, err)
+	assert.Equal(t, certPEM, regenerated)
+}
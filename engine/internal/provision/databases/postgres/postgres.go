@@ -46,7 +46,13 @@ const (
 func Start(r runners.Runner, c *resources.AppConfig) error {
 	log.Dbg("Starting Postgres container...")
 
-	if extraConf := c.ExtraConf(); len(extraConf) > 0 {
+	if c.TLS != nil {
+		if err := installCertificate(c.TLS, c.DataDir()); err != nil {
+			return errors.Wrap(err, "failed to install a server certificate")
+		}
+	}
+
+	if extraConf := buildExtraConf(c); len(extraConf) > 0 {
 		configManager, err := pgconfig.NewCorrector(c.DataDir())
 		if err != nil {
 			return errors.Wrap(err, "failed to create a config manager")
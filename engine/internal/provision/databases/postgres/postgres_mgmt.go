@@ -325,3 +325,12 @@ func restrictedObjectsQuery(username string) string {
 
 	return repl.Replace(restrictionTemplate)
 }
+
+// Checkpoint forces a checkpoint to flush the clone data to disk.
+func Checkpoint(c *resources.AppConfig) error {
+	if _, err := runSimpleSQL("checkpoint", getPgConnStr(c.Host, c.DB.DBName, c.DB.Username, c.Port)); err != nil {
+		return errors.Wrap(err, "failed to run checkpoint")
+	}
+
+	return nil
+}
@@ -0,0 +1,79 @@
+/*
+2022 © Postgres.ai
+*/
+
+package postgres
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"syscall"
+
+	"gitlab.com/postgres-ai/database-lab/v3/internal/provision/resources"
+)
+
+const (
+	// serverCertName defines the name of the server certificate inside the clone data directory.
+	serverCertName = "dblab_server.crt"
+
+	// serverKeyName defines the name of the server private key inside the clone data directory.
+	serverKeyName = "dblab_server.key"
+)
+
+// buildExtraConf merges the extra configuration of the clone with the SSL parameters.
+func buildExtraConf(c *resources.AppConfig) map[string]string {
+	extraConf := c.ExtraConf()
+
+	if c.TLS == nil {
+		return extraConf
+	}
+
+	conf := make(map[string]string, len(extraConf)+3)
+
+	for key, value := range extraConf {
+		conf[key] = value
+	}
+
+	// Relative paths are resolved against the data directory.
+	conf["ssl"] = "on"
+	conf["ssl_cert_file"] = serverCertName
+	conf["ssl_key_file"] = serverKeyName
+
+	return conf
+}
+
+// installCertificate copies the server certificate and its key into the data directory.
+// Postgres requires the key to be owned by the database user, so files inherit the owner of the data directory.
+func installCertificate(tlsFiles *resources.TLSFiles, dataDir string) error {
+	dataDirInfo, err := os.Stat(dataDir)
+	if err != nil {
+		return fmt.Errorf("failed to stat data directory: %w", err)
+	}
+
+	files := map[string]string{
+		tlsFiles.CertFile: serverCertName,
+		tlsFiles.KeyFile:  serverKeyName,
+	}
+
+	for src, name := range files {
+		content, err := os.ReadFile(src)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", src, err)
+		}
+
+		dst := path.Join(dataDir, name)
+
+		if err := os.WriteFile(dst, content, 0600); err != nil {
+			return fmt.Errorf("failed to write %s: %w", dst, err)
+		}
+
+		if stat, ok := dataDirInfo.Sys().(*syscall.Stat_t); ok {
+			if err := os.Chown(dst, int(stat.Uid), int(stat.Gid)); err != nil {
+				return fmt.Errorf("failed to change owner of %s: %w", dst, err)
+			}
+		}
+	}
+
+	return nil
+}
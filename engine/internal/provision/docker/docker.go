@@ -65,8 +65,10 @@ func RunContainer(r runners.Runner, c *resources.AppConfig) error {
 		return errors.Wrap(err, "failed to create socket clone directory")
 	}
 
-	containerFlags := make([]string, 0, len(c.ContainerConf))
-	for flagName, flagValue := range c.ContainerConf {
+	containerConf := buildContainerConf(c)
+
+	containerFlags := make([]string, 0, len(containerConf))
+	for flagName, flagValue := range containerConf {
 		containerFlags = append(containerFlags, fmt.Sprintf("--%s=%s", flagName, flagValue))
 	}
 
@@ -99,6 +101,37 @@ func RunContainer(r runners.Runner, c *resources.AppConfig) error {
 	return nil
 }
 
+// buildContainerConf merges the common container configuration with resource limits of the clone.
+func buildContainerConf(c *resources.AppConfig) map[string]string {
+	containerConf := make(map[string]string, len(c.ContainerConf))
+
+	for flagName, flagValue := range c.ContainerConf {
+		containerConf[flagName] = flagValue
+	}
+
+	if c.Resources == nil {
+		return containerConf
+	}
+
+	if c.Resources.CPUs > 0 {
+		containerConf["cpus"] = strconv.FormatFloat(c.Resources.CPUs, 'f', -1, 64)
+	}
+
+	if c.Resources.Memory > 0 {
+		containerConf["memory"] = strconv.FormatInt(c.Resources.Memory, 10)
+	}
+
+	if c.Resources.ShmSize > 0 {
+		containerConf["shm-size"] = strconv.FormatInt(c.Resources.ShmSize, 10)
+	}
+
+	if c.Resources.BlkioWeight > 0 {
+		containerConf["blkio-weight"] = strconv.FormatUint(uint64(c.Resources.BlkioWeight), 10)
+	}
+
+	return containerConf
+}
+
 func createDefaultVolumes(c *resources.AppConfig) (string, []string) {
 	unixSocketCloneDir := c.Pool.SocketCloneDir(c.CloneName)
 
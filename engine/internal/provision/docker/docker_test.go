@@ -94,3 +94,24 @@ func TestDefaultVolumes(t *testing.T) {
 		"--volume /tmp/test/default:/tmp/test/default",
 		"--volume /tmp/test/default/socket:/tmp/test/default/socket"}, volumes)
 }
+
+func TestContainerConfWithResources(t *testing.T) {
+	appConfig := &resources.AppConfig{
+		ContainerConf: map[string]string{"shm-size": "1gb", "ulimit": "nofile=1024"},
+	}
+
+	assert.Equal(t, map[string]string{"shm-size": "1gb", "ulimit": "nofile=1024"}, buildContainerConf(appConfig))
+
+	appConfig.Resources = &resources.ContainerResources{CPUs: 1.5, Memory: 2 << 30, ShmSize: 512 << 20, BlkioWeight: 300}
+
+	assert.Equal(t, map[string]string{
+		"shm-size":     "536870912",
+		"ulimit":       "nofile=1024",
+		"cpus":         "1.5",
+		"memory":       "2147483648",
+		"blkio-weight": "300",
+	}, buildContainerConf(appConfig))
+
+	// The common configuration stays untouched.
+	assert.Equal(t, "1gb", appConfig.ContainerConf["shm-size"])
+}
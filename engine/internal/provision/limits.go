@@ -0,0 +1,149 @@
+/*
+2022 © Postgres.ai
+*/
+
+package provision
+
+import (
+	"fmt"
+
+	"github.com/docker/go-units"
+	"github.com/pkg/errors"
+
+	"gitlab.com/postgres-ai/database-lab/v3/internal/provision/resources"
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/client/dblabapi/types"
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/models"
+)
+
+const (
+	minBlkioWeight = 10
+	maxBlkioWeight = 1000
+)
+
+// ResourceLimits defines the maximum resources that can be requested for a single clone. Zero values mean no limits.
+type ResourceLimits struct {
+	CPUs        float64 `yaml:"cpus"`
+	Memory      string  `yaml:"memory"`
+	ShmSize     string  `yaml:"shmSize"`
+	BlkioWeight uint16  `yaml:"blkioWeight"`
+}
+
+// parseResourceLimits converts the configured limits to container resources.
+func parseResourceLimits(limits ResourceLimits) (resources.ContainerResources, error) {
+	memory, err := parseSize(limits.Memory)
+	if err != nil {
+		return resources.ContainerResources{}, errors.Wrap(err, `invalid "cloneResourceLimits.memory"`)
+	}
+
+	shmSize, err := parseSize(limits.ShmSize)
+	if err != nil {
+		return resources.ContainerResources{}, errors.Wrap(err, `invalid "cloneResourceLimits.shmSize"`)
+	}
+
+	if limits.CPUs < 0 {
+		return resources.ContainerResources{}, errors.New(`"cloneResourceLimits.cpus" must not be negative`)
+	}
+
+	return resources.ContainerResources{
+		CPUs:        limits.CPUs,
+		Memory:      memory,
+		ShmSize:     shmSize,
+		BlkioWeight: limits.BlkioWeight,
+	}, nil
+}
+
+// ParseResourcesRequest converts requested clone resources to container resources.
+func ParseResourcesRequest(request *types.ResourcesRequest) (*resources.ContainerResources, error) {
+	if request == nil {
+		return nil, nil
+	}
+
+	if request.CPUs < 0 {
+		return nil, errors.New("the number of CPUs must not be negative")
+	}
+
+	if request.BlkioWeight != 0 && (request.BlkioWeight < minBlkioWeight || request.BlkioWeight > maxBlkioWeight) {
+		return nil, fmt.Errorf("block IO weight must be in the range from %d to %d", minBlkioWeight, maxBlkioWeight)
+	}
+
+	memory, err := parseSize(request.Memory)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid memory limit")
+	}
+
+	shmSize, err := parseSize(request.ShmSize)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid shared memory size")
+	}
+
+	return &resources.ContainerResources{
+		CPUs:        request.CPUs,
+		Memory:      memory,
+		ShmSize:     shmSize,
+		BlkioWeight: request.BlkioWeight,
+	}, nil
+}
+
+// ApplyResourceLimits checks if the requested clone resources do not exceed the configured limits
+// and returns the resources of the clone container.
+// Resources that have not been requested are capped by the configured limits.
+func (p *Provisioner) ApplyResourceLimits(containerResources *resources.ContainerResources) (*resources.ContainerResources, error) {
+	limits, err := parseResourceLimits(p.config.CloneResourceLimits)
+	if err != nil {
+		return nil, err
+	}
+
+	if containerResources == nil {
+		if limits == (resources.ContainerResources{}) {
+			return nil, nil
+		}
+
+		return &limits, nil
+	}
+
+	if limits.CPUs > 0 && containerResources.CPUs > limits.CPUs {
+		return nil, models.New(models.ErrCodeBadRequest, fmt.Sprintf("requested CPUs exceed the limit of %g", limits.CPUs))
+	}
+
+	if limits.Memory > 0 && containerResources.Memory > limits.Memory {
+		return nil, models.New(models.ErrCodeBadRequest, "requested memory exceeds the limit of "+p.config.CloneResourceLimits.Memory)
+	}
+
+	if limits.ShmSize > 0 && containerResources.ShmSize > limits.ShmSize {
+		return nil, models.New(models.ErrCodeBadRequest,
+			"requested shared memory size exceeds the limit of "+p.config.CloneResourceLimits.ShmSize)
+	}
+
+	if limits.BlkioWeight > 0 && containerResources.BlkioWeight > limits.BlkioWeight {
+		return nil, models.New(models.ErrCodeBadRequest, fmt.Sprintf("requested block IO weight exceeds the limit of %d", limits.BlkioWeight))
+	}
+
+	applied := *containerResources
+
+	// Zero values mean no limits, so the missing ones are capped by the configured limits.
+	if applied.CPUs == 0 {
+		applied.CPUs = limits.CPUs
+	}
+
+	if applied.Memory == 0 {
+		applied.Memory = limits.Memory
+	}
+
+	if applied.ShmSize == 0 {
+		applied.ShmSize = limits.ShmSize
+	}
+
+	if applied.BlkioWeight == 0 {
+		applied.BlkioWeight = limits.BlkioWeight
+	}
+
+	return &applied, nil
+}
+
+func parseSize(size string) (int64, error) {
+	if size == "" {
+		return 0, nil
+	}
+
+	return units.RAMInBytes(size)
+}
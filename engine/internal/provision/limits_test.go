@@ -0,0 +1,86 @@
+package provision
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/postgres-ai/database-lab/v3/internal/provision/resources"
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/client/dblabapi/types"
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/models"
+)
+
+func TestParseResourcesRequest(t *testing.T) {
+	containerResources, err := ParseResourcesRequest(nil)
+	require.NoError(t, err)
+	assert.Nil(t, containerResources)
+
+	containerResources, err = ParseResourcesRequest(&types.ResourcesRequest{CPUs: 1.5, Memory: "2g", ShmSize: "512m", BlkioWeight: 300})
+	require.NoError(t, err)
+	assert.Equal(t, &resources.ContainerResources{CPUs: 1.5, Memory: 2 << 30, ShmSize: 512 << 20, BlkioWeight: 300}, containerResources)
+
+	invalidRequests := []types.ResourcesRequest{
+		{CPUs: -1},
+		{Memory: "two gigabytes"},
+		{ShmSize: "-1"},
+		{BlkioWeight: 5},
+		{BlkioWeight: 1001},
+	}
+
+	for _, request := range invalidRequests {
+		request := request
+
+		_, err := ParseResourcesRequest(&request)
+		assert.Error(t, err, request)
+	}
+}
+
+func TestApplyResourceLimits(t *testing.T) {
+	p := &Provisioner{config: &Config{CloneResourceLimits: ResourceLimits{CPUs: 2, Memory: "4g", BlkioWeight: 500}}}
+
+	containerResources, err := p.ApplyResourceLimits(nil)
+	require.NoError(t, err)
+	assert.Equal(t, &resources.ContainerResources{CPUs: 2, Memory: 4 << 30, BlkioWeight: 500}, containerResources)
+
+	containerResources, err = p.ApplyResourceLimits(&resources.ContainerResources{CPUs: 2, Memory: 4 << 30, ShmSize: 8 << 30, BlkioWeight: 500})
+	require.NoError(t, err)
+	assert.Equal(t, &resources.ContainerResources{CPUs: 2, Memory: 4 << 30, ShmSize: 8 << 30, BlkioWeight: 500}, containerResources)
+
+	containerResources, err = p.ApplyResourceLimits(&resources.ContainerResources{CPUs: 1, ShmSize: 1 << 30})
+	require.NoError(t, err)
+	assert.Equal(t, &resources.ContainerResources{CPUs: 1, Memory: 4 << 30, ShmSize: 1 << 30, BlkioWeight: 500}, containerResources)
+
+	unlimited := &Provisioner{config: &Config{}}
+
+	containerResources, err = unlimited.ApplyResourceLimits(nil)
+	require.NoError(t, err)
+	assert.Nil(t, containerResources)
+
+	exceedingResources := []resources.ContainerResources{
+		{CPUs: 2.5},
+		{Memory: 4<<30 + 1},
+		{BlkioWeight: 1000},
+	}
+
+	for _, containerResources := range exceedingResources {
+		containerResources := containerResources
+
+		_, err := p.ApplyResourceLimits(&containerResources)
+		require.Error(t, err)
+
+		var modelErr *models.Error
+		assert.True(t, errors.As(err, &modelErr))
+		assert.Equal(t, models.ErrCodeBadRequest, modelErr.Code)
+	}
+}
+
+func TestInvalidResourceLimits(t *testing.T) {
+	cfg := Config{
+		PortPool:            PortPool{From: 6000, To: 6002},
+		CloneResourceLimits: ResourceLimits{Memory: "a lot"},
+	}
+
+	assert.Error(t, IsValidConfig(cfg))
+}
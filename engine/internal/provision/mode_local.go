@@ -27,6 +27,7 @@ import (
 	"gitlab.com/postgres-ai/database-lab/v3/internal/provision/pool"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/provision/resources"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/provision/runners"
+	"gitlab.com/postgres-ai/database-lab/v3/internal/provision/thinclones"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/retrieval/engine/postgres/tools"
 	"gitlab.com/postgres-ai/database-lab/v3/pkg/log"
 	"gitlab.com/postgres-ai/database-lab/v3/pkg/models"
@@ -49,11 +50,13 @@ type PortPool struct {
 
 // Config defines configuration for provisioning.
 type Config struct {
-	PortPool          PortPool          `yaml:"portPool"`
-	DockerImage       string            `yaml:"dockerImage"`
-	UseSudo           bool              `yaml:"useSudo"`
-	KeepUserPasswords bool              `yaml:"keepUserPasswords"`
-	ContainerConfig   map[string]string `yaml:"containerConfig"`
+	PortPool            PortPool          `yaml:"portPool"`
+	DockerImage         string            `yaml:"dockerImage"`
+	UseSudo             bool              `yaml:"useSudo"`
+	KeepUserPasswords   bool              `yaml:"keepUserPasswords"`
+	ContainerConfig     map[string]string `yaml:"containerConfig"`
+	CloneResourceLimits ResourceLimits    `yaml:"cloneResourceLimits"`
+	CloneTLS            CloneTLS          `yaml:"cloneTLS"`
 }
 
 // Provisioner describes a struct for ports and clones management.
@@ -116,6 +119,14 @@ func isValidConfigModeLocal(config Config) error {
 		return errors.New(`"portPool" must include at least one port`)
 	}
 
+	if _, err := parseResourceLimits(config.CloneResourceLimits); err != nil {
+		return err
+	}
+
+	if err := isValidCloneTLS(config.CloneTLS); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -148,7 +159,7 @@ func (p *Provisioner) ContainerOptions() models.ContainerOptions {
 
 // StartSession starts a new session.
 func (p *Provisioner) StartSession(snapshotID string, user resources.EphemeralUser,
-	extraConfig map[string]string) (*resources.Session, error) {
+	extraConfig map[string]string, containerResources *resources.ContainerResources) (*resources.Session, error) {
 	snapshot, err := p.getSnapshot(snapshotID)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to get snapshots")
@@ -184,6 +195,11 @@ func (p *Provisioner) StartSession(snapshotID string, user resources.EphemeralUs
 
 	appConfig := p.getAppConfig(fsm.Pool(), name, port)
 	appConfig.SetExtraConf(extraConfig)
+	appConfig.Resources = containerResources
+
+	if appConfig.TLS, err = p.cloneTLSFiles(); err != nil {
+		return nil, errors.Wrap(err, "failed to prepare a server certificate")
+	}
 
 	if err = postgres.Start(p.runner, appConfig); err != nil {
 		return nil, errors.Wrap(err, "failed to start a container")
@@ -203,6 +219,8 @@ func (p *Provisioner) StartSession(snapshotID string, user resources.EphemeralUs
 		SocketHost:    appConfig.Host,
 		EphemeralUser: user,
 		ExtraConfig:   extraConfig,
+		Resources:     containerResources,
+		SSLMode:       p.config.CloneTLS.SSLMode(),
 	}
 
 	return session, nil
@@ -280,6 +298,13 @@ func (p *Provisioner) ResetSession(session *resources.Session, snapshotID string
 
 	appConfig := p.getAppConfig(newFSManager.Pool(), name, session.Port)
 	appConfig.SetExtraConf(session.ExtraConfig)
+	appConfig.Resources = session.Resources
+
+	if appConfig.TLS, err = p.cloneTLSFiles(); err != nil {
+		return nil, errors.Wrap(err, "failed to prepare a server certificate")
+	}
+
+	session.SSLMode = p.config.CloneTLS.SSLMode()
 
 	if err = postgres.Start(p.runner, appConfig); err != nil {
 		return nil, errors.Wrap(err, "failed to start container")
@@ -298,6 +323,80 @@ func (p *Provisioner) ResetSession(session *resources.Session, snapshotID string
 	return snapshotModel, nil
 }
 
+// SnapshotSession checkpoints the session database and takes a snapshot of the clone data.
+func (p *Provisioner) SnapshotSession(session *resources.Session) (*resources.Snapshot, error) {
+	fsm, err := p.pm.GetFSManager(session.Pool)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find a filesystem manager of this session")
+	}
+
+	name := util.GetCloneName(session.Port)
+	appConfig := p.getAppConfig(fsm.Pool(), name, session.Port)
+
+	if err := postgres.Checkpoint(appConfig); err != nil {
+		return nil, errors.Wrap(err, "failed to checkpoint the clone database")
+	}
+
+	snapshotID, err := fsm.CreateSnapshot(name, time.Now().Format(util.DataStateAtFormat))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create a snapshot of the clone")
+	}
+
+	if snapshotID == "" {
+		return nil, errors.Errorf("pool %s does not support snapshots of clones", fsm.Pool().Name)
+	}
+
+	for _, snapshot := range fsm.SnapshotList() {
+		if snapshot.ID == snapshotID {
+			return &snapshot, nil
+		}
+	}
+
+	return nil, errors.Errorf("snapshot %q not found", snapshotID)
+}
+
+// DestroySnapshot destroys the snapshot.
+func (p *Provisioner) DestroySnapshot(snapshotID string) error {
+	fsm, err := p.getSnapshotFSManager(snapshotID)
+	if err != nil {
+		return err
+	}
+
+	if err := fsm.DestroySnapshot(snapshotID, thinclones.DestroyOptions{}); err != nil {
+		return errors.Wrap(err, "failed to destroy snapshot")
+	}
+
+	return nil
+}
+
+// ProtectSnapshot changes the protection of the snapshot from the retention.
+func (p *Provisioner) ProtectSnapshot(snapshotID string, protected bool) error {
+	fsm, err := p.getSnapshotFSManager(snapshotID)
+	if err != nil {
+		return err
+	}
+
+	if err := fsm.ProtectSnapshot(snapshotID, protected); err != nil {
+		return errors.Wrap(err, "failed to protect snapshot")
+	}
+
+	return nil
+}
+
+func (p *Provisioner) getSnapshotFSManager(snapshotID string) (pool.FSManager, error) {
+	snapshot, err := p.getSnapshot(snapshotID)
+	if err != nil {
+		return nil, err
+	}
+
+	fsm, err := p.pm.GetFSManager(snapshot.Pool)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find a filesystem manager of this snapshot")
+	}
+
+	return fsm, nil
+}
+
 // GetSnapshots provides a snapshot list from active pools.
 func (p *Provisioner) GetSnapshots() ([]resources.Snapshot, error) {
 	snapshots := []resources.Snapshot{}
@@ -510,6 +609,20 @@ func (p *Provisioner) FreePort(port uint) error {
 	return p.setPortStatus(port, false)
 }
 
+// PortPoolUsage returns the size of the port pool and the number of occupied ports.
+func (p *Provisioner) PortPoolUsage() (total, used int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, bind := range p.ports {
+		if bind {
+			used++
+		}
+	}
+
+	return len(p.ports), used
+}
+
 // setPortStatus updates the port status.
 // It's not safe to invoke without ports mutex locking. Use allocatePort and FreePort methods.
 func (p *Provisioner) setPortStatus(port uint, bind bool) error {
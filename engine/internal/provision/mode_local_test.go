@@ -13,6 +13,7 @@ import (
 	"gitlab.com/postgres-ai/database-lab/v3/internal/provision/pool"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/provision/resources"
 
+	"gitlab.com/postgres-ai/database-lab/v3/internal/provision/thinclones"
 	"gitlab.com/postgres-ai/database-lab/v3/pkg/models"
 )
 
@@ -38,6 +39,10 @@ func TestPortAllocation(t *testing.T) {
 	_, err = p.allocatePort()
 	require.NoError(t, err)
 
+	total, used := p.PortPoolUsage()
+	assert.Equal(t, 2, total)
+	assert.Equal(t, 2, used)
+
 	// Impossible allocate a new port.
 	_, err = p.allocatePort()
 	assert.IsType(t, errors.Cause(err), &NoRoomError{})
@@ -76,7 +81,11 @@ func (m mockFSManager) CreateSnapshot(poolSuffix, dataStateAt string) (snapshotN
 	return "", nil
 }
 
-func (m mockFSManager) DestroySnapshot(snapshotName string) (err error) {
+func (m mockFSManager) DestroySnapshot(snapshotName string, opts thinclones.DestroyOptions) (err error) {
+	return nil
+}
+
+func (m mockFSManager) ProtectSnapshot(snapshotName string, protected bool) error {
 	return nil
 }
 
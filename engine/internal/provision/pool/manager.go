@@ -13,6 +13,9 @@ import (
 
 	"gitlab.com/postgres-ai/database-lab/v3/internal/provision/resources"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/provision/runners"
+	"gitlab.com/postgres-ai/database-lab/v3/internal/provision/thinclones"
+	"gitlab.com/postgres-ai/database-lab/v3/internal/provision/thinclones/btrfs"
+	"gitlab.com/postgres-ai/database-lab/v3/internal/provision/thinclones/dir"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/provision/thinclones/lvm"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/provision/thinclones/zfs"
 	"gitlab.com/postgres-ai/database-lab/v3/pkg/log"
@@ -43,7 +46,8 @@ type StateReporter interface {
 // Snapshotter describes methods of snapshot management.
 type Snapshotter interface {
 	CreateSnapshot(poolSuffix, dataStateAt string) (snapshotName string, err error)
-	DestroySnapshot(snapshotName string) (err error)
+	DestroySnapshot(snapshotName string, opts thinclones.DestroyOptions) (err error)
+	ProtectSnapshot(snapshotName string, protected bool) error
 	CleanupSnapshots(retentionLimit int) ([]string, error)
 	SnapshotList() []resources.Snapshot
 	RefreshSnapshotList()
@@ -77,10 +81,32 @@ func NewManager(runner runners.Runner, config ManagerConfig) (FSManager, error)
 		manager = zfs.NewFSManager(runner, zfsConfig)
 
 	case lvm.PoolMode:
-		if manager, err = lvm.NewFSManager(runner, config.Pool); err != nil {
+		lvmConfig := lvm.Config{Pool: config.Pool, PreSnapshotSuffix: config.PreSnapshotSuffix}
+
+		if manager, err = lvm.NewFSManager(runner, lvmConfig); err != nil {
 			return nil, errors.Wrap(err, "failed to initialize LVM thin-clone manager")
 		}
 
+	case btrfs.PoolMode:
+		btrfsConfig, err := buildBtrfsConfig(config)
+		if err != nil {
+			return nil, err
+		}
+
+		if manager, err = btrfs.NewFSManager(runner, btrfsConfig); err != nil {
+			return nil, errors.Wrap(err, "failed to initialize Btrfs thin-clone manager")
+		}
+
+	case dir.PoolMode:
+		dirConfig, err := buildDirConfig(config)
+		if err != nil {
+			return nil, err
+		}
+
+		if manager, err = dir.NewFSManager(runner, dirConfig); err != nil {
+			return nil, errors.Wrap(err, "failed to initialize directory thin-clone manager")
+		}
+
 	default:
 		return nil, fmt.Errorf(`unsupported thin-clone manager specified: "%s"`, config.Pool.Mode)
 	}
@@ -104,7 +130,27 @@ func BuildFromExistingManager(fsm FSManager, config ManagerConfig) (FSManager, e
 		fsm = manager
 
 	case *lvm.LVManager:
-		manager.UpdateConfig(config.Pool)
+		manager.UpdateConfig(lvm.Config{Pool: config.Pool, PreSnapshotSuffix: config.PreSnapshotSuffix})
+
+		fsm = manager
+
+	case *btrfs.Manager:
+		btrfsConfig, err := buildBtrfsConfig(config)
+		if err != nil {
+			return nil, err
+		}
+
+		manager.UpdateConfig(btrfsConfig)
+
+		fsm = manager
+
+	case *dir.Manager:
+		dirConfig, err := buildDirConfig(config)
+		if err != nil {
+			return nil, err
+		}
+
+		manager.UpdateConfig(dirConfig)
 
 		fsm = manager
 
@@ -127,3 +173,29 @@ func buildZFSConfig(config ManagerConfig) (zfs.Config, error) {
 		OSUsername:        osUser.Username,
 	}, nil
 }
+
+func buildBtrfsConfig(config ManagerConfig) (btrfs.Config, error) {
+	osUser, err := user.Current()
+	if err != nil {
+		return btrfs.Config{}, fmt.Errorf("failed to get current user: %w", err)
+	}
+
+	return btrfs.Config{
+		Pool:              config.Pool,
+		PreSnapshotSuffix: config.PreSnapshotSuffix,
+		OSUsername:        osUser.Username,
+	}, nil
+}
+
+func buildDirConfig(config ManagerConfig) (dir.Config, error) {
+	osUser, err := user.Current()
+	if err != nil {
+		return dir.Config{}, fmt.Errorf("failed to get current user: %w", err)
+	}
+
+	return dir.Config{
+		Pool:              config.Pool,
+		PreSnapshotSuffix: config.PreSnapshotSuffix,
+		OSUsername:        osUser.Username,
+	}, nil
+}
@@ -17,6 +17,8 @@ import (
 
 	"gitlab.com/postgres-ai/database-lab/v3/internal/provision/resources"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/provision/runners"
+	"gitlab.com/postgres-ai/database-lab/v3/internal/provision/thinclones/btrfs"
+	"gitlab.com/postgres-ai/database-lab/v3/internal/provision/thinclones/dir"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/provision/thinclones/lvm"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/provision/thinclones/zfs"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/retrieval/dbmarker"
@@ -49,6 +51,7 @@ type Config struct {
 	ObserverSubDir    string `yaml:"observerSubDir"`
 	PreSnapshotSuffix string `yaml:"preSnapshotSuffix"`
 	SelectedPool      string `yaml:"selectedPool"`
+	Mode              string `yaml:"mode"`
 }
 
 // NewPoolManager creates a new pool manager.
@@ -278,13 +281,19 @@ func (pm *Manager) examineEntries(entries []os.DirEntry) (map[string]FSManager,
 			continue
 		}
 
-		fsType, err := pm.getFSInfo(dataPath)
-		if err != nil {
-			log.Msg("failed to get a filesystem info: ", err.Error())
-			continue
+		fsType := pm.cfg.Mode
+
+		if fsType == "" {
+			detectedType, err := pm.getFSInfo(dataPath)
+			if err != nil {
+				log.Msg("failed to get a filesystem info: ", err.Error())
+				continue
+			}
+
+			fsType = detectedType
 		}
 
-		if fsType != zfs.PoolMode && fsType != lvm.PoolMode {
+		if fsType != zfs.PoolMode && fsType != lvm.PoolMode && fsType != btrfs.PoolMode && fsType != dir.PoolMode {
 			log.Msg("Unsupported filesystem: ", fsType, entry.Name())
 			continue
 		}
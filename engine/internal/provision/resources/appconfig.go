@@ -19,9 +19,17 @@ type AppConfig struct {
 	NetworkID   string
 
 	ContainerConf map[string]string
+	Resources     *ContainerResources
+	TLS           *TLSFiles
 	pgExtraConf   map[string]string
 }
 
+// TLSFiles describes a server certificate and its private key to install into a clone.
+type TLSFiles struct {
+	CertFile string
+	KeyFile  string
+}
+
 // DB describes a default database configuration.
 type DB struct {
 	Username string
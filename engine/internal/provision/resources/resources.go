@@ -15,11 +15,21 @@ type Session struct {
 	Pool string `json:"pool"`
 
 	// Database.
-	Port          uint              `json:"port"`
-	User          string            `json:"user"`
-	SocketHost    string            `json:"socketHost"`
-	EphemeralUser EphemeralUser     `json:"ephemeralUser"`
-	ExtraConfig   map[string]string `json:"extraConfig"`
+	Port          uint                `json:"port"`
+	User          string              `json:"user"`
+	SocketHost    string              `json:"socketHost"`
+	EphemeralUser EphemeralUser       `json:"ephemeralUser"`
+	ExtraConfig   map[string]string   `json:"extraConfig"`
+	Resources     *ContainerResources `json:"resources,omitempty"`
+	SSLMode       string              `json:"sslMode,omitempty"`
+}
+
+// ContainerResources describes resource limits of a clone container. Zero values mean no limits.
+type ContainerResources struct {
+	CPUs        float64 `json:"cpus"`
+	Memory      int64   `json:"memory"`
+	ShmSize     int64   `json:"shmSize"`
+	BlkioWeight uint16  `json:"blkioWeight"`
 }
 
 // EphemeralUser describes an ephemeral database user defined by Database Lab users.
@@ -39,6 +49,7 @@ type Snapshot struct {
 	Used              uint64
 	LogicalReferenced uint64
 	Pool              string
+	Protected         bool
 }
 
 // SessionState defines current state of a Session.
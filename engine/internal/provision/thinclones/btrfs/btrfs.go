@@ -0,0 +1,689 @@
+/*
+2022 © Postgres.ai
+*/
+
+// Package btrfs provides an interface to work with Btrfs subvolumes and snapshots.
+package btrfs
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"gitlab.com/postgres-ai/database-lab/v3/internal/provision/resources"
+	"gitlab.com/postgres-ai/database-lab/v3/internal/provision/runners"
+	"gitlab.com/postgres-ai/database-lab/v3/internal/provision/thinclones"
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/log"
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/models"
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/util"
+)
+
+const (
+	// PoolMode defines the btrfs filesystem name.
+	PoolMode = "btrfs"
+
+	snapshotsDir    = ".snapshots"
+	snapshotPrefix  = "snapshot_"
+	protectedSuffix = ".protected"
+)
+
+// Manager describes a filesystem manager for Btrfs.
+//
+// The pool directory is a subvolume. Snapshots are read-only subvolume snapshots stored in the ".snapshots" directory of the pool,
+// clones are writable snapshots stored in the clone directory of the pool. Snapshot IDs follow the ZFS naming,
+// so "pool@snapshot_20220101000000" is a snapshot of the pool and "pool/dblab_clone_6000@snapshot_20220101000000" is a snapshot of a clone.
+type Manager struct {
+	runner    runners.Runner
+	config    Config
+	mu        *sync.Mutex
+	snapshots []resources.Snapshot
+}
+
+// Config defines configuration for Btrfs filesystem manager.
+type Config struct {
+	Pool              *resources.Pool
+	PreSnapshotSuffix string
+	OSUsername        string
+}
+
+// NewFSManager creates a new Manager instance for Btrfs and enables quota groups to track sizes of clones and snapshots.
+func NewFSManager(runner runners.Runner, config Config) (*Manager, error) {
+	m := Manager{
+		runner:    runner,
+		config:    config,
+		mu:        &sync.Mutex{},
+		snapshots: make([]resources.Snapshot, 0),
+	}
+
+	if _, err := m.runner.Run("btrfs quota enable "+m.poolDir(), true); err != nil {
+		return nil, errors.Wrap(err, "failed to enable quota groups")
+	}
+
+	return &m, nil
+}
+
+// Pool gets a storage pool.
+func (m *Manager) Pool() *resources.Pool {
+	return m.config.Pool
+}
+
+// UpdateConfig updates the manager's configuration.
+func (m *Manager) UpdateConfig(cfg Config) {
+	m.config = cfg
+}
+
+// CreateClone creates a new writable snapshot of the snapshot.
+func (m *Manager) CreateClone(cloneName, snapshotID string) error {
+	snapshotPath, err := m.snapshotPath(snapshotID)
+	if err != nil {
+		return err
+	}
+
+	subvolumes, err := m.listSubvolumes()
+	if err != nil {
+		return fmt.Errorf("cannot check the clone existence: %w", err)
+	}
+
+	if _, ok := m.findClone(subvolumes, cloneName); ok {
+		return fmt.Errorf("clone %q is already exists. Skip creation", cloneName)
+	}
+
+	clonePath := m.clonePath(cloneName)
+
+	cmd := "mkdir -p " + m.config.Pool.ClonesDir() + " && " +
+		"btrfs subvolume snapshot " + snapshotPath + " " + clonePath + " && " +
+		"chown -R " + m.config.OSUsername + " " + clonePath
+
+	out, err := m.runner.Run(cmd)
+	if err != nil {
+		return errors.Wrapf(err, "btrfs clone error. Out: %v", out)
+	}
+
+	return nil
+}
+
+// DestroyClone destroys the clone subvolume and the snapshots taken from it.
+func (m *Manager) DestroyClone(cloneName string) error {
+	subvolumes, err := m.listSubvolumes()
+	if err != nil {
+		return errors.Wrap(err, "failed to list subvolumes")
+	}
+
+	if _, ok := m.findClone(subvolumes, cloneName); !ok {
+		log.Msg(fmt.Sprintf("clone %q is not exists. Skip deletion", cloneName))
+		return nil
+	}
+
+	for _, snapshotID := range m.cloneSnapshots(subvolumes, cloneName) {
+		if err := m.DestroySnapshot(snapshotID, thinclones.DestroyOptions{}); err != nil {
+			return errors.Wrap(err, "failed to destroy snapshot of the clone")
+		}
+	}
+
+	if _, err := m.runner.Run("btrfs subvolume delete " + m.clonePath(cloneName)); err != nil {
+		return errors.Wrap(err, "failed to run command")
+	}
+
+	return nil
+}
+
+// ListClonesNames lists clones of the pool.
+func (m *Manager) ListClonesNames() ([]string, error) {
+	subvolumes, err := m.listSubvolumes()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list clones")
+	}
+
+	cloneNames := []string{}
+
+	for _, subvolume := range subvolumes {
+		cloneName, ok := m.cloneName(subvolume)
+		if ok && strings.HasPrefix(cloneName, util.ClonePrefix) {
+			cloneNames = append(cloneNames, cloneName)
+		}
+	}
+
+	return util.Unique(cloneNames), nil
+}
+
+// CreateSnapshot creates a new read-only snapshot of the pool or of the clone if the pool suffix is specified.
+func (m *Manager) CreateSnapshot(poolSuffix, dataStateAt string) (string, error) {
+	sourcePath := m.poolDir()
+
+	if poolSuffix != "" {
+		sourcePath = m.clonePath(poolSuffix)
+	}
+
+	if dataStateAt == "" {
+		dataStateAt = time.Now().Format(util.DataStateAtFormat)
+	}
+
+	snapshotName := m.snapshotName(poolSuffix, dataStateAt)
+
+	snapshotPath, err := m.snapshotPath(snapshotName)
+	if err != nil {
+		return "", err
+	}
+
+	subvolumes, err := m.listSubvolumes()
+	if err != nil {
+		return "", fmt.Errorf("failed to get a snapshot list: %w", err)
+	}
+
+	for _, subvolume := range subvolumes {
+		if id, ok := m.snapshotID(subvolume); ok && id == snapshotName {
+			return "", thinclones.NewSnapshotExistsError(snapshotName)
+		}
+	}
+
+	cmd := "mkdir -p " + path.Dir(snapshotPath) + " && " +
+		"btrfs subvolume snapshot -r " + sourcePath + " " + snapshotPath
+
+	if _, err := m.runner.Run(cmd, true); err != nil {
+		return "", errors.Wrap(err, "failed to create snapshot")
+	}
+
+	dataStateTime, err := util.ParseCustomTime(strings.TrimSuffix(dataStateAt, m.config.PreSnapshotSuffix))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse dataStateAt: %w", err)
+	}
+
+	newSnapshot := resources.Snapshot{
+		ID:          snapshotName,
+		CreatedAt:   time.Now(),
+		DataStateAt: dataStateTime,
+		Pool:        m.config.Pool.Name,
+	}
+
+	if !strings.HasSuffix(snapshotName, m.config.PreSnapshotSuffix) {
+		m.addSnapshotToList(newSnapshot)
+
+		log.Dbg("New snapshot:", newSnapshot)
+
+		m.RefreshSnapshotList()
+	}
+
+	return snapshotName, nil
+}
+
+// DestroySnapshot destroys the snapshot.
+func (m *Manager) DestroySnapshot(snapshotName string, _ thinclones.DestroyOptions) error {
+	snapshotPath, err := m.snapshotPath(snapshotName)
+	if err != nil {
+		return err
+	}
+
+	if _, err := m.runner.Run("btrfs subvolume delete " + snapshotPath); err != nil {
+		return errors.Wrap(err, "failed to run command")
+	}
+
+	if err := os.Remove(snapshotPath + protectedSuffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Err("failed to remove the protection mark of snapshot:", err)
+	}
+
+	m.removeSnapshotFromList(snapshotName)
+
+	return nil
+}
+
+// ProtectSnapshot marks the snapshot as protected from the retention or removes the mark.
+func (m *Manager) ProtectSnapshot(snapshotName string, protected bool) error {
+	snapshotPath, err := m.snapshotPath(snapshotName)
+	if err != nil {
+		return err
+	}
+
+	if protected {
+		err = os.WriteFile(snapshotPath+protectedSuffix, nil, 0600)
+	} else if err = os.Remove(snapshotPath + protectedSuffix); errors.Is(err, os.ErrNotExist) {
+		err = nil
+	}
+
+	if err != nil {
+		return errors.Wrap(err, "failed to change the protection of snapshot")
+	}
+
+	m.RefreshSnapshotList()
+
+	return nil
+}
+
+// CleanupSnapshots destroys old snapshots of the pool considering retention limit and related clones.
+// Pre-clones created from the destroyed snapshots are destroyed as well.
+func (m *Manager) CleanupSnapshots(retentionLimit int) ([]string, error) {
+	subvolumes, err := m.listSubvolumes()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list subvolumes")
+	}
+
+	destroyed := []string{}
+
+	for _, candidate := range m.getRetentionCandidates(subvolumes, retentionLimit) {
+		if err := m.destroyWithPreClones(subvolumes, candidate); err != nil {
+			return destroyed, errors.Wrap(err, "failed to clean up snapshots")
+		}
+
+		destroyed = append(destroyed, candidate)
+	}
+
+	m.RefreshSnapshotList()
+
+	return destroyed, nil
+}
+
+// getRetentionCandidates returns snapshots of the pool exceeding the retention limit
+// that are neither protected nor used by user clones.
+func (m *Manager) getRetentionCandidates(subvolumes []subvolume, retentionLimit int) []string {
+	poolSnapshots := map[string]subvolume{}
+	snapshotIDs := []string{}
+
+	for _, subvolume := range subvolumes {
+		if id, ok := m.snapshotID(subvolume); ok && !strings.Contains(id, "/") {
+			poolSnapshots[id] = subvolume
+			snapshotIDs = append(snapshotIDs, id)
+		}
+	}
+
+	dataStateAt := func(snapshotID string) time.Time {
+		return m.dataStateAt(poolSnapshots[snapshotID])
+	}
+
+	return thinclones.RetentionCandidates(snapshotIDs, retentionLimit, dataStateAt, func(snapshotID string) bool {
+		return m.isBusySnapshot(subvolumes, poolSnapshots[snapshotID])
+	})
+}
+
+// isBusySnapshot checks if the snapshot or snapshots of pre-clones created from it are protected or used by user clones.
+func (m *Manager) isBusySnapshot(subvolumes []subvolume, snapshot subvolume) bool {
+	related := []subvolume{snapshot}
+
+	for _, preClone := range m.dependentClones(subvolumes, snapshot.UUID) {
+		cloneName, _ := m.cloneName(preClone)
+
+		if strings.HasPrefix(cloneName, util.ClonePrefix) {
+			return true
+		}
+
+		for _, subvolume := range subvolumes {
+			if id, ok := m.snapshotID(subvolume); ok && strings.HasPrefix(id, m.config.Pool.Name+"/"+cloneName+"@") {
+				related = append(related, subvolume)
+			}
+		}
+	}
+
+	for _, relatedSnapshot := range related {
+		id, _ := m.snapshotID(relatedSnapshot)
+
+		if m.isProtected(id) {
+			return true
+		}
+
+		for _, clone := range m.dependentClones(subvolumes, relatedSnapshot.UUID) {
+			if cloneName, _ := m.cloneName(clone); strings.HasPrefix(cloneName, util.ClonePrefix) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// destroyWithPreClones destroys the snapshot and pre-clones created from it.
+func (m *Manager) destroyWithPreClones(subvolumes []subvolume, snapshotID string) error {
+	for _, snapshot := range subvolumes {
+		if id, ok := m.snapshotID(snapshot); !ok || id != snapshotID {
+			continue
+		}
+
+		for _, preClone := range m.dependentClones(subvolumes, snapshot.UUID) {
+			cloneName, _ := m.cloneName(preClone)
+
+			if err := m.DestroyClone(cloneName); err != nil {
+				return err
+			}
+		}
+	}
+
+	return m.DestroySnapshot(snapshotID, thinclones.DestroyOptions{})
+}
+
+// dependentClones returns clones created from the subvolume with the given UUID.
+func (m *Manager) dependentClones(subvolumes []subvolume, uuid string) []subvolume {
+	clones := []subvolume{}
+
+	for _, subvolume := range subvolumes {
+		if _, ok := m.cloneName(subvolume); ok && subvolume.ParentUUID == uuid {
+			clones = append(clones, subvolume)
+		}
+	}
+
+	return clones
+}
+
+// cloneSnapshots returns IDs of snapshots taken from the clone.
+func (m *Manager) cloneSnapshots(subvolumes []subvolume, cloneName string) []string {
+	snapshotIDs := []string{}
+	prefix := m.config.Pool.Name + "/" + cloneName + "@"
+
+	for _, subvolume := range subvolumes {
+		if id, ok := m.snapshotID(subvolume); ok && strings.HasPrefix(id, prefix) {
+			snapshotIDs = append(snapshotIDs, id)
+		}
+	}
+
+	return snapshotIDs
+}
+
+// GetSessionState returns a state of a session.
+func (m *Manager) GetSessionState(name string) (*resources.SessionState, error) {
+	subvolumes, err := m.listSubvolumes()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list subvolumes")
+	}
+
+	clone, ok := m.findClone(subvolumes, name)
+	if !ok {
+		return nil, errors.New("cannot get session state: specified clone does not exist")
+	}
+
+	qgroups, err := m.listQGroups()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list quota groups")
+	}
+
+	state := &resources.SessionState{
+		CloneDiffSize:     qgroups[clone.ID].Exclusive,
+		LogicalReferenced: qgroups[clone.ID].Referenced,
+	}
+
+	return state, nil
+}
+
+// GetFilesystemState returns a disk state.
+func (m *Manager) GetFilesystemState() (models.FileSystem, error) {
+	out, err := m.runner.Run("btrfs filesystem usage -b "+m.poolDir(), false)
+	if err != nil {
+		return models.FileSystem{}, errors.Wrap(err, "failed to get filesystem usage")
+	}
+
+	usage, err := parseFilesystemUsage(out)
+	if err != nil {
+		return models.FileSystem{}, err
+	}
+
+	subvolumes, err := m.listSubvolumes()
+	if err != nil {
+		return models.FileSystem{}, errors.Wrap(err, "failed to list subvolumes")
+	}
+
+	qgroups, err := m.listQGroups()
+	if err != nil {
+		return models.FileSystem{}, errors.Wrap(err, "failed to list quota groups")
+	}
+
+	fileSystem := models.FileSystem{
+		Mode:          PoolMode,
+		Size:          usage.Size,
+		Free:          usage.Free,
+		Used:          usage.Used,
+		CompressRatio: 1,
+	}
+
+	if poolID, err := m.poolSubvolumeID(); err == nil {
+		fileSystem.DataSize = qgroups[poolID].Referenced
+	}
+
+	for _, subvolume := range subvolumes {
+		if _, ok := m.snapshotID(subvolume); ok {
+			fileSystem.UsedBySnapshots += qgroups[subvolume.ID].Exclusive
+			continue
+		}
+
+		if _, ok := m.cloneName(subvolume); ok {
+			fileSystem.UsedByClones += qgroups[subvolume.ID].Exclusive
+		}
+	}
+
+	return fileSystem, nil
+}
+
+// SnapshotList returns a list of snapshots.
+func (m *Manager) SnapshotList() []resources.Snapshot {
+	m.mu.Lock()
+	snapshots := m.snapshots
+	m.mu.Unlock()
+
+	return snapshots
+}
+
+// RefreshSnapshotList updates the list of snapshots.
+func (m *Manager) RefreshSnapshotList() {
+	snapshots, err := m.getSnapshots()
+	if err != nil {
+		log.Err("Failed to refresh snapshot list: ", err)
+		return
+	}
+
+	m.mu.Lock()
+	m.snapshots = snapshots
+	m.mu.Unlock()
+}
+
+func (m *Manager) getSnapshots() ([]resources.Snapshot, error) {
+	subvolumes, err := m.listSubvolumes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots: %w", err)
+	}
+
+	qgroups, err := m.listQGroups()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list quota groups: %w", err)
+	}
+
+	snapshots := make([]resources.Snapshot, 0, len(subvolumes))
+
+	for _, subvolume := range subvolumes {
+		id, ok := m.snapshotID(subvolume)
+
+		// Filter pre-snapshots, they will not be allowed to be used for cloning.
+		if !ok || strings.HasSuffix(id, m.config.PreSnapshotSuffix) {
+			continue
+		}
+
+		snapshots = append(snapshots, resources.Snapshot{
+			ID:                id,
+			CreatedAt:         subvolume.CreatedAt,
+			DataStateAt:       m.dataStateAt(subvolume),
+			Used:              qgroups[subvolume.ID].Exclusive,
+			LogicalReferenced: qgroups[subvolume.ID].Referenced,
+			Pool:              m.config.Pool.Name,
+			Protected:         m.isProtected(id),
+		})
+	}
+
+	sort.SliceStable(snapshots, func(i, j int) bool {
+		if snapshots[i].DataStateAt.Equal(snapshots[j].DataStateAt) {
+			return snapshots[i].CreatedAt.After(snapshots[j].CreatedAt)
+		}
+
+		return snapshots[i].DataStateAt.After(snapshots[j].DataStateAt)
+	})
+
+	return snapshots, nil
+}
+
+func (m *Manager) addSnapshotToList(snapshot resources.Snapshot) {
+	m.mu.Lock()
+	m.snapshots = append([]resources.Snapshot{snapshot}, m.snapshots...)
+	m.mu.Unlock()
+}
+
+func (m *Manager) removeSnapshotFromList(snapshotName string) {
+	m.mu.Lock()
+
+	for i, snapshot := range m.snapshots {
+		if snapshot.ID == snapshotName {
+			m.snapshots = append((m.snapshots)[:i], (m.snapshots)[i+1:]...)
+
+			break
+		}
+	}
+
+	m.mu.Unlock()
+}
+
+func (m *Manager) isProtected(snapshotID string) bool {
+	snapshotPath, err := m.snapshotPath(snapshotID)
+	if err != nil {
+		return false
+	}
+
+	_, err = os.Stat(snapshotPath + protectedSuffix)
+
+	return err == nil
+}
+
+func (m *Manager) listSubvolumes() ([]subvolume, error) {
+	// Snapshots and clones are direct children of the pool subvolume, and all of them are snapshots.
+	out, err := m.runner.Run("btrfs subvolume list -o -s -u -q "+m.poolDir(), false)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseSubvolumeList(out)
+}
+
+func (m *Manager) listQGroups() (map[string]qgroup, error) {
+	out, err := m.runner.Run("btrfs qgroup show --raw "+m.poolDir(), false)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseQGroups(out), nil
+}
+
+func (m *Manager) poolSubvolumeID() (string, error) {
+	out, err := m.runner.Run("btrfs inspect-internal rootid "+m.poolDir(), false)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get the subvolume ID of pool")
+	}
+
+	return strings.TrimSpace(out), nil
+}
+
+func (m *Manager) findClone(subvolumes []subvolume, cloneName string) (subvolume, bool) {
+	for _, subvolume := range subvolumes {
+		if name, ok := m.cloneName(subvolume); ok && name == cloneName {
+			return subvolume, true
+		}
+	}
+
+	return subvolume{}, false
+}
+
+// snapshotID builds the snapshot ID if the subvolume is located in the snapshot directory.
+func (m *Manager) snapshotID(subvolume subvolume) (string, bool) {
+	relativePath := m.relativePath(subvolume.Path)
+
+	if !strings.HasPrefix(relativePath, snapshotsDir+"/") {
+		return "", false
+	}
+
+	relativePath = strings.TrimPrefix(relativePath, snapshotsDir+"/")
+	dataset := m.config.Pool.Name
+
+	if dir := path.Dir(relativePath); dir != "." {
+		dataset += "/" + dir
+	}
+
+	return dataset + "@" + path.Base(relativePath), true
+}
+
+// cloneName returns the clone name if the subvolume is located in the clone directory.
+func (m *Manager) cloneName(subvolume subvolume) (string, bool) {
+	relativePath := m.relativePath(subvolume.Path)
+
+	if strings.HasPrefix(relativePath, snapshotsDir+"/") || path.Dir(relativePath) != path.Clean(m.config.Pool.CloneSubDir) {
+		return "", false
+	}
+
+	return path.Base(relativePath), true
+}
+
+// relativePath trims the subvolume path to the pool directory.
+// Btrfs prints paths relative to the filesystem root, so the prefix differs if the pool is a mounted subvolume.
+func (m *Manager) relativePath(subvolumePath string) string {
+	for _, dir := range []string{snapshotsDir, path.Clean(m.config.Pool.CloneSubDir)} {
+		if strings.HasPrefix(subvolumePath, dir+"/") {
+			return subvolumePath
+		}
+
+		if idx := strings.Index(subvolumePath, "/"+dir+"/"); idx != -1 {
+			return subvolumePath[idx+1:]
+		}
+	}
+
+	return subvolumePath
+}
+
+func (m *Manager) dataStateAt(subvolume subvolume) time.Time {
+	id, _ := m.snapshotID(subvolume)
+
+	dataStateAt := id[strings.LastIndex(id, "@")+1:]
+	dataStateAt = strings.TrimPrefix(dataStateAt, snapshotPrefix)
+	dataStateAt = strings.TrimSuffix(dataStateAt, m.config.PreSnapshotSuffix)
+
+	dataStateTime, err := util.ParseCustomTime(dataStateAt)
+	if err != nil {
+		return subvolume.CreatedAt
+	}
+
+	return dataStateTime
+}
+
+func (m *Manager) snapshotName(poolSuffix, dataStateAt string) string {
+	dataset := m.config.Pool.Name
+
+	if poolSuffix != "" {
+		dataset += "/" + poolSuffix
+	}
+
+	return fmt.Sprintf("%s@%s%s", dataset, snapshotPrefix, dataStateAt)
+}
+
+// snapshotPath returns the path of the snapshot subvolume.
+func (m *Manager) snapshotPath(snapshotID string) (string, error) {
+	const snapshotIDParts = 2
+
+	parts := strings.SplitN(snapshotID, "@", snapshotIDParts)
+	if len(parts) != snapshotIDParts || !strings.HasPrefix(parts[1], snapshotPrefix) || strings.Contains(parts[1], "/") {
+		return "", fmt.Errorf("invalid snapshot ID %q", snapshotID)
+	}
+
+	dataset, name := parts[0], parts[1]
+
+	if dataset == m.config.Pool.Name {
+		return path.Join(m.poolDir(), snapshotsDir, name), nil
+	}
+
+	poolSuffix := strings.TrimPrefix(dataset, m.config.Pool.Name+"/")
+	if poolSuffix == dataset || poolSuffix == "" || poolSuffix == "." || poolSuffix == ".." || strings.Contains(poolSuffix, "/") {
+		return "", fmt.Errorf("snapshot %q does not belong to pool %q", snapshotID, m.config.Pool.Name)
+	}
+
+	return path.Join(m.poolDir(), snapshotsDir, poolSuffix, name), nil
+}
+
+func (m *Manager) poolDir() string {
+	return path.Join(m.config.Pool.MountDir, m.config.Pool.PoolDirName)
+}
+
+func (m *Manager) clonePath(cloneName string) string {
+	return path.Join(m.config.Pool.ClonesDir(), cloneName)
+}
@@ -0,0 +1,135 @@
+//go:build integration
+// +build integration
+
+/*
+2022 © Postgres.ai
+*/
+
+package btrfs
+
+import (
+	"os"
+	"os/exec"
+	"os/user"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/postgres-ai/database-lab/v3/internal/provision/resources"
+	"gitlab.com/postgres-ai/database-lab/v3/internal/provision/runners"
+)
+
+const imageSize = "512M"
+
+// mountLoopbackImage creates a Btrfs filesystem in a loopback file image and mounts it to the pool directory.
+func mountLoopbackImage(t *testing.T, poolDir string) {
+	t.Helper()
+
+	if os.Geteuid() != 0 {
+		t.Skip("mounting a loopback image requires root privileges")
+	}
+
+	if _, err := exec.LookPath("mkfs.btrfs"); err != nil {
+		t.Skip("mkfs.btrfs is not installed")
+	}
+
+	imageFile := path.Join(t.TempDir(), "btrfs.img")
+
+	require.NoError(t, os.MkdirAll(poolDir, 0700))
+	require.NoError(t, exec.Command("truncate", "--size", imageSize, imageFile).Run())
+	require.NoError(t, exec.Command("mkfs.btrfs", "-q", imageFile).Run())
+	require.NoError(t, exec.Command("mount", "-o", "loop", imageFile, poolDir).Run())
+
+	t.Cleanup(func() {
+		_ = exec.Command("umount", poolDir).Run()
+	})
+}
+
+func TestBtrfsLoopbackImage(t *testing.T) {
+	mountDir := t.TempDir()
+	pool := &resources.Pool{
+		Name:        "dblab_pool",
+		Mode:        PoolMode,
+		MountDir:    mountDir,
+		PoolDirName: "dblab_pool",
+		CloneSubDir: "clones",
+		DataSubDir:  "data",
+	}
+
+	mountLoopbackImage(t, path.Join(mountDir, pool.PoolDirName))
+
+	osUser, err := user.Current()
+	require.NoError(t, err)
+
+	m, err := NewFSManager(runners.NewLocalRunner(false), Config{Pool: pool, PreSnapshotSuffix: "_pre", OSUsername: osUser.Username})
+	require.NoError(t, err)
+
+	require.NoError(t, os.MkdirAll(pool.DataDir(), 0700))
+	require.NoError(t, os.WriteFile(path.Join(pool.DataDir(), "PG_VERSION"), []byte("14"), 0600))
+
+	// Snapshots of the pool.
+	snapshotID, err := m.CreateSnapshot("", "20220127100000")
+	require.NoError(t, err)
+	assert.Equal(t, "dblab_pool@snapshot_20220127100000", snapshotID)
+
+	_, err = m.CreateSnapshot("", "20220127100000")
+	require.Error(t, err)
+
+	oldSnapshotID, err := m.CreateSnapshot("", "20220127090000")
+	require.NoError(t, err)
+
+	snapshots := m.SnapshotList()
+	require.Len(t, snapshots, 2)
+	assert.Equal(t, snapshotID, snapshots[0].ID)
+
+	// Clones.
+	const cloneName = "dblab_clone_6000"
+
+	require.NoError(t, m.CreateClone(cloneName, snapshotID))
+
+	content, err := os.ReadFile(path.Join(pool.ClonesDir(), cloneName, pool.DataSubDir, "PG_VERSION"))
+	require.NoError(t, err)
+	assert.Equal(t, "14", string(content))
+
+	require.NoError(t, os.WriteFile(path.Join(pool.ClonesDir(), cloneName, pool.DataSubDir, "diff"), make([]byte, 1<<20), 0600))
+	require.NoError(t, exec.Command("sync").Run())
+
+	cloneNames, err := m.ListClonesNames()
+	require.NoError(t, err)
+	assert.Equal(t, []string{cloneName}, cloneNames)
+
+	_, err = m.GetSessionState(cloneName)
+	require.NoError(t, err)
+
+	fileSystem, err := m.GetFilesystemState()
+	require.NoError(t, err)
+	assert.Equal(t, PoolMode, fileSystem.Mode)
+	assert.NotZero(t, fileSystem.Size)
+
+	// Snapshots of clones.
+	cloneSnapshotID, err := m.CreateSnapshot(cloneName, "20220127110000")
+	require.NoError(t, err)
+	assert.Equal(t, "dblab_pool/dblab_clone_6000@snapshot_20220127110000", cloneSnapshotID)
+
+	// Retention keeps snapshots used by clones and protected snapshots.
+	require.NoError(t, m.ProtectSnapshot(oldSnapshotID, true))
+
+	destroyed, err := m.CleanupSnapshots(0)
+	require.NoError(t, err)
+	assert.Empty(t, destroyed)
+
+	require.NoError(t, m.ProtectSnapshot(oldSnapshotID, false))
+
+	destroyed, err = m.CleanupSnapshots(0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{oldSnapshotID}, destroyed)
+
+	require.NoError(t, m.DestroyClone(cloneName))
+
+	cloneNames, err = m.ListClonesNames()
+	require.NoError(t, err)
+	assert.Empty(t, cloneNames)
+	assert.Len(t, m.SnapshotList(), 1)
+}
@@ -0,0 +1,171 @@
+package btrfs
+
+import (
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/postgres-ai/database-lab/v3/internal/provision/resources"
+)
+
+type runnerMock struct {
+	cmdOutput string
+	err       error
+}
+
+func (r runnerMock) Run(string, ...bool) (string, error) {
+	return r.cmdOutput, r.err
+}
+
+const subvolumeListOutput = `ID 257 gen 20 cgen 10 top level 5 otime 2022-01-27 10:00:00 parent_uuid - uuid a1 path .snapshots/snapshot_20220127100000
+ID 258 gen 21 cgen 11 top level 5 otime 2022-01-27 11:00:00 parent_uuid - uuid a2 path .snapshots/snapshot_20220127110000_pre
+ID 259 gen 22 cgen 12 top level 5 otime 2022-01-27 11:00:01 parent_uuid a2 uuid a3 path clones/clone_pre_20220127110000
+ID 260 gen 23 cgen 13 top level 5 otime 2022-01-27 11:05:00 parent_uuid a3 uuid a4 path .snapshots/clone_pre_20220127110000/snapshot_20220127110000
+ID 261 gen 24 cgen 14 top level 5 otime 2022-01-27 12:00:00 parent_uuid - uuid a5 path .snapshots/snapshot_20220127120000
+ID 262 gen 25 cgen 15 top level 5 otime 2022-01-27 12:30:00 parent_uuid a5 uuid a6 path clones/dblab_clone_6000
+ID 263 gen 26 cgen 16 top level 5 otime 2022-01-27 13:00:00 parent_uuid - uuid a7 path .snapshots/snapshot_20220127130000
+`
+
+func newTestManager(mountDir string) *Manager {
+	return &Manager{
+		config: Config{
+			Pool: &resources.Pool{
+				Name:        "dblab_pool",
+				MountDir:    mountDir,
+				PoolDirName: "dblab_pool",
+				CloneSubDir: "clones",
+				DataSubDir:  "data",
+			},
+			PreSnapshotSuffix: "_pre",
+		},
+	}
+}
+
+func TestParseSubvolumeList(t *testing.T) {
+	subvolumes, err := parseSubvolumeList(subvolumeListOutput)
+	require.NoError(t, err)
+	require.Len(t, subvolumes, 7)
+
+	assert.Equal(t, subvolume{
+		ID:         "260",
+		ParentUUID: "a3",
+		UUID:       "a4",
+		Path:       ".snapshots/clone_pre_20220127110000/snapshot_20220127110000",
+		CreatedAt:  time.Date(2022, 1, 27, 11, 5, 0, 0, time.Local),
+	}, subvolumes[3])
+
+	_, err = parseSubvolumeList("ID 256 gen 10 top level 5")
+	require.Error(t, err)
+}
+
+func TestParseQGroups(t *testing.T) {
+	out := `qgroupid         rfer         excl
+--------         ----         ----
+0/5             16384        16384
+0/257       104857600        65536
+1/100       104857600        65536
+`
+	qgroups := parseQGroups(out)
+
+	require.Len(t, qgroups, 2)
+	assert.Equal(t, qgroup{Referenced: 104857600, Exclusive: 65536}, qgroups["257"])
+}
+
+func TestParseFilesystemUsage(t *testing.T) {
+	out := `Overall:
+    Device size:		  1073741824
+    Device allocated:		   228196352
+    Device unallocated:		   845545472
+    Device missing:		           0
+    Used:			   110362624
+    Free (estimated):		   926859264	(min: 504086528)
+    Data ratio:			        1.00
+
+Data,single: Size:8388608, Used:4194304 (50.00%)
+   /dev/loop0	   8388608
+`
+	usage, err := parseFilesystemUsage(out)
+	require.NoError(t, err)
+	assert.Equal(t, filesystemUsage{Size: 1073741824, Used: 110362624, Free: 926859264}, usage)
+
+	_, err = parseFilesystemUsage("Overall:\n")
+	require.Error(t, err)
+}
+
+func TestSubvolumeNames(t *testing.T) {
+	m := newTestManager("/var/lib/dblab/pool")
+
+	testCases := []struct {
+		path       string
+		snapshotID string
+		cloneName  string
+	}{
+		{path: ".snapshots/snapshot_20220127100000", snapshotID: "dblab_pool@snapshot_20220127100000"},
+		{path: "@pool/.snapshots/dblab_clone_6000/snapshot_20220127100000", snapshotID: "dblab_pool/dblab_clone_6000@snapshot_20220127100000"},
+		{path: "clones/dblab_clone_6000", cloneName: "dblab_clone_6000"},
+		{path: "@pool/clones/dblab_clone_6001", cloneName: "dblab_clone_6001"},
+		{path: "data"},
+	}
+
+	for _, tc := range testCases {
+		snapshotID, _ := m.snapshotID(subvolume{Path: tc.path})
+		assert.Equal(t, tc.snapshotID, snapshotID, tc.path)
+
+		cloneName, _ := m.cloneName(subvolume{Path: tc.path})
+		assert.Equal(t, tc.cloneName, cloneName, tc.path)
+	}
+}
+
+func TestSnapshotPath(t *testing.T) {
+	m := newTestManager("/var/lib/dblab/pool")
+
+	snapshotPath, err := m.snapshotPath("dblab_pool@snapshot_20220127100000")
+	require.NoError(t, err)
+	assert.Equal(t, "/var/lib/dblab/pool/dblab_pool/.snapshots/snapshot_20220127100000", snapshotPath)
+
+	snapshotPath, err = m.snapshotPath("dblab_pool/dblab_clone_6000@snapshot_20220127100000")
+	require.NoError(t, err)
+	assert.Equal(t, "/var/lib/dblab/pool/dblab_pool/.snapshots/dblab_clone_6000/snapshot_20220127100000", snapshotPath)
+
+	invalidIDs := []string{
+		"dblab_pool", "other_pool@snapshot_1", "dblab_pool/a/b@snapshot_1", "dblab_pool@../x/y", "dblab_pool@..", "dblab_pool/..@snapshot_1",
+	}
+
+	for _, snapshotID := range invalidIDs {
+		_, err = m.snapshotPath(snapshotID)
+		assert.Error(t, err, snapshotID)
+	}
+}
+
+func TestListClonesNames(t *testing.T) {
+	m := newTestManager("/var/lib/dblab/pool")
+	m.runner = runnerMock{cmdOutput: subvolumeListOutput}
+
+	cloneNames, err := m.ListClonesNames()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dblab_clone_6000"}, cloneNames)
+}
+
+func TestRetentionCandidates(t *testing.T) {
+	m := newTestManager(t.TempDir())
+
+	subvolumes, err := parseSubvolumeList(subvolumeListOutput)
+	require.NoError(t, err)
+
+	// The snapshot of 12:00 is used by the user clone, so it is kept regardless of the limit.
+	assert.Equal(t, []string{"dblab_pool@snapshot_20220127110000_pre", "dblab_pool@snapshot_20220127100000"},
+		m.getRetentionCandidates(subvolumes, 1))
+	assert.Empty(t, m.getRetentionCandidates(subvolumes, 4))
+
+	// The final snapshot of the pre-clone is protected, so its pre-snapshot is kept.
+	snapshotPath, err := m.snapshotPath("dblab_pool/clone_pre_20220127110000@snapshot_20220127110000")
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(path.Dir(snapshotPath), 0700))
+	require.NoError(t, os.WriteFile(snapshotPath+protectedSuffix, nil, 0600))
+
+	assert.Equal(t, []string{"dblab_pool@snapshot_20220127100000"}, m.getRetentionCandidates(subvolumes, 1))
+}
@@ -0,0 +1,178 @@
+/*
+2022 © Postgres.ai
+*/
+
+package btrfs
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const otimeLayout = "2006-01-02 15:04:05"
+
+var qgroupIDRegexp = regexp.MustCompile(`^0/(\d+)$`)
+
+// subvolume describes an entry of the "btrfs subvolume list" output.
+type subvolume struct {
+	ID         string
+	ParentUUID string
+	UUID       string
+	Path       string
+	CreatedAt  time.Time
+}
+
+// qgroup describes sizes of a subvolume reported by the "btrfs qgroup show" output.
+type qgroup struct {
+	Referenced uint64
+	Exclusive  uint64
+}
+
+// filesystemUsage describes the overall disk usage reported by the "btrfs filesystem usage" output.
+type filesystemUsage struct {
+	Size uint64
+	Used uint64
+	Free uint64
+}
+
+// parseSubvolumeList parses lines like
+// "ID 258 gen 12 cgen 11 top level 5 otime 2022-01-27 10:52:15 parent_uuid 1a2b... uuid 3c4d... path .snapshots/snapshot_20220127105215".
+func parseSubvolumeList(listOutput string) ([]subvolume, error) {
+	subvolumes := []subvolume{}
+
+	for _, line := range strings.Split(listOutput, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		var entry subvolume
+
+	fieldLoop:
+		for i := 0; i < len(fields)-1; i++ {
+			switch fields[i] {
+			case "ID":
+				entry.ID = fields[i+1]
+				i++
+
+			case "parent_uuid":
+				entry.ParentUUID = fields[i+1]
+				i++
+
+			case "uuid":
+				entry.UUID = fields[i+1]
+				i++
+
+			case "otime":
+				if i+2 < len(fields) {
+					if createdAt, err := time.ParseInLocation(otimeLayout, fields[i+1]+" "+fields[i+2], time.Local); err == nil {
+						entry.CreatedAt = createdAt
+					}
+				}
+
+				i += 2
+
+			case "path":
+				// Paths may contain spaces, so the rest of the line is the path.
+				entry.Path = strings.Join(fields[i+1:], " ")
+				break fieldLoop
+			}
+		}
+
+		if entry.ID == "" || entry.Path == "" {
+			return nil, errors.Errorf("failed to parse the subvolume entry: %q", line)
+		}
+
+		subvolumes = append(subvolumes, entry)
+	}
+
+	return subvolumes, nil
+}
+
+// parseQGroups parses the "btrfs qgroup show --raw" output and maps sizes to subvolume IDs.
+func parseQGroups(qgroupOutput string) map[string]qgroup {
+	qgroups := make(map[string]qgroup)
+
+	for _, line := range strings.Split(qgroupOutput, "\n") {
+		fields := strings.Fields(line)
+
+		if len(fields) < 3 {
+			continue
+		}
+
+		matches := qgroupIDRegexp.FindStringSubmatch(fields[0])
+		if matches == nil {
+			continue
+		}
+
+		referenced, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+
+		exclusive, err := strconv.ParseUint(fields[2], 10, 64)
+		if err != nil {
+			continue
+		}
+
+		qgroups[matches[1]] = qgroup{Referenced: referenced, Exclusive: exclusive}
+	}
+
+	return qgroups
+}
+
+// parseFilesystemUsage parses the overall section of the "btrfs filesystem usage -b" output.
+func parseFilesystemUsage(usageOutput string) (filesystemUsage, error) {
+	usage := filesystemUsage{}
+	parsed := make(map[string]struct{})
+
+	for _, line := range strings.Split(usageOutput, "\n") {
+		key, value, found := strings.Cut(strings.TrimSpace(line), ":")
+		if !found {
+			continue
+		}
+
+		valueFields := strings.Fields(value)
+		if len(valueFields) == 0 {
+			continue
+		}
+
+		var target *uint64
+
+		switch key {
+		case "Device size":
+			target = &usage.Size
+		case "Used":
+			target = &usage.Used
+		case "Free (estimated)":
+			target = &usage.Free
+		default:
+			continue
+		}
+
+		// Only the overall section is taken into account.
+		if _, ok := parsed[key]; ok {
+			continue
+		}
+
+		size, err := strconv.ParseUint(valueFields[0], 10, 64)
+		if err != nil {
+			return filesystemUsage{}, errors.Wrapf(err, "failed to parse %q", key)
+		}
+
+		*target = size
+		parsed[key] = struct{}{}
+	}
+
+	const expectedFields = 3
+
+	if len(parsed) < expectedFields {
+		return filesystemUsage{}, errors.New("failed to parse filesystem usage: some fields are missing")
+	}
+
+	return usage, nil
+}
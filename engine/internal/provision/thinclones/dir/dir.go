@@ -0,0 +1,689 @@
+/*
+2022 © Postgres.ai
+*/
+
+// Package dir provides a thin-clone manager working with plain directories.
+// Clones and snapshots are copies made by "cp --reflink=auto", so they share data blocks
+// on filesystems supporting reflinks (XFS, Btrfs) and fall back to full copies elsewhere (ext4).
+package dir
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"gitlab.com/postgres-ai/database-lab/v3/internal/provision/resources"
+	"gitlab.com/postgres-ai/database-lab/v3/internal/provision/runners"
+	"gitlab.com/postgres-ai/database-lab/v3/internal/provision/thinclones"
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/log"
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/models"
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/util"
+)
+
+const (
+	// PoolMode defines the plain directory mode name.
+	PoolMode = "dir"
+
+	snapshotsDir    = ".snapshots"
+	snapshotPrefix  = "snapshot_"
+	protectedSuffix = ".protected"
+	originFilename  = ".origin"
+)
+
+// Manager describes a filesystem manager for plain directories.
+//
+// Snapshots are read-only copies of the data directory stored in the ".snapshots" directory of the pool,
+// clones are writable copies of snapshots stored in the clone directory of the pool. Snapshot IDs follow the ZFS naming,
+// so "pool@snapshot_20220101000000" is a snapshot of the pool and "pool/dblab_clone_6000@snapshot_20220101000000" is a snapshot of a clone.
+type Manager struct {
+	runner    runners.Runner
+	config    Config
+	mu        *sync.Mutex
+	snapshots []resources.Snapshot
+}
+
+// Config defines configuration for the plain directory manager.
+type Config struct {
+	Pool              *resources.Pool
+	PreSnapshotSuffix string
+	OSUsername        string
+}
+
+// NewFSManager creates a new Manager instance for plain directories.
+func NewFSManager(runner runners.Runner, config Config) (*Manager, error) {
+	if config.Pool.DataSubDir == "" {
+		return nil, errors.New("data subdirectory must be specified to keep snapshots and clones outside of the data directory")
+	}
+
+	m := Manager{
+		runner:    runner,
+		config:    config,
+		mu:        &sync.Mutex{},
+		snapshots: make([]resources.Snapshot, 0),
+	}
+
+	return &m, nil
+}
+
+// Pool gets a storage pool.
+func (m *Manager) Pool() *resources.Pool {
+	return m.config.Pool
+}
+
+// UpdateConfig updates the manager's configuration.
+func (m *Manager) UpdateConfig(cfg Config) {
+	m.config = cfg
+}
+
+// CreateClone creates a writable copy of the snapshot.
+func (m *Manager) CreateClone(cloneName, snapshotID string) error {
+	snapshotPath, err := m.snapshotPath(snapshotID)
+	if err != nil {
+		return err
+	}
+
+	clonePath := m.clonePath(cloneName)
+
+	if _, err := os.Stat(clonePath); err == nil {
+		return fmt.Errorf("clone %q is already exists. Skip creation", cloneName)
+	}
+
+	dataSubDir := m.config.Pool.DataSubDir
+
+	cmd := "mkdir -p " + clonePath + " && " +
+		copyCommand(path.Join(snapshotPath, dataSubDir), path.Join(clonePath, dataSubDir)) + " && " +
+		"chmod -R u+w " + clonePath + " && " +
+		"chown -R " + m.config.OSUsername + " " + clonePath
+
+	out, err := m.runner.Run(cmd)
+	if err != nil {
+		return errors.Wrapf(err, "failed to copy the snapshot. Out: %v", out)
+	}
+
+	if err := os.WriteFile(path.Join(clonePath, originFilename), []byte(snapshotID), 0600); err != nil {
+		return errors.Wrap(err, "failed to save the clone origin")
+	}
+
+	return nil
+}
+
+// DestroyClone removes the clone directory and the snapshots taken from the clone.
+func (m *Manager) DestroyClone(cloneName string) error {
+	clonePath := m.clonePath(cloneName)
+
+	if _, err := os.Stat(clonePath); errors.Is(err, os.ErrNotExist) {
+		log.Msg(fmt.Sprintf("clone %q is not exists. Skip deletion", cloneName))
+		return nil
+	}
+
+	cloneSnapshotsPath := path.Join(m.poolDir(), snapshotsDir, cloneName)
+
+	if _, err := m.runner.Run(removeCommand(clonePath) + " && " + removeCommand(cloneSnapshotsPath)); err != nil {
+		return errors.Wrap(err, "failed to remove the clone directory")
+	}
+
+	m.RefreshSnapshotList()
+
+	return nil
+}
+
+// ListClonesNames lists clones of the pool.
+func (m *Manager) ListClonesNames() ([]string, error) {
+	entries, err := os.ReadDir(m.config.Pool.ClonesDir())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return []string{}, nil
+		}
+
+		return nil, errors.Wrap(err, "failed to list clones")
+	}
+
+	cloneNames := []string{}
+
+	for _, entry := range entries {
+		if entry.IsDir() && strings.HasPrefix(entry.Name(), util.ClonePrefix) {
+			cloneNames = append(cloneNames, entry.Name())
+		}
+	}
+
+	return cloneNames, nil
+}
+
+// CreateSnapshot creates a read-only copy of the pool data or of the clone data if the pool suffix is specified.
+func (m *Manager) CreateSnapshot(poolSuffix, dataStateAt string) (string, error) {
+	sourcePath := m.config.Pool.DataDir()
+
+	if poolSuffix != "" {
+		sourcePath = path.Join(m.clonePath(poolSuffix), m.config.Pool.DataSubDir)
+	}
+
+	if dataStateAt == "" {
+		dataStateAt = time.Now().Format(util.DataStateAtFormat)
+	}
+
+	snapshotName := m.snapshotName(poolSuffix, dataStateAt)
+
+	snapshotPath, err := m.snapshotPath(snapshotName)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := os.Stat(snapshotPath); err == nil {
+		return "", thinclones.NewSnapshotExistsError(snapshotName)
+	}
+
+	cmd := "mkdir -p " + snapshotPath + " && " +
+		copyCommand(sourcePath, path.Join(snapshotPath, m.config.Pool.DataSubDir)) + " && " +
+		"chmod -R a-w " + snapshotPath
+
+	if out, err := m.runner.Run(cmd, true); err != nil {
+		// Do not leave a partial copy.
+		if _, rmErr := m.runner.Run(removeCommand(snapshotPath)); rmErr != nil {
+			log.Err("failed to remove the partial snapshot copy:", rmErr)
+		}
+
+		return "", errors.Wrapf(err, "failed to create snapshot. Out: %v", out)
+	}
+
+	dataStateTime, err := util.ParseCustomTime(strings.TrimSuffix(dataStateAt, m.config.PreSnapshotSuffix))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse dataStateAt: %w", err)
+	}
+
+	newSnapshot := resources.Snapshot{
+		ID:          snapshotName,
+		CreatedAt:   time.Now(),
+		DataStateAt: dataStateTime,
+		Pool:        m.config.Pool.Name,
+	}
+
+	if !strings.HasSuffix(snapshotName, m.config.PreSnapshotSuffix) {
+		m.addSnapshotToList(newSnapshot)
+
+		log.Dbg("New snapshot:", newSnapshot)
+
+		m.RefreshSnapshotList()
+	}
+
+	return snapshotName, nil
+}
+
+// DestroySnapshot removes the snapshot directory.
+func (m *Manager) DestroySnapshot(snapshotName string, _ thinclones.DestroyOptions) error {
+	snapshotPath, err := m.snapshotPath(snapshotName)
+	if err != nil {
+		return err
+	}
+
+	if _, err := m.runner.Run(removeCommand(snapshotPath)); err != nil {
+		return errors.Wrap(err, "failed to remove the snapshot directory")
+	}
+
+	if err := os.Remove(snapshotPath + protectedSuffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Err("failed to remove the protection mark of snapshot:", err)
+	}
+
+	m.removeSnapshotFromList(snapshotName)
+
+	return nil
+}
+
+// ProtectSnapshot marks the snapshot as protected from the retention or removes the mark.
+func (m *Manager) ProtectSnapshot(snapshotName string, protected bool) error {
+	snapshotPath, err := m.snapshotPath(snapshotName)
+	if err != nil {
+		return err
+	}
+
+	if protected {
+		err = os.WriteFile(snapshotPath+protectedSuffix, nil, 0600)
+	} else if err = os.Remove(snapshotPath + protectedSuffix); errors.Is(err, os.ErrNotExist) {
+		err = nil
+	}
+
+	if err != nil {
+		return errors.Wrap(err, "failed to change the protection of snapshot")
+	}
+
+	m.RefreshSnapshotList()
+
+	return nil
+}
+
+// CleanupSnapshots destroys old snapshots of the pool considering retention limit and related clones.
+// Pre-clones copied from the destroyed snapshots are destroyed as well.
+func (m *Manager) CleanupSnapshots(retentionLimit int) ([]string, error) {
+	snapshotIDs, err := m.listSnapshotIDs()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list snapshots")
+	}
+
+	origins, err := m.cloneOrigins()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list clones")
+	}
+
+	destroyed := []string{}
+
+	for _, candidate := range m.getRetentionCandidates(snapshotIDs, origins, retentionLimit) {
+		for cloneName, origin := range origins {
+			if origin != candidate {
+				continue
+			}
+
+			if err := m.DestroyClone(cloneName); err != nil {
+				return destroyed, errors.Wrap(err, "failed to destroy pre-clone")
+			}
+		}
+
+		if err := m.DestroySnapshot(candidate, thinclones.DestroyOptions{}); err != nil {
+			return destroyed, errors.Wrap(err, "failed to clean up snapshots")
+		}
+
+		destroyed = append(destroyed, candidate)
+	}
+
+	m.RefreshSnapshotList()
+
+	return destroyed, nil
+}
+
+// getRetentionCandidates returns snapshots of the pool exceeding the retention limit
+// that are neither protected nor used by user clones.
+func (m *Manager) getRetentionCandidates(snapshotIDs []string, origins map[string]string, retentionLimit int) []string {
+	poolSnapshots := []string{}
+
+	for _, snapshotID := range snapshotIDs {
+		if strings.HasPrefix(snapshotID, m.config.Pool.Name+"@") {
+			poolSnapshots = append(poolSnapshots, snapshotID)
+		}
+	}
+
+	return thinclones.RetentionCandidates(poolSnapshots, retentionLimit, m.dataStateAt, func(snapshotID string) bool {
+		return m.isBusySnapshot(snapshotIDs, origins, snapshotID)
+	})
+}
+
+// isBusySnapshot checks if the snapshot or snapshots of pre-clones copied from it are protected or used by user clones.
+func (m *Manager) isBusySnapshot(snapshotIDs []string, origins map[string]string, snapshotID string) bool {
+	related := []string{snapshotID}
+
+	for cloneName, origin := range origins {
+		if origin != snapshotID {
+			continue
+		}
+
+		if strings.HasPrefix(cloneName, util.ClonePrefix) {
+			return true
+		}
+
+		for _, id := range snapshotIDs {
+			if strings.HasPrefix(id, m.config.Pool.Name+"/"+cloneName+"@") {
+				related = append(related, id)
+			}
+		}
+	}
+
+	for _, relatedSnapshot := range related {
+		if m.isProtected(relatedSnapshot) {
+			return true
+		}
+
+		for cloneName, origin := range origins {
+			if origin == relatedSnapshot && strings.HasPrefix(cloneName, util.ClonePrefix) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// GetSessionState returns a state of a session.
+// Copies do not track shared blocks, so the clone size is the size of its data directory.
+func (m *Manager) GetSessionState(name string) (*resources.SessionState, error) {
+	size, err := directorySize(path.Join(m.clonePath(name), m.config.Pool.DataSubDir))
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot get session state")
+	}
+
+	state := &resources.SessionState{
+		CloneDiffSize:     size,
+		LogicalReferenced: size,
+	}
+
+	return state, nil
+}
+
+// GetFilesystemState returns a disk state.
+func (m *Manager) GetFilesystemState() (models.FileSystem, error) {
+	out, err := m.runner.Run("df -B1 --output=size,used,avail "+m.poolDir(), false)
+	if err != nil {
+		return models.FileSystem{}, errors.Wrap(err, "failed to get disk usage")
+	}
+
+	fileSystem, err := parseDiskUsage(out)
+	if err != nil {
+		return models.FileSystem{}, err
+	}
+
+	if fileSystem.DataSize, err = directorySize(m.config.Pool.DataDir()); err != nil {
+		return models.FileSystem{}, errors.Wrap(err, "failed to calculate the data size")
+	}
+
+	if fileSystem.UsedBySnapshots, err = directorySize(path.Join(m.poolDir(), snapshotsDir)); err != nil {
+		return models.FileSystem{}, errors.Wrap(err, "failed to calculate the size of snapshots")
+	}
+
+	if fileSystem.UsedByClones, err = directorySize(m.config.Pool.ClonesDir()); err != nil {
+		return models.FileSystem{}, errors.Wrap(err, "failed to calculate the size of clones")
+	}
+
+	return fileSystem, nil
+}
+
+// parseDiskUsage parses the "df -B1 --output=size,used,avail" output.
+func parseDiskUsage(dfOutput string) (models.FileSystem, error) {
+	const dfFields = 3
+
+	lines := strings.Split(strings.TrimSpace(dfOutput), "\n")
+	fields := strings.Fields(lines[len(lines)-1])
+
+	if len(fields) != dfFields {
+		return models.FileSystem{}, errors.Errorf("failed to parse disk usage: %q", dfOutput)
+	}
+
+	values := make([]uint64, 0, dfFields)
+
+	for _, field := range fields {
+		value, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			return models.FileSystem{}, errors.Wrapf(err, "failed to parse disk usage: %q", dfOutput)
+		}
+
+		values = append(values, value)
+	}
+
+	return models.FileSystem{
+		Mode:          PoolMode,
+		Size:          values[0],
+		Used:          values[1],
+		Free:          values[2],
+		CompressRatio: 1,
+	}, nil
+}
+
+// SnapshotList returns a list of snapshots.
+func (m *Manager) SnapshotList() []resources.Snapshot {
+	m.mu.Lock()
+	snapshots := m.snapshots
+	m.mu.Unlock()
+
+	return snapshots
+}
+
+// RefreshSnapshotList updates the list of snapshots.
+func (m *Manager) RefreshSnapshotList() {
+	snapshots, err := m.getSnapshots()
+	if err != nil {
+		log.Err("Failed to refresh snapshot list: ", err)
+		return
+	}
+
+	m.mu.Lock()
+	m.snapshots = snapshots
+	m.mu.Unlock()
+}
+
+func (m *Manager) getSnapshots() ([]resources.Snapshot, error) {
+	snapshotIDs, err := m.listSnapshotIDs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots: %w", err)
+	}
+
+	snapshots := make([]resources.Snapshot, 0, len(snapshotIDs))
+
+	for _, snapshotID := range snapshotIDs {
+		// Filter pre-snapshots, they will not be allowed to be used for cloning.
+		if strings.HasSuffix(snapshotID, m.config.PreSnapshotSuffix) {
+			continue
+		}
+
+		snapshotPath, err := m.snapshotPath(snapshotID)
+		if err != nil {
+			continue
+		}
+
+		info, err := os.Stat(snapshotPath)
+		if err != nil {
+			continue
+		}
+
+		size, err := directorySize(snapshotPath)
+		if err != nil {
+			log.Err("failed to calculate the snapshot size:", err)
+		}
+
+		snapshots = append(snapshots, resources.Snapshot{
+			ID:                snapshotID,
+			CreatedAt:         info.ModTime(),
+			DataStateAt:       m.dataStateAt(snapshotID),
+			Used:              size,
+			LogicalReferenced: size,
+			Pool:              m.config.Pool.Name,
+			Protected:         m.isProtected(snapshotID),
+		})
+	}
+
+	sort.SliceStable(snapshots, func(i, j int) bool {
+		if snapshots[i].DataStateAt.Equal(snapshots[j].DataStateAt) {
+			return snapshots[i].CreatedAt.After(snapshots[j].CreatedAt)
+		}
+
+		return snapshots[i].DataStateAt.After(snapshots[j].DataStateAt)
+	})
+
+	return snapshots, nil
+}
+
+func (m *Manager) addSnapshotToList(snapshot resources.Snapshot) {
+	m.mu.Lock()
+	m.snapshots = append([]resources.Snapshot{snapshot}, m.snapshots...)
+	m.mu.Unlock()
+}
+
+func (m *Manager) removeSnapshotFromList(snapshotName string) {
+	m.mu.Lock()
+
+	for i, snapshot := range m.snapshots {
+		if snapshot.ID == snapshotName {
+			m.snapshots = append((m.snapshots)[:i], (m.snapshots)[i+1:]...)
+
+			break
+		}
+	}
+
+	m.mu.Unlock()
+}
+
+// listSnapshotIDs lists snapshots of the pool and snapshots of clones.
+func (m *Manager) listSnapshotIDs() ([]string, error) {
+	snapshotIDs := []string{}
+	rootPath := path.Join(m.poolDir(), snapshotsDir)
+
+	entries, err := os.ReadDir(rootPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return snapshotIDs, nil
+		}
+
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		if strings.HasPrefix(entry.Name(), snapshotPrefix) {
+			snapshotIDs = append(snapshotIDs, m.snapshotName("", strings.TrimPrefix(entry.Name(), snapshotPrefix)))
+			continue
+		}
+
+		cloneEntries, err := os.ReadDir(path.Join(rootPath, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, cloneEntry := range cloneEntries {
+			if cloneEntry.IsDir() && strings.HasPrefix(cloneEntry.Name(), snapshotPrefix) {
+				snapshotIDs = append(snapshotIDs, m.snapshotName(entry.Name(), strings.TrimPrefix(cloneEntry.Name(), snapshotPrefix)))
+			}
+		}
+	}
+
+	return snapshotIDs, nil
+}
+
+// cloneOrigins maps names of clones including pre-clones to the snapshots they have been copied from.
+func (m *Manager) cloneOrigins() (map[string]string, error) {
+	origins := make(map[string]string)
+
+	entries, err := os.ReadDir(m.config.Pool.ClonesDir())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return origins, nil
+		}
+
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		origin, err := os.ReadFile(path.Join(m.config.Pool.ClonesDir(), entry.Name(), originFilename))
+		if err != nil {
+			continue
+		}
+
+		origins[entry.Name()] = strings.TrimSpace(string(origin))
+	}
+
+	return origins, nil
+}
+
+func (m *Manager) isProtected(snapshotID string) bool {
+	snapshotPath, err := m.snapshotPath(snapshotID)
+	if err != nil {
+		return false
+	}
+
+	_, err = os.Stat(snapshotPath + protectedSuffix)
+
+	return err == nil
+}
+
+func (m *Manager) dataStateAt(snapshotID string) time.Time {
+	dataStateAt := snapshotID[strings.LastIndex(snapshotID, "@")+1:]
+	dataStateAt = strings.TrimPrefix(dataStateAt, snapshotPrefix)
+	dataStateAt = strings.TrimSuffix(dataStateAt, m.config.PreSnapshotSuffix)
+
+	dataStateTime, err := util.ParseCustomTime(dataStateAt)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return dataStateTime
+}
+
+func (m *Manager) snapshotName(poolSuffix, dataStateAt string) string {
+	dataset := m.config.Pool.Name
+
+	if poolSuffix != "" {
+		dataset += "/" + poolSuffix
+	}
+
+	return fmt.Sprintf("%s@%s%s", dataset, snapshotPrefix, dataStateAt)
+}
+
+// snapshotPath returns the path of the snapshot directory.
+func (m *Manager) snapshotPath(snapshotID string) (string, error) {
+	const snapshotIDParts = 2
+
+	parts := strings.SplitN(snapshotID, "@", snapshotIDParts)
+	if len(parts) != snapshotIDParts || !strings.HasPrefix(parts[1], snapshotPrefix) || strings.Contains(parts[1], "/") {
+		return "", fmt.Errorf("invalid snapshot ID %q", snapshotID)
+	}
+
+	dataset, name := parts[0], parts[1]
+
+	if dataset == m.config.Pool.Name {
+		return path.Join(m.poolDir(), snapshotsDir, name), nil
+	}
+
+	poolSuffix := strings.TrimPrefix(dataset, m.config.Pool.Name+"/")
+	if poolSuffix == dataset || poolSuffix == "" || poolSuffix == "." || poolSuffix == ".." || strings.Contains(poolSuffix, "/") {
+		return "", fmt.Errorf("snapshot %q does not belong to pool %q", snapshotID, m.config.Pool.Name)
+	}
+
+	return path.Join(m.poolDir(), snapshotsDir, poolSuffix, name), nil
+}
+
+func (m *Manager) poolDir() string {
+	return path.Join(m.config.Pool.MountDir, m.config.Pool.PoolDirName)
+}
+
+func (m *Manager) clonePath(cloneName string) string {
+	return path.Join(m.config.Pool.ClonesDir(), cloneName)
+}
+
+// copyCommand builds a command to copy a directory. Reflinks are used if the filesystem supports them.
+func copyCommand(source, target string) string {
+	return "cp -a --reflink=auto " + source + " " + target
+}
+
+// removeCommand builds a command to remove a directory including read-only snapshot copies.
+func removeCommand(target string) string {
+	return "if [ -e " + target + " ]; then chmod -R u+w " + target + " && rm -rf " + target + "; fi"
+}
+
+// directorySize calculates the size of regular files in the directory.
+func directorySize(root string) (uint64, error) {
+	var size uint64
+
+	err := filepath.WalkDir(root, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+
+			return err
+		}
+
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+
+		size += uint64(info.Size())
+
+		return nil
+	})
+
+	return size, err
+}
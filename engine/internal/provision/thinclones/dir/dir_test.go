@@ -0,0 +1,183 @@
+package dir
+
+import (
+	"os"
+	"os/user"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/postgres-ai/database-lab/v3/internal/provision/resources"
+	"gitlab.com/postgres-ai/database-lab/v3/internal/provision/runners"
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/models"
+)
+
+func newTestManager(t *testing.T) *Manager {
+	t.Helper()
+
+	osUser, err := user.Current()
+	require.NoError(t, err)
+
+	pool := &resources.Pool{
+		Name:        "dblab_pool",
+		Mode:        PoolMode,
+		MountDir:    t.TempDir(),
+		PoolDirName: "dblab_pool",
+		CloneSubDir: "clones",
+		DataSubDir:  "data",
+	}
+
+	m, err := NewFSManager(runners.NewLocalRunner(false), Config{Pool: pool, PreSnapshotSuffix: "_pre", OSUsername: osUser.Username})
+	require.NoError(t, err)
+
+	require.NoError(t, os.MkdirAll(pool.DataDir(), 0700))
+	require.NoError(t, os.WriteFile(path.Join(pool.DataDir(), "PG_VERSION"), []byte("14"), 0600))
+
+	return m
+}
+
+func TestParseDiskUsage(t *testing.T) {
+	out := `    1B-blocks        Used       Avail
+  52521566208 14447116288 35379773440
+`
+	fileSystem, err := parseDiskUsage(out)
+	require.NoError(t, err)
+	assert.Equal(t, models.FileSystem{
+		Mode:          PoolMode,
+		Size:          52521566208,
+		Used:          14447116288,
+		Free:          35379773440,
+		CompressRatio: 1,
+	}, fileSystem)
+
+	_, err = parseDiskUsage("1B-blocks Used Avail\n")
+	require.Error(t, err)
+}
+
+func TestSnapshotPath(t *testing.T) {
+	m := &Manager{config: Config{Pool: &resources.Pool{Name: "dblab_pool", MountDir: "/var/lib/dblab/pool", PoolDirName: "dblab_pool"}}}
+
+	snapshotPath, err := m.snapshotPath("dblab_pool@snapshot_20220127100000")
+	require.NoError(t, err)
+	assert.Equal(t, "/var/lib/dblab/pool/dblab_pool/.snapshots/snapshot_20220127100000", snapshotPath)
+
+	snapshotPath, err = m.snapshotPath("dblab_pool/dblab_clone_6000@snapshot_20220127100000")
+	require.NoError(t, err)
+	assert.Equal(t, "/var/lib/dblab/pool/dblab_pool/.snapshots/dblab_clone_6000/snapshot_20220127100000", snapshotPath)
+
+	invalidIDs := []string{
+		"dblab_pool", "other_pool@snapshot_1", "dblab_pool/a/b@snapshot_1", "dblab_pool@../x/y", "dblab_pool@..", "dblab_pool/..@snapshot_1",
+	}
+
+	for _, snapshotID := range invalidIDs {
+		_, err = m.snapshotPath(snapshotID)
+		assert.Error(t, err, snapshotID)
+	}
+}
+
+func TestDirectoryCopies(t *testing.T) {
+	m := newTestManager(t)
+	pool := m.Pool()
+
+	// Snapshots of the pool.
+	snapshotID, err := m.CreateSnapshot("", "20220127100000")
+	require.NoError(t, err)
+	assert.Equal(t, "dblab_pool@snapshot_20220127100000", snapshotID)
+
+	_, err = m.CreateSnapshot("", "20220127100000")
+	require.Error(t, err)
+
+	oldSnapshotID, err := m.CreateSnapshot("", "20220127090000")
+	require.NoError(t, err)
+
+	snapshots := m.SnapshotList()
+	require.Len(t, snapshots, 2)
+	assert.Equal(t, snapshotID, snapshots[0].ID)
+
+	// Clones.
+	const cloneName = "dblab_clone_6000"
+
+	require.NoError(t, m.CreateClone(cloneName, snapshotID))
+	require.Error(t, m.CreateClone(cloneName, snapshotID))
+
+	content, err := os.ReadFile(path.Join(pool.ClonesDir(), cloneName, pool.DataSubDir, "PG_VERSION"))
+	require.NoError(t, err)
+	assert.Equal(t, "14", string(content))
+
+	require.NoError(t, os.WriteFile(path.Join(pool.ClonesDir(), cloneName, pool.DataSubDir, "diff"), make([]byte, 1024), 0600))
+
+	cloneNames, err := m.ListClonesNames()
+	require.NoError(t, err)
+	assert.Equal(t, []string{cloneName}, cloneNames)
+
+	sessionState, err := m.GetSessionState(cloneName)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1026), sessionState.CloneDiffSize)
+
+	fileSystem, err := m.GetFilesystemState()
+	require.NoError(t, err)
+	assert.Equal(t, PoolMode, fileSystem.Mode)
+	assert.NotZero(t, fileSystem.Size)
+	assert.Equal(t, uint64(2), fileSystem.DataSize)
+
+	// Snapshots of clones.
+	cloneSnapshotID, err := m.CreateSnapshot(cloneName, "20220127110000")
+	require.NoError(t, err)
+	assert.Equal(t, "dblab_pool/dblab_clone_6000@snapshot_20220127110000", cloneSnapshotID)
+
+	// Retention keeps snapshots used by clones and protected snapshots.
+	require.NoError(t, m.ProtectSnapshot(oldSnapshotID, true))
+
+	destroyed, err := m.CleanupSnapshots(0)
+	require.NoError(t, err)
+	assert.Empty(t, destroyed)
+
+	require.NoError(t, m.ProtectSnapshot(oldSnapshotID, false))
+
+	destroyed, err = m.CleanupSnapshots(0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{oldSnapshotID}, destroyed)
+
+	require.NoError(t, m.DestroyClone(cloneName))
+
+	cloneNames, err = m.ListClonesNames()
+	require.NoError(t, err)
+	assert.Empty(t, cloneNames)
+	assert.Len(t, m.SnapshotList(), 1)
+}
+
+func TestRetentionOfPreClones(t *testing.T) {
+	m := newTestManager(t)
+
+	// Physical mode: a pre-snapshot, a pre-clone and the final snapshot of the pre-clone.
+	preSnapshotID, err := m.CreateSnapshot("", "20220127100000_pre")
+	require.NoError(t, err)
+
+	const preCloneName = "clone_pre_20220127100000"
+
+	require.NoError(t, m.CreateClone(preCloneName, preSnapshotID))
+
+	snapshotID, err := m.CreateSnapshot(preCloneName, "20220127100000")
+	require.NoError(t, err)
+
+	_, err = m.CreateSnapshot("", "20220127110000")
+	require.NoError(t, err)
+
+	require.NoError(t, m.CreateClone("dblab_clone_6000", snapshotID))
+
+	destroyed, err := m.CleanupSnapshots(0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dblab_pool@snapshot_20220127110000"}, destroyed)
+
+	require.NoError(t, m.DestroyClone("dblab_clone_6000"))
+
+	destroyed, err = m.CleanupSnapshots(0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{preSnapshotID}, destroyed)
+
+	_, err = os.Stat(path.Join(m.Pool().ClonesDir(), preCloneName))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	assert.Empty(t, m.SnapshotList())
+}
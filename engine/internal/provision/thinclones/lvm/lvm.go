@@ -9,6 +9,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"strconv"
+	"strings"
 
 	"github.com/pkg/errors"
 
@@ -21,6 +22,9 @@ const (
 
 	// PoolMode defines the lvm filesystem name.
 	PoolMode = "lvm"
+
+	// lvsOptions defines fields of the "lvs" report.
+	lvsOptions = "lv_name,vg_name,lv_attr,lv_size,pool_lv,origin,data_percent,lv_tags,lv_time"
 )
 
 // LvsOutput defines "lvs" command response.
@@ -41,13 +45,44 @@ type ListEntry struct {
 	Size        string `json:"lv_size"`
 	Pool        string `json:"pool_lv"`
 	Origin      string `json:"origin"`
-	DataPercent string `json:"data_percent"` // TODO(anatoly): Float64.
+	DataPercent string `json:"data_percent"`
+	Tags        string `json:"lv_tags"`
+	Time        string `json:"lv_time"`
+}
+
+// HasTag checks if the logical volume has the tag.
+func (e ListEntry) HasTag(tag string) bool {
+	for _, volumeTag := range strings.Split(e.Tags, ",") {
+		if volumeTag == tag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// UsedBytes calculates the used space of the logical volume using its size and the data usage percentage.
+func (e ListEntry) UsedBytes() uint64 {
+	dataPercent, err := strconv.ParseFloat(e.DataPercent, 64)
+	if err != nil {
+		return 0
+	}
+
+	return uint64(float64(e.SizeBytes()) * dataPercent / 100)
+}
+
+// SizeBytes returns the size of the logical volume.
+func (e ListEntry) SizeBytes() uint64 {
+	size, err := strconv.ParseUint(strings.TrimSuffix(e.Size, "B"), 10, 64)
+	if err != nil {
+		return 0
+	}
+
+	return size
 }
 
 // CreateVolume creates LVM volume.
 func CreateVolume(r runners.Runner, vg, lv, name, mountDir string) error {
-	fullName := getFullName(vg, name)
-
 	volumeCreateCmd := "lvcreate --snapshot " +
 		"--extents " + strconv.Itoa(sizePortion) + "%FREE " +
 		"--name " + name + " " + getFullName(vg, lv)
@@ -57,13 +92,32 @@ func CreateVolume(r runners.Runner, vg, lv, name, mountDir string) error {
 		return errors.Wrap(err, "failed to create a volume")
 	}
 
-	fullMountDir := getFullMountDir(mountDir, name)
-	mountCmd := "mkdir -p " + fullMountDir + " && " +
-		"mount /dev/" + fullName + " " + fullMountDir
+	return mountVolume(r, vg, name, mountDir)
+}
 
-	_, err = r.Run(mountCmd, true)
-	if err != nil {
-		return errors.Wrap(err, "failed to mount a volume")
+// CreateThinVolume creates a writable thin snapshot of the volume and mounts it.
+func CreateThinVolume(r runners.Runner, vg, origin, name, mountDir string) error {
+	if err := CreateThinSnapshot(r, vg, origin, name, "rw"); err != nil {
+		return errors.Wrap(err, "failed to create a volume")
+	}
+
+	return mountVolume(r, vg, name, mountDir)
+}
+
+// CreateThinSnapshot creates a thin snapshot of the volume with the given permission and tags.
+func CreateThinSnapshot(r runners.Runner, vg, origin, name, permission string, tags ...string) error {
+	snapshotCreateCmd := "lvcreate --snapshot --setactivationskip n " +
+		"--permission " + permission + " " +
+		"--name " + name + " "
+
+	for _, tag := range tags {
+		snapshotCreateCmd += "--addtag " + tag + " "
+	}
+
+	snapshotCreateCmd += getFullName(vg, origin)
+
+	if out, err := r.Run(snapshotCreateCmd, true); err != nil {
+		return errors.Wrapf(err, "failed to create a thin snapshot. Out: %v", out)
 	}
 
 	return nil
@@ -71,8 +125,6 @@ func CreateVolume(r runners.Runner, vg, lv, name, mountDir string) error {
 
 // RemoveVolume removes LVM volume.
 func RemoveVolume(r runners.Runner, vg, _, name, mountDir string) error {
-	fullName := getFullName(vg, name)
-
 	unmountCmd := "umount " + getFullMountDir(mountDir, name)
 
 	_, err := r.Run(unmountCmd, true)
@@ -81,7 +133,12 @@ func RemoveVolume(r runners.Runner, vg, _, name, mountDir string) error {
 		log.Err(errors.Wrap(err, "failed to unmount volume"))
 	}
 
-	volumeRemoveCmd := fmt.Sprintf("lvremove --yes %s", fullName)
+	return RemoveSnapshot(r, vg, name)
+}
+
+// RemoveSnapshot removes the logical volume without unmounting.
+func RemoveSnapshot(r runners.Runner, vg, name string) error {
+	volumeRemoveCmd := fmt.Sprintf("lvremove --yes %s", getFullName(vg, name))
 
 	out, err := r.Run(volumeRemoveCmd, true)
 	if err != nil {
@@ -93,18 +150,35 @@ func RemoveVolume(r runners.Runner, vg, _, name, mountDir string) error {
 	return nil
 }
 
-// ListVolumes lists LVM volumes.
-func ListVolumes(r runners.Runner, lv string) ([]ListEntry, error) {
-	listVolumesCmd := `lvs --reportformat json --units b --yes ` +
-		`--select origin="` + lv + `"`
+// ChangeTag adds the tag to the logical volume or deletes it.
+func ChangeTag(r runners.Runner, vg, name, tag string, add bool) error {
+	action := "--deltag"
+	if add {
+		action = "--addtag"
+	}
+
+	if out, err := r.Run("lvchange "+action+" "+tag+" "+getFullName(vg, name), true); err != nil {
+		return errors.Wrapf(err, "failed to change tags of volume. Out: %v", out)
+	}
+
+	return nil
+}
+
+// ListVolumes lists logical volumes of the volume group.
+func ListVolumes(r runners.Runner, vg string) ([]ListEntry, error) {
+	listVolumesCmd := "lvs --reportformat json --units b --nosuffix --yes --options " + lvsOptions + " " + vg
 
 	out, err := r.Run(listVolumesCmd, false)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to list volumes")
 	}
 
+	return parseVolumeList(out)
+}
+
+func parseVolumeList(out string) ([]ListEntry, error) {
 	lvsOutput := &LvsOutput{}
-	if err = json.Unmarshal([]byte(out), lvsOutput); err != nil {
+	if err := json.Unmarshal([]byte(out), lvsOutput); err != nil {
 		return nil, errors.Wrapf(err, "failed to unmarshal json: %s", out)
 	}
 
@@ -115,6 +189,18 @@ func ListVolumes(r runners.Runner, lv string) ([]ListEntry, error) {
 	return lvsOutput.Reports[0].Volumes, nil
 }
 
+func mountVolume(r runners.Runner, vg, name, mountDir string) error {
+	fullMountDir := getFullMountDir(mountDir, name)
+	mountCmd := "mkdir -p " + fullMountDir + " && " +
+		"mount /dev/" + getFullName(vg, name) + " " + fullMountDir
+
+	if _, err := r.Run(mountCmd, true); err != nil {
+		return errors.Wrap(err, "failed to mount a volume")
+	}
+
+	return nil
+}
+
 func getFullName(vg, name string) string {
 	return fmt.Sprintf("%s/%s", vg, name)
 }
@@ -5,34 +5,63 @@
 package lvm
 
 import (
+	"fmt"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/pkg/errors"
 
 	"gitlab.com/postgres-ai/database-lab/v3/internal/provision/resources"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/provision/runners"
+	"gitlab.com/postgres-ai/database-lab/v3/internal/provision/thinclones"
 	"gitlab.com/postgres-ai/database-lab/v3/pkg/log"
 	"gitlab.com/postgres-ai/database-lab/v3/pkg/models"
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/util"
 )
 
 const (
 	poolPartsLen = 2
+
+	// technicalSnapshotID defines the snapshot representing the current state of a volume that does not support thin snapshots.
+	technicalSnapshotID = "TechnicalSnapshot"
+
+	snapshotPrefix = "snapshot_"
+	snapshotTag    = "dblab_snapshot"
+	protectedTag   = "dblab_protected"
+
+	lvTimeLayout = "2006-01-02 15:04:05 -0700"
 )
 
 // LVManager describes an LVM2 filesystem manager.
+//
+// Snapshots are read-only thin snapshots of the pool logical volume or of clones. They are tagged with "dblab_snapshot"
+// and named "<origin>_snapshot_<dataStateAt>", so "pool@snapshot_20220101000000" is kept in the "<lv>_snapshot_20220101000000" volume
+// and "pool/dblab_clone_6000@snapshot_20220101000000" is kept in the "dblab_clone_6000_snapshot_20220101000000" volume.
+// Clones are writable thin snapshots of snapshots.
 type LVManager struct {
 	runner        runners.Runner
-	pool          *resources.Pool
+	config        Config
 	volumeGroup   string
 	logicalVolume string
+	mu            *sync.Mutex
+	snapshots     []resources.Snapshot
+}
+
+// Config defines configuration for the LVM manager.
+type Config struct {
+	Pool              *resources.Pool
+	PreSnapshotSuffix string
 }
 
 // NewFSManager creates a new Manager instance for LVM.
-func NewFSManager(runner runners.Runner, pool *resources.Pool) (*LVManager, error) {
+func NewFSManager(runner runners.Runner, config Config) (*LVManager, error) {
 	m := LVManager{
-		runner: runner,
-		pool:   pool,
+		runner:    runner,
+		config:    config,
+		mu:        &sync.Mutex{},
+		snapshots: make([]resources.Snapshot, 0),
 	}
 
 	if err := m.parsePool(); err != nil {
@@ -44,22 +73,53 @@ func NewFSManager(runner runners.Runner, pool *resources.Pool) (*LVManager, erro
 
 // Pool gets a storage pool.
 func (m *LVManager) Pool() *resources.Pool {
-	return m.pool
+	return m.config.Pool
 }
 
-// UpdateConfig updates the manager's pool.
-func (m *LVManager) UpdateConfig(pool *resources.Pool) {
-	m.pool = pool
+// UpdateConfig updates the manager's configuration.
+func (m *LVManager) UpdateConfig(cfg Config) {
+	m.config = cfg
 }
 
-// CreateClone creates a new volume.
-func (m *LVManager) CreateClone(name, _ string) error {
-	return CreateVolume(m.runner, m.volumeGroup, m.logicalVolume, name, m.pool.ClonesDir())
+// CreateClone creates a new volume from the snapshot.
+// Volumes not supporting thin snapshots are cloned from the current state of the pool volume.
+func (m *LVManager) CreateClone(name, snapshotID string) error {
+	if snapshotID == "" || snapshotID == technicalSnapshotID {
+		return CreateVolume(m.runner, m.volumeGroup, m.logicalVolume, name, m.config.Pool.ClonesDir())
+	}
+
+	snapshotVolume, err := m.snapshotVolumeName(snapshotID)
+	if err != nil {
+		return err
+	}
+
+	return CreateThinVolume(m.runner, m.volumeGroup, snapshotVolume, name, m.config.Pool.ClonesDir())
 }
 
-// DestroyClone destroys volumes.
+// DestroyClone destroys the clone volume and snapshots taken from the clone.
 func (m *LVManager) DestroyClone(name string) error {
-	return RemoveVolume(m.runner, m.volumeGroup, m.logicalVolume, name, m.pool.ClonesDir())
+	if err := RemoveVolume(m.runner, m.volumeGroup, m.logicalVolume, name, m.config.Pool.ClonesDir()); err != nil {
+		return err
+	}
+
+	volumes, err := ListVolumes(m.runner, m.volumeGroup)
+	if err != nil {
+		return errors.Wrap(err, "failed to list LVM volumes")
+	}
+
+	for _, volume := range volumes {
+		if !volume.HasTag(snapshotTag) || !strings.HasPrefix(volume.Name, name+"_"+snapshotPrefix) {
+			continue
+		}
+
+		if err := RemoveSnapshot(m.runner, m.volumeGroup, volume.Name); err != nil {
+			return errors.Wrap(err, "failed to destroy a snapshot of the clone")
+		}
+	}
+
+	m.RefreshSnapshotList()
+
+	return nil
 }
 
 // ListClonesNames returns a list of clone names.
@@ -72,16 +132,18 @@ func (m *LVManager) ListClonesNames() ([]string, error) {
 	volumesNames := make([]string, 0, len(volumes))
 
 	for _, volume := range volumes {
-		volumesNames = append(volumesNames, volume.Name)
+		if strings.HasPrefix(volume.Name, util.ClonePrefix) && !volume.HasTag(snapshotTag) {
+			volumesNames = append(volumesNames, volume.Name)
+		}
 	}
 
 	return volumesNames, nil
 }
 
 func (m *LVManager) parsePool() error {
-	parts := strings.SplitN(m.pool.Name, "-", poolPartsLen)
+	parts := strings.SplitN(m.config.Pool.Name, "-", poolPartsLen)
 	if len(parts) < poolPartsLen {
-		return errors.Errorf("failed to extract volume group and logical volume from %q", m.pool.Name)
+		return errors.Errorf("failed to extract volume group and logical volume from %q", m.config.Pool.Name)
 	}
 
 	m.volumeGroup = parts[0]
@@ -90,53 +152,437 @@ func (m *LVManager) parsePool() error {
 	return nil
 }
 
-// CreateSnapshot is not supported in LVM mode.
-func (m *LVManager) CreateSnapshot(_, _ string) (string, error) {
-	log.Msg("Creating a snapshot is not supported in LVM mode. Skip the operation.")
+// CreateSnapshot creates a read-only thin snapshot of the pool volume or of the clone volume if the pool suffix is specified.
+func (m *LVManager) CreateSnapshot(poolSuffix, dataStateAt string) (string, error) {
+	volumes, err := ListVolumes(m.runner, m.volumeGroup)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to list LVM volumes")
+	}
+
+	if !m.isThin(volumes) {
+		log.Msg("Creating a snapshot requires a thin logical volume. Skip the operation.")
+
+		return "", nil
+	}
+
+	if dataStateAt == "" {
+		dataStateAt = time.Now().Format(util.DataStateAtFormat)
+	}
+
+	snapshotName := m.snapshotName(poolSuffix, dataStateAt)
+
+	snapshotVolume, err := m.snapshotVolumeName(snapshotName)
+	if err != nil {
+		return "", err
+	}
+
+	for _, volume := range volumes {
+		if volume.Name == snapshotVolume {
+			return "", thinclones.NewSnapshotExistsError(snapshotName)
+		}
+	}
+
+	origin := m.logicalVolume
+	if poolSuffix != "" {
+		origin = poolSuffix
+	}
+
+	if err := CreateThinSnapshot(m.runner, m.volumeGroup, origin, snapshotVolume, "r", snapshotTag); err != nil {
+		return "", errors.Wrap(err, "failed to create snapshot")
+	}
+
+	if !strings.HasSuffix(snapshotName, m.config.PreSnapshotSuffix) {
+		m.RefreshSnapshotList()
+	}
+
+	return snapshotName, nil
+}
+
+// DestroySnapshot destroys the snapshot volume.
+func (m *LVManager) DestroySnapshot(snapshotName string, _ thinclones.DestroyOptions) error {
+	snapshotVolume, err := m.snapshotVolumeName(snapshotName)
+	if err != nil {
+		return err
+	}
+
+	if err := RemoveSnapshot(m.runner, m.volumeGroup, snapshotVolume); err != nil {
+		return errors.Wrap(err, "failed to destroy snapshot")
+	}
+
+	m.removeSnapshotFromList(snapshotName)
 
-	return "", nil
+	return nil
 }
 
-// DestroySnapshot is not supported in LVM mode.
-func (m *LVManager) DestroySnapshot(_ string) error {
-	log.Msg("Destroying a snapshot is not supported in LVM mode. Skip the operation.")
+// ProtectSnapshot marks the snapshot volume as protected from the retention or removes the mark.
+func (m *LVManager) ProtectSnapshot(snapshotName string, protected bool) error {
+	snapshotVolume, err := m.snapshotVolumeName(snapshotName)
+	if err != nil {
+		return err
+	}
+
+	if err := ChangeTag(m.runner, m.volumeGroup, snapshotVolume, protectedTag, protected); err != nil {
+		return errors.Wrap(err, "failed to change the protection of snapshot")
+	}
+
+	m.RefreshSnapshotList()
 
 	return nil
 }
 
-// CleanupSnapshots is not supported in LVM mode.
-func (m *LVManager) CleanupSnapshots(_ int) ([]string, error) {
-	log.Msg("Cleanup snapshots is not supported in LVM mode. Skip the operation.")
+// CleanupSnapshots destroys old snapshots of the pool considering retention limit and related clones.
+// Pre-clones created from the destroyed snapshots are destroyed as well.
+func (m *LVManager) CleanupSnapshots(retentionLimit int) ([]string, error) {
+	volumes, err := ListVolumes(m.runner, m.volumeGroup)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list LVM volumes")
+	}
+
+	destroyed := []string{}
+
+	for _, candidate := range m.getRetentionCandidates(volumes, retentionLimit) {
+		snapshotVolume, err := m.snapshotVolumeName(candidate)
+		if err != nil {
+			return destroyed, err
+		}
+
+		for _, preClone := range m.dependentClones(volumes, snapshotVolume) {
+			if err := m.DestroyClone(preClone.Name); err != nil {
+				return destroyed, errors.Wrap(err, "failed to destroy pre-clone")
+			}
+		}
+
+		if err := m.DestroySnapshot(candidate, thinclones.DestroyOptions{}); err != nil {
+			return destroyed, errors.Wrap(err, "failed to clean up snapshots")
+		}
+
+		destroyed = append(destroyed, candidate)
+	}
+
+	m.RefreshSnapshotList()
 
-	return nil, nil
+	return destroyed, nil
 }
 
-// SnapshotList is not implemented.
-func (m *LVManager) SnapshotList() []resources.Snapshot {
-	// TODO(anatoly): Not supported in LVM mode warning.
-	return []resources.Snapshot{
-		{
-			ID:          "TechnicalSnapshot",
-			CreatedAt:   time.Now(),
-			DataStateAt: time.Now(),
-			Pool:        m.pool.Name,
-		},
+// getRetentionCandidates returns snapshots of the pool exceeding the retention limit
+// that are neither protected nor used by user clones.
+func (m *LVManager) getRetentionCandidates(volumes []ListEntry, retentionLimit int) []string {
+	poolSnapshots := []string{}
+
+	for _, volume := range volumes {
+		if snapshotID, ok := m.snapshotID(volume); ok && !strings.Contains(snapshotID, "/") {
+			poolSnapshots = append(poolSnapshots, snapshotID)
+		}
+	}
+
+	return thinclones.RetentionCandidates(poolSnapshots, retentionLimit, m.dataStateAt, func(snapshotID string) bool {
+		return m.isBusySnapshot(volumes, snapshotID)
+	})
+}
+
+// isBusySnapshot checks if the snapshot or snapshots of pre-clones created from it are protected or used by user clones.
+func (m *LVManager) isBusySnapshot(volumes []ListEntry, snapshotID string) bool {
+	snapshotVolume, err := m.snapshotVolumeName(snapshotID)
+	if err != nil {
+		return true
+	}
+
+	related := []string{snapshotVolume}
+
+	for _, preClone := range m.dependentClones(volumes, snapshotVolume) {
+		if strings.HasPrefix(preClone.Name, util.ClonePrefix) {
+			return true
+		}
+
+		for _, volume := range volumes {
+			if volume.HasTag(snapshotTag) && strings.HasPrefix(volume.Name, preClone.Name+"_"+snapshotPrefix) {
+				related = append(related, volume.Name)
+			}
+		}
+	}
+
+	for _, relatedVolume := range related {
+		for _, volume := range volumes {
+			if volume.Name == relatedVolume && volume.HasTag(protectedTag) {
+				return true
+			}
+		}
+
+		for _, clone := range m.dependentClones(volumes, relatedVolume) {
+			if strings.HasPrefix(clone.Name, util.ClonePrefix) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// dependentClones returns clones created from the volume.
+func (m *LVManager) dependentClones(volumes []ListEntry, origin string) []ListEntry {
+	clones := []ListEntry{}
+
+	for _, volume := range volumes {
+		if volume.Origin == origin && !volume.HasTag(snapshotTag) {
+			clones = append(clones, volume)
+		}
 	}
+
+	return clones
 }
 
-// RefreshSnapshotList is not supported in LVM mode.
+// SnapshotList returns a list of snapshots.
+func (m *LVManager) SnapshotList() []resources.Snapshot {
+	m.mu.Lock()
+	snapshots := m.snapshots
+	m.mu.Unlock()
+
+	return snapshots
+}
+
+// RefreshSnapshotList updates the list of snapshots.
 func (m *LVManager) RefreshSnapshotList() {
-	log.Msg("RefreshSnapshotList is not supported for LVM. Skip the operation")
+	snapshots, err := m.getSnapshots()
+	if err != nil {
+		log.Err("Failed to refresh snapshot list: ", err)
+		return
+	}
+
+	m.mu.Lock()
+	m.snapshots = snapshots
+	m.mu.Unlock()
+}
+
+func (m *LVManager) getSnapshots() ([]resources.Snapshot, error) {
+	volumes, err := ListVolumes(m.runner, m.volumeGroup)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list LVM volumes")
+	}
+
+	if !m.isThin(volumes) {
+		// Volumes without thin provisioning have the only data state.
+		return []resources.Snapshot{
+			{
+				ID:          technicalSnapshotID,
+				CreatedAt:   time.Now(),
+				DataStateAt: time.Now(),
+				Pool:        m.config.Pool.Name,
+			},
+		}, nil
+	}
+
+	snapshots := []resources.Snapshot{}
+
+	for _, volume := range volumes {
+		snapshotID, ok := m.snapshotID(volume)
+
+		// Filter pre-snapshots, they will not be allowed to be used for cloning.
+		if !ok || strings.HasSuffix(snapshotID, m.config.PreSnapshotSuffix) {
+			continue
+		}
+
+		createdAt, err := time.Parse(lvTimeLayout, volume.Time)
+		if err != nil {
+			log.Dbg(fmt.Sprintf("failed to parse the creation time of volume %s: %v", volume.Name, err))
+		}
+
+		snapshots = append(snapshots, resources.Snapshot{
+			ID:                snapshotID,
+			CreatedAt:         createdAt,
+			DataStateAt:       m.dataStateAt(snapshotID),
+			Used:              volume.UsedBytes(),
+			LogicalReferenced: volume.UsedBytes(),
+			Pool:              m.config.Pool.Name,
+			Protected:         volume.HasTag(protectedTag),
+		})
+	}
+
+	sort.SliceStable(snapshots, func(i, j int) bool {
+		if snapshots[i].DataStateAt.Equal(snapshots[j].DataStateAt) {
+			return snapshots[i].CreatedAt.After(snapshots[j].CreatedAt)
+		}
+
+		return snapshots[i].DataStateAt.After(snapshots[j].DataStateAt)
+	})
+
+	return snapshots, nil
+}
+
+func (m *LVManager) removeSnapshotFromList(snapshotName string) {
+	m.mu.Lock()
+
+	for i, snapshot := range m.snapshots {
+		if snapshot.ID == snapshotName {
+			m.snapshots = append((m.snapshots)[:i], (m.snapshots)[i+1:]...)
+
+			break
+		}
+	}
+
+	m.mu.Unlock()
 }
 
-// GetSessionState is not implemented.
-func (m *LVManager) GetSessionState(_ string) (*resources.SessionState, error) {
-	// TODO(anatoly): Implement.
-	return &resources.SessionState{}, nil
+// GetSessionState returns a state of a session.
+// Thin volumes do not report exclusive blocks, so the clone diff size is estimated
+// as the difference between the data mapped by the clone and by its origin.
+func (m *LVManager) GetSessionState(name string) (*resources.SessionState, error) {
+	volumes, err := ListVolumes(m.runner, m.volumeGroup)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list LVM volumes")
+	}
+
+	clone, ok := findVolume(volumes, name)
+	if !ok {
+		return nil, errors.Errorf("volume %q not found", name)
+	}
+
+	used := clone.UsedBytes()
+
+	state := &resources.SessionState{
+		CloneDiffSize:     used,
+		LogicalReferenced: used,
+	}
+
+	origin, ok := findVolume(volumes, clone.Origin)
+	if !ok {
+		return state, nil
+	}
+
+	if clone.Pool == "" {
+		// Old-style snapshots report the usage of the copy-on-write area.
+		state.LogicalReferenced = origin.SizeBytes()
+
+		return state, nil
+	}
+
+	state.CloneDiffSize = 0
+
+	if originUsed := origin.UsedBytes(); used > originUsed {
+		state.CloneDiffSize = used - originUsed
+	}
+
+	return state, nil
 }
 
-// GetFilesystemState is not implemented.
+// GetFilesystemState returns a disk state.
 func (m *LVManager) GetFilesystemState() (models.FileSystem, error) {
-	// TODO(anatoly): Implement.
-	return models.FileSystem{Mode: PoolMode}, nil
+	volumes, err := ListVolumes(m.runner, m.volumeGroup)
+	if err != nil {
+		return models.FileSystem{}, errors.Wrap(err, "failed to list LVM volumes")
+	}
+
+	fileSystem := models.FileSystem{
+		Mode:          PoolMode,
+		CompressRatio: 1,
+	}
+
+	poolVolume, ok := findVolume(volumes, m.logicalVolume)
+	if !ok {
+		return fileSystem, errors.Errorf("volume %q not found", m.logicalVolume)
+	}
+
+	fileSystem.Size = poolVolume.SizeBytes()
+	fileSystem.DataSize = poolVolume.UsedBytes()
+
+	if thinPool, ok := findVolume(volumes, poolVolume.Pool); ok && poolVolume.Pool != "" {
+		fileSystem.Size = thinPool.SizeBytes()
+		fileSystem.Used = thinPool.UsedBytes()
+		fileSystem.Free = fileSystem.Size - fileSystem.Used
+	}
+
+	for _, volume := range volumes {
+		switch {
+		case volume.HasTag(snapshotTag):
+			fileSystem.UsedBySnapshots += volume.UsedBytes()
+
+		case strings.HasPrefix(volume.Name, util.ClonePrefix):
+			fileSystem.UsedByClones += volume.UsedBytes()
+		}
+	}
+
+	return fileSystem, nil
+}
+
+// isThin checks if the pool volume is a thin volume supporting thin snapshots.
+func (m *LVManager) isThin(volumes []ListEntry) bool {
+	poolVolume, ok := findVolume(volumes, m.logicalVolume)
+
+	return ok && poolVolume.Pool != ""
+}
+
+// snapshotID returns the snapshot ID of the snapshot volume.
+func (m *LVManager) snapshotID(volume ListEntry) (string, bool) {
+	if !volume.HasTag(snapshotTag) {
+		return "", false
+	}
+
+	separatorIndex := strings.LastIndex(volume.Name, "_"+snapshotPrefix)
+	if separatorIndex <= 0 {
+		return "", false
+	}
+
+	origin, name := volume.Name[:separatorIndex], volume.Name[separatorIndex+1:]
+
+	poolSuffix := origin
+	if origin == m.logicalVolume {
+		poolSuffix = ""
+	}
+
+	return m.snapshotName(poolSuffix, strings.TrimPrefix(name, snapshotPrefix)), true
+}
+
+// snapshotVolumeName returns the name of the volume keeping the snapshot.
+func (m *LVManager) snapshotVolumeName(snapshotID string) (string, error) {
+	const snapshotIDParts = 2
+
+	parts := strings.SplitN(snapshotID, "@", snapshotIDParts)
+	if len(parts) != snapshotIDParts || !strings.HasPrefix(parts[1], snapshotPrefix) || strings.ContainsAny(parts[1], "/ ") {
+		return "", fmt.Errorf("invalid snapshot ID %q", snapshotID)
+	}
+
+	dataset, name := parts[0], parts[1]
+
+	if dataset == m.config.Pool.Name {
+		return m.logicalVolume + "_" + name, nil
+	}
+
+	poolSuffix := strings.TrimPrefix(dataset, m.config.Pool.Name+"/")
+	if poolSuffix == dataset || poolSuffix == "" || strings.ContainsAny(poolSuffix, "/ ") {
+		return "", fmt.Errorf("snapshot %q does not belong to pool %q", snapshotID, m.config.Pool.Name)
+	}
+
+	return poolSuffix + "_" + name, nil
+}
+
+func (m *LVManager) snapshotName(poolSuffix, dataStateAt string) string {
+	dataset := m.config.Pool.Name
+
+	if poolSuffix != "" {
+		dataset += "/" + poolSuffix
+	}
+
+	return fmt.Sprintf("%s@%s%s", dataset, snapshotPrefix, dataStateAt)
+}
+
+func (m *LVManager) dataStateAt(snapshotID string) time.Time {
+	dataStateAt := snapshotID[strings.LastIndex(snapshotID, "@")+1:]
+	dataStateAt = strings.TrimPrefix(dataStateAt, snapshotPrefix)
+	dataStateAt = strings.TrimSuffix(dataStateAt, m.config.PreSnapshotSuffix)
+
+	dataStateTime, err := util.ParseCustomTime(dataStateAt)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return dataStateTime
+}
+
+func findVolume(volumes []ListEntry, name string) (ListEntry, bool) {
+	for _, volume := range volumes {
+		if volume.Name == name {
+			return volume, true
+		}
+	}
+
+	return ListEntry{}, false
 }
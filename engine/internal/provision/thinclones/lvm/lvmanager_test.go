@@ -0,0 +1,187 @@
+package lvm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/postgres-ai/database-lab/v3/internal/provision/resources"
+)
+
+type runnerMock struct {
+	cmdOutput string
+	err       error
+}
+
+func (r runnerMock) Run(string, ...bool) (string, error) {
+	return r.cmdOutput, r.err
+}
+
+const lvsOutput = `{
+  "report": [
+    {
+      "lv": [
+        {"lv_name":"pg_lv", "vg_name":"dblab_vg", "lv_attr":"Vwi-aotz--", "lv_size":"10737418240", "pool_lv":"pg_pool",
+          "origin":"", "data_percent":"10.00", "lv_tags":"", "lv_time":"2022-01-27 09:00:00 +0000"},
+        {"lv_name":"pg_pool", "vg_name":"dblab_vg", "lv_attr":"twi-aotz--", "lv_size":"21474836480", "pool_lv":"",
+          "origin":"", "data_percent":"25.00", "lv_tags":"", "lv_time":"2022-01-27 09:00:00 +0000"},
+        {"lv_name":"pg_lv_snapshot_20220127100000", "vg_name":"dblab_vg", "lv_attr":"Vri-a-tz-k", "lv_size":"10737418240",
+          "pool_lv":"pg_pool", "origin":"pg_lv", "data_percent":"5.00", "lv_tags":"dblab_snapshot", "lv_time":"2022-01-27 10:00:00 +0000"},
+        {"lv_name":"pg_lv_snapshot_20220127110000_pre", "vg_name":"dblab_vg", "lv_attr":"Vri-a-tz-k", "lv_size":"10737418240",
+          "pool_lv":"pg_pool", "origin":"pg_lv", "data_percent":"6.00", "lv_tags":"dblab_snapshot", "lv_time":"2022-01-27 11:00:00 +0000"},
+        {"lv_name":"clone_pre_20220127110000", "vg_name":"dblab_vg", "lv_attr":"Vwi-aotz--", "lv_size":"10737418240",
+          "pool_lv":"pg_pool", "origin":"pg_lv_snapshot_20220127110000_pre", "data_percent":"6.00", "lv_tags":"",
+          "lv_time":"2022-01-27 11:00:01 +0000"},
+        {"lv_name":"clone_pre_20220127110000_snapshot_20220127110000", "vg_name":"dblab_vg", "lv_attr":"Vri-a-tz-k",
+          "lv_size":"10737418240", "pool_lv":"pg_pool", "origin":"clone_pre_20220127110000", "data_percent":"6.00",
+          "lv_tags":"dblab_snapshot", "lv_time":"2022-01-27 11:05:00 +0000"},
+        {"lv_name":"pg_lv_snapshot_20220127120000", "vg_name":"dblab_vg", "lv_attr":"Vri-a-tz-k", "lv_size":"10737418240",
+          "pool_lv":"pg_pool", "origin":"pg_lv", "data_percent":"7.00", "lv_tags":"dblab_snapshot", "lv_time":"2022-01-27 12:00:00 +0000"},
+        {"lv_name":"dblab_clone_6000", "vg_name":"dblab_vg", "lv_attr":"Vwi-aotz--", "lv_size":"10737418240", "pool_lv":"pg_pool",
+          "origin":"pg_lv_snapshot_20220127120000", "data_percent":"8.00", "lv_tags":"", "lv_time":"2022-01-27 12:30:00 +0000"},
+        {"lv_name":"pg_lv_snapshot_20220127130000", "vg_name":"dblab_vg", "lv_attr":"Vri-a-tz-k", "lv_size":"10737418240",
+          "pool_lv":"pg_pool", "origin":"pg_lv", "data_percent":"9.00", "lv_tags":"dblab_snapshot,dblab_protected",
+          "lv_time":"2022-01-27 13:00:00 +0000"}
+      ]
+    }
+  ]
+}`
+
+func newTestManager(t *testing.T, cmdOutput string) *LVManager {
+	t.Helper()
+
+	m, err := NewFSManager(runnerMock{cmdOutput: cmdOutput}, Config{
+		Pool:              &resources.Pool{Name: "dblab_vg-pg_lv", MountDir: "/var/lib/dblab", PoolDirName: "dblab_vg-pg_lv"},
+		PreSnapshotSuffix: "_pre",
+	})
+	require.NoError(t, err)
+
+	return m
+}
+
+func TestSnapshotNames(t *testing.T) {
+	m := newTestManager(t, lvsOutput)
+
+	testCases := []struct {
+		volume     string
+		snapshotID string
+	}{
+		{volume: "pg_lv_snapshot_20220127100000", snapshotID: "dblab_vg-pg_lv@snapshot_20220127100000"},
+		{volume: "pg_lv_snapshot_20220127110000_pre", snapshotID: "dblab_vg-pg_lv@snapshot_20220127110000_pre"},
+		{
+			volume:     "clone_pre_20220127110000_snapshot_20220127110000",
+			snapshotID: "dblab_vg-pg_lv/clone_pre_20220127110000@snapshot_20220127110000",
+		},
+	}
+
+	for _, tc := range testCases {
+		snapshotID, ok := m.snapshotID(ListEntry{Name: tc.volume, Tags: snapshotTag})
+		require.True(t, ok)
+		assert.Equal(t, tc.snapshotID, snapshotID)
+
+		volume, err := m.snapshotVolumeName(tc.snapshotID)
+		require.NoError(t, err)
+		assert.Equal(t, tc.volume, volume)
+	}
+
+	_, ok := m.snapshotID(ListEntry{Name: "pg_lv_snapshot_20220127100000"})
+	assert.False(t, ok)
+
+	for _, snapshotID := range []string{"dblab_vg-pg_lv", "other@snapshot_1", "dblab_vg-pg_lv/a/b@snapshot_1", "dblab_vg-pg_lv@1"} {
+		_, err := m.snapshotVolumeName(snapshotID)
+		assert.Error(t, err, snapshotID)
+	}
+}
+
+func TestSnapshotList(t *testing.T) {
+	m := newTestManager(t, lvsOutput)
+	m.RefreshSnapshotList()
+
+	snapshots := m.SnapshotList()
+	require.Len(t, snapshots, 4)
+
+	assert.Equal(t, "dblab_vg-pg_lv@snapshot_20220127130000", snapshots[0].ID)
+	assert.True(t, snapshots[0].Protected)
+	assert.Equal(t, uint64(966367641), snapshots[0].Used)
+	assert.Equal(t, "dblab_vg-pg_lv/clone_pre_20220127110000@snapshot_20220127110000", snapshots[2].ID)
+	assert.Equal(t, 11, snapshots[2].CreatedAt.Hour())
+}
+
+func TestSnapshotListWithoutThinProvisioning(t *testing.T) {
+	m := newTestManager(t, `{"report": [{"lv": [{"lv_name":"pg_lv", "vg_name":"dblab_vg", "lv_size":"10737418240", "pool_lv":""}]}]}`)
+	m.RefreshSnapshotList()
+
+	snapshots := m.SnapshotList()
+	require.Len(t, snapshots, 1)
+	assert.Equal(t, technicalSnapshotID, snapshots[0].ID)
+
+	snapshotID, err := m.CreateSnapshot("", "20220127100000")
+	require.NoError(t, err)
+	assert.Empty(t, snapshotID)
+}
+
+func TestListClonesNames(t *testing.T) {
+	m := newTestManager(t, lvsOutput)
+
+	cloneNames, err := m.ListClonesNames()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dblab_clone_6000"}, cloneNames)
+}
+
+func TestRetentionCandidates(t *testing.T) {
+	m := newTestManager(t, lvsOutput)
+
+	volumes, err := parseVolumeList(lvsOutput)
+	require.NoError(t, err)
+
+	// The snapshot of 12:00 is used by the user clone and the snapshot of 13:00 is protected, so they are kept regardless of the limit.
+	assert.Equal(t, []string{"dblab_vg-pg_lv@snapshot_20220127110000_pre", "dblab_vg-pg_lv@snapshot_20220127100000"},
+		m.getRetentionCandidates(volumes, 0))
+	assert.Equal(t, []string{"dblab_vg-pg_lv@snapshot_20220127100000"}, m.getRetentionCandidates(volumes, 3))
+	assert.Empty(t, m.getRetentionCandidates(volumes, 4))
+
+	// The user clone of the pre-clone snapshot makes the pre-snapshot busy.
+	for i := range volumes {
+		if volumes[i].Name == "dblab_clone_6000" {
+			volumes[i].Origin = "clone_pre_20220127110000_snapshot_20220127110000"
+		}
+	}
+
+	assert.Equal(t, []string{"dblab_vg-pg_lv@snapshot_20220127120000", "dblab_vg-pg_lv@snapshot_20220127100000"},
+		m.getRetentionCandidates(volumes, 0))
+}
+
+func TestSessionState(t *testing.T) {
+	m := newTestManager(t, lvsOutput)
+
+	state, err := m.GetSessionState("dblab_clone_6000")
+	require.NoError(t, err)
+	assert.Equal(t, &resources.SessionState{CloneDiffSize: 107374183, LogicalReferenced: 858993459}, state)
+
+	_, err = m.GetSessionState("dblab_clone_6001")
+	require.Error(t, err)
+}
+
+func TestFilesystemState(t *testing.T) {
+	m := newTestManager(t, lvsOutput)
+
+	fileSystem, err := m.GetFilesystemState()
+	require.NoError(t, err)
+
+	assert.Equal(t, uint64(21474836480), fileSystem.Size)
+	assert.Equal(t, uint64(5368709120), fileSystem.Used)
+	assert.Equal(t, uint64(16106127360), fileSystem.Free)
+	assert.Equal(t, uint64(1073741824), fileSystem.DataSize)
+	assert.Equal(t, uint64(858993459), fileSystem.UsedByClones)
+	assert.Equal(t, PoolMode, fileSystem.Mode)
+}
+
+func TestHasTag(t *testing.T) {
+	volume := ListEntry{Tags: "dblab_snapshot,dblab_protected"}
+
+	assert.True(t, volume.HasTag(snapshotTag))
+	assert.True(t, volume.HasTag(protectedTag))
+	assert.False(t, volume.HasTag("dblab"))
+	assert.False(t, ListEntry{}.HasTag(snapshotTag))
+}
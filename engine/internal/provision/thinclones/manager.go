@@ -9,6 +9,12 @@ import (
 	"fmt"
 )
 
+// DestroyOptions defines how a snapshot is destroyed.
+type DestroyOptions struct {
+	// Force destroys clones depending on the snapshot along with it.
+	Force bool
+}
+
 // SnapshotExistsError defines an error when snapshot already exists.
 type SnapshotExistsError struct {
 	name string
@@ -0,0 +1,36 @@
+/*
+2022 © Postgres.ai
+*/
+
+package thinclones
+
+import (
+	"sort"
+	"time"
+)
+
+// RetentionCandidates returns snapshots exceeding the retention limit that are not busy.
+// The newest snapshots according to their data state time are kept.
+func RetentionCandidates(snapshotIDs []string, retentionLimit int, dataStateAt func(string) time.Time,
+	isBusy func(string) bool) []string {
+	if len(snapshotIDs) <= retentionLimit {
+		return nil
+	}
+
+	snapshots := make([]string, len(snapshotIDs))
+	copy(snapshots, snapshotIDs)
+
+	sort.SliceStable(snapshots, func(i, j int) bool {
+		return dataStateAt(snapshots[i]).After(dataStateAt(snapshots[j]))
+	})
+
+	candidates := []string{}
+
+	for _, snapshotID := range snapshots[retentionLimit:] {
+		if !isBusy(snapshotID) {
+			candidates = append(candidates, snapshotID)
+		}
+	}
+
+	return candidates
+}
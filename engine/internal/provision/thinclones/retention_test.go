@@ -0,0 +1,37 @@
+/*
+2022 © Postgres.ai
+*/
+
+package thinclones
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetentionCandidates(t *testing.T) {
+	dataStates := map[string]time.Time{
+		"pool@snapshot_20220127100000": time.Date(2022, 1, 27, 10, 0, 0, 0, time.UTC),
+		"pool@snapshot_20220127110000": time.Date(2022, 1, 27, 11, 0, 0, 0, time.UTC),
+		"pool@snapshot_20220127120000": time.Date(2022, 1, 27, 12, 0, 0, 0, time.UTC),
+	}
+
+	snapshotIDs := []string{"pool@snapshot_20220127110000", "pool@snapshot_20220127100000", "pool@snapshot_20220127120000"}
+
+	dataStateAt := func(snapshotID string) time.Time {
+		return dataStates[snapshotID]
+	}
+
+	isBusy := func(snapshotID string) bool {
+		return snapshotID == "pool@snapshot_20220127110000"
+	}
+
+	assert.Equal(t, []string{"pool@snapshot_20220127100000"}, RetentionCandidates(snapshotIDs, 1, dataStateAt, isBusy))
+	assert.Equal(t, []string{"pool@snapshot_20220127120000", "pool@snapshot_20220127100000"},
+		RetentionCandidates(snapshotIDs, 0, dataStateAt, isBusy))
+	assert.Empty(t, RetentionCandidates(snapshotIDs, 3, dataStateAt, isBusy))
+	assert.Equal(t, []string{"pool@snapshot_20220127110000", "pool@snapshot_20220127100000", "pool@snapshot_20220127120000"},
+		snapshotIDs, "the input order must be kept")
+}
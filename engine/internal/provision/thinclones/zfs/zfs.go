@@ -28,6 +28,14 @@ const (
 	headerOffset        = 1
 	dataStateAtLabel    = "dblab:datastateat"
 	isRoughStateAtLabel = "dblab:isroughdsa"
+	protectedLabel      = "dblab:protected"
+	protectedValue      = "on"
+
+	// preClonePrefix starts names of datasets the physical snapshot job takes snapshots in.
+	preClonePrefix = "clone_pre_"
+
+	// pitrClonePrefix starts names of datasets point-in-time snapshots are taken in.
+	pitrClonePrefix = "clone_pitr_"
 
 	// PoolMode defines the zfs filesystem name.
 	PoolMode = "zfs"
@@ -357,8 +365,14 @@ func RollbackSnapshot(r runners.Runner, pool string, snapshot string) error {
 }
 
 // DestroySnapshot destroys the snapshot.
-func (m *Manager) DestroySnapshot(snapshotName string) error {
-	cmd := fmt.Sprintf("zfs destroy -R %s", snapshotName)
+// Without the force option, ZFS refuses to destroy the snapshot if it has clones,
+// and the pre-clone dataset the snapshot has been taken in is destroyed along with its pre-snapshot.
+func (m *Manager) DestroySnapshot(snapshotName string, opts thinclones.DestroyOptions) error {
+	cmd := fmt.Sprintf("zfs destroy %s", snapshotName)
+
+	if opts.Force {
+		cmd = fmt.Sprintf("zfs destroy -R %s", snapshotName)
+	}
 
 	if _, err := m.runner.Run(cmd); err != nil {
 		return errors.Wrap(err, "failed to run command")
@@ -366,9 +380,41 @@ func (m *Manager) DestroySnapshot(snapshotName string) error {
 
 	m.removeSnapshotFromList(snapshotName)
 
+	if !opts.Force {
+		m.destroyPreClone(snapshotName)
+	}
+
 	return nil
 }
 
+// destroyPreClone destroys the pre-clone dataset of the snapshot and the pre-snapshot the dataset has been cloned from.
+// Nothing is destroyed if the dataset still has snapshots or the pre-snapshot has other clones.
+func (m *Manager) destroyPreClone(snapshotName string) {
+	dataset, _, ok := strings.Cut(snapshotName, "@")
+	if !ok || !strings.HasPrefix(dataset, m.config.Pool.Name+"/"+preClonePrefix) {
+		return
+	}
+
+	preSnapshot, err := m.runner.Run(buildOriginCommand(dataset), false)
+	if err != nil {
+		log.Err("failed to get pre-clone origin:", err)
+		return
+	}
+
+	if _, err := m.runner.Run("zfs destroy " + dataset); err != nil {
+		log.Err(fmt.Sprintf("failed to destroy pre-clone %s: %v", dataset, err))
+		return
+	}
+
+	if preSnapshot = strings.TrimSpace(preSnapshot); preSnapshot == "" || preSnapshot == "-" {
+		return
+	}
+
+	if _, err := m.runner.Run("zfs destroy " + preSnapshot); err != nil {
+		log.Err(fmt.Sprintf("failed to destroy pre-snapshot %s: %v", preSnapshot, err))
+	}
+}
+
 // CleanupSnapshots destroys old snapshots considering retention limit and related clones.
 func (m *Manager) CleanupSnapshots(retentionLimit int) ([]string, error) {
 	clonesCmd := fmt.Sprintf("zfs list -S clones -o name,origin -H -r %s", m.config.Pool.Name)
@@ -378,7 +424,12 @@ func (m *Manager) CleanupSnapshots(retentionLimit int) ([]string, error) {
 		return nil, errors.Wrap(err, "failed to list snapshots")
 	}
 
-	busySnapshots := m.getBusySnapshotList(clonesOutput)
+	protectedSnapshots, err := m.listProtectedSnapshots()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list protected snapshots")
+	}
+
+	busySnapshots := m.getBusySnapshotList(clonesOutput, protectedSnapshots)
 
 	cleanupCmd := fmt.Sprintf(
 		"zfs list -t snapshot -H -o name -s %s -s creation -r %s | grep -v clone | head -n -%d %s"+
@@ -394,10 +445,82 @@ func (m *Manager) CleanupSnapshots(retentionLimit int) ([]string, error) {
 
 	m.RefreshSnapshotList()
 
+	busyPITRSnapshots := userCloneOrigins(clonesOutput, m.config.Pool.Name+"/"+util.ClonePrefix)
+
+	for _, protectedSnapshot := range protectedSnapshots {
+		busyPITRSnapshots[protectedSnapshot] = struct{}{}
+	}
+
+	pitrLines, err := m.cleanupPITRSnapshots(retentionLimit, busyPITRSnapshots)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to clean up point-in-time snapshots")
+	}
+
+	if len(pitrLines) > 0 {
+		lines = append(lines, pitrLines...)
+
+		m.RefreshSnapshotList()
+	}
+
 	return lines, nil
 }
 
-func (m *Manager) getBusySnapshotList(clonesOutput string) []string {
+// cleanupPITRSnapshots destroys point-in-time snapshots exceeding the retention limit along with their datasets.
+// The datasets are cloned from pre-snapshots, so they are not counted by the retention of pre-snapshots.
+func (m *Manager) cleanupPITRSnapshots(retentionLimit int, busySnapshots map[string]struct{}) ([]string, error) {
+	pitrDatasetPrefix := m.config.Pool.Name + "/" + pitrClonePrefix
+	dataStateAt := make(map[string]time.Time)
+	snapshotIDs := []string{}
+
+	for _, snapshot := range m.SnapshotList() {
+		if strings.HasPrefix(snapshot.ID, pitrDatasetPrefix) {
+			dataStateAt[snapshot.ID] = snapshot.DataStateAt
+			snapshotIDs = append(snapshotIDs, snapshot.ID)
+		}
+	}
+
+	candidates := thinclones.RetentionCandidates(snapshotIDs, retentionLimit,
+		func(snapshotID string) time.Time { return dataStateAt[snapshotID] },
+		func(snapshotID string) bool {
+			_, ok := busySnapshots[snapshotID]
+			return ok
+		})
+
+	destroyed := make([]string, 0, len(candidates))
+
+	for _, snapshotID := range candidates {
+		dataset, _, _ := strings.Cut(snapshotID, "@")
+
+		// Clones of the snapshot make the command fail, so they are never destroyed.
+		if _, err := m.runner.Run("zfs destroy -r " + dataset); err != nil {
+			return destroyed, errors.Wrapf(err, "failed to destroy %s", dataset)
+		}
+
+		destroyed = append(destroyed, snapshotID)
+	}
+
+	return destroyed, nil
+}
+
+// userCloneOrigins returns the snapshots user clones have been created from.
+func userCloneOrigins(clonesOutput, userClonePrefix string) map[string]struct{} {
+	origins := make(map[string]struct{})
+
+	for _, line := range strings.Split(clonesOutput, "\n") {
+		cloneLine := strings.FieldsFunc(line, unicode.IsSpace)
+
+		if len(cloneLine) != 2 || cloneLine[1] == "-" || !strings.HasPrefix(cloneLine[0], userClonePrefix) {
+			continue
+		}
+
+		origins[cloneLine[1]] = struct{}{}
+	}
+
+	return origins
+}
+
+// getBusySnapshotList returns snapshots that must not be destroyed by the retention: origins of user clones and protected snapshots.
+func (m *Manager) getBusySnapshotList(clonesOutput string, protectedSnapshots []string) []string {
 	systemClones, userClones := make(map[string]string), make(map[string]struct{})
 
 	userClonePrefix := m.config.Pool.Name + "/" + util.ClonePrefix
@@ -424,15 +547,74 @@ func (m *Manager) getBusySnapshotList(clonesOutput string) []string {
 		systemClones[cloneLine[0]] = cloneLine[1]
 	}
 
-	busySnapshots := make([]string, 0, len(userClones))
+	busySnapshots := make([]string, 0, len(userClones)+len(protectedSnapshots))
 
 	for userClone := range userClones {
 		busySnapshots = append(busySnapshots, systemClones[userClone])
 	}
 
+	for _, protectedSnapshot := range protectedSnapshots {
+		dataset := protectedSnapshot
+
+		if idx := strings.Index(dataset, "@"); idx != -1 {
+			dataset = dataset[:idx]
+		}
+
+		// The retention destroys pre-snapshots recursively, so the origin of the snapshot dataset has to be kept as well.
+		if origin, ok := systemClones[dataset]; ok {
+			busySnapshots = append(busySnapshots, origin)
+			continue
+		}
+
+		busySnapshots = append(busySnapshots, protectedSnapshot)
+	}
+
 	return busySnapshots
 }
 
+// ProtectSnapshot marks the snapshot as protected from the retention or removes the mark.
+func (m *Manager) ProtectSnapshot(snapshotName string, protected bool) error {
+	cmd := fmt.Sprintf("zfs inherit %s %s", protectedLabel, snapshotName)
+
+	if protected {
+		cmd = fmt.Sprintf("zfs set %s=%q %s", protectedLabel, protectedValue, snapshotName)
+	}
+
+	if _, err := m.runner.Run(cmd, true); err != nil {
+		return errors.Wrap(err, "failed to change the protection of snapshot")
+	}
+
+	m.RefreshSnapshotList()
+
+	return nil
+}
+
+// listProtectedSnapshots returns names of snapshots protected from the retention.
+func (m *Manager) listProtectedSnapshots() ([]string, error) {
+	cmd := fmt.Sprintf("zfs list -t snapshot -H -o name,%s -r %s", protectedLabel, m.config.Pool.Name)
+
+	out, err := m.runner.Run(cmd, true)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list snapshots")
+	}
+
+	return parseProtectedSnapshots(out), nil
+}
+
+func parseProtectedSnapshots(listOutput string) []string {
+	protectedSnapshots := []string{}
+
+	for _, line := range strings.Split(listOutput, "\n") {
+		fields := strings.Fields(line)
+
+		if len(fields) == 2 && fields[1] == protectedValue {
+			protectedSnapshots = append(protectedSnapshots, fields[0])
+		}
+	}
+
+	return protectedSnapshots
+}
+
 // excludeBusySnapshots excludes snapshots that match a pattern by name.
 // The exclusion logic relies on the fact that snapshots have unique substrings (timestamps).
 func excludeBusySnapshots(busySnapshots []string) string {
@@ -549,6 +731,17 @@ func (m *Manager) getSnapshots() ([]resources.Snapshot, error) {
 		return nil, fmt.Errorf("failed to list snapshots: %w", err)
 	}
 
+	protectedSnapshots, err := m.listProtectedSnapshots()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list protected snapshots: %w", err)
+	}
+
+	protected := make(map[string]struct{}, len(protectedSnapshots))
+
+	for _, snapshotName := range protectedSnapshots {
+		protected[snapshotName] = struct{}{}
+	}
+
 	snapshots := make([]resources.Snapshot, 0, len(entries))
 
 	for _, entry := range entries {
@@ -566,6 +759,10 @@ func (m *Manager) getSnapshots() ([]resources.Snapshot, error) {
 			Pool:              m.config.Pool.Name,
 		}
 
+		if _, ok := protected[entry.Name]; ok {
+			snapshot.Protected = true
+		}
+
 		snapshots = append(snapshots, snapshot)
 	}
 
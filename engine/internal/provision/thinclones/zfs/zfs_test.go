@@ -3,11 +3,13 @@ package zfs
 import (
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"gitlab.com/postgres-ai/database-lab/v3/internal/provision/resources"
+	"gitlab.com/postgres-ai/database-lab/v3/internal/provision/thinclones"
 )
 
 type runnerMock struct {
@@ -130,10 +132,29 @@ dblab_pool/dblab_clone_6001	dblab_pool/clone_pre_20210127123000@snapshot_2021012
 `
 	expected := []string{"dblab_pool@snapshot_20210127133000_pre", "dblab_pool@snapshot_20210127123000_pre"}
 
-	list := m.getBusySnapshotList(out)
+	list := m.getBusySnapshotList(out, nil)
 	require.Equal(t, 2, len(list))
 	assert.Contains(t, list, expected[0])
 	assert.Contains(t, list, expected[1])
+
+	protected := []string{"dblab_pool/clone_pre_20210127113000@snapshot_20210127113000", "dblab_pool@snapshot_20210127100000"}
+
+	list = m.getBusySnapshotList(out, protected)
+	require.Equal(t, 4, len(list))
+	assert.Contains(t, list, "dblab_pool@snapshot_20210127113000_pre")
+	assert.Contains(t, list, "dblab_pool@snapshot_20210127100000")
+}
+
+func TestProtectedSnapshotList(t *testing.T) {
+	out := `dblab_pool@snapshot_20210127100000	on
+dblab_pool@snapshot_20210127105215_pre	-
+dblab_pool/clone_pre_20210127105215@snapshot_20210127105215	-
+dblab_pool/clone_pre_20210127113000@snapshot_20210127113000	on
+`
+	expected := []string{"dblab_pool@snapshot_20210127100000", "dblab_pool/clone_pre_20210127113000@snapshot_20210127113000"}
+
+	assert.Equal(t, expected, parseProtectedSnapshots(out))
+	assert.Equal(t, []string{}, parseProtectedSnapshots(""))
 }
 
 func TestExcludingBusySnapshots(t *testing.T) {
@@ -224,3 +245,66 @@ func TestSnapshotList(t *testing.T) {
 		require.Equal(t, []resources.Snapshot{{ID: "test3"}, {ID: "test1"}}, fsManager.SnapshotList())
 	})
 }
+
+type recordingRunner struct {
+	commands []string
+	outputs  map[string]string
+}
+
+func (r *recordingRunner) Run(cmd string, _ ...bool) (string, error) {
+	r.commands = append(r.commands, cmd)
+
+	return r.outputs[cmd], nil
+}
+
+func TestDestroySnapshot(t *testing.T) {
+	runner := &recordingRunner{outputs: map[string]string{
+		"zfs get -H -o value origin dblab_pool/clone_pre_20221012101112": "dblab_pool@snapshot_20221012101112_pre\n",
+	}}
+	m := NewFSManager(runner, Config{Pool: &resources.Pool{Name: "dblab_pool"}})
+
+	require.NoError(t, m.DestroySnapshot("dblab_pool/clone_pre_20221012101112@snapshot_20221012100000", thinclones.DestroyOptions{}))
+	assert.Equal(t, []string{
+		"zfs destroy dblab_pool/clone_pre_20221012101112@snapshot_20221012100000",
+		"zfs get -H -o value origin dblab_pool/clone_pre_20221012101112",
+		"zfs destroy dblab_pool/clone_pre_20221012101112",
+		"zfs destroy dblab_pool@snapshot_20221012101112_pre",
+	}, runner.commands)
+
+	runner.commands = nil
+
+	require.NoError(t, m.DestroySnapshot("dblab_pool/dblab_clone_6000@snapshot_20221012110000", thinclones.DestroyOptions{}))
+	assert.Equal(t, []string{"zfs destroy dblab_pool/dblab_clone_6000@snapshot_20221012110000"}, runner.commands)
+
+	runner.commands = nil
+
+	require.NoError(t, m.DestroySnapshot("dblab_pool@snapshot_20221012101112_pre", thinclones.DestroyOptions{Force: true}))
+	assert.Equal(t, []string{"zfs destroy -R dblab_pool@snapshot_20221012101112_pre"}, runner.commands)
+}
+
+func TestCleanupPITRSnapshots(t *testing.T) {
+	runner := &recordingRunner{}
+	m := NewFSManager(runner, Config{Pool: &resources.Pool{Name: "dblab_pool"}})
+
+	for _, snapshot := range []resources.Snapshot{
+		{ID: "dblab_pool/clone_pre_20221012100510@snapshot_20221012100000", DataStateAt: time.Date(2022, 10, 12, 10, 0, 0, 0, time.UTC)},
+		{ID: "dblab_pool/clone_pitr_20221012090000@snapshot_20221012090000", DataStateAt: time.Date(2022, 10, 12, 9, 0, 0, 0, time.UTC)},
+		{ID: "dblab_pool/clone_pitr_20221012093000@snapshot_20221012093000", DataStateAt: time.Date(2022, 10, 12, 9, 30, 0, 0, time.UTC)},
+		{ID: "dblab_pool/clone_pitr_20221012094500@snapshot_20221012094500", DataStateAt: time.Date(2022, 10, 12, 9, 45, 0, 0, time.UTC)},
+		{ID: "dblab_pool/clone_pitr_20221012095000@snapshot_20221012095000", DataStateAt: time.Date(2022, 10, 12, 9, 50, 0, 0, time.UTC)},
+	} {
+		m.addSnapshotToList(snapshot)
+	}
+
+	clonesOutput := `dblab_pool	-
+dblab_pool/clone_pitr_20221012090000	dblab_pool@snapshot_20221012080000_pre
+dblab_pool/dblab_clone_6000	dblab_pool/clone_pitr_20221012090000@snapshot_20221012090000
+`
+	busySnapshots := userCloneOrigins(clonesOutput, "dblab_pool/dblab_clone_")
+	assert.Equal(t, map[string]struct{}{"dblab_pool/clone_pitr_20221012090000@snapshot_20221012090000": {}}, busySnapshots)
+
+	destroyed, err := m.cleanupPITRSnapshots(2, busySnapshots)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dblab_pool/clone_pitr_20221012093000@snapshot_20221012093000"}, destroyed)
+	assert.Equal(t, []string{"zfs destroy -r dblab_pool/clone_pitr_20221012093000"}, runner.commands)
+}
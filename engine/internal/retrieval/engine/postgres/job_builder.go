@@ -8,6 +8,7 @@ package postgres
 import (
 	"github.com/pkg/errors"
 
+	"gitlab.com/postgres-ai/database-lab/v3/internal/events"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/provision/pool"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/retrieval/components"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/retrieval/config"
@@ -15,6 +16,7 @@ import (
 	"gitlab.com/postgres-ai/database-lab/v3/internal/retrieval/engine/postgres/physical"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/retrieval/engine/postgres/snapshot"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/telemetry"
+	"gitlab.com/postgres-ai/database-lab/v3/internal/webhooks"
 
 	"gitlab.com/postgres-ai/database-lab/v3/pkg/config/global"
 )
@@ -30,15 +32,20 @@ type JobBuilder struct {
 	globalCfg    *global.Config
 	engineProps  global.EngineProps
 	tm           *telemetry.Agent
+	wh           *webhooks.Service
+	broker       *events.Broker
 }
 
 // NewJobBuilder create a new job builder.
-func NewJobBuilder(global *global.Config, engineProps global.EngineProps, cm pool.FSManager, tm *telemetry.Agent) *JobBuilder {
+func NewJobBuilder(global *global.Config, engineProps global.EngineProps, cm pool.FSManager, tm *telemetry.Agent,
+	wh *webhooks.Service, broker *events.Broker) *JobBuilder {
 	return &JobBuilder{
 		globalCfg:    global,
 		engineProps:  engineProps,
 		cloneManager: cm,
 		tm:           tm,
+		wh:           wh,
+		broker:       broker,
 	}
 }
 
@@ -55,10 +62,10 @@ func (s *JobBuilder) BuildJob(jobCfg config.JobConfig) (components.JobRunner, er
 		return physical.NewJob(jobCfg, s.globalCfg, s.engineProps)
 
 	case snapshot.LogicalSnapshotType:
-		return snapshot.NewLogicalInitialJob(jobCfg, s.globalCfg, s.engineProps, s.cloneManager, s.tm)
+		return snapshot.NewLogicalInitialJob(jobCfg, s.globalCfg, s.engineProps, s.cloneManager, s.tm, s.wh, s.broker)
 
 	case snapshot.PhysicalSnapshotType:
-		return snapshot.NewPhysicalInitialJob(jobCfg, s.globalCfg, s.engineProps, s.cloneManager, s.tm)
+		return snapshot.NewPhysicalInitialJob(jobCfg, s.globalCfg, s.engineProps, s.cloneManager, s.tm, s.wh, s.broker)
 	}
 
 	return nil, errors.Errorf("unknown job type: %q", jobCfg.Spec.Name)
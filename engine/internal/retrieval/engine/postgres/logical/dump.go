@@ -104,7 +104,10 @@ type DumpDefinition struct {
 	ExcludeTables []string        `yaml:"excludeTables"`
 	Format        string          `yaml:"format"`
 	Compression   compressionType `yaml:"compression"`
-	dbName        string
+
+	// Subset defines a referentially consistent subset of data to restore instead of the whole database.
+	Subset *SubsetDefinition `yaml:"subset"`
+	dbName string
 }
 
 type dumpJobConfig struct {
@@ -171,6 +174,24 @@ func (d *DumpJob) validate() error {
 Either set 'numberOfJobs' equals to 1 or disable the restore section`)
 	}
 
+	for dbName, definition := range d.Databases {
+		if definition.Subset == nil {
+			continue
+		}
+
+		if !d.Restore.Enabled {
+			return errors.Errorf("subset of the database %q requires the immediate restore", dbName)
+		}
+
+		if len(definition.Tables) > 0 || len(definition.ExcludeTables) > 0 {
+			return errors.Errorf("subset of the database %q cannot be combined with 'tables' and 'excludeTables'", dbName)
+		}
+
+		if err := definition.Subset.validate(); err != nil {
+			return errors.Wrapf(err, "invalid subset of the database %q", dbName)
+		}
+	}
+
 	return nil
 }
 
@@ -489,6 +510,18 @@ func (d *DumpJob) cleanupDumpLocation(ctx context.Context, dumpContID string, db
 }
 
 func (d *DumpJob) dumpDatabase(ctx context.Context, dumpContID, dbName string, dumpDefinition DumpDefinition) error {
+	if dumpDefinition.Subset != nil {
+		log.Msg("Subset dump")
+
+		if err := d.dumpSubset(ctx, dumpContID, dbName, dumpDefinition.Subset); err != nil {
+			return errors.Wrap(err, "failed to dump a subset of the database")
+		}
+
+		log.Msg(fmt.Sprintf("Dumping job for the database %q has been finished", dbName))
+
+		return nil
+	}
+
 	dumpCommand := d.buildLogicalDumpCommand(dbName, dumpDefinition)
 
 	if len(dumpDefinition.Tables) > 0 ||
@@ -668,21 +701,7 @@ func (d *DumpJob) buildLogicalDumpCommand(dbName string, dump DumpDefinition) []
 	// don't use map here, it creates inconsistency in the order of arguments
 	dumpCmd := []string{"pg_dump", "--create"}
 
-	if d.config.db.Host != "" {
-		dumpCmd = append(dumpCmd, "--host", d.config.db.Host)
-	}
-
-	if d.config.db.Port > 0 {
-		dumpCmd = append(dumpCmd, "--port", strconv.Itoa(d.config.db.Port))
-	}
-
-	if d.config.db.Username != "" {
-		dumpCmd = append(dumpCmd, "--username", d.config.db.Username)
-	}
-
-	if dbName != "" {
-		dumpCmd = append(dumpCmd, "--dbname", dbName)
-	}
+	dumpCmd = append(dumpCmd, d.buildSourceConnectionOptions(dbName)...)
 
 	if d.DumpOptions.ParallelJobs > 0 {
 		dumpCmd = append(dumpCmd, "--jobs", strconv.Itoa(d.DumpOptions.ParallelJobs))
@@ -714,6 +733,29 @@ func (d *DumpJob) buildLogicalDumpCommand(dbName string, dump DumpDefinition) []
 	return dumpCmd
 }
 
+// buildSourceConnectionOptions returns connection options of Postgres client tools for the source database.
+func (d *DumpJob) buildSourceConnectionOptions(dbName string) []string {
+	options := []string{}
+
+	if d.config.db.Host != "" {
+		options = append(options, "--host", d.config.db.Host)
+	}
+
+	if d.config.db.Port > 0 {
+		options = append(options, "--port", strconv.Itoa(d.config.db.Port))
+	}
+
+	if d.config.db.Username != "" {
+		options = append(options, "--username", d.config.db.Username)
+	}
+
+	if dbName != "" {
+		options = append(options, "--dbname", dbName)
+	}
+
+	return options
+}
+
 func (d *DumpJob) buildLogicalRestoreCommand(dbName string) []string {
 	restoreCmd := []string{"|", "pg_restore", "--username", d.globalCfg.Database.User(), "--dbname", defaults.DBName}
 
@@ -0,0 +1,330 @@
+/*
+2022 © Postgres.ai
+*/
+
+package logical
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/jackc/pgx/v4"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+
+	"gitlab.com/postgres-ai/database-lab/v3/internal/retrieval/engine/postgres/tools"
+	"gitlab.com/postgres-ai/database-lab/v3/internal/retrieval/engine/postgres/tools/db"
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/log"
+)
+
+const (
+	// generatedColumnsVersion defines the first Postgres version supporting generated columns.
+	generatedColumnsVersion = 120000
+
+	// subsetTablesQuery lists user tables with their columns. Partitions are copied through their partitioned tables.
+	subsetTablesQuery = `SELECT n.nspname, c.relname, c.relkind = 'p', array_agg(a.attname::text ORDER BY a.attnum)
+FROM pg_class c
+  JOIN pg_namespace n ON n.oid = c.relnamespace
+  JOIN pg_attribute a ON a.attrelid = c.oid AND a.attnum > 0 AND NOT a.attisdropped %s
+WHERE c.relkind IN ('r', 'p')
+  AND n.nspname NOT IN ('pg_catalog', 'information_schema')
+  AND n.nspname NOT LIKE 'pg\_toast%%' AND n.nspname NOT LIKE 'pg\_temp%%'
+  AND NOT EXISTS (
+    SELECT FROM pg_inherits i JOIN pg_class p ON p.oid = i.inhparent WHERE i.inhrelid = c.oid AND p.relkind = 'p'
+  )
+GROUP BY n.nspname, c.relname, c.relkind
+ORDER BY n.nspname, c.relname`
+
+	// subsetForeignKeysQuery lists foreign keys with columns in the order of the key definition.
+	subsetForeignKeysQuery = `SELECT con.conname::text,
+  cn.nspname || '.' || cc.relname,
+  array(SELECT a.attname::text FROM unnest(con.conkey) WITH ORDINALITY k(attnum, pos)
+    JOIN pg_attribute a ON a.attrelid = con.conrelid AND a.attnum = k.attnum ORDER BY k.pos),
+  pn.nspname || '.' || pc.relname,
+  array(SELECT a.attname::text FROM unnest(con.confkey) WITH ORDINALITY k(attnum, pos)
+    JOIN pg_attribute a ON a.attrelid = con.confrelid AND a.attnum = k.attnum ORDER BY k.pos)
+FROM pg_constraint con
+  JOIN pg_class cc ON cc.oid = con.conrelid
+  JOIN pg_namespace cn ON cn.oid = cc.relnamespace
+  JOIN pg_class pc ON pc.oid = con.confrelid
+  JOIN pg_namespace pn ON pn.oid = pc.relnamespace
+WHERE con.contype = 'f'
+ORDER BY 2, 1`
+
+	subsetSequencesQuery = `SELECT n.nspname, c.relname
+FROM pg_class c
+  JOIN pg_namespace n ON n.oid = c.relnamespace
+WHERE c.relkind = 'S'
+  AND n.nspname NOT IN ('pg_catalog', 'information_schema')
+ORDER BY n.nspname, c.relname`
+
+	// subsetCopyScript copies rows selected in the source database to the target one.
+	// The source session imports the exported snapshot, so all tables are copied consistently.
+	// SQL is passed through environment variables to avoid shell quoting.
+	subsetCopyScript = `set -o pipefail; %s -c "BEGIN ISOLATION LEVEL REPEATABLE READ READ ONLY" ` +
+		`-c "SET TRANSACTION SNAPSHOT '$SUBSET_SNAPSHOT'" -c "COPY ($SUBSET_QUERY) TO STDOUT" | %s -c "$SUBSET_COPY"`
+)
+
+// psqlOptions makes psql skip psqlrc, keep the COPY stream clean of command tags, and stop on the first error.
+var psqlOptions = []string{"-X", "-q", "-v", "ON_ERROR_STOP=1"}
+
+// subsetSequence describes the state of a sequence in the source database.
+type subsetSequence struct {
+	Name      string
+	LastValue int64
+	IsCalled  bool
+}
+
+// dumpSubset restores the schema and a referentially consistent subset of rows of the source database.
+func (d *DumpJob) dumpSubset(ctx context.Context, dumpContID, dbName string, subset *SubsetDefinition) error {
+	connStr := db.ConnectionString(d.config.db.Host, strconv.Itoa(d.config.db.Port), d.config.db.Username, dbName, d.getPassword())
+
+	conn, err := pgx.Connect(ctx, connStr)
+	if err != nil {
+		return fmt.Errorf("failed to connect to DB: %w", err)
+	}
+
+	defer func() {
+		if err := conn.Close(ctx); err != nil {
+			log.Err("Failed to close connection:", err)
+		}
+	}()
+
+	// The transaction holds the exported snapshot until all data is copied.
+	tx, err := conn.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			log.Err("Failed to finish transaction:", err)
+		}
+	}()
+
+	var snapshotID string
+
+	if err := tx.QueryRow(ctx, "SELECT pg_export_snapshot()").Scan(&snapshotID); err != nil {
+		return fmt.Errorf("failed to export snapshot: %w", err)
+	}
+
+	tables, err := loadSubsetTables(ctx, tx)
+	if err != nil {
+		return fmt.Errorf("failed to list tables: %w", err)
+	}
+
+	fks, err := loadForeignKeys(ctx, tx)
+	if err != nil {
+		return fmt.Errorf("failed to list foreign keys: %w", err)
+	}
+
+	sequences, err := loadSequences(ctx, tx)
+	if err != nil {
+		return fmt.Errorf("failed to read sequences: %w", err)
+	}
+
+	queries, err := buildSubsetPlan(*subset, tables, fks)
+	if err != nil {
+		return errors.Wrap(err, "failed to build subset queries")
+	}
+
+	log.Msg(fmt.Sprintf("Subset dump of %d of %d tables, snapshot %s", len(queries), len(tables), snapshotID))
+
+	preDataCmd := d.buildSubsetSectionCommand(dbName, snapshotID, "pre-data", d.buildLogicalRestoreCommand(dbName))
+
+	log.Msg("Running dump command: ", preDataCmd)
+
+	if output, err := d.performDumpCommand(ctx, dumpContID, types.ExecConfig{
+		Tty: true,
+		Cmd: preDataCmd,
+		Env: d.getExecEnvironmentVariables(),
+	}); err != nil {
+		log.Dbg(output)
+		return errors.Wrap(err, "failed to restore schema")
+	}
+
+	for _, query := range queries {
+		log.Dbg("Subset query:", query.Query)
+
+		if output, err := tools.ExecCommandWithOutput(ctx, d.dockerClient, dumpContID, types.ExecConfig{
+			Tty: true,
+			Cmd: []string{"bash", "-c", fmt.Sprintf(subsetCopyScript, d.buildSourcePSQLCommand(dbName), d.buildTargetPSQLCommand(dbName))},
+			Env: append(d.getExecEnvironmentVariables(),
+				"SUBSET_SNAPSHOT="+snapshotID,
+				"SUBSET_QUERY="+query.Query,
+				"SUBSET_COPY="+fmt.Sprintf("COPY %s (%s) FROM STDIN", query.Table.ident(), selectList(query.Table.Columns)),
+			),
+		}); err != nil {
+			log.Dbg(output)
+			return errors.Wrapf(err, "failed to copy rows of table %s", query.Table)
+		}
+	}
+
+	if len(sequences) > 0 {
+		if output, err := tools.ExecCommandWithOutput(ctx, d.dockerClient, dumpContID, types.ExecConfig{
+			Tty: true,
+			Cmd: []string{"bash", "-c", d.buildTargetPSQLCommand(dbName) + ` -c "$SUBSET_SEQUENCES"`},
+			Env: append(d.getExecEnvironmentVariables(), "SUBSET_SEQUENCES="+setSequencesSQL(sequences)),
+		}); err != nil {
+			log.Dbg(output)
+			return errors.Wrap(err, "failed to set sequence values")
+		}
+	}
+
+	postDataRestoreCmd := append([]string{"|", "pg_restore", "--username", d.globalCfg.Database.User(), "--dbname", dbName},
+		d.DumpOptions.Restore.CustomOptions...)
+
+	postDataCmd := d.buildSubsetSectionCommand(dbName, snapshotID, "post-data", postDataRestoreCmd)
+
+	log.Msg("Running dump command: ", postDataCmd)
+
+	if output, err := tools.ExecCommandWithOutput(ctx, d.dockerClient, dumpContID, types.ExecConfig{
+		Tty: true,
+		Cmd: postDataCmd,
+		Env: d.getExecEnvironmentVariables(),
+	}); err != nil {
+		log.Dbg(output)
+		return errors.Wrap(err, "failed to restore indexes and constraints")
+	}
+
+	return nil
+}
+
+// buildSubsetSectionCommand dumps a section of the schema using the exported snapshot and pipes it to the restore command.
+func (d *DumpJob) buildSubsetSectionCommand(dbName, snapshotID, section string, restoreCmd []string) []string {
+	dumpCmd := []string{"pg_dump"}
+
+	if section == "pre-data" {
+		dumpCmd = append(dumpCmd, "--create")
+	}
+
+	dumpCmd = append(dumpCmd, d.buildSourceConnectionOptions(dbName)...)
+	dumpCmd = append(dumpCmd, "--snapshot", snapshotID, "--section", section, "--format", customFormat)
+	dumpCmd = append(dumpCmd, restoreCmd...)
+
+	return []string{"sh", "-c", strings.Join(dumpCmd, " ")}
+}
+
+func (d *DumpJob) buildSourcePSQLCommand(dbName string) string {
+	psqlCmd := append([]string{"psql"}, d.buildSourceConnectionOptions(dbName)...)
+
+	return strings.Join(append(psqlCmd, psqlOptions...), " ")
+}
+
+func (d *DumpJob) buildTargetPSQLCommand(dbName string) string {
+	psqlCmd := []string{"psql", "--username", d.globalCfg.Database.User(), "--dbname", dbName}
+
+	return strings.Join(append(psqlCmd, psqlOptions...), " ")
+}
+
+func loadSubsetTables(ctx context.Context, tx pgx.Tx) ([]subsetTable, error) {
+	var version int
+
+	if err := tx.QueryRow(ctx, "SELECT current_setting('server_version_num')::int").Scan(&version); err != nil {
+		return nil, err
+	}
+
+	columnFilter := ""
+	if version >= generatedColumnsVersion {
+		columnFilter = "AND a.attgenerated = ''"
+	}
+
+	rows, err := tx.Query(ctx, fmt.Sprintf(subsetTablesQuery, columnFilter))
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	tables := []subsetTable{}
+
+	for rows.Next() {
+		var table subsetTable
+
+		if err := rows.Scan(&table.Schema, &table.Name, &table.Partitioned, &table.Columns); err != nil {
+			return nil, err
+		}
+
+		tables = append(tables, table)
+	}
+
+	return tables, rows.Err()
+}
+
+func loadForeignKeys(ctx context.Context, tx pgx.Tx) ([]foreignKey, error) {
+	rows, err := tx.Query(ctx, subsetForeignKeysQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	fks := []foreignKey{}
+
+	for rows.Next() {
+		var fk foreignKey
+
+		if err := rows.Scan(&fk.Name, &fk.Child, &fk.ChildColumns, &fk.Parent, &fk.ParentColumns); err != nil {
+			return nil, err
+		}
+
+		fks = append(fks, fk)
+	}
+
+	return fks, rows.Err()
+}
+
+func loadSequences(ctx context.Context, tx pgx.Tx) ([]subsetSequence, error) {
+	rows, err := tx.Query(ctx, subsetSequencesQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+
+	for rows.Next() {
+		var schema, name string
+
+		if err := rows.Scan(&schema, &name); err != nil {
+			rows.Close()
+			return nil, err
+		}
+
+		names = append(names, pq.QuoteIdentifier(schema)+"."+pq.QuoteIdentifier(name))
+	}
+
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sequences := make([]subsetSequence, 0, len(names))
+
+	for _, name := range names {
+		sequence := subsetSequence{Name: name}
+
+		if err := tx.QueryRow(ctx, "SELECT last_value, is_called FROM "+name).Scan(&sequence.LastValue, &sequence.IsCalled); err != nil {
+			return nil, fmt.Errorf("failed to read sequence %s: %w", name, err)
+		}
+
+		sequences = append(sequences, sequence)
+	}
+
+	return sequences, nil
+}
+
+// setSequencesSQL keeps sequence values of the source database, so new rows do not conflict with copied ones.
+func setSequencesSQL(sequences []subsetSequence) string {
+	statements := make([]string, 0, len(sequences))
+
+	for _, sequence := range sequences {
+		statements = append(statements, fmt.Sprintf("SELECT pg_catalog.setval(%s, %d, %t);",
+			pq.QuoteLiteral(sequence.Name), sequence.LastValue, sequence.IsCalled))
+	}
+
+	return strings.Join(statements, "\n")
+}
@@ -0,0 +1,620 @@
+/*
+2022 © Postgres.ai
+*/
+
+package logical
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultSchema = "public"
+
+	// sampleBase defines the precision of percentage sampling: 0.01%.
+	sampleBase = 10000
+
+	subsetRowAlias = "t"
+)
+
+// SubsetDefinition describes a referentially consistent subset of a database.
+// Rows are selected in root tables, then rows referencing them are followed down foreign keys,
+// and finally all rows referenced by selected rows are added, so all foreign keys stay valid.
+type SubsetDefinition struct {
+	Roots []SubsetRoot `yaml:"roots"`
+
+	// FullTables are copied entirely, for example, dictionaries. They also break foreign key cycles.
+	FullTables []string `yaml:"fullTables"`
+}
+
+// SubsetRoot defines rows to start subsetting from.
+type SubsetRoot struct {
+	Table   string  `yaml:"table"`
+	Where   string  `yaml:"where"`
+	Percent float64 `yaml:"percent"`
+}
+
+func (s *SubsetDefinition) validate() error {
+	if len(s.Roots) == 0 {
+		return errors.New("at least one root table must be defined")
+	}
+
+	for _, root := range s.Roots {
+		if _, err := parseSubsetTable(root.Table); err != nil {
+			return err
+		}
+
+		if root.Percent < 0 || root.Percent > 100 {
+			return errors.Errorf("percent of table %q must be between 0 and 100", root.Table)
+		}
+	}
+
+	for _, table := range s.FullTables {
+		if _, err := parseSubsetTable(table); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// subsetTable describes a table of the source database.
+type subsetTable struct {
+	Schema      string
+	Name        string
+	Partitioned bool
+
+	// Columns lists columns to copy. Generated columns are skipped because they cannot be loaded by COPY.
+	Columns []string
+}
+
+func (t subsetTable) String() string {
+	return t.Schema + "." + t.Name
+}
+
+func (t subsetTable) ident() string {
+	return pq.QuoteIdentifier(t.Schema) + "." + pq.QuoteIdentifier(t.Name)
+}
+
+// from returns the FROM item of the table. Rows of inheritance children are copied separately,
+// while partitioned tables have no rows of their own.
+func (t subsetTable) from() string {
+	if t.Partitioned {
+		return t.ident() + " AS " + subsetRowAlias
+	}
+
+	return "ONLY " + t.ident() + " AS " + subsetRowAlias
+}
+
+func (t subsetTable) selectColumns() string {
+	if len(t.Columns) == 0 {
+		return subsetRowAlias + ".*"
+	}
+
+	return columnList(subsetRowAlias, t.Columns)
+}
+
+func parseSubsetTable(name string) (subsetTable, error) {
+	parts := strings.Split(name, ".")
+
+	switch {
+	case len(parts) == 1 && parts[0] != "":
+		return subsetTable{Schema: defaultSchema, Name: parts[0]}, nil
+
+	case len(parts) == 2 && parts[0] != "" && parts[1] != "":
+		return subsetTable{Schema: parts[0], Name: parts[1]}, nil
+	}
+
+	return subsetTable{}, errors.Errorf("invalid table name %q: use \"schema.table\" or \"table\"", name)
+}
+
+// foreignKey describes a foreign key: columns of the child table reference columns of the parent table.
+type foreignKey struct {
+	Name          string
+	Child         string
+	ChildColumns  []string
+	Parent        string
+	ParentColumns []string
+}
+
+// subsetQuery defines the query selecting rows of the table.
+type subsetQuery struct {
+	Table subsetTable
+	Query string
+}
+
+// cte describes a common table expression selecting keys of rows of a table.
+type cte struct {
+	sql  string
+	deps []string
+}
+
+// subsetPlanner builds queries selecting the subset.
+type subsetPlanner struct {
+	tables    map[string]subsetTable
+	order     []string
+	index     map[string]int
+	byChild   map[string][]foreignKey
+	byParent  map[string][]foreignKey
+	roots     map[string][]SubsetRoot
+	full      map[string]bool
+	downOrder map[string]int
+	reachable map[string]bool
+	ctes      map[string]cte
+	selected  map[string]string
+	state     map[string]int
+}
+
+const (
+	stateVisiting = iota + 1
+	stateDone
+)
+
+// buildSubsetPlan returns queries selecting the subset for every table that has rows in it.
+func buildSubsetPlan(def SubsetDefinition, tables []subsetTable, fks []foreignKey) ([]subsetQuery, error) {
+	p := &subsetPlanner{
+		tables:    make(map[string]subsetTable, len(tables)),
+		index:     make(map[string]int, len(tables)),
+		byChild:   make(map[string][]foreignKey),
+		byParent:  make(map[string][]foreignKey),
+		roots:     make(map[string][]SubsetRoot),
+		full:      make(map[string]bool),
+		downOrder: make(map[string]int),
+		reachable: make(map[string]bool),
+		ctes:      make(map[string]cte),
+		selected:  make(map[string]string),
+		state:     make(map[string]int),
+	}
+
+	for _, table := range tables {
+		p.index[table.String()] = len(p.order)
+		p.tables[table.String()] = table
+		p.order = append(p.order, table.String())
+	}
+
+	for _, fk := range fks {
+		if _, ok := p.tables[fk.Child]; !ok {
+			continue
+		}
+
+		if _, ok := p.tables[fk.Parent]; !ok {
+			continue
+		}
+
+		p.byChild[fk.Child] = append(p.byChild[fk.Child], fk)
+		p.byParent[fk.Parent] = append(p.byParent[fk.Parent], fk)
+	}
+
+	rootOrder := []string{}
+
+	for _, root := range def.Roots {
+		table, err := p.lookup(root.Table)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, ok := p.roots[table]; !ok {
+			rootOrder = append(rootOrder, table)
+		}
+
+		p.roots[table] = append(p.roots[table], root)
+	}
+
+	for _, name := range def.FullTables {
+		table, err := p.lookup(name)
+		if err != nil {
+			return nil, err
+		}
+
+		p.full[table] = true
+	}
+
+	p.walkDown(rootOrder)
+	p.walkUp()
+
+	queries := []subsetQuery{}
+
+	for _, table := range p.order {
+		if !p.reachable[table] {
+			continue
+		}
+
+		condition, err := p.selectCondition(table)
+		if err != nil {
+			return nil, err
+		}
+
+		query, err := p.query(table, condition)
+		if err != nil {
+			return nil, err
+		}
+
+		queries = append(queries, subsetQuery{Table: p.tables[table], Query: query})
+	}
+
+	return queries, nil
+}
+
+func (p *subsetPlanner) lookup(name string) (string, error) {
+	table, err := parseSubsetTable(name)
+	if err != nil {
+		return "", err
+	}
+
+	if _, ok := p.tables[table.String()]; !ok {
+		return "", errors.Errorf("table %s does not exist", table)
+	}
+
+	return table.String(), nil
+}
+
+// walkDown finds tables referencing rows of root tables directly or through other tables.
+func (p *subsetPlanner) walkDown(roots []string) {
+	queue := append([]string{}, roots...)
+
+	for _, root := range roots {
+		p.downOrder[root] = len(p.downOrder)
+	}
+
+	for len(queue) > 0 {
+		parent := queue[0]
+		queue = queue[1:]
+
+		for _, fk := range p.byParent[parent] {
+			if _, ok := p.downOrder[fk.Child]; ok {
+				continue
+			}
+
+			p.downOrder[fk.Child] = len(p.downOrder)
+			queue = append(queue, fk.Child)
+		}
+	}
+}
+
+// walkUp marks tables that get rows: tables found walking down, full tables, and tables referenced by them.
+func (p *subsetPlanner) walkUp() {
+	queue := []string{}
+
+	for _, table := range p.order {
+		if _, ok := p.downOrder[table]; ok || p.full[table] {
+			p.reachable[table] = true
+			queue = append(queue, table)
+		}
+	}
+
+	for len(queue) > 0 {
+		child := queue[0]
+		queue = queue[1:]
+
+		for _, fk := range p.byChild[child] {
+			if !p.reachable[fk.Parent] {
+				p.reachable[fk.Parent] = true
+				queue = append(queue, fk.Parent)
+			}
+		}
+	}
+}
+
+// downEdges returns foreign keys followed down to the table. Keys to tables found later are skipped to avoid cycles.
+func (p *subsetPlanner) downEdges(table string) []foreignKey {
+	order, ok := p.downOrder[table]
+	if !ok {
+		return nil
+	}
+
+	edges := []foreignKey{}
+
+	for _, fk := range p.byChild[table] {
+		if parentOrder, ok := p.downOrder[fk.Parent]; ok && parentOrder < order && fk.Parent != table {
+			edges = append(edges, fk)
+		}
+	}
+
+	return edges
+}
+
+// downCondition selects rows of root tables and rows referencing rows selected walking down.
+func (p *subsetPlanner) downCondition(table string) (string, []string) {
+	conditions := []string{}
+	deps := []string{}
+
+	for _, root := range p.roots[table] {
+		conditions = append(conditions, rootCondition(root))
+	}
+
+	for _, fk := range p.downEdges(table) {
+		name := p.downCTE(fk.Parent)
+		conditions = append(conditions, inCondition(fk.ChildColumns, fk.ParentColumns, name))
+		deps = append(deps, name)
+	}
+
+	return orConditions(conditions), deps
+}
+
+// downCTE defines keys of rows selected walking down, which are referenced by child tables.
+func (p *subsetPlanner) downCTE(table string) string {
+	name := fmt.Sprintf("down_%d", p.downOrder[table])
+
+	if _, ok := p.ctes[name]; ok {
+		return name
+	}
+
+	columns := []string{}
+
+	for _, fk := range p.byParent[table] {
+		if order, ok := p.downOrder[fk.Child]; ok && order > p.downOrder[table] {
+			columns = appendUnique(columns, fk.ParentColumns...)
+		}
+	}
+
+	condition, deps := p.downCondition(table)
+
+	p.ctes[name] = cte{
+		sql:  fmt.Sprintf("%s AS (SELECT %s FROM %s WHERE %s)", name, selectList(columns), p.tables[table].from(), condition),
+		deps: deps,
+	}
+
+	return name
+}
+
+// selectCondition selects rows of the table in the subset.
+func (p *subsetPlanner) selectCondition(table string) (string, error) {
+	condition, err := p.baseCondition(table)
+	if err != nil {
+		return "", err
+	}
+
+	// Rows referenced by selected rows of the same table are added recursively.
+	if fks := p.selfKeys(table); len(fks) > 0 {
+		for _, fk := range fks {
+			condition = fmt.Sprintf("%s OR %s", condition, inCondition(fk.ParentColumns, fk.ChildColumns, p.selectCTEName(table)))
+		}
+	}
+
+	return condition, nil
+}
+
+// baseCondition selects rows of the table except rows referenced only by rows of the same table.
+func (p *subsetPlanner) baseCondition(table string) (string, error) {
+	if condition, ok := p.selected[table]; ok {
+		return condition, nil
+	}
+
+	if p.state[table] == stateVisiting {
+		return "", errors.Errorf("foreign keys of table %s form a cycle, add one of the tables in the cycle to \"fullTables\"", table)
+	}
+
+	p.state[table] = stateVisiting
+
+	conditions := []string{}
+
+	if p.full[table] {
+		conditions = append(conditions, "TRUE")
+	} else {
+		if _, ok := p.downOrder[table]; ok {
+			condition, _ := p.downCondition(table)
+			conditions = append(conditions, condition)
+		}
+
+		for _, fk := range p.byParent[table] {
+			if fk.Child == table || !p.reachable[fk.Child] {
+				continue
+			}
+
+			name, err := p.selectCTE(fk.Child)
+			if err != nil {
+				return "", err
+			}
+
+			conditions = append(conditions, inCondition(fk.ParentColumns, fk.ChildColumns, name))
+		}
+	}
+
+	condition := orConditions(conditions)
+
+	p.selected[table] = condition
+	p.state[table] = stateDone
+
+	return condition, nil
+}
+
+// conditionDeps lists CTEs used in the base condition of the table.
+func (p *subsetPlanner) conditionDeps(table string) []string {
+	if p.full[table] {
+		return nil
+	}
+
+	deps := []string{}
+
+	if _, ok := p.downOrder[table]; ok {
+		_, downDeps := p.downCondition(table)
+		deps = append(deps, downDeps...)
+	}
+
+	for _, fk := range p.byParent[table] {
+		if fk.Child != table && p.reachable[fk.Child] {
+			deps = append(deps, p.selectCTEName(fk.Child))
+		}
+	}
+
+	return deps
+}
+
+func (p *subsetPlanner) selfKeys(table string) []foreignKey {
+	fks := []foreignKey{}
+
+	for _, fk := range p.byChild[table] {
+		if fk.Parent == table {
+			fks = append(fks, fk)
+		}
+	}
+
+	return fks
+}
+
+func (p *subsetPlanner) selectCTEName(table string) string {
+	return fmt.Sprintf("sel_%d", p.index[table])
+}
+
+// selectCTE defines keys of selected rows of the table, which are referenced by parent tables.
+// Rows referenced by selected rows of the same table are added by the recursive part.
+func (p *subsetPlanner) selectCTE(table string) (string, error) {
+	name := p.selectCTEName(table)
+
+	if _, ok := p.ctes[name]; ok {
+		return name, nil
+	}
+
+	condition, err := p.baseCondition(table)
+	if err != nil {
+		return "", err
+	}
+
+	columns := []string{}
+
+	for _, fk := range p.byChild[table] {
+		columns = appendUnique(columns, fk.ChildColumns...)
+	}
+
+	sql := fmt.Sprintf("%s AS (SELECT %s FROM %s WHERE %s", name, selectList(columns), p.tables[table].from(), condition)
+
+	if fks := p.selfKeys(table); len(fks) > 0 {
+		joins := make([]string, 0, len(fks))
+
+		for _, fk := range fks {
+			joins = append(joins, fmt.Sprintf("(%s) = (%s)", columnList(subsetRowAlias, fk.ParentColumns), columnList("s", fk.ChildColumns)))
+		}
+
+		sql += fmt.Sprintf(" UNION SELECT %s FROM %s JOIN %s AS s ON %s",
+			columnList(subsetRowAlias, columns), p.tables[table].from(), name, strings.Join(joins, " OR "))
+	}
+
+	p.ctes[name] = cte{sql: sql + ")", deps: p.conditionDeps(table)}
+
+	return name, nil
+}
+
+// query builds the query selecting rows of the table with all CTEs it depends on.
+func (p *subsetPlanner) query(table, condition string) (string, error) {
+	deps := p.conditionDeps(table)
+
+	if len(p.selfKeys(table)) > 0 {
+		if _, err := p.selectCTE(table); err != nil {
+			return "", err
+		}
+
+		deps = append(deps, p.selectCTEName(table))
+	}
+
+	ordered := []string{}
+	visited := make(map[string]bool)
+
+	var visit func(name string)
+
+	visit = func(name string) {
+		if visited[name] {
+			return
+		}
+
+		visited[name] = true
+
+		for _, dep := range p.ctes[name].deps {
+			if dep != name {
+				visit(dep)
+			}
+		}
+
+		ordered = append(ordered, p.ctes[name].sql)
+	}
+
+	for _, dep := range deps {
+		visit(dep)
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s", p.tables[table].selectColumns(), p.tables[table].from(), condition)
+
+	if len(ordered) == 0 {
+		return query, nil
+	}
+
+	return fmt.Sprintf("WITH RECURSIVE %s %s", strings.Join(ordered, ", "), query), nil
+}
+
+// rootCondition selects rows of a root table. Sampling is deterministic within a snapshot of the data.
+func rootCondition(root SubsetRoot) string {
+	conditions := []string{}
+
+	if root.Where != "" {
+		conditions = append(conditions, "("+root.Where+")")
+	}
+
+	if root.Percent > 0 && root.Percent < 100 {
+		conditions = append(conditions, fmt.Sprintf("('x' || substr(md5(%s::text), 1, 7))::bit(28)::int %% %d < %d",
+			subsetRowAlias, sampleBase, int(math.Round(root.Percent*sampleBase/100))))
+	}
+
+	if len(conditions) == 0 {
+		return "TRUE"
+	}
+
+	return "(" + strings.Join(conditions, " AND ") + ")"
+}
+
+func inCondition(columns, referencedColumns []string, cteName string) string {
+	return fmt.Sprintf("(%s) IN (SELECT %s FROM %s)", columnList(subsetRowAlias, columns), selectList(referencedColumns), cteName)
+}
+
+func orConditions(conditions []string) string {
+	switch len(conditions) {
+	case 0:
+		return "FALSE"
+	case 1:
+		return conditions[0]
+	}
+
+	return "(" + strings.Join(conditions, " OR ") + ")"
+}
+
+func columnList(alias string, columns []string) string {
+	quoted := make([]string, 0, len(columns))
+
+	for _, column := range columns {
+		quoted = append(quoted, alias+"."+pq.QuoteIdentifier(column))
+	}
+
+	return strings.Join(quoted, ", ")
+}
+
+func selectList(columns []string) string {
+	quoted := make([]string, 0, len(columns))
+
+	for _, column := range columns {
+		quoted = append(quoted, pq.QuoteIdentifier(column))
+	}
+
+	return strings.Join(quoted, ", ")
+}
+
+func appendUnique(values []string, items ...string) []string {
+	for _, item := range items {
+		found := false
+
+		for _, value := range values {
+			if value == item {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			values = append(values, item)
+		}
+	}
+
+	return values
+}
@@ -0,0 +1,167 @@
+/*
+2022 © Postgres.ai
+*/
+
+package logical
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testSubsetSchema() ([]subsetTable, []foreignKey) {
+	tables := []subsetTable{
+		{Schema: "public", Name: "customers", Columns: []string{"id", "referrer_id"}},
+		{Schema: "public", Name: "orders", Columns: []string{"id", "customer_id"}},
+		{Schema: "public", Name: "order_items", Columns: []string{"order_id", "product_id"}},
+		{Schema: "public", Name: "products", Columns: []string{"id", "category_id"}},
+		{Schema: "public", Name: "categories", Columns: []string{"id"}},
+		{Schema: "audit", Name: "logs", Columns: []string{"id"}, Partitioned: true},
+	}
+
+	fks := []foreignKey{
+		{Child: "public.orders", ChildColumns: []string{"customer_id"}, Parent: "public.customers", ParentColumns: []string{"id"}},
+		{Child: "public.order_items", ChildColumns: []string{"order_id"}, Parent: "public.orders", ParentColumns: []string{"id"}},
+		{Child: "public.order_items", ChildColumns: []string{"product_id"}, Parent: "public.products", ParentColumns: []string{"id"}},
+		{Child: "public.products", ChildColumns: []string{"category_id"}, Parent: "public.categories", ParentColumns: []string{"id"}},
+		{Child: "public.customers", ChildColumns: []string{"referrer_id"}, Parent: "public.customers", ParentColumns: []string{"id"}},
+		{Child: "public.unknown", ChildColumns: []string{"id"}, Parent: "public.customers", ParentColumns: []string{"id"}},
+	}
+
+	return tables, fks
+}
+
+func subsetQueries(queries []subsetQuery) map[string]string {
+	result := make(map[string]string, len(queries))
+
+	for _, query := range queries {
+		result[query.Table.String()] = query.Query
+	}
+
+	return result
+}
+
+func TestSubsetDefinitionValidate(t *testing.T) {
+	testCases := []struct {
+		name   string
+		subset SubsetDefinition
+		valid  bool
+	}{
+		{name: "valid", subset: SubsetDefinition{Roots: []SubsetRoot{{Table: "customers", Percent: 10}}}, valid: true},
+		{name: "no roots", subset: SubsetDefinition{}},
+		{name: "invalid root", subset: SubsetDefinition{Roots: []SubsetRoot{{Table: "a.b.c"}}}},
+		{name: "invalid percent", subset: SubsetDefinition{Roots: []SubsetRoot{{Table: "customers", Percent: 101}}}},
+		{
+			name:   "invalid full table",
+			subset: SubsetDefinition{Roots: []SubsetRoot{{Table: "customers"}}, FullTables: []string{"."}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.subset.validate()
+			if tc.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestBuildSubsetPlan(t *testing.T) {
+	tables, fks := testSubsetSchema()
+
+	queries, err := buildSubsetPlan(SubsetDefinition{Roots: []SubsetRoot{{Table: "customers", Where: "id < 10"}}}, tables, fks)
+	require.NoError(t, err)
+
+	plan := subsetQueries(queries)
+
+	// Tables not related to root tables are restored empty.
+	assert.Len(t, plan, 5)
+	assert.NotContains(t, plan, "audit.logs")
+
+	assert.Equal(t, `WITH RECURSIVE down_0 AS (SELECT "id" FROM ONLY "public"."customers" AS t WHERE ((id < 10))), `+
+		`down_1 AS (SELECT "id" FROM ONLY "public"."orders" AS t WHERE (t."customer_id") IN (SELECT "id" FROM down_0)) `+
+		`SELECT t."order_id", t."product_id" FROM ONLY "public"."order_items" AS t WHERE (t."order_id") IN (SELECT "id" FROM down_1)`,
+		plan["public.order_items"])
+
+	// Referenced rows are selected up the foreign keys.
+	assert.Contains(t, plan["public.categories"], `sel_3 AS (SELECT "category_id" FROM ONLY "public"."products" AS t `+
+		`WHERE (t."id") IN (SELECT "product_id" FROM sel_2))`)
+	assert.Contains(t, plan["public.categories"], `WHERE (t."id") IN (SELECT "category_id" FROM sel_3)`)
+
+	// Self-referencing rows are added recursively.
+	assert.Contains(t, plan["public.customers"], `UNION SELECT t."referrer_id" FROM ONLY "public"."customers" AS t `+
+		`JOIN sel_0 AS s ON (t."id") = (s."referrer_id")`)
+	assert.Contains(t, plan["public.customers"], `OR (t."id") IN (SELECT "referrer_id" FROM sel_0)`)
+}
+
+func TestBuildSubsetPlanFullTables(t *testing.T) {
+	tables, fks := testSubsetSchema()
+
+	queries, err := buildSubsetPlan(SubsetDefinition{
+		Roots:      []SubsetRoot{{Table: "public.orders", Percent: 5}},
+		FullTables: []string{"categories", "audit.logs"},
+	}, tables, fks)
+	require.NoError(t, err)
+
+	plan := subsetQueries(queries)
+
+	assert.Equal(t, `SELECT t."id" FROM ONLY "public"."categories" AS t WHERE TRUE`, plan["public.categories"])
+	assert.Equal(t, `SELECT t."id" FROM "audit"."logs" AS t WHERE TRUE`, plan["audit.logs"])
+	assert.Contains(t, plan["public.orders"], `('x' || substr(md5(t::text), 1, 7))::bit(28)::int % 10000 < 500`)
+	assert.Contains(t, plan["public.products"], `WHERE (t."id") IN (SELECT "product_id" FROM sel_2)`)
+}
+
+func TestBuildSubsetPlanCycle(t *testing.T) {
+	tables := []subsetTable{
+		{Schema: "public", Name: "users", Columns: []string{"id", "last_order_id"}},
+		{Schema: "public", Name: "orders", Columns: []string{"id", "user_id"}},
+	}
+
+	fks := []foreignKey{
+		{Child: "public.orders", ChildColumns: []string{"user_id"}, Parent: "public.users", ParentColumns: []string{"id"}},
+		{Child: "public.users", ChildColumns: []string{"last_order_id"}, Parent: "public.orders", ParentColumns: []string{"id"}},
+	}
+
+	_, err := buildSubsetPlan(SubsetDefinition{Roots: []SubsetRoot{{Table: "users", Percent: 1}}}, tables, fks)
+	assert.EqualError(t, err, `foreign keys of table public.users form a cycle, add one of the tables in the cycle to "fullTables"`)
+
+	queries, err := buildSubsetPlan(SubsetDefinition{Roots: []SubsetRoot{{Table: "users", Percent: 1}}, FullTables: []string{"orders"}},
+		tables, fks)
+	require.NoError(t, err)
+	assert.Len(t, queries, 2)
+}
+
+func TestBuildSubsetPlanUnknownTable(t *testing.T) {
+	tables, fks := testSubsetSchema()
+
+	_, err := buildSubsetPlan(SubsetDefinition{Roots: []SubsetRoot{{Table: "accounts"}}}, tables, fks)
+	assert.EqualError(t, err, "table public.accounts does not exist")
+}
+
+func TestRootCondition(t *testing.T) {
+	assert.Equal(t, "TRUE", rootCondition(SubsetRoot{Table: "users"}))
+	assert.Equal(t, "TRUE", rootCondition(SubsetRoot{Table: "users", Percent: 100}))
+	assert.Equal(t, "((created_at > now() - interval '1 month'))",
+		rootCondition(SubsetRoot{Table: "users", Where: "created_at > now() - interval '1 month'"}))
+	assert.Equal(t, "(('x' || substr(md5(t::text), 1, 7))::bit(28)::int % 10000 < 1)",
+		rootCondition(SubsetRoot{Table: "users", Percent: 0.01}))
+}
+
+func TestSubsetTableIdent(t *testing.T) {
+	assert.Equal(t, `"public"."Weird""Name"`, subsetTable{Schema: "public", Name: `Weird"Name`}.ident())
+}
+
+func TestSetSequencesSQL(t *testing.T) {
+	sql := setSequencesSQL([]subsetSequence{
+		{Name: `"public"."users_id_seq"`, LastValue: 42, IsCalled: true},
+		{Name: `"public"."it's_seq"`, LastValue: 1},
+	})
+
+	assert.Equal(t, `SELECT pg_catalog.setval('"public"."users_id_seq"', 42, true);`+"\n"+
+		`SELECT pg_catalog.setval('"public"."it''s_seq"', 1, false);`, sql)
+}
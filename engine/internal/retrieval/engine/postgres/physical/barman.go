@@ -0,0 +1,151 @@
+/*
+2022 © Postgres.ai
+*/
+
+package physical
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"gitlab.com/postgres-ai/database-lab/v3/internal/retrieval/engine/postgres/tools/defaults"
+)
+
+const (
+	barmanTool = "barman"
+
+	// barmanLatestBackup is the Barman shortcut for the most recent backup.
+	barmanLatestBackup = "latest"
+)
+
+// barman defines Barman as an archival restoration tool.
+type barman struct {
+	options barmanOptions
+}
+
+type barmanOptions struct {
+	ServerName string `yaml:"serverName"`
+	BackupID   string `yaml:"backupID"`
+
+	// Host and User define the SSH connection to the Barman server used by barman recover and barman-wal-restore.
+	// If Host is empty, data and WAL files are fetched by the local Barman installation.
+	Host string `yaml:"host"`
+	User string `yaml:"user"`
+
+	// RemoteSSHCommand is used by the Barman server to copy the backup to the restore container.
+	RemoteSSHCommand string `yaml:"remoteSSHCommand"`
+
+	Cloud barmanCloudOptions `yaml:"cloud"`
+}
+
+// barmanCloudOptions defines a backup made by barman-cloud-backup in an object storage.
+type barmanCloudOptions struct {
+	SourceURL     string `yaml:"sourceURL"`
+	EndpointURL   string `yaml:"endpointURL"`
+	CloudProvider string `yaml:"cloudProvider"`
+}
+
+func newBarman(options barmanOptions) (*barman, error) {
+	if options.ServerName == "" {
+		return nil, errors.New("the Barman server name must be defined")
+	}
+
+	if options.BackupID == "" {
+		options.BackupID = barmanLatestBackup
+	}
+
+	if options.Host != "" && options.Cloud.SourceURL == "" && options.RemoteSSHCommand == "" {
+		return nil, errors.New("the remote SSH command must be defined to recover data from the Barman server")
+	}
+
+	return &barman{
+		options: options,
+	}, nil
+}
+
+// GetRestoreCommand returns a command to restore data.
+func (b *barman) GetRestoreCommand() string {
+	if b.isCloud() {
+		return strings.Join(append(append([]string{"barman-cloud-restore"}, b.cloudOptions()...),
+			b.options.Cloud.SourceURL, b.options.ServerName, b.options.BackupID, "${PGDATA}"), " ")
+	}
+
+	if b.options.Host == "" {
+		return fmt.Sprintf("barman recover %s %s ${PGDATA}", b.options.ServerName, b.options.BackupID)
+	}
+
+	// The remote SSH command is parsed twice: by the local shell and by the shell of the Barman server.
+	return fmt.Sprintf("ssh %s barman recover --remote-ssh-command %s %s %s ${PGDATA}", b.sshDestination(),
+		quoteShell(quoteShell(b.options.RemoteSSHCommand)), b.options.ServerName, b.options.BackupID)
+}
+
+// GetRecoveryConfig returns a recovery config to restore data.
+func (b *barman) GetRecoveryConfig(pgVersion float64) map[string]string {
+	recoveryCfg := map[string]string{
+		"restore_command": b.walRestoreCommand(),
+	}
+
+	if pgVersion < defaults.PGVersion12 {
+		recoveryCfg["recovery_target_timeline"] = "latest"
+	}
+
+	return recoveryCfg
+}
+
+// Init initialize Barman tool.
+func (b *barman) Init(ctx context.Context, containerID string) error {
+	return nil
+}
+
+func (b *barman) isCloud() bool {
+	return b.options.Cloud.SourceURL != ""
+}
+
+func (b *barman) walRestoreCommand() string {
+	if b.isCloud() {
+		return strings.Join(append(append([]string{"barman-cloud-wal-restore"}, b.cloudOptions()...),
+			b.options.Cloud.SourceURL, b.options.ServerName, "%f", "%p"), " ")
+	}
+
+	if b.options.Host == "" {
+		return fmt.Sprintf("barman get-wal %s %%f > %%p", b.options.ServerName)
+	}
+
+	walRestoreCmd := []string{"barman-wal-restore"}
+
+	if b.options.User != "" {
+		walRestoreCmd = append(walRestoreCmd, "--user", b.options.User)
+	}
+
+	return strings.Join(append(walRestoreCmd, b.options.Host, b.options.ServerName, "%f", "%p"), " ")
+}
+
+func (b *barman) sshDestination() string {
+	if b.options.User == "" {
+		return b.options.Host
+	}
+
+	return b.options.User + "@" + b.options.Host
+}
+
+func quoteShell(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+func (b *barman) cloudOptions() []string {
+	cloudOptions := []string{}
+
+	if b.options.Cloud.CloudProvider != "" {
+		cloudOptions = append(cloudOptions, "--cloud-provider", b.options.Cloud.CloudProvider)
+	}
+
+	// An S3-compatible storage, for example, MinIO.
+	if b.options.Cloud.EndpointURL != "" {
+		cloudOptions = append(cloudOptions, "--endpoint-url", b.options.Cloud.EndpointURL)
+	}
+
+	return cloudOptions
+}
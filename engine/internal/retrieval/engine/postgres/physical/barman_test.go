@@ -0,0 +1,86 @@
+package physical
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBarmanOptions(t *testing.T) {
+	_, err := newBarman(barmanOptions{})
+	assert.EqualError(t, err, "the Barman server name must be defined")
+
+	barman, err := newBarman(barmanOptions{ServerName: "pg"})
+	require.NoError(t, err)
+	assert.Equal(t, "latest", barman.options.BackupID)
+
+	_, err = newBarman(barmanOptions{ServerName: "pg", Host: "backup.example.com"})
+	assert.EqualError(t, err, "the remote SSH command must be defined to recover data from the Barman server")
+}
+
+func TestBarmanRecoveryConfig(t *testing.T) {
+	barman, err := newBarman(barmanOptions{
+		ServerName:       "pg",
+		Host:             "backup.example.com",
+		User:             "barman",
+		RemoteSSHCommand: "ssh postgres@dblab.example.com",
+	})
+	require.NoError(t, err)
+
+	recoveryConfig := barman.GetRecoveryConfig(11.7)
+	expectedResponse11 := map[string]string{
+		"restore_command":          "barman-wal-restore --user barman backup.example.com pg %f %p",
+		"recovery_target_timeline": "latest",
+	}
+	assert.Equal(t, expectedResponse11, recoveryConfig)
+
+	recoveryConfig = barman.GetRecoveryConfig(12.3)
+	expectedResponse12 := map[string]string{
+		"restore_command": "barman-wal-restore --user barman backup.example.com pg %f %p",
+	}
+	assert.Equal(t, expectedResponse12, recoveryConfig)
+
+	barman.options.Host = ""
+	recoveryConfig = barman.GetRecoveryConfig(14.5)
+	assert.Equal(t, map[string]string{"restore_command": "barman get-wal pg %f > %p"}, recoveryConfig)
+}
+
+func TestBarmanRestoreCommand(t *testing.T) {
+	barman, err := newBarman(barmanOptions{ServerName: "pg", BackupID: "20221012T101112"})
+	require.NoError(t, err)
+
+	assert.Equal(t, "barman recover pg 20221012T101112 ${PGDATA}", barman.GetRestoreCommand())
+
+	barman, err = newBarman(barmanOptions{
+		ServerName:       "pg",
+		Host:             "backup.example.com",
+		User:             "barman",
+		RemoteSSHCommand: "ssh -p 2222 postgres@dblab.example.com",
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, `ssh barman@backup.example.com barman recover --remote-ssh-command `+
+		`''\''ssh -p 2222 postgres@dblab.example.com'\''' pg latest ${PGDATA}`, barman.GetRestoreCommand())
+}
+
+func TestBarmanCloud(t *testing.T) {
+	barman, err := newBarman(barmanOptions{
+		ServerName: "pg",
+		Cloud: barmanCloudOptions{
+			SourceURL:     "s3://backups/barman",
+			EndpointURL:   "http://minio:9000",
+			CloudProvider: "aws-s3",
+		},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "barman-cloud-restore --cloud-provider aws-s3 --endpoint-url http://minio:9000 "+
+		"s3://backups/barman pg latest ${PGDATA}", barman.GetRestoreCommand())
+
+	expectedResponse := map[string]string{
+		"restore_command": "barman-cloud-wal-restore --cloud-provider aws-s3 --endpoint-url http://minio:9000 " +
+			"s3://backups/barman pg %f %p",
+	}
+	assert.Equal(t, expectedResponse, barman.GetRecoveryConfig(14.5))
+}
@@ -0,0 +1,133 @@
+/*
+2022 © Postgres.ai
+*/
+
+package physical
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"gitlab.com/postgres-ai/database-lab/v3/internal/retrieval/engine/postgres/tools/defaults"
+)
+
+const (
+	pgbasebackupTool = "pgbasebackup"
+
+	// syncApplicationName identifies the sync instance in pg_stat_replication of the source.
+	syncApplicationName = "dblab_sync"
+)
+
+// pgbasebackup defines pg_basebackup as a tool to copy data from the source using the replication protocol.
+type pgbasebackup struct {
+	options pgbasebackupOptions
+}
+
+type pgbasebackupOptions struct {
+	Connection pgbasebackupConnection `yaml:"connection"`
+
+	// Slot defines a physical replication slot used by pg_basebackup and the sync instance.
+	Slot string `yaml:"slot"`
+
+	// CreateSlot creates the replication slot if it does not exist.
+	CreateSlot bool `yaml:"createSlot"`
+}
+
+// pgbasebackupConnection defines the source connection. The password is passed using the PGPASSWORD variable in "envs".
+type pgbasebackupConnection struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	Username string `yaml:"username"`
+}
+
+func newPgBaseBackup(options pgbasebackupOptions) *pgbasebackup {
+	return &pgbasebackup{
+		options: options,
+	}
+}
+
+// GetRestoreCommand returns a command to restore data.
+func (p *pgbasebackup) GetRestoreCommand() string {
+	connectionOptions := p.connectionOptions()
+
+	// The short form of the WAL method option is used because Postgres 9.6 names it "--xlog-method".
+	restoreCmd := append([]string{"pg_basebackup", "--pgdata=${PGDATA}"}, connectionOptions...)
+	restoreCmd = append(restoreCmd, "-X", "stream", "--checkpoint=fast", "--no-password", "--verbose")
+
+	if p.options.Slot == "" {
+		return strings.Join(restoreCmd, " ")
+	}
+
+	restoreCmd = append(restoreCmd, "--slot="+p.options.Slot)
+
+	if !p.options.CreateSlot {
+		return strings.Join(restoreCmd, " ")
+	}
+
+	createSlotCmd := append([]string{"pg_receivewal"}, connectionOptions...)
+	createSlotCmd = append(createSlotCmd, "--slot="+p.options.Slot, "--create-slot", "--if-not-exists", "--no-password")
+
+	// Group commands, so the output of both is redirected to the container logs.
+	return "(" + strings.Join(createSlotCmd, " ") + " && " + strings.Join(restoreCmd, " ") + ")"
+}
+
+// GetRecoveryConfig returns a recovery config to restore data.
+// The sync instance follows the source using streaming replication instead of restoring WAL from an archive.
+func (p *pgbasebackup) GetRecoveryConfig(pgVersion float64) map[string]string {
+	recoveryCfg := map[string]string{
+		"primary_conninfo": p.primaryConnInfo(),
+	}
+
+	if p.options.Slot != "" {
+		recoveryCfg["primary_slot_name"] = p.options.Slot
+	}
+
+	if pgVersion < defaults.PGVersion12 {
+		recoveryCfg["standby_mode"] = "on"
+		recoveryCfg["recovery_target_timeline"] = "latest"
+	}
+
+	return recoveryCfg
+}
+
+// Init initialize pg_basebackup tool.
+func (p *pgbasebackup) Init(ctx context.Context, containerID string) error {
+	return nil
+}
+
+func (p *pgbasebackup) connectionOptions() []string {
+	connectionOptions := []string{}
+
+	if p.options.Connection.Host != "" {
+		connectionOptions = append(connectionOptions, "--host="+p.options.Connection.Host)
+	}
+
+	if p.options.Connection.Port > 0 {
+		connectionOptions = append(connectionOptions, "--port="+strconv.Itoa(p.options.Connection.Port))
+	}
+
+	if p.options.Connection.Username != "" {
+		connectionOptions = append(connectionOptions, "--username="+p.options.Connection.Username)
+	}
+
+	return connectionOptions
+}
+
+func (p *pgbasebackup) primaryConnInfo() string {
+	connInfo := []string{}
+
+	if p.options.Connection.Host != "" {
+		connInfo = append(connInfo, "host="+p.options.Connection.Host)
+	}
+
+	if p.options.Connection.Port > 0 {
+		connInfo = append(connInfo, "port="+strconv.Itoa(p.options.Connection.Port))
+	}
+
+	if p.options.Connection.Username != "" {
+		connInfo = append(connInfo, "user="+p.options.Connection.Username)
+	}
+
+	return strings.Join(append(connInfo, "application_name="+syncApplicationName), " ")
+}
@@ -0,0 +1,81 @@
+//go:build integration
+// +build integration
+
+/*
+2022 © Postgres.ai
+*/
+
+package physical
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+const (
+	sourcePGData   = "/var/lib/postgresql/data/"
+	replicaPGData  = "/tmp/dblab_replica"
+	testSlotName   = "dblab_test"
+	sourceUser     = "postgres"
+	sourcePassword = "password"
+)
+
+func TestPgBaseBackupFromLocalContainer(t *testing.T) {
+	ctx := context.Background()
+
+	logStrategyForAcceptingConnections := wait.NewLogStrategy("database system is ready to accept connections")
+	logStrategyForAcceptingConnections.Occurrence = 2
+
+	req := testcontainers.ContainerRequest{
+		Name:  "pg_basebackup_test",
+		Image: "postgres:14",
+		WaitingFor: wait.ForAll(
+			logStrategyForAcceptingConnections,
+			wait.ForLog("PostgreSQL init process complete; ready for start up."),
+		),
+		Env: map[string]string{
+			"POSTGRES_PASSWORD": sourcePassword,
+			"PGDATA":            sourcePGData,
+		},
+	}
+
+	postgresContainer, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: req,
+		Started:          true,
+	})
+	require.Nil(t, err)
+
+	defer func() { _ = postgresContainer.Terminate(ctx) }()
+
+	// The default pg_hba.conf allows replication connections from localhost, so the copy is taken inside the source container.
+	restorer := newPgBaseBackup(pgbasebackupOptions{
+		Connection: pgbasebackupConnection{Host: "127.0.0.1", Port: 5432, Username: sourceUser},
+		Slot:       testSlotName,
+		CreateSlot: true,
+	})
+
+	restoreCmd := "export PGDATA=" + replicaPGData + "; " + restorer.GetRestoreCommand()
+
+	code, err := postgresContainer.Exec(ctx, []string{"bash", "-c", restoreCmd})
+	require.Nil(t, err)
+	assert.Equal(t, 0, code)
+
+	code, err = postgresContainer.Exec(ctx, []string{"test", "-f", replicaPGData + "/PG_VERSION"})
+	require.Nil(t, err)
+	assert.Equal(t, 0, code)
+
+	// The slot is created once, so the restore command can be repeated.
+	code, err = postgresContainer.Exec(ctx, []string{"bash", "-c", "rm -rf " + replicaPGData + " && " + restoreCmd})
+	require.Nil(t, err)
+	assert.Equal(t, 0, code)
+
+	code, err = postgresContainer.Exec(ctx, []string{"psql", "-U", sourceUser, "-XAt", "-v", "ON_ERROR_STOP=1", "-c",
+		"SELECT 1 / count(*) FROM pg_replication_slots WHERE slot_name = '" + testSlotName + "'"})
+	require.Nil(t, err)
+	assert.Equal(t, 0, code)
+}
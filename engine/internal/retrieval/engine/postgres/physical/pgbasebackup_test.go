@@ -0,0 +1,51 @@
+package physical
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPgBaseBackupRecoveryConfig(t *testing.T) {
+	pgbasebackup := newPgBaseBackup(pgbasebackupOptions{
+		Connection: pgbasebackupConnection{Host: "source.example.com", Port: 5432, Username: "replicator"},
+		Slot:       "dblab",
+	})
+
+	recoveryConfig := pgbasebackup.GetRecoveryConfig(11.7)
+	expectedResponse11 := map[string]string{
+		"primary_conninfo":         "host=source.example.com port=5432 user=replicator application_name=dblab_sync",
+		"primary_slot_name":        "dblab",
+		"standby_mode":             "on",
+		"recovery_target_timeline": "latest",
+	}
+	assert.Equal(t, expectedResponse11, recoveryConfig)
+
+	pgbasebackup.options.Slot = ""
+	recoveryConfig = pgbasebackup.GetRecoveryConfig(14.5)
+	expectedResponse14 := map[string]string{
+		"primary_conninfo": "host=source.example.com port=5432 user=replicator application_name=dblab_sync",
+	}
+	assert.Equal(t, expectedResponse14, recoveryConfig)
+}
+
+func TestPgBaseBackupRestoreCommand(t *testing.T) {
+	pgbasebackup := newPgBaseBackup(pgbasebackupOptions{
+		Connection: pgbasebackupConnection{Host: "source.example.com", Port: 5432, Username: "replicator"},
+	})
+
+	restoreCmd := pgbasebackup.GetRestoreCommand()
+	expectedResponse := "pg_basebackup --pgdata=${PGDATA} --host=source.example.com --port=5432 --username=replicator " +
+		"-X stream --checkpoint=fast --no-password --verbose"
+	assert.Equal(t, expectedResponse, restoreCmd)
+
+	pgbasebackup.options.Slot = "dblab"
+	restoreCmd = pgbasebackup.GetRestoreCommand()
+	assert.Equal(t, expectedResponse+" --slot=dblab", restoreCmd)
+
+	pgbasebackup.options.CreateSlot = true
+	restoreCmd = pgbasebackup.GetRestoreCommand()
+	expectedResponse = "(pg_receivewal --host=source.example.com --port=5432 --username=replicator " +
+		"--slot=dblab --create-slot --if-not-exists --no-password && " + expectedResponse + " --slot=dblab)"
+	assert.Equal(t, expectedResponse, restoreCmd)
+}
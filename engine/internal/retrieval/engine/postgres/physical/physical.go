@@ -77,6 +77,8 @@ type CopyOptions struct {
 	Envs            map[string]string      `yaml:"envs"`
 	WALG            walgOptions            `yaml:"walg"`
 	PgBackRest      pgbackrestOptions      `yaml:"pgbackrest"`
+	PgBaseBackup    pgbasebackupOptions    `yaml:"pgbasebackup"`
+	Barman          barmanOptions          `yaml:"barman"`
 	CustomTool      customOptions          `yaml:"customTool"`
 	Sync            Sync                   `yaml:"sync"`
 }
@@ -142,6 +144,12 @@ func (r *RestoreJob) getRestorer(client *client.Client, tool string) (restorer,
 	case pgbackrestTool:
 		return newPgBackRest(r.PgBackRest), nil
 
+	case pgbasebackupTool:
+		return newPgBaseBackup(r.PgBaseBackup), nil
+
+	case barmanTool:
+		return newBarman(r.Barman)
+
 	case customTool:
 		return newCustomTool(r.CustomTool), nil
 	}
@@ -20,6 +20,7 @@ import (
 	"github.com/pkg/errors"
 
 	"gitlab.com/postgres-ai/database-lab/v3/internal/diagnostic"
+	"gitlab.com/postgres-ai/database-lab/v3/internal/events"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/provision/databases/postgres/pgconfig"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/provision/pool"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/provision/resources"
@@ -30,9 +31,11 @@ import (
 	"gitlab.com/postgres-ai/database-lab/v3/internal/retrieval/engine/postgres/tools/activity"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/retrieval/engine/postgres/tools/cont"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/retrieval/engine/postgres/tools/health"
+	"gitlab.com/postgres-ai/database-lab/v3/internal/retrieval/engine/postgres/tools/masking"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/retrieval/engine/postgres/tools/query"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/retrieval/options"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/telemetry"
+	"gitlab.com/postgres-ai/database-lab/v3/internal/webhooks"
 	"gitlab.com/postgres-ai/database-lab/v3/pkg/config/global"
 	"gitlab.com/postgres-ai/database-lab/v3/pkg/log"
 	"gitlab.com/postgres-ai/database-lab/v3/pkg/util"
@@ -50,6 +53,8 @@ type LogicalInitial struct {
 	name           string
 	cloneManager   pool.FSManager
 	tm             *telemetry.Agent
+	wh             *webhooks.Service
+	broker         *events.Broker
 	fsPool         *resources.Pool
 	dockerClient   *client.Client
 	options        LogicalOptions
@@ -57,12 +62,14 @@ type LogicalInitial struct {
 	engineProps    global.EngineProps
 	dbMarker       *dbmarker.Marker
 	queryProcessor *query.Processor
+	masker         *masking.Masker
 }
 
 // LogicalOptions describes options for a logical initialization job.
 type LogicalOptions struct {
 	DataPatching        DataPatching      `yaml:"dataPatching"`
 	PreprocessingScript string            `yaml:"preprocessingScript"`
+	Masking             masking.Config    `yaml:"masking"`
 	Configs             map[string]string `yaml:"configs"`
 	Schedule            Scheduler         `yaml:"schedule"`
 }
@@ -76,7 +83,7 @@ type DataPatching struct {
 
 // NewLogicalInitialJob creates a new logical initial job.
 func NewLogicalInitialJob(cfg config.JobConfig, global *global.Config, engineProps global.EngineProps, cloneManager pool.FSManager,
-	tm *telemetry.Agent) (*LogicalInitial, error) {
+	tm *telemetry.Agent, wh *webhooks.Service, broker *events.Broker) (*LogicalInitial, error) {
 	li := &LogicalInitial{
 		name:         cfg.Spec.Name,
 		cloneManager: cloneManager,
@@ -86,10 +93,12 @@ func NewLogicalInitialJob(cfg config.JobConfig, global *global.Config, enginePro
 		engineProps:  engineProps,
 		dbMarker:     cfg.Marker,
 		tm:           tm,
+		wh:           wh,
+		broker:       broker,
 	}
 
 	if err := li.Reload(cfg.Spec.Options); err != nil {
-		return nil, errors.Wrap(err, "failed to unmarshal configuration options")
+		return nil, errors.Wrap(err, "failed to load configuration options")
 	}
 
 	if qp := li.options.DataPatching.QueryPreprocessing; qp.QueryPath != "" || qp.Inline != "" {
@@ -109,9 +118,26 @@ func (s *LogicalInitial) patchContainerName() string {
 	return patchContainerPrefix + s.engineProps.InstanceID
 }
 
-// Reload reloads job configuration.
+// Reload reloads job configuration. The previous configuration is kept if the new one is invalid.
 func (s *LogicalInitial) Reload(cfg map[string]interface{}) (err error) {
-	return options.Unmarshal(cfg, &s.options)
+	logicalOptions := LogicalOptions{}
+
+	if err := options.Unmarshal(cfg, &logicalOptions); err != nil {
+		return errors.Wrap(err, "failed to unmarshal configuration options")
+	}
+
+	if err := logicalOptions.Masking.Validate(); err != nil {
+		return errors.Wrap(err, "invalid masking configuration")
+	}
+
+	s.options = logicalOptions
+	s.masker = nil
+
+	if s.options.Masking.Enabled() {
+		s.masker = masking.NewMasker(s.dockerClient, s.options.Masking, s.globalCfg.Database.Name(), s.globalCfg.Database.User())
+	}
+
+	return nil
 }
 
 // ReportActivity reports the current job activity.
@@ -144,7 +170,7 @@ func (s *LogicalInitial) Run(ctx context.Context) error {
 		return errors.Wrap(err, "failed to store PostgreSQL configs for the snapshot")
 	}
 
-	if s.queryProcessor != nil {
+	if s.queryProcessor != nil || s.masker != nil {
 		if err := s.runPreprocessingQueries(ctx, dataDir); err != nil {
 			return errors.Wrap(err, "failed to run preprocessing queries")
 		}
@@ -152,7 +178,8 @@ func (s *LogicalInitial) Run(ctx context.Context) error {
 
 	dataStateAt := extractDataStateAt(s.dbMarker)
 
-	if _, err := s.cloneManager.CreateSnapshot("", dataStateAt); err != nil {
+	snapshotID, err := s.cloneManager.CreateSnapshot("", dataStateAt)
+	if err != nil {
 		var existsError *thinclones.SnapshotExistsError
 		if errors.As(err, &existsError) {
 			log.Msg("Skip snapshotting: ", existsError.Error())
@@ -167,6 +194,8 @@ func (s *LogicalInitial) Run(ctx context.Context) error {
 	}
 
 	s.tm.SendEvent(ctx, telemetry.SnapshotCreatedEvent, telemetry.SnapshotCreated{})
+	s.wh.Emit(webhooks.SnapshotCreatedEvent, webhooks.SnapshotEvent{IDs: []string{snapshotID}, Pool: s.fsPool.Name})
+	s.broker.Publish(snapshotAddedEvent(snapshotID, s.fsPool.Name))
 
 	return nil
 }
@@ -263,6 +292,10 @@ func (s *LogicalInitial) runPreprocessingQueries(ctx context.Context, dataDir st
 		return errors.Wrap(err, "failed to run preprocessing queries")
 	}
 
+	if err := s.masker.Apply(ctx, containerID); err != nil {
+		return errors.Wrap(err, "failed to mask data")
+	}
+
 	return nil
 }
 
@@ -28,9 +28,11 @@ import (
 	"github.com/robfig/cron/v3"
 
 	"gitlab.com/postgres-ai/database-lab/v3/internal/diagnostic"
+	"gitlab.com/postgres-ai/database-lab/v3/internal/events"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/provision/databases/postgres/pgconfig"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/provision/pool"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/provision/resources"
+	"gitlab.com/postgres-ai/database-lab/v3/internal/provision/thinclones"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/retrieval/config"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/retrieval/dbmarker"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/retrieval/engine/postgres/tools"
@@ -38,10 +40,12 @@ import (
 	"gitlab.com/postgres-ai/database-lab/v3/internal/retrieval/engine/postgres/tools/cont"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/retrieval/engine/postgres/tools/defaults"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/retrieval/engine/postgres/tools/health"
+	"gitlab.com/postgres-ai/database-lab/v3/internal/retrieval/engine/postgres/tools/masking"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/retrieval/engine/postgres/tools/pgtool"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/retrieval/engine/postgres/tools/query"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/retrieval/options"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/telemetry"
+	"gitlab.com/postgres-ai/database-lab/v3/internal/webhooks"
 	"gitlab.com/postgres-ai/database-lab/v3/pkg/config/global"
 	"gitlab.com/postgres-ai/database-lab/v3/pkg/log"
 	"gitlab.com/postgres-ai/database-lab/v3/pkg/util"
@@ -58,9 +62,11 @@ const (
 
 	checkpointTimestampLabel = "Time of latest checkpoint:"
 
-	restoreCommandOption = "restore_command"
-	targetActionOption   = "recovery_target_action"
-	promoteTargetAction  = "promote"
+	restoreCommandOption  = "restore_command"
+	primaryConnInfoOption = "primary_conninfo"
+	primarySlotNameOption = "primary_slot_name"
+	targetActionOption    = "recovery_target_action"
+	promoteTargetAction   = "promote"
 
 	// WAL parsing constants.
 	walNameLen  = 24
@@ -99,7 +105,14 @@ type PhysicalInitial struct {
 	schedulerCtx   context.Context
 	promotionMutex sync.Mutex
 	queryProcessor *query.Processor
+	masker         *masking.Masker
 	tm             *telemetry.Agent
+	wh             *webhooks.Service
+	broker         *events.Broker
+
+	// runMutex serializes scheduled, on-demand, and point-in-time snapshots, which share the data state mark.
+	runMutex           sync.Mutex
+	lastPreDataStateAt string
 }
 
 // PhysicalOptions describes options for a physical initialization job.
@@ -107,6 +120,7 @@ type PhysicalOptions struct {
 	SkipStartSnapshot   bool              `yaml:"skipStartSnapshot"`
 	Promotion           Promotion         `yaml:"promotion"`
 	PreprocessingScript string            `yaml:"preprocessingScript"`
+	Masking             masking.Config    `yaml:"masking"`
 	Configs             map[string]string `yaml:"configs"`
 	Sysctls             map[string]string `yaml:"sysctls"`
 	Envs                map[string]string `yaml:"envs"`
@@ -157,7 +171,7 @@ type syncState struct {
 // NewPhysicalInitialJob creates a new physical initial job.
 func NewPhysicalInitialJob(
 	cfg config.JobConfig, global *global.Config, engineProps global.EngineProps, cloneManager pool.FSManager,
-	tm *telemetry.Agent,
+	tm *telemetry.Agent, wh *webhooks.Service, broker *events.Broker,
 ) (*PhysicalInitial, error) {
 	p := &PhysicalInitial{
 		name:         cfg.Spec.Name,
@@ -169,6 +183,8 @@ func NewPhysicalInitialJob(
 		dbMark:       &dbmarker.Config{DataType: dbmarker.PhysicalDataType},
 		dockerClient: cfg.Docker,
 		tm:           tm,
+		wh:           wh,
+		broker:       broker,
 	}
 
 	if err := p.loadConfig(cfg.Spec.Options); err != nil {
@@ -183,11 +199,20 @@ func NewPhysicalInitialJob(
 		p.queryProcessor = query.NewQueryProcessor(cfg.Docker, qp, global.Database.Name(), global.Database.User())
 	}
 
+	p.setupMasker()
 	p.setupScheduler()
 
 	return p, nil
 }
 
+func (p *PhysicalInitial) setupMasker() {
+	p.masker = nil
+
+	if p.options.Masking.Enabled() {
+		p.masker = masking.NewMasker(p.dockerClient, p.options.Masking, p.globalCfg.Database.Name(), p.globalCfg.Database.User())
+	}
+}
+
 func (p *PhysicalInitial) setupScheduler() {
 	if !p.hasSchedulingOptions() {
 		return
@@ -214,6 +239,15 @@ func (p *PhysicalInitial) validateConfig() error {
 		return err
 	}
 
+	if err := p.options.Masking.Validate(); err != nil {
+		return errors.Wrap(err, "invalid masking configuration")
+	}
+
+	// Masking requires a running Postgres instance, which is available only during the promotion.
+	if p.options.Masking.Enabled() && !p.options.Promotion.Enabled {
+		return errors.New("masking requires promotion to be enabled")
+	}
+
 	return nil
 }
 
@@ -245,22 +279,34 @@ func (p *PhysicalInitial) Name() string {
 	return p.name
 }
 
-// Reload reloads job configuration.
+// Reload reloads job configuration. The previous configuration is kept if the new one is invalid.
 func (p *PhysicalInitial) Reload(cfg map[string]interface{}) (err error) {
+	previousOptions := p.options
+
 	if err := p.loadConfig(cfg); err != nil {
 		return errors.Wrap(err, "failed to load job config")
 	}
 
+	if err := p.validateConfig(); err != nil {
+		p.options = previousOptions
+		return errors.Wrap(err, "invalid physicalSnapshot configuration")
+	}
+
+	p.setupMasker()
 	p.reloadScheduler()
 
 	return nil
 }
 
 func (p *PhysicalInitial) loadConfig(cfg map[string]interface{}) (err error) {
-	if err := options.Unmarshal(cfg, &p.options); err != nil {
+	physicalOptions := PhysicalOptions{}
+
+	if err := options.Unmarshal(cfg, &physicalOptions); err != nil {
 		return errors.Wrap(err, "failed to unmarshal configuration options")
 	}
 
+	p.options = physicalOptions
+
 	return nil
 }
 
@@ -300,7 +346,12 @@ func (p *PhysicalInitial) Run(ctx context.Context) (err error) {
 	return p.run(p.schedulerCtx)
 }
 
-func (p *PhysicalInitial) run(ctx context.Context) (err error) {
+// TakeSnapshot takes a snapshot on demand. The schedule of automatic snapshots is kept as is.
+func (p *PhysicalInitial) TakeSnapshot(ctx context.Context) error {
+	return p.takeSnapshot(ctx)
+}
+
+func (p *PhysicalInitial) run(ctx context.Context) error {
 	select {
 	case <-ctx.Done():
 		if p.scheduler != nil {
@@ -313,10 +364,17 @@ func (p *PhysicalInitial) run(ctx context.Context) (err error) {
 	default:
 	}
 
+	return p.takeSnapshot(ctx)
+}
+
+func (p *PhysicalInitial) takeSnapshot(ctx context.Context) (err error) {
+	p.runMutex.Lock()
+	defer p.runMutex.Unlock()
+
 	p.dbMark.DataStateAt = extractDataStateAt(p.dbMarker)
 
 	// Snapshot data.
-	preDataStateAt := time.Now().Format(tools.DataStateAtFormat)
+	preDataStateAt := p.nextPreDataStateAt()
 	cloneName := fmt.Sprintf("clone%s_%s", pre, preDataStateAt)
 
 	defer func() {
@@ -345,7 +403,7 @@ func (p *PhysicalInitial) run(ctx context.Context) (err error) {
 
 	defer func() {
 		if err != nil {
-			if errDestroy := p.cloneManager.DestroySnapshot(snapshotName); errDestroy != nil {
+			if errDestroy := p.cloneManager.DestroySnapshot(snapshotName, thinclones.DestroyOptions{Force: true}); errDestroy != nil {
 				log.Err(fmt.Sprintf("Failed to destroy the %q snapshot: %v", snapshotName, errDestroy))
 			}
 		}
@@ -365,7 +423,7 @@ func (p *PhysicalInitial) run(ctx context.Context) (err error) {
 
 	// Promotion.
 	if p.options.Promotion.Enabled {
-		if err := p.promoteInstance(ctx, path.Join(p.fsPool.ClonesDir(), cloneName, p.fsPool.DataSubDir), syState); err != nil {
+		if err := p.promoteInstance(ctx, path.Join(p.fsPool.ClonesDir(), cloneName, p.fsPool.DataSubDir), syState, nil); err != nil {
 			return errors.Wrap(err, "failed to promote instance")
 		}
 	}
@@ -383,17 +441,38 @@ func (p *PhysicalInitial) run(ctx context.Context) (err error) {
 	}
 
 	// Create a snapshot.
-	if _, err := p.cloneManager.CreateSnapshot(cloneName, p.dbMark.DataStateAt); err != nil {
+	snapshotID, err := p.cloneManager.CreateSnapshot(cloneName, p.dbMark.DataStateAt)
+	if err != nil {
 		return errors.Wrap(err, "failed to create a snapshot")
 	}
 
 	p.updateDataStateAt()
 
 	p.tm.SendEvent(ctx, telemetry.SnapshotCreatedEvent, telemetry.SnapshotCreated{})
+	p.wh.Emit(webhooks.SnapshotCreatedEvent, webhooks.SnapshotEvent{IDs: []string{snapshotID}, Pool: p.fsPool.Name})
+	p.broker.Publish(snapshotAddedEvent(snapshotID, p.fsPool.Name))
 
 	return nil
 }
 
+// nextPreDataStateAt returns the time of the pre-snapshot. Snapshots taken in the same second
+// would share names, so it waits for the next second if the previous snapshot has been taken in the current one.
+// The caller must hold runMutex.
+func (p *PhysicalInitial) nextPreDataStateAt() string {
+	now := time.Now().UTC()
+	preDataStateAt := now.Format(tools.DataStateAtFormat)
+
+	if preDataStateAt == p.lastPreDataStateAt {
+		next := now.Truncate(time.Second).Add(time.Second)
+		time.Sleep(next.Sub(now))
+		preDataStateAt = next.Format(tools.DataStateAtFormat)
+	}
+
+	p.lastPreDataStateAt = preDataStateAt
+
+	return preDataStateAt
+}
+
 func (p *PhysicalInitial) checkSyncInstance(ctx context.Context) (string, error) {
 	log.Msg("Check the sync instance state: ", p.syncInstanceName())
 
@@ -490,7 +569,10 @@ func (p *PhysicalInitial) promoteContainerName() string {
 	return promoteContainerPrefix + p.engineProps.InstanceID
 }
 
-func (p *PhysicalInitial) promoteInstance(ctx context.Context, clonePath string, syState syncState) (err error) {
+// promoteInstance runs a promotion container on the clone data and prepares the data for snapshotting.
+// If recoveryTarget is defined, WAL from the archive is replayed up to the target instead of promoting the latest state.
+func (p *PhysicalInitial) promoteInstance(ctx context.Context, clonePath string, syState syncState,
+	recoveryTarget map[string]string) (err error) {
 	p.promotionMutex.Lock()
 	defer p.promotionMutex.Unlock()
 
@@ -511,6 +593,10 @@ func (p *PhysicalInitial) promoteInstance(ctx context.Context, clonePath string,
 		return errors.Wrap(err, "failed to read recovery configuration file")
 	}
 
+	// The promotion instance must not stream from the source and occupy the replication slot of the sync instance.
+	delete(recoveryFileConfig, primaryConnInfoOption)
+	delete(recoveryFileConfig, primarySlotNameOption)
+
 	if len(recoveryFileConfig) == 0 {
 		if err := cfgManager.RemoveRecoveryConfig(); err != nil {
 			return errors.Wrap(err, "failed to remove recovery config file")
@@ -520,7 +606,19 @@ func (p *PhysicalInitial) promoteInstance(ctx context.Context, clonePath string,
 	recoveryConfig := make(map[string]string)
 
 	// Item 5. Remove a recovery file: https://gitlab.com/postgres-ai/database-lab/-/issues/236#note_513401256
-	if syState.Err != nil {
+	if len(recoveryTarget) > 0 {
+		if recoveryConfig, err = buildTargetRecoveryConfig(recoveryFileConfig, recoveryTarget); err != nil {
+			return err
+		}
+
+		if err := cfgManager.RemoveRecoveryConfig(); err != nil {
+			return errors.Wrap(err, "failed to remove recovery config file")
+		}
+
+		if err := cfgManager.ApplyRecovery(recoveryConfig); err != nil {
+			return errors.Wrap(err, "failed to apply recovery configuration")
+		}
+	} else if syState.Err != nil {
 		recoveryConfig = buildRecoveryConfig(recoveryFileConfig, p.options.Promotion.Recovery)
 
 		if err := cfgManager.ApplyRecovery(recoveryFileConfig); err != nil {
@@ -631,8 +729,11 @@ func (p *PhysicalInitial) promoteInstance(ctx context.Context, clonePath string,
 		}
 	}
 
-	if err := p.markDSA(ctx, syState.DSA, containerID, clonePath, cfgManager.GetPgVersion()); err != nil {
-		return errors.Wrap(err, "failed to mark dataStateAt")
+	// The dataStateAt of a point-in-time snapshot is defined by the recovery target.
+	if len(recoveryTarget) == 0 {
+		if err := p.markDSA(ctx, syState.DSA, containerID, clonePath, cfgManager.GetPgVersion()); err != nil {
+			return errors.Wrap(err, "failed to mark dataStateAt")
+		}
 	}
 
 	if p.queryProcessor != nil {
@@ -641,6 +742,10 @@ func (p *PhysicalInitial) promoteInstance(ctx context.Context, clonePath string,
 		}
 	}
 
+	if err := p.masker.Apply(ctx, containerID); err != nil {
+		return errors.Wrap(err, "failed to mask data")
+	}
+
 	if err := tools.RunCheckpoint(ctx, p.dockerClient, containerID, p.globalCfg.Database.User(), p.globalCfg.Database.Name()); err != nil {
 		return errors.Wrap(err, "failed to run checkpoint")
 	}
@@ -1067,10 +1172,14 @@ func (p *PhysicalInitial) cleanupSnapshots(retentionLimit int) error {
 	default:
 	}
 
-	_, err := p.cloneManager.CleanupSnapshots(retentionLimit)
+	destroyedSnapshots, err := p.cloneManager.CleanupSnapshots(retentionLimit)
 	if err != nil {
 		return errors.Wrap(err, "failed to clean up snapshots")
 	}
 
+	if len(destroyedSnapshots) > 0 {
+		p.wh.Emit(webhooks.SnapshotCleanedUpEvent, webhooks.SnapshotEvent{IDs: destroyedSnapshots, Pool: p.fsPool.Name})
+	}
+
 	return nil
 }
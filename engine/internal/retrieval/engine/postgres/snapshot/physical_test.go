@@ -12,6 +12,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/config/global"
 	"gitlab.com/postgres-ai/database-lab/v3/pkg/log"
 )
 
@@ -210,3 +211,45 @@ func TestRecoveryConfig(t *testing.T) {
 		assert.EqualValues(t, tc.recoveryConfig, recoveryConfig)
 	}
 }
+
+func TestReloadMasking(t *testing.T) {
+	p := &PhysicalInitial{globalCfg: &global.Config{}}
+
+	require.NoError(t, p.Reload(map[string]interface{}{}))
+	assert.Nil(t, p.masker)
+
+	maskingCfg := map[string]interface{}{
+		"tables": []interface{}{
+			map[string]interface{}{"name": "users", "columns": map[string]interface{}{"email": "fake_email"}},
+		},
+	}
+
+	err := p.Reload(map[string]interface{}{"masking": maskingCfg})
+	assert.EqualError(t, err, "invalid physicalSnapshot configuration: masking requires promotion to be enabled")
+	assert.Nil(t, p.masker)
+	assert.False(t, p.options.Masking.Enabled())
+
+	require.NoError(t, p.Reload(map[string]interface{}{"masking": maskingCfg, "promotion": map[string]interface{}{"enabled": true}}))
+	assert.NotNil(t, p.masker)
+
+	require.NoError(t, p.Reload(map[string]interface{}{"promotion": map[string]interface{}{"enabled": true}}))
+	assert.Nil(t, p.masker)
+}
+
+func TestReloadLogicalMasking(t *testing.T) {
+	s := &LogicalInitial{globalCfg: &global.Config{}}
+
+	maskingCfg := map[string]interface{}{
+		"tables": []interface{}{
+			map[string]interface{}{"name": "users", "columns": map[string]interface{}{"email": "encrypt"}},
+		},
+	}
+
+	assert.Error(t, s.Reload(map[string]interface{}{"masking": maskingCfg}))
+	assert.Nil(t, s.masker)
+
+	maskingCfg["tables"].([]interface{})[0].(map[string]interface{})["columns"] = map[string]interface{}{"email": "hash"}
+
+	require.NoError(t, s.Reload(map[string]interface{}{"masking": maskingCfg}))
+	assert.NotNil(t, s.masker)
+}
@@ -0,0 +1,185 @@
+/*
+2022 © Postgres.ai
+*/
+
+package snapshot
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"gitlab.com/postgres-ai/database-lab/v3/internal/provision/resources"
+	"gitlab.com/postgres-ai/database-lab/v3/internal/telemetry"
+	"gitlab.com/postgres-ai/database-lab/v3/internal/webhooks"
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/log"
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/util"
+)
+
+const (
+	pitr = "_pitr"
+
+	targetTimeOption     = "recovery_target_time"
+	targetTimelineOption = "recovery_target_timeline"
+
+	// recoveryTargetTimeFormat defines the timestamp format of recovery_target_time.
+	recoveryTargetTimeFormat = "2006-01-02 15:04:05.999999-07:00"
+)
+
+// TakePITRSnapshot recovers the data as of the target time and takes a snapshot of it.
+// The recovery starts from the closest snapshot taken before the target time and replays WAL from the archive.
+func (p *PhysicalInitial) TakePITRSnapshot(ctx context.Context, targetTime time.Time) (snapshotID, baseSnapshotID string, err error) {
+	p.runMutex.Lock()
+	defer p.runMutex.Unlock()
+
+	if err := p.CheckPITRTarget(targetTime); err != nil {
+		return "", "", err
+	}
+
+	baseSnapshot, preSnapshotID, err := findBaseSnapshot(p.cloneManager.SnapshotList(), p.fsPool.Name, targetTime)
+	if err != nil {
+		return "", "", err
+	}
+
+	log.Msg(fmt.Sprintf("Recover the data as of %s from snapshot %s", targetTime.Format(time.RFC3339), baseSnapshot.ID))
+
+	dataStateAt := targetTime.UTC().Format(util.DataStateAtFormat)
+	cloneName := fmt.Sprintf("clone%s_%s", pitr, dataStateAt)
+
+	// The pre-snapshot keeps the data of the sync instance before promotion, so WAL can still be replayed on top of it.
+	if err := p.cloneManager.CreateClone(cloneName, preSnapshotID); err != nil {
+		return "", "", errors.Wrapf(err, "failed to create \"pitr\" clone %s", cloneName)
+	}
+
+	defer func() {
+		if err != nil {
+			if errDestroy := p.cloneManager.DestroyClone(cloneName); errDestroy != nil {
+				log.Err(fmt.Sprintf("Failed to destroy clone %q: %v", cloneName, errDestroy))
+			}
+		}
+	}()
+
+	recoveryTarget := map[string]string{
+		targetTimeOption:   targetTime.UTC().Format(recoveryTargetTimeFormat),
+		targetActionOption: promoteTargetAction,
+	}
+
+	clonePath := path.Join(p.fsPool.ClonesDir(), cloneName, p.fsPool.DataSubDir)
+
+	if err := p.promoteInstance(ctx, clonePath, syncState{DSA: dataStateAt}, recoveryTarget); err != nil {
+		return "", "", errors.Wrap(err, "failed to recover instance")
+	}
+
+	snapshotID, err = p.cloneManager.CreateSnapshot(cloneName, dataStateAt)
+	if err != nil {
+		return "", "", errors.Wrap(err, "failed to create a snapshot")
+	}
+
+	if snapshotID == "" {
+		return "", "", errors.Errorf("pool %s does not support snapshots of clones", p.fsPool.Name)
+	}
+
+	p.tm.SendEvent(ctx, telemetry.SnapshotCreatedEvent, telemetry.SnapshotCreated{})
+	p.wh.Emit(webhooks.SnapshotCreatedEvent, webhooks.SnapshotEvent{IDs: []string{snapshotID}, Pool: p.fsPool.Name})
+	p.broker.Publish(snapshotAddedEvent(snapshotID, p.fsPool.Name))
+
+	return snapshotID, baseSnapshot.ID, nil
+}
+
+// CheckPITRTarget checks that the data can be recovered to the target time.
+func (p *PhysicalInitial) CheckPITRTarget(targetTime time.Time) error {
+	if !p.options.Promotion.Enabled {
+		return errors.New("promotion must be enabled to take point-in-time snapshots")
+	}
+
+	if targetTime.After(time.Now()) {
+		return errors.Errorf("target time %s is in the future", targetTime.Format(time.RFC3339))
+	}
+
+	_, _, err := findBaseSnapshot(p.cloneManager.SnapshotList(), p.fsPool.Name, targetTime)
+
+	return err
+}
+
+// findBaseSnapshot returns the snapshot prepared by the physical job from the latest pre-snapshot taken before the target time
+// and the ID of that pre-snapshot. The promoted data of the snapshot may be more recent than the pre-snapshot,
+// so the recovery starts from the pre-snapshot, which must not be ahead of the target.
+func findBaseSnapshot(snapshots []resources.Snapshot, poolName string, targetTime time.Time) (*resources.Snapshot, string, error) {
+	var (
+		baseSnapshot       *resources.Snapshot
+		preSnapshotID      string
+		preSnapshotTakenAt time.Time
+	)
+
+	for i := range snapshots {
+		// Derived snapshots and point-in-time snapshots contain promoted data and cannot be used for recovery.
+		preID, preTakenAt, ok := buildPreSnapshotID(snapshots[i].ID, poolName)
+		if !ok || preTakenAt.After(targetTime) {
+			continue
+		}
+
+		if baseSnapshot != nil && !preSnapshotTakenAt.Before(preTakenAt) {
+			continue
+		}
+
+		baseSnapshot, preSnapshotID, preSnapshotTakenAt = &snapshots[i], preID, preTakenAt
+	}
+
+	if baseSnapshot == nil {
+		return nil, "", errors.Errorf("no snapshots taken before %s found", targetTime.Format(time.RFC3339))
+	}
+
+	return baseSnapshot, preSnapshotID, nil
+}
+
+// buildPreSnapshotID returns the ID and the creation time of the pre-snapshot a snapshot of the physical job has been cloned from.
+// For example, "pool/clone_pre_20221012101112@snapshot_20221012100000" has been cloned from "pool@snapshot_20221012101112_pre".
+func buildPreSnapshotID(snapshotID, poolName string) (string, time.Time, bool) {
+	dataset, snapshotName, ok := strings.Cut(snapshotID, "@")
+	if !ok {
+		return "", time.Time{}, false
+	}
+
+	cloneDataset := fmt.Sprintf("%s/clone%s_", poolName, pre)
+
+	if !strings.HasPrefix(dataset, cloneDataset) {
+		return "", time.Time{}, false
+	}
+
+	preDataStateAt := strings.TrimPrefix(dataset, cloneDataset)
+
+	preTakenAt, err := time.Parse(util.DataStateAtFormat, preDataStateAt)
+	if err != nil {
+		return "", time.Time{}, false
+	}
+
+	snapshotPrefix := snapshotName[:strings.LastIndex(snapshotName, "_")+1]
+
+	return poolName + "@" + snapshotPrefix + preDataStateAt + pre, preTakenAt, true
+}
+
+// buildTargetRecoveryConfig defines the recovery configuration to replay WAL from the archive up to the recovery target.
+func buildTargetRecoveryConfig(fileConfig, recoveryTarget map[string]string) (map[string]string, error) {
+	restoreCommand := fileConfig[restoreCommandOption]
+	if restoreCommand == "" {
+		return nil, errors.New("restore_command is not defined in the recovery configuration, WAL archive is not available")
+	}
+
+	recoveryConfig := map[string]string{
+		restoreCommandOption: restoreCommand,
+	}
+
+	if timeline, ok := fileConfig[targetTimelineOption]; ok {
+		recoveryConfig[targetTimelineOption] = timeline
+	}
+
+	for k, v := range recoveryTarget {
+		recoveryConfig[k] = v
+	}
+
+	return recoveryConfig, nil
+}
@@ -0,0 +1,85 @@
+/*
+2022 © Postgres.ai
+*/
+
+package snapshot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/postgres-ai/database-lab/v3/internal/provision/resources"
+)
+
+func TestFindBaseSnapshot(t *testing.T) {
+	snapshots := []resources.Snapshot{
+		{
+			ID:          "dblab_pool/clone_pre_20221012100510@snapshot_20221012100000",
+			DataStateAt: time.Date(2022, 10, 12, 10, 0, 0, 0, time.UTC),
+		},
+		{
+			ID:          "dblab_pool/clone_pre_20221012120510@snapshot_20221012120000",
+			DataStateAt: time.Date(2022, 10, 12, 12, 0, 0, 0, time.UTC),
+		},
+		{
+			ID:          "dblab_pool/clone_pitr_20221012130000@snapshot_20221012130000",
+			DataStateAt: time.Date(2022, 10, 12, 13, 0, 0, 0, time.UTC),
+		},
+		{
+			ID:          "dblab_pool/dblab_clone_6000@snapshot_20221012133000",
+			DataStateAt: time.Date(2022, 10, 12, 13, 30, 0, 0, time.UTC),
+		},
+		{
+			ID:          "dblab_pool/clone_pre_20221012140510@snapshot_20221012140000",
+			DataStateAt: time.Date(2022, 10, 12, 14, 0, 0, 0, time.UTC),
+		},
+	}
+
+	baseSnapshot, preSnapshotID, err := findBaseSnapshot(snapshots, "dblab_pool", time.Date(2022, 10, 12, 13, 45, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Equal(t, "dblab_pool/clone_pre_20221012120510@snapshot_20221012120000", baseSnapshot.ID)
+	assert.Equal(t, "dblab_pool@snapshot_20221012120510_pre", preSnapshotID)
+
+	// The promoted data of the latest snapshot is older than the target, but its pre-snapshot is more recent.
+	baseSnapshot, _, err = findBaseSnapshot(snapshots, "dblab_pool", time.Date(2022, 10, 12, 14, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Equal(t, "dblab_pool/clone_pre_20221012120510@snapshot_20221012120000", baseSnapshot.ID)
+
+	baseSnapshot, preSnapshotID, err = findBaseSnapshot(snapshots, "dblab_pool", time.Date(2022, 10, 12, 14, 10, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Equal(t, "dblab_pool/clone_pre_20221012140510@snapshot_20221012140000", baseSnapshot.ID)
+	assert.Equal(t, "dblab_pool@snapshot_20221012140510_pre", preSnapshotID)
+
+	_, _, err = findBaseSnapshot(snapshots, "dblab_pool", time.Date(2022, 10, 12, 10, 0, 0, 0, time.UTC))
+	assert.EqualError(t, err, "no snapshots taken before 2022-10-12T10:00:00Z found")
+}
+
+func TestTargetRecoveryConfig(t *testing.T) {
+	recoveryTarget := map[string]string{
+		targetTimeOption:   "2022-10-12 14:03:27+00:00",
+		targetActionOption: promoteTargetAction,
+	}
+
+	_, err := buildTargetRecoveryConfig(map[string]string{"primary_conninfo": "host=source"}, recoveryTarget)
+	assert.EqualError(t, err, "restore_command is not defined in the recovery configuration, WAL archive is not available")
+
+	fileConfig := map[string]string{
+		"restore_command":          "wal-g wal-fetch %f %p",
+		"standby_mode":             "on",
+		"recovery_target_timeline": "latest",
+	}
+
+	recoveryConfig, err := buildTargetRecoveryConfig(fileConfig, recoveryTarget)
+	require.NoError(t, err)
+
+	expectedConfig := map[string]string{
+		"restore_command":          "wal-g wal-fetch %f %p",
+		"recovery_target_timeline": "latest",
+		"recovery_target_time":     "2022-10-12 14:03:27+00:00",
+		"recovery_target_action":   "promote",
+	}
+	assert.Equal(t, expectedConfig, recoveryConfig)
+}
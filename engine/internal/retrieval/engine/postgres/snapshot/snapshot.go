@@ -6,11 +6,14 @@
 package snapshot
 
 import (
+	"time"
+
 	"github.com/pkg/errors"
 
 	"gitlab.com/postgres-ai/database-lab/v3/internal/provision/runners"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/retrieval/dbmarker"
 	"gitlab.com/postgres-ai/database-lab/v3/pkg/log"
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/models"
 )
 
 func extractDataStateAt(dbMarker *dbmarker.Marker) string {
@@ -33,3 +36,11 @@ func runPreprocessingScript(preprocessingScript string) error {
 
 	return nil
 }
+
+// snapshotAddedEvent builds a stream event about a snapshot taken by a retrieval job.
+func snapshotAddedEvent(snapshotID, poolName string) models.StreamEvent {
+	return models.StreamEvent{
+		Type:     models.StreamSnapshotAdded,
+		Snapshot: &models.Snapshot{ID: snapshotID, Pool: poolName, CreatedAt: models.NewLocalTime(time.Now())},
+	}
+}
@@ -0,0 +1,218 @@
+/*
+2022 © Postgres.ai
+*/
+
+package masking
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+
+	"gitlab.com/postgres-ai/database-lab/v3/internal/retrieval/engine/postgres/tools"
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/log"
+)
+
+const (
+	fieldSeparator = "\x1f"
+	seedLength     = 16
+)
+
+// columnsQuery lists columns of user tables. Children of partitioned and inherited tables are marked.
+// Columns of string types and columns covered by unique indexes checked immediately (not deferrable) are marked as well.
+const columnsQuery = `SELECT n.nspname, c.relname, a.attname, format_type(a.atttypid, a.atttypmod),
+  EXISTS (SELECT FROM pg_inherits i WHERE i.inhrelid = c.oid),
+  t.typcategory = 'S',
+  EXISTS (SELECT FROM pg_index x WHERE x.indrelid = c.oid AND x.indisunique AND x.indimmediate AND a.attnum = ANY (x.indkey::int2[]))
+FROM pg_attribute a
+  JOIN pg_class c ON c.oid = a.attrelid
+  JOIN pg_namespace n ON n.oid = c.relnamespace
+  JOIN pg_type t ON t.oid = a.atttypid
+WHERE c.relkind IN ('r', 'p') AND a.attnum > 0 AND NOT a.attisdropped
+  AND n.nspname NOT IN ('pg_catalog', 'information_schema')
+  AND n.nspname NOT LIKE 'pg\_toast%' AND n.nspname NOT LIKE 'pg\_temp%'`
+
+// databasesQuery lists databases to mask. Templates are skipped because clones do not use them.
+const databasesQuery = `SELECT datname FROM pg_database WHERE datallowconn AND NOT datistemplate ORDER BY datname`
+
+// Masker masks sensitive data in all databases of the Postgres instance.
+type Masker struct {
+	docker   *client.Client
+	dbName   string
+	username string
+	cfg      Config
+}
+
+// NewMasker creates a new Masker. The database is used to connect to the instance and list its databases.
+func NewMasker(docker *client.Client, cfg Config, dbName, username string) *Masker {
+	return &Masker{docker: docker, dbName: dbName, username: username, cfg: cfg}
+}
+
+// Apply masks data in every database of the Postgres instance inside the specified container.
+// It fails before changing data if any sensitive column is not covered by masking rules
+// or if a column to mask or a sensitive column does not exist in any database.
+func (m *Masker) Apply(ctx context.Context, containerID string) error {
+	if m == nil {
+		return nil
+	}
+
+	dbNames, err := m.listDatabases(ctx, containerID)
+	if err != nil {
+		return fmt.Errorf("failed to list databases: %w", err)
+	}
+
+	seed := m.cfg.Seed
+	if seed == "" {
+		if seed, err = randomSeed(); err != nil {
+			return err
+		}
+
+		log.Msg("Masking seed is not defined, masked values will differ between snapshots")
+	}
+
+	plans := make(map[string]*plan, len(dbNames))
+
+	for _, dbName := range dbNames {
+		columns, err := m.loadColumns(ctx, containerID, dbName)
+		if err != nil {
+			return fmt.Errorf("failed to list table columns of database %s: %w", dbName, err)
+		}
+
+		if plans[dbName], err = buildPlan(m.cfg, seed, columns); err != nil {
+			return err
+		}
+	}
+
+	if missing := missingColumns(plans); len(missing) > 0 {
+		return fmt.Errorf("columns do not exist in any database: %s", strings.Join(missing, ", "))
+	}
+
+	for _, dbName := range dbNames {
+		report := plans[dbName].report
+
+		log.Msg(fmt.Sprintf("Masking coverage of database %s: %d of %d sensitive columns are masked (%.0f%%)",
+			dbName, len(report.Sensitive)-len(report.Unmasked), len(report.Sensitive), report.Coverage()*100))
+
+		if len(report.Unmasked) > 0 {
+			return fmt.Errorf("sensitive columns of database %s are not masked: %s", dbName, strings.Join(report.Unmasked, ", "))
+		}
+	}
+
+	for _, dbName := range dbNames {
+		if err := m.mask(ctx, containerID, dbName, plans[dbName]); err != nil {
+			return err
+		}
+	}
+
+	log.Msg("Data has been masked")
+
+	return nil
+}
+
+func (m *Masker) mask(ctx context.Context, containerID, dbName string, maskingPlan *plan) error {
+	if len(maskingPlan.report.Masked) == 0 {
+		return nil
+	}
+
+	log.Msg(fmt.Sprintf("Masking columns of database %s: %s", dbName, strings.Join(maskingPlan.report.Masked, ", ")))
+
+	if _, err := m.runSQL(ctx, containerID, dbName, maskingPlan.script); err != nil {
+		return fmt.Errorf("failed to mask data of database %s: %w", dbName, err)
+	}
+
+	// Rewrite tables, so original values do not remain in dead tuples of the snapshot.
+	for _, table := range maskingPlan.tables {
+		if _, err := m.runSQL(ctx, containerID, dbName, "VACUUM FULL "+table); err != nil {
+			return fmt.Errorf("failed to rewrite masked table %s of database %s: %w", table, dbName, err)
+		}
+	}
+
+	return nil
+}
+
+func (m *Masker) listDatabases(ctx context.Context, containerID string) ([]string, error) {
+	output, err := m.runSQL(ctx, containerID, m.dbName, databasesQuery, "-At")
+	if err != nil {
+		return nil, err
+	}
+
+	dbNames := []string{}
+
+	for _, line := range strings.Split(output, "\n") {
+		if dbName := strings.TrimSpace(line); dbName != "" {
+			dbNames = append(dbNames, dbName)
+		}
+	}
+
+	return dbNames, nil
+}
+
+func (m *Masker) loadColumns(ctx context.Context, containerID, dbName string) ([]dbColumn, error) {
+	output, err := m.runSQL(ctx, containerID, dbName, columnsQuery, "-At", "-F", fieldSeparator)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseColumns(output)
+}
+
+func parseColumns(output string) ([]dbColumn, error) {
+	columns := []dbColumn{}
+
+	for _, line := range strings.Split(output, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		fields := strings.Split(line, fieldSeparator)
+
+		const fieldCount = 7
+
+		if len(fields) != fieldCount {
+			return nil, fmt.Errorf("unexpected column description: %q", line)
+		}
+
+		columns = append(columns, dbColumn{
+			columnName: columnName{Schema: fields[0], Table: fields[1], Column: fields[2]},
+			Type:       fields[3],
+			Inherited:  fields[4] == "t",
+			Textual:    fields[5] == "t",
+			Unique:     fields[6] == "t",
+		})
+	}
+
+	return columns, nil
+}
+
+func (m *Masker) runSQL(ctx context.Context, containerID, dbName, sql string, options ...string) (string, error) {
+	psqlCommand := []string{"psql",
+		"-U", m.username,
+		"-d", dbName,
+		"-X", "-v", "ON_ERROR_STOP=1",
+	}
+
+	psqlCommand = append(psqlCommand, options...)
+	psqlCommand = append(psqlCommand, "-c", sql)
+
+	output, err := tools.ExecCommandWithOutput(ctx, m.docker, containerID, types.ExecConfig{Cmd: psqlCommand})
+	if err != nil {
+		log.Dbg("Failed to execute masking SQL:", output)
+	}
+
+	return output, err
+}
+
+func randomSeed() (string, error) {
+	data := make([]byte, seedLength)
+
+	if _, err := rand.Read(data); err != nil {
+		return "", fmt.Errorf("failed to generate a masking seed: %w", err)
+	}
+
+	return hex.EncodeToString(data), nil
+}
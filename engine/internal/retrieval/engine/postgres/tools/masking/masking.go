@@ -0,0 +1,181 @@
+/*
+2022 © Postgres.ai
+*/
+
+// Package masking provides declarative masking of sensitive data before snapshotting.
+package masking
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Strategy defines how values of a column are masked.
+type Strategy string
+
+const (
+	// StrategyHash replaces values with their MD5 hashes.
+	StrategyHash Strategy = "hash"
+
+	// StrategyFakeName replaces values with generated first and last names.
+	StrategyFakeName Strategy = "fake_name"
+
+	// StrategyFakeEmail replaces values with generated emails in the example.com domain.
+	StrategyFakeEmail Strategy = "fake_email"
+
+	// StrategyFakePhone replaces values with generated phone numbers.
+	StrategyFakePhone Strategy = "fake_phone"
+
+	// StrategyNullify replaces values with NULL.
+	StrategyNullify Strategy = "nullify"
+
+	// StrategyShuffle permutes values within the column.
+	StrategyShuffle Strategy = "shuffle"
+
+	// StrategyKeepFormat replaces digits with digits and letters with letters keeping separators and the length.
+	StrategyKeepFormat Strategy = "keep_format"
+)
+
+const defaultSchema = "public"
+
+var strategies = map[Strategy]struct{}{
+	StrategyHash:       {},
+	StrategyFakeName:   {},
+	StrategyFakeEmail:  {},
+	StrategyFakePhone:  {},
+	StrategyNullify:    {},
+	StrategyShuffle:    {},
+	StrategyKeepFormat: {},
+}
+
+// Config defines masking rules.
+type Config struct {
+	// Seed makes generated values deterministic: the same value is masked the same way in all tables and snapshots.
+	// If empty, a random seed is generated for every snapshot.
+	Seed   string  `yaml:"seed"`
+	Tables []Table `yaml:"tables"`
+
+	// SensitiveColumns lists columns ("schema.table.column" or "table.column") that must be masked.
+	SensitiveColumns []string `yaml:"sensitiveColumns"`
+
+	// SensitivePatterns lists regular expressions matched against column names; all matching columns must be masked.
+	SensitivePatterns []string `yaml:"sensitivePatterns"`
+}
+
+// Table defines masking strategies of table columns.
+type Table struct {
+	// Name is "schema.table" or "table" in the public schema.
+	Name    string              `yaml:"name"`
+	Columns map[string]Strategy `yaml:"columns"`
+}
+
+// Enabled checks if masking is configured.
+func (c Config) Enabled() bool {
+	return len(c.Tables) > 0 || len(c.SensitiveColumns) > 0 || len(c.SensitivePatterns) > 0
+}
+
+// Validate checks masking rules.
+func (c Config) Validate() error {
+	for _, table := range c.Tables {
+		if _, _, err := splitTableName(table.Name); err != nil {
+			return err
+		}
+
+		if len(table.Columns) == 0 {
+			return fmt.Errorf("no columns to mask are defined for table %q", table.Name)
+		}
+
+		for column, strategy := range table.Columns {
+			if _, ok := strategies[strategy]; !ok {
+				return fmt.Errorf("unknown masking strategy %q of column %s.%s", strategy, table.Name, column)
+			}
+		}
+	}
+
+	for _, column := range c.SensitiveColumns {
+		if _, err := parseColumnName(column); err != nil {
+			return err
+		}
+	}
+
+	for _, pattern := range c.SensitivePatterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid sensitive column pattern %q: %w", pattern, err)
+		}
+	}
+
+	return nil
+}
+
+// columnName identifies a column of a table.
+type columnName struct {
+	Schema string
+	Table  string
+	Column string
+}
+
+func (c columnName) String() string {
+	return c.Schema + "." + c.Table + "." + c.Column
+}
+
+func (c columnName) tableName() string {
+	return c.Schema + "." + c.Table
+}
+
+// rule binds a column to its masking strategy.
+type rule struct {
+	column   columnName
+	strategy Strategy
+}
+
+// rules returns masking rules ordered by tables and columns.
+func (c Config) rules() []rule {
+	rules := []rule{}
+
+	for _, table := range c.Tables {
+		schema, tableName, err := splitTableName(table.Name)
+		if err != nil {
+			continue
+		}
+
+		for column, strategy := range table.Columns {
+			rules = append(rules, rule{column: columnName{Schema: schema, Table: tableName, Column: column}, strategy: strategy})
+		}
+	}
+
+	sort.Slice(rules, func(i, j int) bool {
+		return rules[i].column.String() < rules[j].column.String()
+	})
+
+	return rules
+}
+
+func splitTableName(name string) (string, string, error) {
+	parts := strings.Split(name, ".")
+
+	switch {
+	case len(parts) == 1 && parts[0] != "":
+		return defaultSchema, parts[0], nil
+
+	case len(parts) == 2 && parts[0] != "" && parts[1] != "":
+		return parts[0], parts[1], nil
+	}
+
+	return "", "", fmt.Errorf("invalid table name %q: use \"schema.table\" or \"table\"", name)
+}
+
+func parseColumnName(name string) (columnName, error) {
+	separator := strings.LastIndex(name, ".")
+	if separator == -1 {
+		return columnName{}, fmt.Errorf("invalid column name %q: use \"schema.table.column\" or \"table.column\"", name)
+	}
+
+	schema, table, err := splitTableName(name[:separator])
+	if err != nil || name[separator+1:] == "" {
+		return columnName{}, fmt.Errorf("invalid column name %q: use \"schema.table.column\" or \"table.column\"", name)
+	}
+
+	return columnName{Schema: schema, Table: table, Column: name[separator+1:]}, nil
+}
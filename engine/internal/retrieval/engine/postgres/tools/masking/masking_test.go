@@ -0,0 +1,218 @@
+package masking
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testColumns() []dbColumn {
+	return []dbColumn{
+		{columnName: columnName{Schema: "public", Table: "users", Column: "id"}, Type: "bigint", Unique: true},
+		{columnName: columnName{Schema: "public", Table: "users", Column: "email"}, Type: "character varying(255)", Textual: true, Unique: true},
+		{columnName: columnName{Schema: "public", Table: "users", Column: "full_name"}, Type: "text", Textual: true},
+		{columnName: columnName{Schema: "public", Table: "users", Column: "phone"}, Type: "text", Textual: true},
+		{columnName: columnName{Schema: "public", Table: "users", Column: "tags"}, Type: "text[]"},
+		{columnName: columnName{Schema: "billing", Table: "cards", Column: "number"}, Type: "text", Textual: true},
+		{columnName: columnName{Schema: "billing", Table: "cards", Column: "holder_email"}, Type: "text", Textual: true},
+		{columnName: columnName{Schema: "billing", Table: "cards_2022", Column: "holder_email"}, Type: "text", Textual: true, Inherited: true},
+	}
+}
+
+func TestValidate(t *testing.T) {
+	testCases := []struct {
+		name  string
+		cfg   Config
+		valid bool
+	}{
+		{name: "empty", cfg: Config{}, valid: true},
+		{
+			name: "valid",
+			cfg: Config{
+				Tables:            []Table{{Name: "users", Columns: map[string]Strategy{"email": StrategyFakeEmail}}},
+				SensitiveColumns:  []string{"public.users.email", "users.phone"},
+				SensitivePatterns: []string{"(^|_)email$"},
+			},
+			valid: true,
+		},
+		{name: "unknown strategy", cfg: Config{Tables: []Table{{Name: "users", Columns: map[string]Strategy{"email": "encrypt"}}}}},
+		{name: "no columns", cfg: Config{Tables: []Table{{Name: "users"}}}},
+		{name: "invalid table", cfg: Config{Tables: []Table{{Name: "a.b.c", Columns: map[string]Strategy{"email": StrategyHash}}}}},
+		{name: "invalid sensitive column", cfg: Config{SensitiveColumns: []string{"email"}}},
+		{name: "invalid pattern", cfg: Config{SensitivePatterns: []string{"(email"}}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.cfg.Validate()
+			if tc.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestBuildPlanCoverage(t *testing.T) {
+	cfg := Config{
+		Tables: []Table{
+			{Name: "users", Columns: map[string]Strategy{"email": StrategyFakeEmail, "full_name": StrategyFakeName}},
+		},
+		SensitiveColumns:  []string{"public.users.full_name", "public.users.ssn"},
+		SensitivePatterns: []string{"email$"},
+	}
+
+	maskingPlan, err := buildPlan(cfg, "seed", testColumns())
+	require.NoError(t, err)
+
+	report := maskingPlan.report
+	assert.Equal(t, []string{"public.users.email", "public.users.full_name"}, report.Masked)
+	assert.Equal(t, []string{"billing.cards.holder_email", "public.users.email", "public.users.full_name"}, report.Sensitive)
+	assert.Equal(t, []string{"billing.cards.holder_email"}, report.Unmasked)
+	assert.Equal(t, []string{"public.users.ssn"}, report.Missing)
+	assert.InDelta(t, 2.0/3, report.Coverage(), 0.001)
+}
+
+func TestBuildPlanMissingColumns(t *testing.T) {
+	cfg := Config{
+		Tables:           []Table{{Name: "users", Columns: map[string]Strategy{"email": StrategyHash, "password": StrategyHash}}},
+		SensitiveColumns: []string{"billing.cards.number"},
+	}
+
+	usersPlan, err := buildPlan(cfg, "seed", []dbColumn{
+		{columnName: columnName{Schema: "public", Table: "users", Column: "email"}, Type: "text", Textual: true},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"public.users.email"}, usersPlan.report.Masked)
+	assert.Equal(t, []string{"public.users.password", "billing.cards.number"}, usersPlan.report.Missing)
+	assert.NotContains(t, usersPlan.script, "password")
+
+	cardsPlan, err := buildPlan(cfg, "seed", []dbColumn{
+		{columnName: columnName{Schema: "billing", Table: "cards", Column: "number"}, Type: "text", Textual: true},
+	})
+	require.NoError(t, err)
+	assert.Empty(t, cardsPlan.report.Masked)
+	assert.Equal(t, []string{"billing.cards.number"}, cardsPlan.report.Unmasked)
+
+	plans := map[string]*plan{"app": usersPlan, "billing": cardsPlan}
+	assert.Equal(t, []string{"public.users.password"}, missingColumns(plans))
+	assert.Nil(t, missingColumns(nil))
+}
+
+func TestBuildPlanScript(t *testing.T) {
+	cfg := Config{
+		Tables: []Table{
+			{Name: "users", Columns: map[string]Strategy{
+				"email":     StrategyFakeEmail,
+				"full_name": StrategyShuffle,
+				"phone":     StrategyNullify,
+			}},
+			{Name: "billing.cards", Columns: map[string]Strategy{"number": StrategyKeepFormat, "holder_email": StrategyHash}},
+		},
+	}
+
+	maskingPlan, err := buildPlan(cfg, "it's a seed", testColumns())
+	require.NoError(t, err)
+
+	script := maskingPlan.script
+
+	assert.True(t, strings.HasPrefix(script, "SET client_min_messages TO warning;\nSET session_replication_role TO replica;\n"))
+	assert.Contains(t, script, "CREATE FUNCTION pg_temp.dblab_mask_keep_format")
+	assert.Contains(t, script, `UPDATE "billing"."cards" SET "holder_email" = CAST(md5('it''s a seed' || ':' || "holder_email"::text) AS text), `+
+		`"number" = CAST(pg_temp.dblab_mask_keep_format("number"::text, 'it''s a seed') AS text);`)
+	assert.Contains(t, script, `UPDATE "public"."users" SET "email" = CAST('user_' || substr(md5('it''s a seed' || ':' || "email"::text), 1, 12)`+
+		` || '@example.com' AS character varying(255)), "phone" = NULL;`)
+	assert.Contains(t, script, `UPDATE "public"."users" SET "full_name" = masked_values.value`)
+
+	// Shuffling runs after other updates.
+	assert.Greater(t, strings.Index(script, "WITH masked_rows AS"), strings.Index(script, `UPDATE "public"."users" SET "email"`))
+
+	assert.Equal(t, []string{`"billing"."cards"`, `"public"."users"`}, maskingPlan.tables)
+}
+
+func TestBuildPlanUnsupportedStrategies(t *testing.T) {
+	testCases := []struct {
+		table    string
+		column   string
+		strategy Strategy
+		err      string
+	}{
+		{
+			table: "users", column: "email", strategy: StrategyShuffle,
+			err: `masking strategy "shuffle" is not supported for column public.users.email: ` +
+				`the column is a part of a primary key or a unique constraint`,
+		},
+		{
+			table: "users", column: "email", strategy: StrategyFakeName,
+			err: `masking strategy "fake_name" is not supported for column public.users.email: ` +
+				`the column is a part of a primary key or a unique constraint`,
+		},
+		{
+			table: "users", column: "tags", strategy: StrategyHash,
+			err: `masking strategy "hash" is not supported for column public.users.tags of type text[]: ` +
+				`only string columns can be masked with generated values`,
+		},
+		{
+			table: "users", column: "id", strategy: StrategyKeepFormat,
+			err: `masking strategy "keep_format" is not supported for column public.users.id of type bigint: ` +
+				`only string columns can be masked with generated values`,
+		},
+		{table: "users", column: "email", strategy: StrategyFakeEmail},
+		{table: "users", column: "id", strategy: StrategyNullify},
+		{table: "users", column: "tags", strategy: StrategyShuffle},
+	}
+
+	for _, tc := range testCases {
+		t.Run(string(tc.strategy)+" "+tc.column, func(t *testing.T) {
+			cfg := Config{Tables: []Table{{Name: tc.table, Columns: map[string]Strategy{tc.column: tc.strategy}}}}
+
+			_, err := buildPlan(cfg, "seed", testColumns())
+			if tc.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.err)
+			}
+		})
+	}
+}
+
+func TestMaskExpressionKeepsSeedDeterminism(t *testing.T) {
+	for strategy := range strategies {
+		if strategy == StrategyShuffle || strategy == StrategyNullify {
+			continue
+		}
+
+		assert.Contains(t, maskExpression(strategy, `"col"::text`, "'seed'"), "'seed'", strategy)
+	}
+
+	assert.Equal(t, "NULL", maskExpression(StrategyNullify, `"col"::text`, "'seed'"))
+}
+
+func TestParseColumns(t *testing.T) {
+	output := "public\x1fusers\x1femail\x1fcharacter varying(255)\x1ff\x1ft\x1ft\nbilling\x1fcards_2022\x1fnumber\x1fbigint\x1ft\x1ff\x1ff\n"
+
+	columns, err := parseColumns(output)
+	require.NoError(t, err)
+	require.Len(t, columns, 2)
+
+	assert.Equal(t, "public.users.email", columns[0].String())
+	assert.Equal(t, "character varying(255)", columns[0].Type)
+	assert.False(t, columns[0].Inherited)
+	assert.True(t, columns[0].Textual)
+	assert.True(t, columns[0].Unique)
+	assert.True(t, columns[1].Inherited)
+	assert.False(t, columns[1].Textual)
+	assert.False(t, columns[1].Unique)
+
+	_, err = parseColumns("public\x1fusers")
+	assert.Error(t, err)
+}
+
+func TestQuote(t *testing.T) {
+	assert.Equal(t, `"Weird""Name"`, quoteIdent(`Weird"Name`))
+	assert.Equal(t, `'it''s'`, quoteLiteral(`it's`))
+	assert.Equal(t, ` E'back\\slash'`, quoteLiteral(`back\slash`))
+}
@@ -0,0 +1,352 @@
+/*
+2022 © Postgres.ai
+*/
+
+package masking
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// keepFormatFunction replaces every digit and Latin letter with a character of the same class derived from the seed.
+const keepFormatFunction = `CREATE FUNCTION pg_temp.dblab_mask_keep_format(value text, seed text) RETURNS text
+LANGUAGE plpgsql IMMUTABLE STRICT AS $$
+DECLARE
+  result text := '';
+  ch text;
+  n int;
+BEGIN
+  FOR i IN 1..length(value) LOOP
+    ch := substr(value, i, 1);
+    n := ('x' || substr(md5(seed || ':' || value || ':' || i), 1, 7))::bit(28)::int;
+
+    IF ch ~ '[0-9]' THEN
+      result := result || chr(48 + n % 10);
+    ELSIF ch ~ '[A-Z]' THEN
+      result := result || chr(65 + n % 26);
+    ELSIF ch ~ '[a-z]' THEN
+      result := result || chr(97 + n % 26);
+    ELSE
+      result := result || ch;
+    END IF;
+  END LOOP;
+
+  RETURN result;
+END
+$$;`
+
+var (
+	firstNames = []string{"James", "Mary", "John", "Patricia", "Robert", "Jennifer", "Michael", "Linda", "William", "Elizabeth",
+		"David", "Barbara", "Richard", "Susan", "Joseph", "Jessica", "Thomas", "Sarah", "Charles", "Karen"}
+	lastNames = []string{"Smith", "Johnson", "Williams", "Brown", "Jones", "Garcia", "Miller", "Davis", "Rodriguez", "Martinez",
+		"Hernandez", "Lopez", "Gonzalez", "Wilson", "Anderson", "Thomas", "Taylor", "Moore", "Jackson", "Martin"}
+)
+
+// dbColumn describes a column found in the database.
+type dbColumn struct {
+	columnName
+	Type string
+
+	// Inherited marks columns of partitions and child tables, which are masked through their parents.
+	Inherited bool
+
+	// Textual marks columns of string types, such as text and character varying.
+	Textual bool
+
+	// Unique marks columns of primary keys and unique constraints or indexes that are not deferrable.
+	Unique bool
+}
+
+// Report describes the coverage of sensitive columns of a database by masking rules.
+// Missing lists columns to mask and sensitive columns that do not exist in the database.
+type Report struct {
+	Masked    []string
+	Sensitive []string
+	Unmasked  []string
+	Missing   []string
+}
+
+// Coverage returns the share of sensitive columns that are masked.
+func (r Report) Coverage() float64 {
+	if len(r.Sensitive) == 0 {
+		return 1
+	}
+
+	return float64(len(r.Sensitive)-len(r.Unmasked)) / float64(len(r.Sensitive))
+}
+
+// plan contains the masking script and the tables to rewrite after masking.
+type plan struct {
+	report Report
+	script string
+	tables []string
+}
+
+// buildPlan checks masking rules against the database columns and generates the masking script.
+// Rules of columns that do not exist in the database are skipped: the database may not contain the table.
+func buildPlan(cfg Config, seed string, columns []dbColumn) (*plan, error) {
+	known := make(map[string]dbColumn, len(columns))
+
+	for _, column := range columns {
+		known[column.String()] = column
+	}
+
+	rules := []rule{}
+	masked := make(map[string]struct{})
+	report := Report{}
+
+	for _, r := range cfg.rules() {
+		column, ok := known[r.column.String()]
+		if !ok {
+			report.Missing = append(report.Missing, r.column.String())
+			continue
+		}
+
+		if err := checkStrategy(column, r.strategy); err != nil {
+			return nil, err
+		}
+
+		rules = append(rules, r)
+		masked[r.column.String()] = struct{}{}
+		report.Masked = append(report.Masked, r.column.String())
+	}
+
+	sensitive, err := sensitiveColumns(cfg, columns)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range sensitive {
+		if _, ok := known[name]; !ok {
+			report.Missing = append(report.Missing, name)
+			continue
+		}
+
+		report.Sensitive = append(report.Sensitive, name)
+
+		if _, ok := masked[name]; !ok {
+			report.Unmasked = append(report.Unmasked, name)
+		}
+	}
+
+	script, tables := buildScript(seed, rules, known)
+
+	return &plan{report: report, script: script, tables: tables}, nil
+}
+
+// checkStrategy checks that the strategy can mask the column.
+// Generated values are cast back to the column type, so only string columns are supported by value-based strategies.
+// Unique values are checked after every row update, so values must not be permuted or replaced by values that often repeat.
+func checkStrategy(column dbColumn, strategy Strategy) error {
+	switch strategy {
+	case StrategyNullify:
+		return nil
+
+	case StrategyShuffle, StrategyFakeName, StrategyFakePhone:
+		if column.Unique {
+			return fmt.Errorf("masking strategy %q is not supported for column %s: "+
+				"the column is a part of a primary key or a unique constraint", strategy, column)
+		}
+
+		if strategy == StrategyShuffle {
+			return nil
+		}
+	}
+
+	if !column.Textual {
+		return fmt.Errorf("masking strategy %q is not supported for column %s of type %s: "+
+			"only string columns can be masked with generated values", strategy, column, column.Type)
+	}
+
+	return nil
+}
+
+// missingColumns returns sorted names of columns to mask and sensitive columns that do not exist in any database.
+func missingColumns(plans map[string]*plan) []string {
+	if len(plans) == 0 {
+		return nil
+	}
+
+	counts := make(map[string]int)
+
+	for _, dbPlan := range plans {
+		for _, name := range dbPlan.report.Missing {
+			counts[name]++
+		}
+	}
+
+	missing := []string{}
+
+	for name, count := range counts {
+		if count == len(plans) {
+			missing = append(missing, name)
+		}
+	}
+
+	sort.Strings(missing)
+
+	return missing
+}
+
+// sensitiveColumns returns sorted names of columns listed as sensitive or matching sensitive patterns.
+func sensitiveColumns(cfg Config, columns []dbColumn) ([]string, error) {
+	names := make(map[string]struct{})
+
+	for _, column := range cfg.SensitiveColumns {
+		name, err := parseColumnName(column)
+		if err != nil {
+			return nil, err
+		}
+
+		names[name.String()] = struct{}{}
+	}
+
+	for _, pattern := range cfg.SensitivePatterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sensitive column pattern %q: %w", pattern, err)
+		}
+
+		for _, column := range columns {
+			if !column.Inherited && re.MatchString(column.Column) {
+				names[column.String()] = struct{}{}
+			}
+		}
+	}
+
+	sorted := make([]string, 0, len(names))
+
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+
+	sort.Strings(sorted)
+
+	return sorted, nil
+}
+
+// buildScript generates a single UPDATE per table for value-based strategies and a statement per shuffled column.
+// Triggers and foreign key checks are disabled: deterministic strategies produce the same values in referencing columns.
+func buildScript(seed string, rules []rule, known map[string]dbColumn) (string, []string) {
+	statements := []string{
+		"SET client_min_messages TO warning;",
+		"SET session_replication_role TO replica;",
+	}
+
+	for _, r := range rules {
+		if r.strategy == StrategyKeepFormat {
+			statements = append(statements, keepFormatFunction)
+			break
+		}
+	}
+
+	tables := []string{}
+	assignments := make(map[string][]string)
+	shuffles := []string{}
+
+	for _, r := range rules {
+		table := quoteIdent(r.column.Schema) + "." + quoteIdent(r.column.Table)
+
+		if _, ok := assignments[table]; !ok {
+			tables = append(tables, table)
+			assignments[table] = []string{}
+		}
+
+		if r.strategy == StrategyShuffle {
+			shuffles = append(shuffles, shuffleStatement(table, quoteIdent(r.column.Column), seed))
+			continue
+		}
+
+		column := quoteIdent(r.column.Column)
+		value := maskExpression(r.strategy, column+"::text", quoteLiteral(seed))
+
+		if r.strategy != StrategyNullify {
+			value = fmt.Sprintf("CAST(%s AS %s)", value, known[r.column.String()].Type)
+		}
+
+		assignments[table] = append(assignments[table], fmt.Sprintf("%s = %s", column, value))
+	}
+
+	for _, table := range tables {
+		if len(assignments[table]) > 0 {
+			statements = append(statements, fmt.Sprintf("UPDATE %s SET %s;", table, strings.Join(assignments[table], ", ")))
+		}
+	}
+
+	statements = append(statements, shuffles...)
+
+	return strings.Join(statements, "\n"), tables
+}
+
+// maskExpression returns the SQL expression of the masked value. All expressions keep NULL values.
+func maskExpression(strategy Strategy, value, seed string) string {
+	digest := fmt.Sprintf("md5(%s || ':' || %s)", seed, value)
+
+	switch strategy {
+	case StrategyHash:
+		return digest
+
+	case StrategyFakeName:
+		return fmt.Sprintf("(%s)[1 + %s %% %d] || ' ' || (%s)[1 + %s %% %d]",
+			arrayLiteral(firstNames), hashNumber(digest, 1), len(firstNames),
+			arrayLiteral(lastNames), hashNumber(digest, 8), len(lastNames))
+
+	case StrategyFakeEmail:
+		return fmt.Sprintf("'user_' || substr(%s, 1, 12) || '@example.com'", digest)
+
+	case StrategyFakePhone:
+		return fmt.Sprintf("'+1555' || lpad((%s %% 10000000)::text, 7, '0')", hashNumber(digest, 1))
+
+	case StrategyKeepFormat:
+		return fmt.Sprintf("pg_temp.dblab_mask_keep_format(%s, %s)", value, seed)
+	}
+
+	return "NULL"
+}
+
+// hashNumber converts 7 hex digits of the digest starting from the position into a non-negative integer.
+func hashNumber(digest string, position int) string {
+	return fmt.Sprintf("('x' || substr(%s, %d, 7))::bit(28)::int", digest, position)
+}
+
+// shuffleStatement permutes column values between rows in the order derived from the seed.
+// Rows are identified by tableoid and ctid, so partitioned tables are supported.
+func shuffleStatement(table, column, seed string) string {
+	seed = quoteLiteral(seed)
+
+	return fmt.Sprintf(`WITH masked_rows AS (
+  SELECT tableoid AS row_table, ctid AS row_id,
+    row_number() OVER (ORDER BY md5(%[3]s || ':row:' || tableoid::text || ctid::text)) AS row_order
+  FROM %[1]s
+), masked_values AS (
+  SELECT %[2]s AS value,
+    row_number() OVER (ORDER BY md5(%[3]s || ':value:' || tableoid::text || ctid::text)) AS row_order
+  FROM %[1]s
+)
+UPDATE %[1]s SET %[2]s = masked_values.value
+FROM masked_rows JOIN masked_values USING (row_order)
+WHERE %[1]s.tableoid = masked_rows.row_table AND %[1]s.ctid = masked_rows.row_id;`, table, column, seed)
+}
+
+func arrayLiteral(values []string) string {
+	quoted := make([]string, 0, len(values))
+
+	for _, value := range values {
+		quoted = append(quoted, quoteLiteral(value))
+	}
+
+	return "ARRAY[" + strings.Join(quoted, ", ") + "]"
+}
+
+func quoteIdent(name string) string {
+	return pq.QuoteIdentifier(name)
+}
+
+func quoteLiteral(value string) string {
+	return pq.QuoteLiteral(value)
+}
@@ -11,6 +11,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/docker/docker/api/types"
@@ -19,9 +20,11 @@ import (
 	"github.com/pkg/errors"
 	"github.com/robfig/cron/v3"
 
+	"gitlab.com/postgres-ai/database-lab/v3/internal/events"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/provision/pool"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/provision/resources"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/provision/runners"
+	"gitlab.com/postgres-ai/database-lab/v3/internal/provision/thinclones"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/retrieval/components"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/retrieval/config"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/retrieval/dbmarker"
@@ -35,6 +38,7 @@ import (
 	"gitlab.com/postgres-ai/database-lab/v3/internal/retrieval/options"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/retrieval/status"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/telemetry"
+	"gitlab.com/postgres-ai/database-lab/v3/internal/webhooks"
 	"gitlab.com/postgres-ai/database-lab/v3/pkg/util"
 
 	dblabCfg "gitlab.com/postgres-ai/database-lab/v3/pkg/config"
@@ -53,6 +57,26 @@ const (
 
 type jobGroup string
 
+// snapshotTaker describes a stateful job that takes snapshots on demand.
+type snapshotTaker interface {
+	TakeSnapshot(ctx context.Context) error
+}
+
+// snapshotReadyStatuses are the retrieval statuses that allow taking a snapshot.
+var snapshotReadyStatuses = []models.RetrievalStatus{models.Inactive, models.Renewed, models.Finished}
+
+// refreshReadyStatuses are the retrieval statuses that allow refreshing the data.
+var refreshReadyStatuses = []models.RetrievalStatus{models.Inactive, models.Failed, models.Renewed, models.Finished}
+
+// statefulSnapshotReadyStatuses also allow the running snapshot job to retry after a failed snapshot.
+var statefulSnapshotReadyStatuses = append([]models.RetrievalStatus{models.Failed}, snapshotReadyStatuses...)
+
+// pitrSnapshotTaker describes a stateful job that takes snapshots recovered to a point in time.
+type pitrSnapshotTaker interface {
+	CheckPITRTarget(targetTime time.Time) error
+	TakePITRSnapshot(ctx context.Context, targetTime time.Time) (snapshotID, baseSnapshotID string, err error)
+}
+
 // Retrieval describes a data retrieval.
 type Retrieval struct {
 	Scheduler    Scheduler
@@ -64,7 +88,11 @@ type Retrieval struct {
 	docker       *client.Client
 	poolManager  *pool.Manager
 	tm           *telemetry.Agent
+	wh           *webhooks.Service
+	broker       *events.Broker
 	runner       runners.Runner
+	ctxMu        sync.Mutex
+	runCtx       context.Context
 	ctxCancel    context.CancelFunc
 	statefulJobs []components.JobRunner
 }
@@ -77,13 +105,15 @@ type Scheduler struct {
 
 // New creates a new data retrieval.
 func New(cfg *dblabCfg.Config, engineProps global.EngineProps, docker *client.Client, pm *pool.Manager, tm *telemetry.Agent,
-	runner runners.Runner) (*Retrieval, error) {
+	wh *webhooks.Service, broker *events.Broker, runner runners.Runner) (*Retrieval, error) {
 	r := &Retrieval{
 		global:      &cfg.Global,
 		engineProps: engineProps,
 		docker:      docker,
 		poolManager: pm,
 		tm:          tm,
+		wh:          wh,
+		broker:      broker,
 		runner:      runner,
 		State: State{
 			Status: models.Inactive,
@@ -125,7 +155,7 @@ func checkPendingMarker(r *Retrieval) error {
 		return fmt.Errorf("failed to get pending file info: %w", err)
 	}
 
-	r.State.Status = models.Pending
+	r.setStatus(models.Pending)
 
 	return nil
 }
@@ -145,7 +175,7 @@ func (r *Retrieval) RemovePendingMarker() error {
 		return err
 	}
 
-	r.State.Status = models.Inactive
+	r.setStatus(models.Inactive)
 
 	return nil
 }
@@ -182,7 +212,7 @@ func (r *Retrieval) reloadStatefulJobs() {
 // Run start retrieving process.
 func (r *Retrieval) Run(ctx context.Context) error {
 	runCtx, cancel := context.WithCancel(ctx)
-	r.ctxCancel = cancel
+	r.setRunContext(runCtx, cancel)
 
 	log.Msg("Retrieval mode:", r.State.Mode)
 
@@ -194,7 +224,7 @@ func (r *Retrieval) Run(ctx context.Context) error {
 	if err != nil {
 		var skipError *SkipRefreshingError
 		if errors.As(err, &skipError) {
-			r.State.Status = models.Finished
+			r.setStatus(models.Finished)
 
 			log.Msg("Continue without performing a full refresh:", skipError.Error())
 			r.setupScheduler(ctx)
@@ -206,26 +236,37 @@ func (r *Retrieval) Run(ctx context.Context) error {
 			Level:   models.RefreshFailed,
 			Message: "Pool to perform data refresh not found",
 		}
-		r.State.Status = models.Failed
-		r.State.addAlert(alert)
+		r.setStatus(models.Failed)
+		r.addAlert(alert)
 		r.tm.SendEvent(ctx, telemetry.AlertEvent, alert)
+		r.wh.Emit(webhooks.RetrievalFailedEvent, webhooks.RetrievalEvent{Mode: r.State.Mode, Message: alert.Message})
 
 		return fmt.Errorf("failed to choose pool to refresh: %w", err)
 	}
 
 	log.Msg("Pool to perform data retrieving: ", fsManager.Pool().Name)
 
-	if r.State.Status == models.Pending {
-		log.Msg("Data retrieving suspended because Retrieval state is pending")
+	previousStatus, err := r.startRefreshing()
+	if err != nil {
+		if previousStatus == models.Pending {
+			log.Msg("Data retrieving suspended because Retrieval state is pending")
 
-		return nil
+			return nil
+		}
+
+		return err
 	}
 
-	if err := r.run(runCtx, fsManager); err != nil {
+	if err := r.run(runCtx, fsManager, previousStatus); err != nil {
 		alert := telemetry.Alert{Level: models.RefreshFailed,
 			Message: fmt.Sprintf("Failed to perform initial data retrieving: %s", r.State.Mode)}
-		r.State.addAlert(alert)
+		r.addAlert(alert)
 		r.tm.SendEvent(ctx, telemetry.AlertEvent, alert)
+		r.wh.Emit(webhooks.RetrievalFailedEvent, webhooks.RetrievalEvent{
+			Mode:    r.State.Mode,
+			Pool:    fsManager.Pool().Name,
+			Message: err.Error(),
+		})
 
 		return err
 	}
@@ -316,9 +357,11 @@ func (r *Retrieval) getNextPoolToDataRetrieving() (pool.FSManager, error) {
 	return poolToRefresh, nil
 }
 
-func (r *Retrieval) run(ctx context.Context, fsm pool.FSManager) (err error) {
+// run refreshes the data and takes a snapshot. The caller must switch the status to Refreshing by startRefreshing.
+func (r *Retrieval) run(ctx context.Context, fsm pool.FSManager, previousStatus models.RetrievalStatus) (err error) {
 	// Check the pool aliveness.
 	if _, err := fsm.GetFilesystemState(); err != nil {
+		r.setStatus(previousStatus)
 		return errors.Wrap(errors.Unwrap(err), "filesystem manager is not ready")
 	}
 
@@ -326,15 +369,16 @@ func (r *Retrieval) run(ctx context.Context, fsm pool.FSManager) (err error) {
 	poolElement := r.poolManager.GetPoolByName(poolName)
 
 	if poolElement == nil {
+		r.setStatus(previousStatus)
 		return errors.Errorf("pool %s not found", poolName)
 	}
 
-	if err := r.RefreshData(ctx, poolName); err != nil {
+	if err := r.refreshData(ctx, fsm, previousStatus); err != nil {
 		return err
 	}
 
 	if r.State.Status == models.Renewed {
-		r.State.cleanAlerts()
+		r.cleanAlerts()
 	}
 
 	if err := r.SnapshotData(ctx, poolName); err != nil {
@@ -343,7 +387,10 @@ func (r *Retrieval) run(ctx context.Context, fsm pool.FSManager) (err error) {
 
 	if r.State.Status == models.Finished {
 		r.poolManager.MakeActive(poolElement)
-		r.State.cleanAlerts()
+		r.cleanAlerts()
+
+		r.wh.Emit(webhooks.RetrievalFinishedEvent, webhooks.RetrievalEvent{Mode: r.State.Mode, Pool: poolName})
+		r.wh.Emit(webhooks.PoolSwitchedEvent, webhooks.PoolEvent{Pool: poolName})
 	}
 
 	return nil
@@ -356,17 +403,37 @@ func (r *Retrieval) RefreshData(ctx context.Context, poolName string) error {
 		return fmt.Errorf("failed to get %q FSManager: %w", poolName, err)
 	}
 
-	if r.State.Status == models.Refreshing || r.State.Status == models.Snapshotting {
-		return fmt.Errorf("skip refreshing the data because the pool is still busy: %s", r.State.Status)
+	previousStatus, err := r.startRefreshing()
+	if err != nil {
+		return err
+	}
+
+	return r.refreshData(ctx, fsm, previousStatus)
+}
+
+// startRefreshing switches the status to Refreshing if the pool is not busy.
+func (r *Retrieval) startRefreshing() (models.RetrievalStatus, error) {
+	previousStatus, ok := r.State.compareAndSetStatus(models.Refreshing, refreshReadyStatuses...)
+	if !ok {
+		return previousStatus, fmt.Errorf("skip refreshing the data because the pool is still busy: %s", previousStatus)
 	}
 
+	r.publishState()
+
+	return previousStatus, nil
+}
+
+func (r *Retrieval) refreshData(ctx context.Context, fsm pool.FSManager, previousStatus models.RetrievalStatus) (err error) {
 	jobs, err := r.buildJobs(fsm, refreshJobs)
 	if err != nil {
-		return fmt.Errorf("failed to build refresh jobs for %s: %w", poolName, err)
+		r.setStatus(previousStatus)
+		return fmt.Errorf("failed to build refresh jobs for %s: %w", fsm.Pool().Name, err)
 	}
 
 	if len(jobs) == 0 {
+		r.setStatus(previousStatus)
 		log.Dbg("no jobs to refresh pool:", fsm.Pool())
+
 		return nil
 	}
 
@@ -374,30 +441,34 @@ func (r *Retrieval) RefreshData(ctx context.Context, poolName string) error {
 
 	fsm.Pool().SetStatus(resources.RefreshingPool)
 
-	r.State.Status = models.Refreshing
-	r.State.LastRefresh = models.NewLocalTime(time.Now().Truncate(time.Second))
+	refreshStartedAt := time.Now()
+
+	r.setStatus(models.Refreshing)
+	r.State.LastRefresh = models.NewLocalTime(refreshStartedAt.Truncate(time.Second))
 
 	defer func() {
-		r.State.Status = models.Renewed
+		r.State.LastRefreshDuration = time.Since(refreshStartedAt)
+		status := models.Renewed
 
 		if err != nil {
-			r.State.Status = models.Failed
+			status = models.Failed
 
 			fsm.Pool().SetStatus(resources.EmptyPool)
 		}
 
-		r.State.CurrentJob = nil
+		r.State.setCurrentJob(nil)
+		r.setStatus(status)
 	}()
 
 	for _, j := range jobs {
-		r.State.CurrentJob = j
+		r.State.setCurrentJob(j)
 
 		if err = j.Run(ctx); err != nil {
 			return err
 		}
 	}
 
-	r.State.CurrentJob = nil
+	r.State.setCurrentJob(nil)
 
 	return nil
 }
@@ -409,13 +480,19 @@ func (r *Retrieval) SnapshotData(ctx context.Context, poolName string) error {
 		return fmt.Errorf("failed to get %q FSManager: %w", poolName, err)
 	}
 
-	if r.State.Status != models.Inactive && r.State.Status != models.Renewed && r.State.Status != models.Finished {
-		return fmt.Errorf("pool is not ready to take a snapshot: %s", r.State.Status)
+	previousStatus, err := r.startSnapshotting(snapshotReadyStatuses...)
+	if err != nil {
+		return err
 	}
 
+	return r.snapshotData(ctx, fsm, previousStatus)
+}
+
+func (r *Retrieval) snapshotData(ctx context.Context, fsm pool.FSManager, previousStatus models.RetrievalStatus) (err error) {
 	jobs, err := r.buildJobs(fsm, snapshotJobs)
 	if err != nil {
-		return fmt.Errorf("failed to build snapshot jobs for %s: %w", poolName, err)
+		r.setStatus(previousStatus)
+		return fmt.Errorf("failed to build snapshot jobs for %s: %w", fsm.Pool().Name, err)
 	}
 
 	if r.State.Mode == models.Physical {
@@ -424,27 +501,23 @@ func (r *Retrieval) SnapshotData(ctx context.Context, poolName string) error {
 
 	if len(jobs) == 0 {
 		log.Dbg("no jobs to snapshot pool data:", fsm.Pool())
+		r.setStatus(previousStatus)
+
 		return nil
 	}
 
 	log.Dbg("Taking a snapshot on the pool: ", fsm.Pool())
 
-	r.State.Status = models.Snapshotting
-
 	defer func() {
-		r.State.Status = models.Finished
-
 		if err != nil {
-			r.State.Status = models.Failed
-
 			fsm.Pool().SetStatus(resources.EmptyPool)
 		}
 
-		r.State.CurrentJob = nil
+		r.finishSnapshotting(err)
 	}()
 
 	for _, j := range jobs {
-		r.State.CurrentJob = j
+		r.State.setCurrentJob(j)
 
 		if err = j.Run(ctx); err != nil {
 			return err
@@ -454,9 +527,127 @@ func (r *Retrieval) SnapshotData(ctx context.Context, poolName string) error {
 	return nil
 }
 
+// TakeSnapshot starts taking a snapshot of the pool data on demand. The snapshot is taken in the background,
+// the result is reported by the retrieval status.
+// In the physical mode, the snapshot is taken by the running snapshot job, so its schedule stays untouched.
+func (r *Retrieval) TakeSnapshot(poolName string) error {
+	ctx := r.runContext()
+
+	for _, job := range r.statefulJobs {
+		taker, ok := job.(snapshotTaker)
+		if !ok {
+			continue
+		}
+
+		if _, err := r.startSnapshotting(statefulSnapshotReadyStatuses...); err != nil {
+			return err
+		}
+
+		log.Dbg("Taking a snapshot on demand: ", poolName)
+
+		r.State.setCurrentJob(job)
+
+		go func() {
+			err := taker.TakeSnapshot(ctx)
+			if err != nil {
+				log.Err("Failed to take a snapshot on demand: ", err)
+			}
+
+			r.finishSnapshotting(err)
+		}()
+
+		return nil
+	}
+
+	fsm, err := r.poolManager.GetFSManager(poolName)
+	if err != nil {
+		return models.New(models.ErrCodeBadRequest, fmt.Sprintf("pool %q not found", poolName))
+	}
+
+	previousStatus, err := r.startSnapshotting(snapshotReadyStatuses...)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		if err := r.snapshotData(ctx, fsm, previousStatus); err != nil {
+			log.Err("Failed to take a snapshot on demand: ", err)
+		}
+	}()
+
+	return nil
+}
+
+// startSnapshotting switches the status to Snapshotting if the current status is one of the ready statuses.
+func (r *Retrieval) startSnapshotting(readyStatuses ...models.RetrievalStatus) (models.RetrievalStatus, error) {
+	previousStatus, ok := r.State.compareAndSetStatus(models.Snapshotting, readyStatuses...)
+	if !ok {
+		return previousStatus, models.New(models.ErrCodeBadRequest, fmt.Sprintf("pool is not ready to take a snapshot: %s", previousStatus))
+	}
+
+	r.publishState()
+
+	return previousStatus, nil
+}
+
+// finishSnapshotting reports the result of snapshotting.
+func (r *Retrieval) finishSnapshotting(err error) {
+	status := models.Finished
+
+	if err != nil {
+		status = models.Failed
+	}
+
+	r.State.setCurrentJob(nil)
+	r.setStatus(status)
+}
+
+// TakePITRSnapshot starts taking a snapshot of the data recovered to the target time from the WAL archive.
+// The snapshot is taken in the background, onSnapshot receives the IDs of the new snapshot
+// and of the snapshot the recovery has been started from.
+func (r *Retrieval) TakePITRSnapshot(targetTime time.Time, onSnapshot func(snapshotID, baseSnapshotID string)) error {
+	ctx := r.runContext()
+
+	for _, job := range r.statefulJobs {
+		taker, ok := job.(pitrSnapshotTaker)
+		if !ok {
+			continue
+		}
+
+		if err := taker.CheckPITRTarget(targetTime); err != nil {
+			return models.New(models.ErrCodeBadRequest, err.Error())
+		}
+
+		if _, err := r.startSnapshotting(statefulSnapshotReadyStatuses...); err != nil {
+			return err
+		}
+
+		log.Dbg("Taking a point-in-time snapshot: ", targetTime)
+
+		r.State.setCurrentJob(job)
+
+		go func() {
+			snapshotID, baseSnapshotID, err := taker.TakePITRSnapshot(ctx, targetTime)
+			if err != nil {
+				log.Err("Failed to take a point-in-time snapshot: ", err)
+			}
+
+			r.finishSnapshotting(err)
+
+			if err == nil {
+				onSnapshot(snapshotID, baseSnapshotID)
+			}
+		}()
+
+		return nil
+	}
+
+	return models.New(models.ErrCodeBadRequest, "point-in-time snapshots are available only in the physical mode")
+}
+
 // buildJobs processes the configuration spec to build data retrieval jobs.
 func (r *Retrieval) buildJobs(fsm pool.FSManager, groupName jobGroup) ([]components.JobRunner, error) {
-	retrievalRunner, err := engine.JobBuilder(r.global, r.engineProps, fsm, r.tm)
+	retrievalRunner, err := engine.JobBuilder(r.global, r.engineProps, fsm, r.tm, r.wh, r.broker)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to get a job builder")
 	}
@@ -544,8 +735,9 @@ func (r *Retrieval) refreshFunc(ctx context.Context) func() {
 	return func() {
 		if err := r.FullRefresh(ctx); err != nil {
 			alert := telemetry.Alert{Level: models.RefreshFailed, Message: "Failed to run full-refresh"}
-			r.State.addAlert(alert)
+			r.addAlert(alert)
 			r.tm.SendEvent(ctx, telemetry.AlertEvent, alert)
+			r.wh.Emit(webhooks.RetrievalFailedEvent, webhooks.RetrievalEvent{Mode: r.State.Mode, Message: err.Error()})
 			log.Err(alert.Message, err)
 		}
 	}
@@ -553,23 +745,33 @@ func (r *Retrieval) refreshFunc(ctx context.Context) func() {
 
 // FullRefresh performs full refresh for an unused storage pool and makes it active.
 func (r *Retrieval) FullRefresh(ctx context.Context) error {
-	if r.State.Status == models.Refreshing || r.State.Status == models.Snapshotting {
+	previousStatus, err := r.startRefreshing()
+	if err != nil {
+		if previousStatus == models.Pending {
+			log.Msg("Data retrieving suspended because Retrieval state is pending")
+
+			return nil
+		}
+
 		alert := telemetry.Alert{
 			Level:   models.RefreshSkipped,
 			Message: "The data refresh/snapshot is currently in progress. Skip a new data refresh iteration",
 		}
-		r.State.addAlert(alert)
+		r.addAlert(alert)
 		r.tm.SendEvent(ctx, telemetry.AlertEvent, alert)
 		log.Msg(alert.Message)
 
 		return nil
 	}
 
-	if r.State.Status == models.Pending {
-		log.Msg("Data retrieving suspended because Retrieval state is pending")
+	started := false
 
-		return nil
-	}
+	defer func() {
+		// Release the status if the refresh has not been started.
+		if !started {
+			r.setStatus(previousStatus)
+		}
+	}()
 
 	// Stop previous runs and snapshot schedulers.
 	if r.ctxCancel != nil {
@@ -577,7 +779,7 @@ func (r *Retrieval) FullRefresh(ctx context.Context) error {
 	}
 
 	runCtx, cancel := context.WithCancel(ctx)
-	r.ctxCancel = cancel
+	r.setRunContext(runCtx, cancel)
 	elementToUpdate := r.poolManager.GetPoolToUpdate()
 
 	if elementToUpdate == nil || elementToUpdate.Value == nil {
@@ -585,7 +787,7 @@ func (r *Retrieval) FullRefresh(ctx context.Context) error {
 			Level:   models.RefreshSkipped,
 			Message: "Pool to perform full refresh not found. Skip refreshing",
 		}
-		r.State.addAlert(alert)
+		r.addAlert(alert)
 		r.tm.SendEvent(ctx, telemetry.AlertEvent, alert)
 		log.Msg(alert.Message + ". Hint: Check that there is at least one pool that does not have clones running. " +
 			"Refresh can be performed only to a pool without clones.")
@@ -611,16 +813,90 @@ func (r *Retrieval) FullRefresh(ctx context.Context) error {
 		return cleanUpErr
 	}
 
-	if err := r.run(runCtx, poolToUpdate); err != nil {
+	started = true
+
+	if err := r.run(runCtx, poolToUpdate, previousStatus); err != nil {
 		return err
 	}
 
 	r.poolManager.MakeActive(elementToUpdate)
-	r.State.cleanAlerts()
+	r.cleanAlerts()
 
 	return nil
 }
 
+// setStatus changes the retrieval status and notifies the event stream subscribers.
+func (r *Retrieval) setStatus(status models.RetrievalStatus) {
+	r.State.mu.Lock()
+
+	if r.State.Status == status {
+		r.State.mu.Unlock()
+		return
+	}
+
+	r.State.Status = status
+	r.State.mu.Unlock()
+
+	r.publishState()
+}
+
+// setRunContext stores the context of the current retrieval run. On-demand jobs are stopped with the run.
+func (r *Retrieval) setRunContext(ctx context.Context, cancel context.CancelFunc) {
+	r.ctxMu.Lock()
+	r.runCtx, r.ctxCancel = ctx, cancel
+	r.ctxMu.Unlock()
+}
+
+// runContext returns the context of the current retrieval run.
+func (r *Retrieval) runContext() context.Context {
+	r.ctxMu.Lock()
+	defer r.ctxMu.Unlock()
+
+	if r.runCtx == nil {
+		return context.Background()
+	}
+
+	return r.runCtx
+}
+
+// addAlert registers the alert and notifies the event stream subscribers.
+func (r *Retrieval) addAlert(alert telemetry.Alert) {
+	r.State.addAlert(alert)
+	r.publishState()
+}
+
+// cleanAlerts removes all alerts and notifies the event stream subscribers.
+func (r *Retrieval) cleanAlerts() {
+	if len(r.State.Alerts()) == 0 {
+		return
+	}
+
+	r.State.cleanAlerts()
+	r.publishState()
+}
+
+// publishState sends the current retrieval state to the event stream.
+func (r *Retrieval) publishState() {
+	alerts := make(map[models.AlertType]models.Alert)
+
+	for alertType, alert := range r.State.Alerts() {
+		alerts[alertType] = alert
+	}
+
+	retrieving := &models.Retrieving{
+		Mode:        r.State.Mode,
+		Status:      r.State.Status,
+		LastRefresh: r.State.LastRefresh,
+		Alerts:      alerts,
+	}
+
+	if spec := r.Scheduler.Spec; spec != nil {
+		retrieving.NextRefresh = models.NewLocalTime(spec.Next(time.Now()))
+	}
+
+	r.broker.Publish(models.StreamEvent{Type: models.StreamRetrievalStatus, Retrieval: retrieving})
+}
+
 // Stop stops a retrieval service.
 func (r *Retrieval) Stop() {
 	r.stopScheduler()
@@ -653,7 +929,7 @@ func preparePoolToRefresh(poolToUpdate pool.FSManager) error {
 	}
 
 	for _, snapshotEntry := range snapshots {
-		if err := poolToUpdate.DestroySnapshot(snapshotEntry.ID); err != nil {
+		if err := poolToUpdate.DestroySnapshot(snapshotEntry.ID, thinclones.DestroyOptions{Force: true}); err != nil {
 			return errors.Wrap(err, "failed to destroy the existing snapshot")
 		}
 	}
@@ -130,3 +130,21 @@ func TestSyncStatusNotReportedForLogicalMode(t *testing.T) {
 	assert.NotNil(t, status)
 	assert.Equal(t, models.SyncStatusNotAvailable, status.Status.Code)
 }
+
+func TestStartRefreshing(t *testing.T) {
+	r := &Retrieval{State: State{Status: models.Snapshotting}}
+
+	_, err := r.startRefreshing()
+	assert.EqualError(t, err, "skip refreshing the data because the pool is still busy: snapshotting")
+	assert.Equal(t, models.Snapshotting, r.State.Status)
+
+	r.State.Status = models.Finished
+
+	previousStatus, err := r.startRefreshing()
+	require.NoError(t, err)
+	assert.Equal(t, models.Finished, previousStatus)
+	assert.Equal(t, models.Refreshing, r.State.Status)
+
+	_, err = r.startRefreshing()
+	assert.Error(t, err)
+}
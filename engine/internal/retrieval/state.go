@@ -18,9 +18,11 @@ type State struct {
 	Mode        models.RetrievalMode
 	Status      models.RetrievalStatus
 	LastRefresh *models.LocalTime
-	CurrentJob  components.JobRunner
-	mu          sync.Mutex
-	alerts      map[models.AlertType]models.Alert
+	// LastRefreshDuration is the duration of the last data refresh jobs run.
+	LastRefreshDuration time.Duration
+	CurrentJob          components.JobRunner
+	mu                  sync.Mutex
+	alerts              map[models.AlertType]models.Alert
 }
 
 // Alerts returns all registered retrieval alerts.
@@ -62,3 +64,36 @@ func (s *State) cleanAlerts() {
 	s.alerts = make(map[models.AlertType]models.Alert)
 	s.mu.Unlock()
 }
+
+// compareAndSetStatus sets the status if the current status is one of the expected ones.
+// It returns the previous status and whether the status has been changed.
+func (s *State) compareAndSetStatus(status models.RetrievalStatus, expected ...models.RetrievalStatus) (models.RetrievalStatus, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous := s.Status
+
+	for _, expectedStatus := range expected {
+		if previous == expectedStatus {
+			s.Status = status
+			return previous, true
+		}
+	}
+
+	return previous, false
+}
+
+// setCurrentJob sets the job that is running at the moment.
+func (s *State) setCurrentJob(job components.JobRunner) {
+	s.mu.Lock()
+	s.CurrentJob = job
+	s.mu.Unlock()
+}
+
+// ActiveJob returns the job that is running at the moment.
+func (s *State) ActiveJob() components.JobRunner {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.CurrentJob
+}
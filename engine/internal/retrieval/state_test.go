@@ -44,3 +44,17 @@ func TestState(t *testing.T) {
 
 	assert.Equal(t, 0, len(state.alerts))
 }
+
+func TestStateCompareAndSetStatus(t *testing.T) {
+	state := State{Status: models.Finished}
+
+	previous, ok := state.compareAndSetStatus(models.Snapshotting, models.Inactive, models.Finished)
+	assert.True(t, ok)
+	assert.Equal(t, models.Finished, previous)
+	assert.Equal(t, models.Snapshotting, state.Status)
+
+	previous, ok = state.compareAndSetStatus(models.Snapshotting, models.Inactive, models.Finished)
+	assert.False(t, ok)
+	assert.Equal(t, models.Snapshotting, previous)
+	assert.Equal(t, models.Snapshotting, state.Status)
+}
@@ -54,7 +54,7 @@ func NewServer(cfg *Config, dle *dblabapi.Client, platform *platform.Service, co
 func (s *Server) Run() error {
 	r := mux.NewRouter().StrictSlash(true)
 
-	authMW := mw.NewAuth(s.config.App.VerificationToken, s.platform)
+	authMW := mw.NewAuth(s.config.App.VerificationToken, nil, s.platform, nil)
 
 	r.HandleFunc("/migration/run", authMW.Authorized(s.runMigration)).Methods(http.MethodPost)
 	r.HandleFunc("/artifact/download", authMW.Authorized(s.downloadArtifact)).Methods(http.MethodGet)
@@ -51,6 +51,16 @@ func SendUnauthorizedError(w http.ResponseWriter, r *http.Request) {
 	SendError(w, r, errorUnauthorized)
 }
 
+// SendForbiddenError sends a forbidden request error.
+func SendForbiddenError(w http.ResponseWriter, r *http.Request) {
+	errorForbidden := models.Error{
+		Code:    models.ErrCodeForbidden,
+		Message: "The token does not have permission to perform this action.",
+	}
+
+	SendError(w, r, errorForbidden)
+}
+
 // SendNotFoundError sends a not found error.
 func SendNotFoundError(w http.ResponseWriter, r *http.Request) {
 	errorNotFound := models.Error{
@@ -80,6 +90,9 @@ func toStatusCode(err models.Error) int {
 	case models.ErrCodeUnauthorized:
 		return http.StatusUnauthorized
 
+	case models.ErrCodeForbidden:
+		return http.StatusForbidden
+
 	case models.ErrCodeNotFound:
 		return http.StatusNotFound
 
@@ -25,6 +25,10 @@ func TestErrorCodeStatuses(t *testing.T) {
 			error: "UNAUTHORIZED",
 			code:  401,
 		},
+		{
+			error: "FORBIDDEN",
+			code:  403,
+		},
 		{
 			error: "NOT_FOUND",
 			code:  404,
@@ -0,0 +1,84 @@
+/*
+2022 © Postgres.ai
+*/
+
+package srv
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"gitlab.com/postgres-ai/database-lab/v3/internal/audit"
+	"gitlab.com/postgres-ai/database-lab/v3/internal/srv/api"
+	"gitlab.com/postgres-ai/database-lab/v3/internal/srv/mw"
+)
+
+// audited records requests of the handler to the audit log.
+func (s *Server) audited(h http.HandlerFunc) http.HandlerFunc {
+	if s.audit == nil {
+		return h
+	}
+
+	return mw.Audit(s.audit, h)
+}
+
+func (s *Server) getAuditLog(w http.ResponseWriter, r *http.Request) {
+	if s.audit == nil {
+		api.SendNotFoundError(w, r)
+		return
+	}
+
+	filter, limit, err := parseAuditFilter(r.URL.Query())
+	if err != nil {
+		api.SendBadRequestError(w, r, err.Error())
+		return
+	}
+
+	entries, err := s.audit.Find(filter)
+	if err != nil {
+		api.SendError(w, r, err)
+		return
+	}
+
+	if limit > 0 && len(entries) > limit {
+		entries = entries[len(entries)-limit:]
+	}
+
+	if err := api.WriteJSON(w, http.StatusOK, entries); err != nil {
+		api.SendError(w, r, err)
+		return
+	}
+}
+
+// parseAuditFilter parses the "from", "to", "actor", and "limit" query parameters.
+// The limit keeps the most recent entries.
+func parseAuditFilter(query url.Values) (audit.Filter, int, error) {
+	filter := audit.Filter{Actor: query.Get("actor")}
+
+	var err error
+
+	if from := query.Get("from"); from != "" {
+		if filter.From, err = time.Parse(time.RFC3339, from); err != nil {
+			return audit.Filter{}, 0, fmt.Errorf("invalid from parameter: %w", err)
+		}
+	}
+
+	if to := query.Get("to"); to != "" {
+		if filter.To, err = time.Parse(time.RFC3339, to); err != nil {
+			return audit.Filter{}, 0, fmt.Errorf("invalid to parameter: %w", err)
+		}
+	}
+
+	limit := 0
+
+	if limitParam := query.Get("limit"); limitParam != "" {
+		if limit, err = strconv.Atoi(limitParam); err != nil || limit < 0 {
+			return audit.Filter{}, 0, fmt.Errorf("invalid limit parameter: %q", limitParam)
+		}
+	}
+
+	return filter, limit, nil
+}
@@ -0,0 +1,121 @@
+package srv
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+
+	"gitlab.com/postgres-ai/database-lab/v3/internal/audit"
+	"gitlab.com/postgres-ai/database-lab/v3/internal/srv/api"
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/client/dblabapi/types"
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/log"
+)
+
+func (s *Server) getBranches(w http.ResponseWriter, r *http.Request) {
+	if err := api.WriteJSON(w, http.StatusOK, s.Cloning.GetBranches()); err != nil {
+		api.SendError(w, r, err)
+		return
+	}
+}
+
+func (s *Server) createBranch(w http.ResponseWriter, r *http.Request) {
+	var branchRequest types.BranchCreateRequest
+	if err := api.ReadJSON(r, &branchRequest); err != nil {
+		api.SendBadRequestError(w, r, err.Error())
+		return
+	}
+
+	branch, err := s.Cloning.CreateBranch(branchRequest)
+	if err != nil {
+		sendCloningError(w, r, errors.Wrap(err, "failed to create branch"))
+		return
+	}
+
+	audit.SetBranch(r.Context(), branch.Name)
+
+	if err := api.WriteJSON(w, http.StatusCreated, branch); err != nil {
+		api.SendError(w, r, err)
+		return
+	}
+
+	log.Dbg(fmt.Sprintf("Branch %s has been created", branch.Name))
+}
+
+func (s *Server) deleteBranch(w http.ResponseWriter, r *http.Request) {
+	branchName := mux.Vars(r)["name"]
+
+	if err := s.Cloning.DeleteBranch(branchName); err != nil {
+		sendCloningError(w, r, errors.Wrap(err, "failed to delete branch"))
+		return
+	}
+
+	log.Dbg(fmt.Sprintf("Branch %s has been deleted", branchName))
+}
+
+func (s *Server) getBranchLog(w http.ResponseWriter, r *http.Request) {
+	snapshotLog, err := s.Cloning.GetBranchLog(mux.Vars(r)["name"])
+	if err != nil {
+		sendCloningError(w, r, errors.Wrap(err, "failed to get branch log"))
+		return
+	}
+
+	if err := api.WriteJSON(w, http.StatusOK, snapshotLog); err != nil {
+		api.SendError(w, r, err)
+		return
+	}
+}
+
+func (s *Server) commitBranch(w http.ResponseWriter, r *http.Request) {
+	branchName := mux.Vars(r)["name"]
+
+	var commitRequest types.BranchCommitRequest
+	if err := api.ReadJSON(r, &commitRequest); err != nil {
+		api.SendBadRequestError(w, r, err.Error())
+		return
+	}
+
+	if commitRequest.CloneID == "" {
+		api.SendBadRequestError(w, r, "clone ID must not be empty")
+		return
+	}
+
+	audit.SetCloneID(r.Context(), commitRequest.CloneID)
+
+	snapshot, err := s.Cloning.CommitBranch(branchName, commitRequest)
+	if err != nil {
+		sendCloningError(w, r, errors.Wrap(err, "failed to commit"))
+		return
+	}
+
+	if err := api.WriteJSON(w, http.StatusCreated, snapshot); err != nil {
+		api.SendError(w, r, err)
+		return
+	}
+
+	log.Dbg(fmt.Sprintf("Clone ID=%s has been committed to branch %s as %s", commitRequest.CloneID, branchName, snapshot.ID))
+}
+
+func (s *Server) resetBranch(w http.ResponseWriter, r *http.Request) {
+	branchName := mux.Vars(r)["name"]
+
+	var resetRequest types.BranchResetRequest
+	if err := api.ReadJSON(r, &resetRequest); err != nil {
+		api.SendBadRequestError(w, r, err.Error())
+		return
+	}
+
+	branch, err := s.Cloning.ResetBranch(branchName, resetRequest)
+	if err != nil {
+		sendCloningError(w, r, errors.Wrap(err, "failed to reset branch"))
+		return
+	}
+
+	if err := api.WriteJSON(w, http.StatusOK, branch); err != nil {
+		api.SendError(w, r, err)
+		return
+	}
+
+	log.Dbg(fmt.Sprintf("Branch %s has been reset to %s", branchName, branch.SnapshotID))
+}
@@ -7,8 +7,51 @@ package config
 
 // Config provides configuration management via DLE API
 type Config struct {
-	VerificationToken         string `yaml:"verificationToken" json:"-"`
-	Host                      string `yaml:"host"`
-	Port                      uint   `yaml:"port"`
-	DisableConfigModification bool   `yaml:"disableConfigModification" json:"-"`
+	VerificationToken         string        `yaml:"verificationToken" json:"-"`
+	Tokens                    []TokenConfig `yaml:"tokens" json:"-"`
+	Host                      string        `yaml:"host"`
+	Port                      uint          `yaml:"port"`
+	DisableConfigModification bool          `yaml:"disableConfigModification" json:"-"`
+	TLS                       TLSConfig     `yaml:"tls" json:"-"`
+	OIDC                      OIDCConfig    `yaml:"oidc" json:"-"`
+}
+
+// TLSConfig defines files to serve the API over HTTPS. A client CA file enables mutual TLS.
+type TLSConfig struct {
+	CertFile     string `yaml:"certFile"`
+	KeyFile      string `yaml:"keyFile"`
+	ClientCAFile string `yaml:"clientCAFile"`
+}
+
+// Enabled checks if the API is served over HTTPS.
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != "" || c.KeyFile != ""
+}
+
+// OIDCConfig defines an OpenID Connect provider whose ID tokens are accepted as API tokens.
+// Groups of users are mapped to roles, the most privileged role wins.
+type OIDCConfig struct {
+	Issuer        string            `yaml:"issuer"`
+	ClientID      string            `yaml:"clientID"`
+	ClientSecret  string            `yaml:"clientSecret"`
+	RedirectURL   string            `yaml:"redirectURL"`
+	UIURL         string            `yaml:"uiURL"`
+	Scopes        []string          `yaml:"scopes"`
+	UsernameClaim string            `yaml:"usernameClaim"`
+	GroupsClaim   string            `yaml:"groupsClaim"`
+	GroupRoles    map[string]string `yaml:"groupRoles"`
+	DefaultRole   string            `yaml:"defaultRole"`
+}
+
+// Enabled checks if an OpenID Connect provider is configured.
+func (c OIDCConfig) Enabled() bool {
+	return c.Issuer != ""
+}
+
+// TokenConfig defines an API token with a role and optional scopes narrowing down the permissions of the role.
+type TokenConfig struct {
+	Name   string   `yaml:"name"`
+	Token  string   `yaml:"token"`
+	Role   string   `yaml:"role"`
+	Scopes []string `yaml:"scopes"`
 }
@@ -0,0 +1,244 @@
+/*
+2022 © Postgres.ai
+*/
+
+package srv
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/log"
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/models"
+)
+
+const metricsNamespace = "dblab"
+
+var (
+	cloneStatusCodes = []models.StatusCode{
+		models.StatusOK,
+		models.StatusCreating,
+		models.StatusResetting,
+		models.StatusDeleting,
+		models.StatusExporting,
+		models.StatusFatal,
+	}
+
+	retrievalStatuses = []models.RetrievalStatus{
+		models.Inactive,
+		models.Pending,
+		models.Failed,
+		models.Refreshing,
+		models.Renewed,
+		models.Snapshotting,
+		models.Finished,
+	}
+)
+
+// metricsCollector collects metrics of the engine state on every scrape.
+type metricsCollector struct {
+	server *Server
+
+	clones                   *prometheus.Desc
+	cloneDiffSize            *prometheus.Desc
+	poolSize                 *prometheus.Desc
+	poolUsed                 *prometheus.Desc
+	poolFree                 *prometheus.Desc
+	snapshots                *prometheus.Desc
+	snapshotNewestAge        *prometheus.Desc
+	snapshotOldestAge        *prometheus.Desc
+	retrievalStatus          *prometheus.Desc
+	retrievalLastRefresh     *prometheus.Desc
+	retrievalRefreshDuration *prometheus.Desc
+	portPoolSize             *prometheus.Desc
+	portPoolUsed             *prometheus.Desc
+}
+
+func newMetricsCollector(server *Server) *metricsCollector {
+	return &metricsCollector{
+		server: server,
+
+		clones: prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "", "clones"),
+			"Number of clones by status.", []string{"status"}, nil),
+		cloneDiffSize: prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "clones", "diff_size_bytes"),
+			"Total size of changes made in clones of the pool since their creation.", []string{"pool"}, nil),
+		poolSize: prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "pool", "size_bytes"),
+			"Total size of the pool.", []string{"pool", "mode"}, nil),
+		poolUsed: prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "pool", "used_bytes"),
+			"Used space of the pool.", []string{"pool", "mode"}, nil),
+		poolFree: prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "pool", "free_bytes"),
+			"Free space of the pool.", []string{"pool", "mode"}, nil),
+		snapshots: prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "", "snapshots"),
+			"Number of snapshots in the pool.", []string{"pool"}, nil),
+		snapshotNewestAge: prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "snapshot", "newest_data_age_seconds"),
+			"Age of the data state of the newest snapshot in the pool.", []string{"pool"}, nil),
+		snapshotOldestAge: prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "snapshot", "oldest_data_age_seconds"),
+			"Age of the data state of the oldest snapshot in the pool.", []string{"pool"}, nil),
+		retrievalStatus: prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "retrieval", "status"),
+			"Current status of data retrieval, 1 for the active status.", []string{"status", "mode"}, nil),
+		retrievalLastRefresh: prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "retrieval", "last_refresh_timestamp_seconds"),
+			"Time when the last data refresh started.", nil, nil),
+		retrievalRefreshDuration: prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "retrieval", "last_refresh_duration_seconds"),
+			"Duration of the last data refresh.", nil, nil),
+		portPoolSize: prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "port_pool", "size"),
+			"Number of ports in the port pool.", nil, nil),
+		portPoolUsed: prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "port_pool", "used"),
+			"Number of ports occupied by clones.", nil, nil),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (m *metricsCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{
+		m.clones, m.cloneDiffSize, m.poolSize, m.poolUsed, m.poolFree, m.snapshots, m.snapshotNewestAge, m.snapshotOldestAge,
+		m.retrievalStatus, m.retrievalLastRefresh, m.retrievalRefreshDuration, m.portPoolSize, m.portPoolUsed,
+	} {
+		ch <- desc
+	}
+}
+
+// Collect implements prometheus.Collector.
+func (m *metricsCollector) Collect(ch chan<- prometheus.Metric) {
+	m.collectClones(ch)
+	m.collectPools(ch)
+	m.collectSnapshots(ch)
+	m.collectRetrieval(ch)
+	m.collectPortPool(ch)
+}
+
+func (m *metricsCollector) collectClones(ch chan<- prometheus.Metric) {
+	clonesByStatus := make(map[models.StatusCode]int, len(cloneStatusCodes))
+
+	for _, code := range cloneStatusCodes {
+		clonesByStatus[code] = 0
+	}
+
+	// Diff sizes are summed up by pools because clone IDs would make the number of series unbounded.
+	diffSizeByPool := make(map[string]uint64)
+
+	for _, clone := range m.server.Cloning.GetClones() {
+		clonesByStatus[clone.Status.Code]++
+
+		var pool string
+		if clone.Snapshot != nil {
+			pool = clone.Snapshot.Pool
+		}
+
+		diffSizeByPool[pool] += clone.Metadata.CloneDiffSize
+	}
+
+	for code, number := range clonesByStatus {
+		ch <- prometheus.MustNewConstMetric(m.clones, prometheus.GaugeValue, float64(number), string(code))
+	}
+
+	for pool, size := range diffSizeByPool {
+		ch <- prometheus.MustNewConstMetric(m.cloneDiffSize, prometheus.GaugeValue, float64(size), pool)
+	}
+}
+
+func (m *metricsCollector) collectPools(ch chan<- prometheus.Metric) {
+	for _, pool := range m.server.provisioner.GetPoolEntryList() {
+		fs := pool.FileSystem
+
+		ch <- prometheus.MustNewConstMetric(m.poolSize, prometheus.GaugeValue, float64(fs.Size), pool.Name, pool.Mode)
+		ch <- prometheus.MustNewConstMetric(m.poolUsed, prometheus.GaugeValue, float64(fs.Used), pool.Name, pool.Mode)
+		ch <- prometheus.MustNewConstMetric(m.poolFree, prometheus.GaugeValue, float64(fs.Free), pool.Name, pool.Mode)
+	}
+}
+
+func (m *metricsCollector) collectSnapshots(ch chan<- prometheus.Metric) {
+	snapshots, err := m.server.Cloning.GetSnapshots()
+	if err != nil {
+		log.Err("Failed to collect snapshot metrics: ", err)
+		return
+	}
+
+	type poolSnapshots struct {
+		number         int
+		newest, oldest time.Time
+	}
+
+	pools := make(map[string]*poolSnapshots)
+
+	for _, snapshot := range snapshots {
+		stat, ok := pools[snapshot.Pool]
+		if !ok {
+			stat = &poolSnapshots{}
+			pools[snapshot.Pool] = stat
+		}
+
+		stat.number++
+
+		if snapshot.DataStateAt == nil || snapshot.DataStateAt.IsZero() {
+			continue
+		}
+
+		if stat.newest.IsZero() || snapshot.DataStateAt.After(stat.newest) {
+			stat.newest = snapshot.DataStateAt.Time
+		}
+
+		if stat.oldest.IsZero() || snapshot.DataStateAt.Before(stat.oldest) {
+			stat.oldest = snapshot.DataStateAt.Time
+		}
+	}
+
+	for pool, stat := range pools {
+		ch <- prometheus.MustNewConstMetric(m.snapshots, prometheus.GaugeValue, float64(stat.number), pool)
+
+		if !stat.newest.IsZero() {
+			ch <- prometheus.MustNewConstMetric(m.snapshotNewestAge, prometheus.GaugeValue, time.Since(stat.newest).Seconds(), pool)
+			ch <- prometheus.MustNewConstMetric(m.snapshotOldestAge, prometheus.GaugeValue, time.Since(stat.oldest).Seconds(), pool)
+		}
+	}
+}
+
+func (m *metricsCollector) collectRetrieval(ch chan<- prometheus.Metric) {
+	if m.server.Retrieval == nil {
+		return
+	}
+
+	state := &m.server.Retrieval.State
+
+	for _, status := range retrievalStatuses {
+		var value float64
+		if state.Status == status {
+			value = 1
+		}
+
+		ch <- prometheus.MustNewConstMetric(m.retrievalStatus, prometheus.GaugeValue, value, string(status), string(state.Mode))
+	}
+
+	if state.LastRefresh != nil && !state.LastRefresh.IsZero() {
+		ch <- prometheus.MustNewConstMetric(m.retrievalLastRefresh, prometheus.GaugeValue, float64(state.LastRefresh.Unix()))
+	}
+
+	if state.LastRefreshDuration > 0 {
+		ch <- prometheus.MustNewConstMetric(m.retrievalRefreshDuration, prometheus.GaugeValue, state.LastRefreshDuration.Seconds())
+	}
+}
+
+func (m *metricsCollector) collectPortPool(ch chan<- prometheus.Metric) {
+	total, used := m.server.provisioner.PortPoolUsage()
+
+	ch <- prometheus.MustNewConstMetric(m.portPoolSize, prometheus.GaugeValue, float64(total))
+	ch <- prometheus.MustNewConstMetric(m.portPoolUsed, prometheus.GaugeValue, float64(used))
+}
+
+// metricsHandler returns an HTTP handler exposing metrics in the Prometheus text format.
+func (s *Server) metricsHandler() http.Handler {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(newMetricsCollector(s), s.Cloning.CloningDuration())
+
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{ErrorLog: metricsErrorLogger{}})
+}
+
+// metricsErrorLogger passes errors of metrics serving to the engine log.
+type metricsErrorLogger struct{}
+
+// Println implements promhttp.Logger.
+func (metricsErrorLogger) Println(v ...interface{}) {
+	log.Err(v...)
+}
@@ -0,0 +1,50 @@
+package srv
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/postgres-ai/database-lab/v3/internal/retrieval"
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/models"
+)
+
+func TestRetrievalMetrics(t *testing.T) {
+	s := &Server{Retrieval: &retrieval.Retrieval{}}
+	s.Retrieval.State.Mode = models.Physical
+	s.Retrieval.State.Status = models.Finished
+	s.Retrieval.State.LastRefresh = models.NewLocalTime(time.Date(2022, 1, 27, 10, 0, 0, 0, time.UTC))
+	s.Retrieval.State.LastRefreshDuration = 90 * time.Second
+
+	m := newMetricsCollector(s)
+	ch := make(chan prometheus.Metric, len(retrievalStatuses)+2)
+
+	m.collectRetrieval(ch)
+	close(ch)
+
+	activeStatuses := []string{}
+	values := make(map[string]float64)
+
+	for metric := range ch {
+		var pb dto.Metric
+		require.NoError(t, metric.Write(&pb))
+
+		switch metric.Desc() {
+		case m.retrievalStatus:
+			if pb.GetGauge().GetValue() == 1 {
+				activeStatuses = append(activeStatuses, pb.GetLabel()[1].GetValue())
+			}
+
+		default:
+			values[metric.Desc().String()] = pb.GetGauge().GetValue()
+		}
+	}
+
+	assert.Equal(t, []string{string(models.Finished)}, activeStatuses)
+	assert.Equal(t, float64(1643277600), values[m.retrievalLastRefresh.String()])
+	assert.Equal(t, float64(90), values[m.retrievalRefreshDuration.String()])
+}
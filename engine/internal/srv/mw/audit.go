@@ -0,0 +1,88 @@
+/*
+2022 © Postgres.ai
+*/
+
+package mw
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"gitlab.com/postgres-ai/database-lab/v3/internal/audit"
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/log"
+)
+
+// statusRecorder captures the status code of a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader records the status code and sends it.
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Audit records the request to the audit log. It has to wrap the authorization middleware,
+// so requests rejected because of missing or insufficient tokens are recorded as well.
+// The actor is set by the authorization middleware once the token owner is identified.
+func Audit(logger *audit.Logger, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		entry := newAuditEntry(r)
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		h(recorder, r.WithContext(audit.WithEntry(r.Context(), entry)))
+
+		entry.Status = recorder.status
+		entry.Result = audit.ResultSuccess
+
+		if recorder.status >= http.StatusBadRequest {
+			entry.Result = audit.ResultFailure
+		}
+
+		if err := logger.Record(*entry); err != nil {
+			log.Err("Failed to record an audit entry:", err)
+		}
+	}
+}
+
+func newAuditEntry(r *http.Request) *audit.Entry {
+	entry := &audit.Entry{
+		Time:       time.Now(),
+		RemoteAddr: r.RemoteAddr,
+		Method:     r.Method,
+		Route:      r.URL.Path,
+	}
+
+	if route := mux.CurrentRoute(r); route != nil {
+		if template, err := route.GetPathTemplate(); err == nil {
+			entry.Route = template
+		}
+	}
+
+	vars := mux.Vars(r)
+
+	if cloneID, ok := vars["clone_id"]; ok {
+		entry.CloneID = cloneID
+	}
+
+	if branch, ok := vars["name"]; ok {
+		entry.Branch = branch
+	}
+
+	if id, ok := vars["id"]; ok {
+		switch {
+		case strings.HasPrefix(entry.Route, "/clone/"):
+			entry.CloneID = id
+
+		case strings.HasPrefix(entry.Route, "/snapshot/"):
+			entry.SnapshotID = id
+		}
+	}
+
+	return entry
+}
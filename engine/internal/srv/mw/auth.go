@@ -8,72 +8,205 @@ package mw
 import (
 	"context"
 	"crypto/subtle"
+	"errors"
+	"fmt"
 	"net/http"
+	"strings"
 
+	"gitlab.com/postgres-ai/database-lab/v3/internal/audit"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/platform"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/srv/api"
+	"gitlab.com/postgres-ai/database-lab/v3/internal/srv/config"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/srv/ws"
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/log"
 )
 
 // VerificationTokenHeader defines the verification token name that should be passed in request headers.
 const VerificationTokenHeader = "Verification-Token"
 
+// bearerPrefix defines the scheme of tokens passed in the Authorization header.
+const bearerPrefix = "Bearer "
+
 // wsTokenKey defines the name of web-sockets token parameter that should be passed in query string.
 const wsTokenKey = "token"
 
 // Auth defines an authorization middleware of the Database Lab HTTP server.
 type Auth struct {
 	verificationToken     string
+	tokens                []tokenIdentity
+	tokensConfigured      bool
 	personalTokenVerifier platform.PersonalTokenVerifier
+	identityVerifier      IdentityVerifier
+}
+
+// IdentityVerifier authenticates tokens issued by an external identity provider.
+type IdentityVerifier interface {
+	// VerifyIdentity returns the name and the role of the token owner.
+	VerifyIdentity(ctx context.Context, token string) (name string, role Role, err error)
+}
+
+// tokenIdentity binds a configured token to its identity.
+type tokenIdentity struct {
+	token    string
+	identity *Identity
 }
 
 // NewAuth creates a new Auth middleware.
-func NewAuth(verificationToken string, personalTokenVerifier platform.PersonalTokenVerifier) *Auth {
-	return &Auth{verificationToken: verificationToken, personalTokenVerifier: personalTokenVerifier}
+func NewAuth(verificationToken string, tokens []config.TokenConfig, personalTokenVerifier platform.PersonalTokenVerifier,
+	identityVerifier IdentityVerifier) *Auth {
+	return &Auth{
+		verificationToken:     verificationToken,
+		tokens:                loadTokens(tokens),
+		tokensConfigured:      len(tokens) > 0,
+		personalTokenVerifier: personalTokenVerifier,
+		identityVerifier:      identityVerifier,
+	}
+}
+
+// ValidateTokens checks if the configured API tokens are valid.
+func ValidateTokens(tokens []config.TokenConfig) error {
+	names := make(map[string]struct{}, len(tokens))
+
+	for _, tokenCfg := range tokens {
+		if err := validateToken(tokenCfg, names); err != nil {
+			return fmt.Errorf(`invalid "server.tokens": %w`, err)
+		}
+
+		names[tokenCfg.Name] = struct{}{}
+	}
+
+	return nil
+}
+
+// loadTokens builds identities of the configured tokens skipping invalid ones.
+func loadTokens(tokens []config.TokenConfig) []tokenIdentity {
+	identities := make([]tokenIdentity, 0, len(tokens))
+	names := make(map[string]struct{}, len(tokens))
+
+	for _, tokenCfg := range tokens {
+		if err := validateToken(tokenCfg, names); err != nil {
+			log.Err("Skip API token:", err)
+			continue
+		}
+
+		names[tokenCfg.Name] = struct{}{}
+
+		identities = append(identities, tokenIdentity{
+			token:    tokenCfg.Token,
+			identity: newIdentity(tokenCfg.Name, Role(tokenCfg.Role), tokenCfg.Scopes),
+		})
+	}
+
+	return identities
+}
+
+func validateToken(tokenCfg config.TokenConfig, names map[string]struct{}) error {
+	if tokenCfg.Name == "" {
+		return errors.New("token name must not be empty")
+	}
+
+	if _, ok := names[tokenCfg.Name]; ok {
+		return fmt.Errorf("token %q is defined more than once", tokenCfg.Name)
+	}
+
+	if tokenCfg.Token == "" {
+		return fmt.Errorf("token %q has an empty value", tokenCfg.Name)
+	}
+
+	if !IsValidRole(Role(tokenCfg.Role)) {
+		return fmt.Errorf("token %q has an unknown role %q", tokenCfg.Name, tokenCfg.Role)
+	}
+
+	return nil
 }
 
 // Authorized checks if the user has permission to access.
 func (a *Auth) Authorized(h http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		token := r.Header.Get(VerificationTokenHeader)
-		if !a.isAccessAllowed(r.Context(), token) {
+		identity, ok := a.identify(r.Context(), requestToken(r))
+		if !ok {
 			api.SendUnauthorizedError(w, r)
 			return
 		}
 
-		h(w, r)
+		audit.SetActor(r.Context(), identity.Name, string(identity.Role))
+
+		h(w, r.WithContext(withIdentity(r.Context(), identity)))
 	}
 }
 
-// AdminMW checks if the user has permission to access to admin sub-route.
-// TODO: check admin permissions.
-func (a *Auth) AdminMW(h http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		token := r.Header.Get(VerificationTokenHeader)
-		if !a.isAccessAllowed(r.Context(), token) {
-			api.SendUnauthorizedError(w, r)
+// requestToken extracts the token from the Verification-Token header or from the Authorization header with the Bearer scheme.
+func requestToken(r *http.Request) string {
+	if token := r.Header.Get(VerificationTokenHeader); token != "" {
+		return token
+	}
+
+	if authorization := r.Header.Get("Authorization"); strings.HasPrefix(authorization, bearerPrefix) {
+		return strings.TrimPrefix(authorization, bearerPrefix)
+	}
+
+	return ""
+}
+
+// Require checks if the user has permission to access routes of the scope.
+func (a *Auth) Require(scope Scope, h http.HandlerFunc) http.HandlerFunc {
+	return a.Authorized(func(w http.ResponseWriter, r *http.Request) {
+		if !IdentityFromContext(r.Context()).HasScope(scope) {
+			api.SendForbiddenError(w, r)
 			return
 		}
 
-		h.ServeHTTP(w, r)
+		h(w, r)
 	})
 }
 
-func (a *Auth) isAccessAllowed(ctx context.Context, token string) bool {
-	if a.verificationToken == "" {
-		return true
+// identify finds the identity of the token owner.
+func (a *Auth) identify(ctx context.Context, token string) (*Identity, bool) {
+	// Skipped invalid tokens must not open access, so the configuration is checked instead of the loaded tokens.
+	if a.verificationToken == "" && !a.tokensConfigured && a.identityVerifier == nil {
+		return adminIdentity(), true
+	}
+
+	if a.verificationToken != "" && subtle.ConstantTimeCompare([]byte(a.verificationToken), []byte(token)) == 1 {
+		return adminIdentity(), true
+	}
+
+	for _, t := range a.tokens {
+		if subtle.ConstantTimeCompare([]byte(t.token), []byte(token)) == 1 {
+			return t.identity, true
+		}
 	}
 
-	if subtle.ConstantTimeCompare([]byte(a.verificationToken), []byte(token)) == 1 {
-		return true
+	if identity, ok := a.verifyExternalIdentity(ctx, token); ok {
+		return identity, true
 	}
 
 	if a.personalTokenVerifier != nil && a.personalTokenVerifier.IsPersonalTokenEnabled() &&
 		a.personalTokenVerifier.IsAllowedToken(ctx, token) {
-		return true
+		return adminIdentity(), true
 	}
 
-	return false
+	return nil, false
+}
+
+// verifyExternalIdentity checks if the token is a JWT issued by the external identity provider.
+func (a *Auth) verifyExternalIdentity(ctx context.Context, token string) (*Identity, bool) {
+	if a.identityVerifier == nil || strings.Count(token, ".") != 2 {
+		return nil, false
+	}
+
+	name, role, err := a.identityVerifier.VerifyIdentity(ctx, token)
+	if err != nil {
+		log.Dbg("Failed to verify an identity token:", err)
+		return nil, false
+	}
+
+	if !IsValidRole(role) {
+		log.Err(fmt.Sprintf("Identity %q has an unknown role %q", name, role))
+		return nil, false
+	}
+
+	return newIdentity(name, role, nil), true
 }
 
 // WebSocketsMW checks if the user has a token to access to web-socket handlers.
@@ -101,3 +234,22 @@ func (a *Auth) WebSocketsMW(holder *ws.TokenKeeper, h http.HandlerFunc) http.Han
 		h(w, r)
 	}
 }
+
+// StreamMW checks access to the event stream. Browsers cannot set headers of EventSource requests,
+// so a one-time web-socket token passed in the query string is accepted as well.
+// Such tokens are issued to admins only, so their requests are authorized as made by an admin.
+func (a *Auth) StreamMW(holder *ws.TokenKeeper, scope Scope, h http.HandlerFunc) http.HandlerFunc {
+	tokenHandler := a.WebSocketsMW(holder, func(w http.ResponseWriter, r *http.Request) {
+		h(w, r.WithContext(withIdentity(r.Context(), adminIdentity())))
+	})
+	headerHandler := a.Require(scope, h)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get(wsTokenKey) != "" {
+			tokenHandler(w, r)
+			return
+		}
+
+		headerHandler(w, r)
+	}
+}
@@ -6,9 +6,16 @@ package mw
 
 import (
 	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/postgres-ai/database-lab/v3/internal/audit"
+	"gitlab.com/postgres-ai/database-lab/v3/internal/srv/config"
 )
 
 // Test constants.
@@ -54,7 +61,209 @@ func TestAccess(t *testing.T) {
 		t.Log(tc.name)
 		mw.personalTokenVerifier = MockPersonalTokenVerifier{isPersonalTokenEnabled: tc.result}
 
-		isAllowed := mw.isAccessAllowed(context.Background(), tc.requestToken)
+		_, isAllowed := mw.identify(context.Background(), tc.requestToken)
 		assert.Equal(t, tc.result, isAllowed)
 	}
 }
+
+func TestTokenRoles(t *testing.T) {
+	auth := NewAuth(testVerificationToken, []config.TokenConfig{
+		{Name: "dashboard", Token: "ViewerToken", Role: "viewer"},
+		{Name: "ci", Token: "CIToken", Role: "clone-user"},
+		{Name: "ci-readonly", Token: "CIReadOnlyToken", Role: "clone-user", Scopes: []string{"clones:read", "admin"}},
+		{Name: "ops", Token: "OpsToken", Role: "admin"},
+		{Name: "unknown", Token: "UnknownToken", Role: "superuser"},
+		{Name: "ci", Token: "DuplicateToken", Role: "admin"},
+	}, nil, nil)
+
+	testCases := []struct {
+		token   string
+		scope   Scope
+		allowed bool
+	}{
+		{token: "ViewerToken", scope: ScopeStatusRead, allowed: true},
+		{token: "ViewerToken", scope: ScopeSnapshotsRead, allowed: true},
+		{token: "ViewerToken", scope: ScopeClonesWrite, allowed: false},
+		{token: "ViewerToken", scope: ScopeAdmin, allowed: false},
+		{token: "CIToken", scope: ScopeClonesWrite, allowed: true},
+		{token: "CIToken", scope: ScopeSnapshotsWrite, allowed: false},
+		{token: "CIToken", scope: ScopeAdmin, allowed: false},
+		{token: "CIReadOnlyToken", scope: ScopeClonesRead, allowed: true},
+		{token: "CIReadOnlyToken", scope: ScopeClonesWrite, allowed: false},
+		{token: "CIReadOnlyToken", scope: ScopeAdmin, allowed: false},
+		{token: "OpsToken", scope: ScopeAdmin, allowed: true},
+		{token: testVerificationToken, scope: ScopeAdmin, allowed: true},
+	}
+
+	for _, tc := range testCases {
+		identity, ok := auth.identify(context.Background(), tc.token)
+		require.True(t, ok, tc.token)
+		assert.Equal(t, tc.allowed, identity.HasScope(tc.scope), "%s: %s", tc.token, tc.scope)
+	}
+
+	for _, token := range []string{"UnknownToken", "DuplicateToken", ""} {
+		_, ok := auth.identify(context.Background(), token)
+		assert.False(t, ok, token)
+	}
+}
+
+func TestValidateTokens(t *testing.T) {
+	require.NoError(t, ValidateTokens(nil))
+	require.NoError(t, ValidateTokens([]config.TokenConfig{
+		{Name: "dashboard", Token: "ViewerToken", Role: "viewer"},
+		{Name: "ci", Token: "CIToken", Role: "clone-user"},
+	}))
+
+	invalidTokens := [][]config.TokenConfig{
+		{{Token: "NoNameToken", Role: "viewer"}},
+		{{Name: "empty", Role: "viewer"}},
+		{{Name: "unknown", Token: "UnknownToken", Role: "superuser"}},
+		{{Name: "ci", Token: "CIToken", Role: "clone-user"}, {Name: "ci", Token: "DuplicateToken", Role: "admin"}},
+	}
+
+	for _, tokens := range invalidTokens {
+		assert.Error(t, ValidateTokens(tokens), tokens)
+	}
+}
+
+func TestInvalidTokensDoNotOpenAccess(t *testing.T) {
+	auth := NewAuth("", []config.TokenConfig{{Name: "unknown", Token: "UnknownToken", Role: "superuser"}}, nil, nil)
+
+	for _, token := range []string{"UnknownToken", ""} {
+		_, ok := auth.identify(context.Background(), token)
+		assert.False(t, ok, token)
+	}
+}
+
+func TestRequire(t *testing.T) {
+	auth := NewAuth("", []config.TokenConfig{{Name: "dashboard", Token: "ViewerToken", Role: "viewer"}}, nil, nil)
+
+	var owner string
+
+	handler := auth.Require(ScopeStatusRead, func(w http.ResponseWriter, r *http.Request) {
+		owner = IdentityFromContext(r.Context()).Owner()
+	})
+
+	testCases := []struct {
+		token  string
+		scope  Scope
+		status int
+	}{
+		{token: "", scope: ScopeStatusRead, status: http.StatusUnauthorized},
+		{token: "ViewerToken", scope: ScopeStatusRead, status: http.StatusOK},
+		{token: "ViewerToken", scope: ScopeAdmin, status: http.StatusForbidden},
+	}
+
+	for _, tc := range testCases {
+		h := handler
+		if tc.scope != ScopeStatusRead {
+			h = auth.Require(tc.scope, handler)
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/status", nil)
+		req.Header.Set(VerificationTokenHeader, tc.token)
+
+		rec := httptest.NewRecorder()
+		h(rec, req)
+
+		assert.Equal(t, tc.status, rec.Code, tc.token)
+	}
+
+	assert.Equal(t, "dashboard", owner)
+}
+
+func TestRequireSetsAuditActor(t *testing.T) {
+	auth := NewAuth("", []config.TokenConfig{{Name: "dashboard", Token: "ViewerToken", Role: "viewer"}}, nil, nil)
+	handler := auth.Require(ScopeClonesWrite, func(w http.ResponseWriter, r *http.Request) {})
+
+	testCases := []struct {
+		token  string
+		actor  string
+		status int
+	}{
+		{token: "", actor: "", status: http.StatusUnauthorized},
+		{token: "ViewerToken", actor: "dashboard", status: http.StatusForbidden},
+	}
+
+	for _, tc := range testCases {
+		entry := &audit.Entry{}
+
+		req := httptest.NewRequest(http.MethodPost, "/clone", nil)
+		req.Header.Set(VerificationTokenHeader, tc.token)
+		req = req.WithContext(audit.WithEntry(req.Context(), entry))
+
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+
+		assert.Equal(t, tc.status, rec.Code, tc.token)
+		assert.Equal(t, tc.actor, entry.Actor, tc.token)
+	}
+}
+
+func TestIdentityOwns(t *testing.T) {
+	cloneUser := newIdentity("ci", RoleCloneUser, nil)
+	assert.True(t, cloneUser.Owns("ci"))
+	assert.False(t, cloneUser.Owns("other"))
+	assert.False(t, cloneUser.Owns(""))
+	assert.Equal(t, "ci", cloneUser.Owner())
+
+	admin := adminIdentity()
+	assert.True(t, admin.Owns("ci"))
+	assert.True(t, admin.Owns(""))
+	assert.Empty(t, admin.Owner())
+}
+
+type mockIdentityVerifier struct {
+	tokens map[string]Role
+}
+
+func (m mockIdentityVerifier) VerifyIdentity(_ context.Context, token string) (string, Role, error) {
+	role, ok := m.tokens[token]
+	if !ok {
+		return "", "", errors.New("invalid token")
+	}
+
+	return "jdoe", role, nil
+}
+
+func TestExternalIdentity(t *testing.T) {
+	verifier := mockIdentityVerifier{tokens: map[string]Role{
+		"header.viewer.signature":  RoleViewer,
+		"header.unknown.signature": "superuser",
+	}}
+
+	auth := NewAuth("", nil, nil, verifier)
+
+	var identity *Identity
+
+	handler := auth.Require(ScopeStatusRead, func(w http.ResponseWriter, r *http.Request) {
+		identity = IdentityFromContext(r.Context())
+	})
+
+	testCases := []struct {
+		header string
+		value  string
+		status int
+	}{
+		{header: "Authorization", value: "Bearer header.viewer.signature", status: http.StatusOK},
+		{header: VerificationTokenHeader, value: "header.viewer.signature", status: http.StatusOK},
+		{header: "Authorization", value: "Basic header.viewer.signature", status: http.StatusUnauthorized},
+		{header: "Authorization", value: "Bearer header.unknown.signature", status: http.StatusUnauthorized},
+		{header: "Authorization", value: "Bearer header.forged.signature", status: http.StatusUnauthorized},
+		{header: "Authorization", value: "", status: http.StatusUnauthorized},
+	}
+
+	for _, tc := range testCases {
+		req := httptest.NewRequest(http.MethodGet, "/status", nil)
+		req.Header.Set(tc.header, tc.value)
+
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+
+		assert.Equal(t, tc.status, rec.Code, tc.value)
+	}
+
+	require.NotNil(t, identity)
+	assert.Equal(t, "jdoe", identity.Name)
+	assert.Equal(t, RoleViewer, identity.Role)
+}
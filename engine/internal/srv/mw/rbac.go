@@ -0,0 +1,147 @@
+/*
+2022 © Postgres.ai
+*/
+
+package mw
+
+import (
+	"context"
+)
+
+// Role defines a set of permissions granted to an API token.
+type Role string
+
+const (
+	// RoleViewer allows reading the instance status and snapshots.
+	RoleViewer Role = "viewer"
+
+	// RoleCloneUser allows managing own clones in addition to the viewer permissions.
+	RoleCloneUser Role = "clone-user"
+
+	// RoleAdmin allows everything, including the admin API.
+	RoleAdmin Role = "admin"
+)
+
+// Scope defines a permission to access a group of API routes.
+type Scope string
+
+const (
+	// ScopeStatusRead allows reading the instance status.
+	ScopeStatusRead Scope = "status:read"
+
+	// ScopeSnapshotsRead allows reading snapshots.
+	ScopeSnapshotsRead Scope = "snapshots:read"
+
+	// ScopeSnapshotsWrite allows creating, updating, and destroying snapshots.
+	ScopeSnapshotsWrite Scope = "snapshots:write"
+
+	// ScopeClonesRead allows reading clones.
+	ScopeClonesRead Scope = "clones:read"
+
+	// ScopeClonesWrite allows creating, updating, resetting, and destroying clones.
+	ScopeClonesWrite Scope = "clones:write"
+
+	// ScopeBranchesRead allows reading branches.
+	ScopeBranchesRead Scope = "branches:read"
+
+	// ScopeBranchesWrite allows creating, committing, resetting, and deleting branches.
+	ScopeBranchesWrite Scope = "branches:write"
+
+	// ScopeObservation allows observing sessions in clones.
+	ScopeObservation Scope = "observation"
+
+	// ScopeAdmin allows accessing the admin API.
+	ScopeAdmin Scope = "admin"
+)
+
+var roleScopes = map[Role][]Scope{
+	RoleViewer: {ScopeStatusRead, ScopeSnapshotsRead},
+	RoleCloneUser: {
+		ScopeStatusRead, ScopeSnapshotsRead, ScopeClonesRead, ScopeClonesWrite, ScopeBranchesRead, ScopeObservation,
+	},
+	RoleAdmin: {
+		ScopeStatusRead, ScopeSnapshotsRead, ScopeSnapshotsWrite, ScopeClonesRead, ScopeClonesWrite,
+		ScopeBranchesRead, ScopeBranchesWrite, ScopeObservation, ScopeAdmin,
+	},
+}
+
+// Identity describes the owner of the token used to make a request.
+type Identity struct {
+	Name   string
+	Role   Role
+	scopes map[Scope]struct{}
+}
+
+// newIdentity creates an identity with the scopes of the role narrowed down to the requested ones.
+// An empty list of requested scopes keeps all the scopes of the role.
+func newIdentity(name string, role Role, requested []string) *Identity {
+	identity := &Identity{Name: name, Role: role, scopes: make(map[Scope]struct{})}
+
+	allowed := make(map[Scope]struct{}, len(requested))
+	for _, scope := range requested {
+		allowed[Scope(scope)] = struct{}{}
+	}
+
+	for _, scope := range roleScopes[role] {
+		if _, ok := allowed[scope]; len(requested) > 0 && !ok {
+			continue
+		}
+
+		identity.scopes[scope] = struct{}{}
+	}
+
+	return identity
+}
+
+// adminIdentity describes requests authorized by the shared verification token or personal tokens.
+func adminIdentity() *Identity {
+	return newIdentity("", RoleAdmin, nil)
+}
+
+// HasScope checks if the identity is permitted to access routes of the scope.
+func (i *Identity) HasScope(scope Scope) bool {
+	_, ok := i.scopes[scope]
+	return ok
+}
+
+// IsAdmin checks if the identity has the admin role.
+func (i *Identity) IsAdmin() bool {
+	return i.Role == RoleAdmin
+}
+
+// Owner returns the owner name that restricts the clones available to the identity.
+// Admins are not restricted, so the empty string is returned for them.
+func (i *Identity) Owner() string {
+	if i.IsAdmin() {
+		return ""
+	}
+
+	return i.Name
+}
+
+// Owns checks if the identity is allowed to manage a clone of the owner.
+func (i *Identity) Owns(owner string) bool {
+	return i.IsAdmin() || (i.Name != "" && i.Name == owner)
+}
+
+// IsValidRole checks if the role is known.
+func IsValidRole(role Role) bool {
+	_, ok := roleScopes[role]
+	return ok
+}
+
+type identityKey struct{}
+
+func withIdentity(ctx context.Context, identity *Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+// IdentityFromContext returns the identity of an authorized request.
+// Requests that passed no authorization middleware are treated as made by an admin.
+func IdentityFromContext(ctx context.Context) *Identity {
+	if identity, ok := ctx.Value(identityKey{}).(*Identity); ok {
+		return identity
+	}
+
+	return adminIdentity()
+}
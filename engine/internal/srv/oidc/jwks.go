@@ -0,0 +1,239 @@
+/*
+2022 © Postgres.ai
+*/
+
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/log"
+)
+
+const (
+	// keysTTL defines how long fetched keys are used without refreshing.
+	keysTTL = time.Hour
+
+	// keysRefreshInterval limits refreshing of keys caused by tokens signed with unknown keys.
+	keysRefreshInterval = time.Minute
+)
+
+// jsonWebKey describes a public key published by the provider.
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type jsonWebKeySet struct {
+	Keys []jsonWebKey `json:"keys"`
+}
+
+// keySet caches signing keys of the provider.
+type keySet struct {
+	mu        sync.RWMutex
+	client    *http.Client
+	keys      map[string]interface{}
+	fetchedAt time.Time
+
+	// fetching is the refresh in progress, requests wait for it instead of fetching the same keys.
+	fetching *keysFetch
+}
+
+// keysFetch is a single refresh of keys shared by concurrent requests.
+type keysFetch struct {
+	done chan struct{}
+	err  error
+}
+
+func newKeySet(client *http.Client) *keySet {
+	return &keySet{client: client}
+}
+
+// key returns the public key with the given ID. Keys are refreshed when they expire or an unknown key is requested,
+// so rotation of keys on the provider side does not require reloading the engine.
+func (s *keySet) key(ctx context.Context, jwksURI, kid string) (interface{}, error) {
+	s.mu.RLock()
+	key, ok := s.lookup(kid)
+	fresh := time.Since(s.fetchedAt) < keysTTL
+	s.mu.RUnlock()
+
+	if ok && fresh {
+		return key, nil
+	}
+
+	if err := s.refresh(ctx, jwksURI); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	key, ok = s.lookup(kid)
+	s.mu.RUnlock()
+
+	if ok {
+		return key, nil
+	}
+
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// lookup finds the key by ID. A token without ID may be verified if the provider publishes only one key.
+func (s *keySet) lookup(kid string) (interface{}, bool) {
+	if kid == "" && len(s.keys) == 1 {
+		for _, key := range s.keys {
+			return key, true
+		}
+	}
+
+	key, ok := s.keys[kid]
+
+	return key, ok
+}
+
+// refresh fetches keys unless they have been fetched recently. Keys are fetched without holding the lock,
+// so cached keys are still served, and concurrent requests share the same fetch.
+func (s *keySet) refresh(ctx context.Context, jwksURI string) error {
+	s.mu.Lock()
+
+	if s.keys != nil && time.Since(s.fetchedAt) < keysRefreshInterval {
+		s.mu.Unlock()
+		return nil
+	}
+
+	if fetch := s.fetching; fetch != nil {
+		s.mu.Unlock()
+
+		select {
+		case <-fetch.done:
+			return fetch.err
+
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	fetch := &keysFetch{done: make(chan struct{})}
+	s.fetching = fetch
+	s.mu.Unlock()
+
+	keys, err := s.fetch(ctx, jwksURI)
+
+	s.mu.Lock()
+
+	if err == nil {
+		s.keys = keys
+		s.fetchedAt = time.Now()
+	}
+
+	s.fetching = nil
+	s.mu.Unlock()
+
+	fetch.err = err
+	close(fetch.done)
+
+	return err
+}
+
+func (s *keySet) fetch(ctx context.Context, jwksURI string) (map[string]interface{}, error) {
+	set := jsonWebKeySet{}
+
+	if err := getJSON(ctx, s.client, jwksURI, &set); err != nil {
+		return nil, fmt.Errorf("failed to fetch signing keys of the OpenID Connect provider: %w", err)
+	}
+
+	keys := make(map[string]interface{}, len(set.Keys))
+
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		key, err := jwk.publicKey()
+		if err != nil {
+			log.Dbg(fmt.Sprintf("Skip signing key %q:", jwk.Kid), err)
+			continue
+		}
+
+		keys[jwk.Kid] = key
+	}
+
+	return keys, nil
+}
+
+// publicKey decodes RSA and EC keys.
+func (k jsonWebKey) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+
+		if !e.IsInt64() {
+			return nil, errors.New("invalid RSA exponent")
+		}
+
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("point is not on the curve")
+		}
+
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode key parameter: %w", err)
+	}
+
+	return new(big.Int).SetBytes(data), nil
+}
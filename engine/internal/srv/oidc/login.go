@@ -0,0 +1,354 @@
+/*
+2022 © Postgres.ai
+*/
+
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"gitlab.com/postgres-ai/database-lab/v3/internal/srv/api"
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/models"
+)
+
+const (
+	// loginTTL defines how long the user may authenticate on the provider side.
+	loginTTL = 10 * time.Minute
+
+	// maxPendingLogins limits logins that are started but not completed, so unauthenticated requests cannot exhaust memory.
+	maxPendingLogins = 1000
+
+	// stateCookieName defines the cookie binding the login state to the browser that has started the login.
+	stateCookieName = "dblab_oidc_state"
+
+	randomLength = 32
+)
+
+var errTooManyLogins = errors.New("too many logins are in progress, try again later")
+
+var defaultScopes = []string{"openid", "profile", "email"}
+
+// LoginResponse contains the ID token returned when no UI address is configured.
+type LoginResponse struct {
+	IDToken string `json:"idToken"`
+	Name    string `json:"name"`
+	Role    string `json:"role"`
+}
+
+type tokenResponse struct {
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// pendingLogin keeps the PKCE verifier and the nonce of the started login.
+type pendingLogin struct {
+	verifier  string
+	nonce     string
+	expiresAt time.Time
+}
+
+// loginStore keeps logins that are started but not completed yet.
+type loginStore struct {
+	mu     sync.Mutex
+	logins map[string]pendingLogin
+}
+
+func newLoginStore() *loginStore {
+	return &loginStore{logins: make(map[string]pendingLogin)}
+}
+
+func (s *loginStore) add(state string, login pendingLogin) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+
+	for key, pending := range s.logins {
+		if now.After(pending.expiresAt) {
+			delete(s.logins, key)
+		}
+	}
+
+	if len(s.logins) >= maxPendingLogins {
+		return errTooManyLogins
+	}
+
+	login.expiresAt = now.Add(loginTTL)
+	s.logins[state] = login
+
+	return nil
+}
+
+// take returns the started login. Every state can be used once.
+func (s *loginStore) take(state string) (pendingLogin, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	login, ok := s.logins[state]
+	if !ok {
+		return pendingLogin{}, false
+	}
+
+	delete(s.logins, state)
+
+	if time.Now().After(login.expiresAt) {
+		return pendingLogin{}, false
+	}
+
+	return login, true
+}
+
+// Login redirects the user to the authorization endpoint of the provider.
+func (p *Provider) Login(w http.ResponseWriter, r *http.Request) {
+	cfg := p.config()
+
+	if !cfg.Enabled() {
+		api.SendNotFoundError(w, r)
+		return
+	}
+
+	metadata, err := p.discover(r.Context())
+	if err != nil {
+		api.SendError(w, r, err)
+		return
+	}
+
+	if metadata.AuthorizationEndpoint == "" {
+		api.SendError(w, r, errors.New("discovery document of the OpenID Connect provider does not define authorization_endpoint"))
+		return
+	}
+
+	state, err := randomString()
+	if err != nil {
+		api.SendError(w, r, err)
+		return
+	}
+
+	verifier, err := randomString()
+	if err != nil {
+		api.SendError(w, r, err)
+		return
+	}
+
+	nonce, err := randomString()
+	if err != nil {
+		api.SendError(w, r, err)
+		return
+	}
+
+	if err := p.logins.add(state, pendingLogin{verifier: verifier, nonce: nonce}); err != nil {
+		api.SendError(w, r, err)
+		return
+	}
+
+	http.SetCookie(w, stateCookie(cfg.RedirectURL, state, int(loginTTL.Seconds())))
+
+	scopes := cfg.Scopes
+	if len(scopes) == 0 {
+		scopes = defaultScopes
+	}
+
+	challenge := sha256.Sum256([]byte(verifier))
+
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", cfg.ClientID)
+	params.Set("redirect_uri", cfg.RedirectURL)
+	params.Set("scope", strings.Join(scopes, " "))
+	params.Set("state", state)
+	params.Set("nonce", nonce)
+	params.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	params.Set("code_challenge_method", "S256")
+
+	http.Redirect(w, r, withQuery(metadata.AuthorizationEndpoint, params), http.StatusFound)
+}
+
+// Callback completes the login: exchanges the authorization code for an ID token, verifies the token,
+// and passes it to the UI. The UI sends the token in the Verification-Token or Authorization header.
+func (p *Provider) Callback(w http.ResponseWriter, r *http.Request) {
+	cfg := p.config()
+
+	if !cfg.Enabled() {
+		api.SendNotFoundError(w, r)
+		return
+	}
+
+	query := r.URL.Query()
+
+	if providerErr := query.Get("error"); providerErr != "" {
+		api.SendError(w, r, unauthorized("login failed: %s %s", providerErr, query.Get("error_description")))
+		return
+	}
+
+	// The state must be the one issued to this browser, so a login started by someone else cannot be completed here.
+	state := query.Get("state")
+
+	cookie, err := r.Cookie(stateCookieName)
+	if err != nil || state == "" || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) != 1 {
+		api.SendBadRequestError(w, r, "login state does not match the browser session")
+		return
+	}
+
+	http.SetCookie(w, stateCookie(cfg.RedirectURL, "", -1))
+
+	login, ok := p.logins.take(state)
+	if !ok {
+		api.SendBadRequestError(w, r, "login state is unknown or expired")
+		return
+	}
+
+	code := query.Get("code")
+	if code == "" {
+		api.SendBadRequestError(w, r, "authorization code is missing")
+		return
+	}
+
+	idToken, err := p.exchangeCode(r, cfg.ClientID, cfg.ClientSecret, cfg.RedirectURL, code, login.verifier)
+	if err != nil {
+		api.SendError(w, r, err)
+		return
+	}
+
+	claims, err := p.verifyClaims(r.Context(), idToken)
+	if err != nil {
+		api.SendError(w, r, unauthorized("invalid ID token: %s", err))
+		return
+	}
+
+	// The nonce binds the ID token to this login, so a token issued for another login cannot be replayed.
+	if nonce, _ := claims["nonce"].(string); subtle.ConstantTimeCompare([]byte(nonce), []byte(login.nonce)) != 1 {
+		api.SendError(w, r, unauthorized("invalid ID token: nonce does not match the login"))
+		return
+	}
+
+	name, role, err := identityFromClaims(cfg, claims)
+	if err != nil {
+		api.SendError(w, r, unauthorized("invalid ID token: %s", err))
+		return
+	}
+
+	if cfg.UIURL == "" {
+		if err := api.WriteJSON(w, http.StatusOK, LoginResponse{IDToken: idToken, Name: name, Role: string(role)}); err != nil {
+			api.SendError(w, r, err)
+		}
+
+		return
+	}
+
+	// The token is passed in the fragment, so it is not sent to servers and not written to access logs.
+	fragment := url.Values{}
+	fragment.Set("token", idToken)
+
+	http.Redirect(w, r, strings.TrimSuffix(cfg.UIURL, "#")+"#"+fragment.Encode(), http.StatusFound)
+}
+
+func (p *Provider) exchangeCode(r *http.Request, clientID, clientSecret, redirectURL, code, verifier string) (string, error) {
+	metadata, err := p.discover(r.Context())
+	if err != nil {
+		return "", err
+	}
+
+	if metadata.TokenEndpoint == "" {
+		return "", errors.New("discovery document of the OpenID Connect provider does not define token_endpoint")
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", redirectURL)
+	form.Set("client_id", clientID)
+	form.Set("code_verifier", verifier)
+
+	if clientSecret != "" {
+		form.Set("client_secret", clientSecret)
+	}
+
+	request, err := http.NewRequestWithContext(r.Context(), http.MethodPost, metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+
+	response, err := p.client.Do(request)
+	if err != nil {
+		return "", fmt.Errorf("failed to exchange the authorization code: %w", err)
+	}
+
+	defer func() { _ = response.Body.Close() }()
+
+	tokens := tokenResponse{}
+
+	if err := json.NewDecoder(response.Body).Decode(&tokens); err != nil {
+		return "", fmt.Errorf("failed to decode the token response: %w", err)
+	}
+
+	if response.StatusCode != http.StatusOK || tokens.Error != "" {
+		return "", unauthorized("failed to exchange the authorization code: %s %s", tokens.Error, tokens.ErrorDescription)
+	}
+
+	if tokens.IDToken == "" {
+		return "", errors.New("token response does not contain id_token")
+	}
+
+	return tokens.IDToken, nil
+}
+
+// stateCookie builds the cookie keeping the login state. The cookie is sent only to the callback.
+// A negative maxAge removes the cookie.
+func stateCookie(redirectURL, state string, maxAge int) *http.Cookie {
+	cookie := &http.Cookie{
+		Name:     stateCookieName,
+		Value:    state,
+		Path:     "/",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+
+	if callbackURL, err := url.Parse(redirectURL); err == nil {
+		if callbackURL.Path != "" {
+			cookie.Path = callbackURL.Path
+		}
+
+		cookie.Secure = callbackURL.Scheme == "https"
+	}
+
+	return cookie
+}
+
+func unauthorized(format string, args ...interface{}) models.Error {
+	return models.Error{Code: models.ErrCodeUnauthorized, Message: strings.TrimSpace(fmt.Sprintf(format, args...))}
+}
+
+func withQuery(endpoint string, params url.Values) string {
+	separator := "?"
+	if strings.Contains(endpoint, "?") {
+		separator = "&"
+	}
+
+	return endpoint + separator + params.Encode()
+}
+
+func randomString() (string, error) {
+	data := make([]byte, randomLength)
+
+	if _, err := rand.Read(data); err != nil {
+		return "", fmt.Errorf("failed to generate a random value: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
@@ -0,0 +1,286 @@
+/*
+2022 © Postgres.ai
+*/
+
+// Package oidc authenticates users of the API and the embedded UI with an OpenID Connect provider.
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+
+	"gitlab.com/postgres-ai/database-lab/v3/internal/srv/config"
+	"gitlab.com/postgres-ai/database-lab/v3/internal/srv/mw"
+)
+
+const (
+	discoveryPath = "/.well-known/openid-configuration"
+
+	defaultUsernameClaim = "preferred_username"
+	defaultGroupsClaim   = "groups"
+
+	// namePrefix distinguishes users of the provider from the owners of the configured tokens.
+	namePrefix = "oidc:"
+
+	requestTimeout = 10 * time.Second
+)
+
+// signingMethods lists accepted signature algorithms. Symmetric algorithms are not accepted,
+// so a token cannot be forged with a public key used as a shared secret.
+var signingMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+
+// rolePriority orders roles from the most privileged one.
+var rolePriority = []mw.Role{mw.RoleAdmin, mw.RoleCloneUser, mw.RoleViewer}
+
+// errDisabled means that no OpenID Connect provider is configured.
+var errDisabled = errors.New("OpenID Connect is not configured")
+
+// providerMetadata contains the endpoints of the provider published in its discovery document.
+type providerMetadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Provider verifies ID tokens issued by an OpenID Connect provider and maps groups of users to roles.
+type Provider struct {
+	mu       sync.Mutex
+	cfg      config.OIDCConfig
+	client   *http.Client
+	metadata *providerMetadata
+	keys     *keySet
+	logins   *loginStore
+}
+
+var _ mw.IdentityVerifier = (*Provider)(nil)
+
+// NewProvider creates a new provider. The discovery document and keys are fetched on the first use,
+// so an unavailable provider does not prevent the engine from starting.
+func NewProvider(cfg config.OIDCConfig) *Provider {
+	client := &http.Client{Timeout: requestTimeout}
+
+	return &Provider{
+		cfg:    cfg,
+		client: client,
+		keys:   newKeySet(client),
+		logins: newLoginStore(),
+	}
+}
+
+// ValidateConfig checks the OpenID Connect configuration.
+func ValidateConfig(cfg config.OIDCConfig) error {
+	if !cfg.Enabled() {
+		return nil
+	}
+
+	if cfg.ClientID == "" {
+		return errors.New(`"server.oidc.clientID" must be defined`)
+	}
+
+	for group, role := range cfg.GroupRoles {
+		if !mw.IsValidRole(mw.Role(role)) {
+			return fmt.Errorf("group %q is mapped to an unknown role %q", group, role)
+		}
+	}
+
+	if cfg.DefaultRole != "" && !mw.IsValidRole(mw.Role(cfg.DefaultRole)) {
+		return fmt.Errorf("unknown default role %q", cfg.DefaultRole)
+	}
+
+	return nil
+}
+
+// Reload applies the new configuration. Cached keys are dropped if the issuer changes.
+func (p *Provider) Reload(cfg config.OIDCConfig) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if cfg.Issuer != p.cfg.Issuer {
+		p.metadata = nil
+		p.keys = newKeySet(p.client)
+	}
+
+	p.cfg = cfg
+}
+
+func (p *Provider) config() config.OIDCConfig {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.cfg
+}
+
+// VerifyIdentity validates the ID token and returns the name and the role of the user.
+func (p *Provider) VerifyIdentity(ctx context.Context, rawToken string) (string, mw.Role, error) {
+	claims, err := p.verifyClaims(ctx, rawToken)
+	if err != nil {
+		return "", "", err
+	}
+
+	return identityFromClaims(p.config(), claims)
+}
+
+// verifyClaims checks the signature, the expiration time, the issuer, and the audience of the ID token.
+func (p *Provider) verifyClaims(ctx context.Context, rawToken string) (jwt.MapClaims, error) {
+	cfg := p.config()
+
+	if !cfg.Enabled() {
+		return nil, errDisabled
+	}
+
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	keys := p.keys
+	p.mu.Unlock()
+
+	claims := jwt.MapClaims{}
+	parser := jwt.NewParser(jwt.WithValidMethods(signingMethods))
+
+	if _, err := parser.ParseWithClaims(rawToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return keys.key(ctx, metadata.JWKSURI, kid)
+	}); err != nil {
+		return nil, fmt.Errorf("invalid token: %w", err)
+	}
+
+	if !claims.VerifyExpiresAt(time.Now().Unix(), true) {
+		return nil, errors.New("token has no expiration time")
+	}
+
+	if !claims.VerifyIssuer(strings.TrimSuffix(cfg.Issuer, "/"), true) && !claims.VerifyIssuer(cfg.Issuer, true) {
+		return nil, errors.New("unexpected token issuer")
+	}
+
+	if !claims.VerifyAudience(cfg.ClientID, true) {
+		return nil, errors.New("token is issued for another client")
+	}
+
+	return claims, nil
+}
+
+// identityFromClaims defines the name of the user and maps the groups of the user to the most privileged role.
+// Names are prefixed, so users of the provider cannot pass for the configured tokens.
+func identityFromClaims(cfg config.OIDCConfig, claims jwt.MapClaims) (string, mw.Role, error) {
+	usernameClaim := cfg.UsernameClaim
+	if usernameClaim == "" {
+		usernameClaim = defaultUsernameClaim
+	}
+
+	name, _ := claims[usernameClaim].(string)
+	if name == "" {
+		name, _ = claims["sub"].(string)
+	}
+
+	if name == "" {
+		return "", "", errors.New("token does not identify the user")
+	}
+
+	groupsClaim := cfg.GroupsClaim
+	if groupsClaim == "" {
+		groupsClaim = defaultGroupsClaim
+	}
+
+	roles := make(map[mw.Role]struct{})
+
+	for _, group := range claimStrings(claims[groupsClaim]) {
+		if role, ok := cfg.GroupRoles[group]; ok {
+			roles[mw.Role(role)] = struct{}{}
+		}
+	}
+
+	for _, role := range rolePriority {
+		if _, ok := roles[role]; ok {
+			return namePrefix + name, role, nil
+		}
+	}
+
+	if cfg.DefaultRole != "" {
+		return namePrefix + name, mw.Role(cfg.DefaultRole), nil
+	}
+
+	return "", "", fmt.Errorf("no role is mapped to the groups of user %q", name)
+}
+
+// claimStrings reads a claim that may contain either a string or a list of strings.
+func claimStrings(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+
+	case []interface{}:
+		values := make([]string, 0, len(v))
+
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+
+		return values
+	}
+
+	return nil
+}
+
+// discover fetches the discovery document of the provider once.
+func (p *Provider) discover(ctx context.Context) (*providerMetadata, error) {
+	p.mu.Lock()
+	metadata := p.metadata
+	issuer := p.cfg.Issuer
+	p.mu.Unlock()
+
+	if metadata != nil {
+		return metadata, nil
+	}
+
+	metadata = &providerMetadata{}
+
+	if err := getJSON(ctx, p.client, strings.TrimSuffix(issuer, "/")+discoveryPath, metadata); err != nil {
+		return nil, fmt.Errorf("failed to discover the OpenID Connect provider: %w", err)
+	}
+
+	if metadata.JWKSURI == "" {
+		return nil, errors.New("discovery document of the OpenID Connect provider does not define jwks_uri")
+	}
+
+	p.mu.Lock()
+	if p.cfg.Issuer == issuer {
+		p.metadata = metadata
+	}
+	p.mu.Unlock()
+
+	return metadata, nil
+}
+
+func getJSON(ctx context.Context, client *http.Client, url string, target interface{}) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = response.Body.Close() }()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code of %s: %d", url, response.StatusCode)
+	}
+
+	return json.NewDecoder(response.Body).Decode(target)
+}
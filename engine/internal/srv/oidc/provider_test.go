@@ -0,0 +1,489 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/postgres-ai/database-lab/v3/internal/srv/config"
+	"gitlab.com/postgres-ai/database-lab/v3/internal/srv/mw"
+)
+
+const testClientID = "dblab"
+
+// testIssuer is a local OpenID Connect provider.
+type testIssuer struct {
+	t      *testing.T
+	server *httptest.Server
+
+	mu        sync.Mutex
+	keys      map[string]*rsa.PrivateKey
+	jwksCalls int
+	jwksDelay time.Duration
+	codes     map[string]issuedCode
+}
+
+// issuedCode keeps the PKCE challenge and the nonce of the login the code is issued for.
+type issuedCode struct {
+	challenge string
+	nonce     string
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	t.Helper()
+
+	issuer := &testIssuer{t: t, keys: make(map[string]*rsa.PrivateKey), codes: make(map[string]issuedCode)}
+	issuer.addKey("key-1")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(discoveryPath, func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(providerMetadata{
+			Issuer:                issuer.server.URL,
+			AuthorizationEndpoint: issuer.server.URL + "/authorize",
+			TokenEndpoint:         issuer.server.URL + "/token",
+			JWKSURI:               issuer.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", issuer.serveKeys)
+	mux.HandleFunc("/token", issuer.serveToken)
+
+	issuer.server = httptest.NewServer(mux)
+	t.Cleanup(issuer.server.Close)
+
+	return issuer
+}
+
+func (i *testIssuer) addKey(kid string) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(i.t, err)
+
+	i.mu.Lock()
+	i.keys[kid] = key
+	i.mu.Unlock()
+}
+
+func (i *testIssuer) serveKeys(w http.ResponseWriter, _ *http.Request) {
+	i.mu.Lock()
+	i.jwksCalls++
+	delay := i.jwksDelay
+	i.mu.Unlock()
+
+	// The delay simulates a slow provider.
+	time.Sleep(delay)
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	set := jsonWebKeySet{}
+
+	for kid, key := range i.keys {
+		set.Keys = append(set.Keys, jsonWebKey{
+			Kty: "RSA",
+			Kid: kid,
+			Use: "sig",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		})
+	}
+
+	// Encryption keys are skipped.
+	set.Keys = append(set.Keys, jsonWebKey{Kty: "RSA", Kid: "enc", Use: "enc"})
+
+	_ = json.NewEncoder(w).Encode(set)
+}
+
+// serveToken exchanges codes issued by issueCode and checks the PKCE verifier.
+func (i *testIssuer) serveToken(w http.ResponseWriter, r *http.Request) {
+	require.NoError(i.t, r.ParseForm())
+
+	i.mu.Lock()
+	issued, ok := i.codes[r.PostForm.Get("code")]
+	i.mu.Unlock()
+
+	verifierHash := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+
+	if !ok || issued.challenge != base64.RawURLEncoding.EncodeToString(verifierHash[:]) || r.PostForm.Get("client_id") != testClientID {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(tokenResponse{Error: "invalid_grant"})
+
+		return
+	}
+
+	claims := i.claims("jdoe", []string{"dba"})
+
+	if issued.nonce != "" {
+		claims["nonce"] = issued.nonce
+	}
+
+	_ = json.NewEncoder(w).Encode(tokenResponse{IDToken: i.sign("key-1", claims)})
+}
+
+func (i *testIssuer) issueCode(code, challenge, nonce string) {
+	i.mu.Lock()
+	i.codes[code] = issuedCode{challenge: challenge, nonce: nonce}
+	i.mu.Unlock()
+}
+
+func (i *testIssuer) claims(username string, groups []string) jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":                i.server.URL,
+		"aud":                testClientID,
+		"sub":                "user-id",
+		"preferred_username": username,
+		"groups":             groups,
+		"exp":                time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func (i *testIssuer) sign(kid string, claims jwt.MapClaims) string {
+	i.mu.Lock()
+	key := i.keys[kid]
+	i.mu.Unlock()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+
+	signed, err := token.SignedString(key)
+	require.NoError(i.t, err)
+
+	return signed
+}
+
+func (i *testIssuer) config() config.OIDCConfig {
+	return config.OIDCConfig{
+		Issuer:      i.server.URL,
+		ClientID:    testClientID,
+		RedirectURL: "https://dblab.example.com/api/auth/oidc/callback",
+		GroupRoles:  map[string]string{"dba": "admin", "developers": "clone-user"},
+	}
+}
+
+func TestVerifyIdentity(t *testing.T) {
+	issuer := newTestIssuer(t)
+	provider := NewProvider(issuer.config())
+
+	expired := issuer.claims("jdoe", []string{"dba"})
+	expired["exp"] = time.Now().Add(-time.Minute).Unix()
+
+	noExpiration := issuer.claims("jdoe", []string{"dba"})
+	delete(noExpiration, "exp")
+
+	otherClient := issuer.claims("jdoe", []string{"dba"})
+	otherClient["aud"] = "other"
+
+	otherIssuer := issuer.claims("jdoe", []string{"dba"})
+	otherIssuer["iss"] = "https://issuer.example.com"
+
+	noUsername := issuer.claims("", []string{"developers"})
+
+	testCases := []struct {
+		name   string
+		claims jwt.MapClaims
+		user   string
+		role   mw.Role
+		valid  bool
+	}{
+		{name: "admin", claims: issuer.claims("jdoe", []string{"developers", "dba"}), user: "oidc:jdoe", role: mw.RoleAdmin, valid: true},
+		{name: "clone user", claims: issuer.claims("jdoe", []string{"developers"}), user: "oidc:jdoe", role: mw.RoleCloneUser, valid: true},
+		{name: "subject as name", claims: noUsername, user: "oidc:user-id", role: mw.RoleCloneUser, valid: true},
+		{name: "unknown groups", claims: issuer.claims("jdoe", []string{"marketing"})},
+		{name: "expired", claims: expired},
+		{name: "no expiration", claims: noExpiration},
+		{name: "other client", claims: otherClient},
+		{name: "other issuer", claims: otherIssuer},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			user, role, err := provider.VerifyIdentity(context.Background(), issuer.sign("key-1", tc.claims))
+
+			if !tc.valid {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.user, user)
+			assert.Equal(t, tc.role, role)
+		})
+	}
+}
+
+func TestVerifyIdentityRejectsForgedTokens(t *testing.T) {
+	issuer := newTestIssuer(t)
+	provider := NewProvider(issuer.config())
+
+	hmacToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, issuer.claims("jdoe", []string{"dba"})).SignedString([]byte("secret"))
+	require.NoError(t, err)
+
+	_, _, err = provider.VerifyIdentity(context.Background(), hmacToken)
+	assert.Error(t, err)
+
+	foreignKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	foreignToken := jwt.NewWithClaims(jwt.SigningMethodRS256, issuer.claims("jdoe", []string{"dba"}))
+	foreignToken.Header["kid"] = "key-1"
+
+	signed, err := foreignToken.SignedString(foreignKey)
+	require.NoError(t, err)
+
+	_, _, err = provider.VerifyIdentity(context.Background(), signed)
+	assert.Error(t, err)
+}
+
+func TestVerifyIdentityDefaultRole(t *testing.T) {
+	issuer := newTestIssuer(t)
+
+	cfg := issuer.config()
+	cfg.DefaultRole = "viewer"
+	cfg.GroupsClaim = "roles"
+
+	provider := NewProvider(cfg)
+
+	claims := issuer.claims("jdoe", nil)
+	claims["roles"] = "developers"
+
+	_, role, err := provider.VerifyIdentity(context.Background(), issuer.sign("key-1", claims))
+	require.NoError(t, err)
+	assert.Equal(t, mw.RoleCloneUser, role)
+
+	_, role, err = provider.VerifyIdentity(context.Background(), issuer.sign("key-1", issuer.claims("jdoe", nil)))
+	require.NoError(t, err)
+	assert.Equal(t, mw.RoleViewer, role)
+}
+
+func TestKeyRotation(t *testing.T) {
+	issuer := newTestIssuer(t)
+	provider := NewProvider(issuer.config())
+
+	_, _, err := provider.VerifyIdentity(context.Background(), issuer.sign("key-1", issuer.claims("jdoe", []string{"dba"})))
+	require.NoError(t, err)
+
+	_, _, err = provider.VerifyIdentity(context.Background(), issuer.sign("key-1", issuer.claims("jdoe", []string{"dba"})))
+	require.NoError(t, err)
+	assert.Equal(t, 1, issuer.jwksCalls, "keys must be cached")
+
+	issuer.addKey("key-2")
+
+	// Keys are refreshed at most once per interval.
+	provider.keys.fetchedAt = time.Now().Add(-keysRefreshInterval)
+
+	_, _, err = provider.VerifyIdentity(context.Background(), issuer.sign("key-2", issuer.claims("jdoe", []string{"dba"})))
+	require.NoError(t, err)
+	assert.Equal(t, 2, issuer.jwksCalls)
+
+	_, _, err = provider.VerifyIdentity(context.Background(), issuer.sign("key-2", issuer.claims("jdoe", []string{"dba"})))
+	require.NoError(t, err)
+
+	issuer.addKey("key-3")
+
+	_, _, err = provider.VerifyIdentity(context.Background(), issuer.sign("key-3", issuer.claims("jdoe", []string{"dba"})))
+	assert.Error(t, err)
+	assert.Equal(t, 2, issuer.jwksCalls, "unknown keys must not cause refreshing on every request")
+}
+
+func TestConcurrentKeyRefresh(t *testing.T) {
+	issuer := newTestIssuer(t)
+	provider := NewProvider(issuer.config())
+
+	_, _, err := provider.VerifyIdentity(context.Background(), issuer.sign("key-1", issuer.claims("jdoe", []string{"dba"})))
+	require.NoError(t, err)
+
+	issuer.addKey("key-2")
+
+	issuer.mu.Lock()
+	issuer.jwksDelay = 500 * time.Millisecond
+	issuer.mu.Unlock()
+
+	provider.keys.mu.Lock()
+	provider.keys.fetchedAt = time.Now().Add(-keysRefreshInterval)
+	provider.keys.mu.Unlock()
+
+	const requests = 5
+
+	wg := sync.WaitGroup{}
+	errs := make(chan error, requests)
+
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			_, _, err := provider.VerifyIdentity(context.Background(), issuer.sign("key-2", issuer.claims("jdoe", []string{"dba"})))
+			errs <- err
+		}()
+	}
+
+	// Wait for the refresh to start, cached keys must be served while it is in progress.
+	require.Eventually(t, func() bool {
+		issuer.mu.Lock()
+		defer issuer.mu.Unlock()
+
+		return issuer.jwksCalls == 2
+	}, time.Second, 10*time.Millisecond)
+
+	startedAt := time.Now()
+
+	_, _, err = provider.VerifyIdentity(context.Background(), issuer.sign("key-1", issuer.claims("jdoe", []string{"dba"})))
+	require.NoError(t, err)
+	assert.Less(t, time.Since(startedAt), 250*time.Millisecond)
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+
+	assert.Equal(t, 2, issuer.jwksCalls, "concurrent requests must share the refresh")
+}
+
+func TestLoginFlow(t *testing.T) {
+	issuer := newTestIssuer(t)
+
+	cfg := issuer.config()
+	cfg.UIURL = "https://dblab.example.com/instance"
+
+	provider := NewProvider(cfg)
+
+	rec := httptest.NewRecorder()
+	provider.Login(rec, httptest.NewRequest(http.MethodGet, "/auth/oidc/login", nil))
+	require.Equal(t, http.StatusFound, rec.Code)
+
+	location, err := url.Parse(rec.Header().Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, issuer.server.URL+"/authorize", location.Scheme+"://"+location.Host+location.Path)
+
+	params := location.Query()
+	assert.Equal(t, testClientID, params.Get("client_id"))
+	assert.Equal(t, cfg.RedirectURL, params.Get("redirect_uri"))
+	assert.Equal(t, "openid profile email", params.Get("scope"))
+	assert.Equal(t, "S256", params.Get("code_challenge_method"))
+	assert.NotEmpty(t, params.Get("nonce"))
+
+	cookies := rec.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, stateCookieName, cookies[0].Name)
+	assert.Equal(t, params.Get("state"), cookies[0].Value)
+	assert.Equal(t, "/api/auth/oidc/callback", cookies[0].Path)
+	assert.True(t, cookies[0].Secure)
+	assert.True(t, cookies[0].HttpOnly)
+
+	issuer.issueCode("auth-code", params.Get("code_challenge"), params.Get("nonce"))
+
+	callback := func(state string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/auth/oidc/callback?code=auth-code&state="+url.QueryEscape(state), nil)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+
+		rec := httptest.NewRecorder()
+		provider.Callback(rec, req)
+
+		return rec
+	}
+
+	// An unknown state is rejected.
+	assert.Equal(t, http.StatusBadRequest, callback("unknown", &http.Cookie{Name: stateCookieName, Value: "unknown"}).Code)
+
+	// The state issued to another browser is rejected.
+	assert.Equal(t, http.StatusBadRequest, callback(params.Get("state"), nil).Code)
+	assert.Equal(t, http.StatusBadRequest, callback(params.Get("state"), &http.Cookie{Name: stateCookieName, Value: "other"}).Code)
+
+	rec = callback(params.Get("state"), cookies[0])
+	require.Equal(t, http.StatusFound, rec.Code)
+
+	redirect, err := url.Parse(rec.Header().Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, "/instance", redirect.Path)
+
+	fragment, err := url.ParseQuery(redirect.Fragment)
+	require.NoError(t, err)
+
+	user, role, err := provider.VerifyIdentity(context.Background(), fragment.Get("token"))
+	require.NoError(t, err)
+	assert.Equal(t, "oidc:jdoe", user)
+	assert.Equal(t, mw.RoleAdmin, role)
+
+	// The state is one-time.
+	assert.Equal(t, http.StatusBadRequest, callback(params.Get("state"), cookies[0]).Code)
+}
+
+func TestLoginNonce(t *testing.T) {
+	issuer := newTestIssuer(t)
+	provider := NewProvider(issuer.config())
+
+	rec := httptest.NewRecorder()
+	provider.Login(rec, httptest.NewRequest(http.MethodGet, "/auth/oidc/login", nil))
+	require.Equal(t, http.StatusFound, rec.Code)
+
+	location, err := url.Parse(rec.Header().Get("Location"))
+	require.NoError(t, err)
+
+	params := location.Query()
+
+	// The provider returns an ID token issued for another login.
+	issuer.issueCode("auth-code", params.Get("code_challenge"), "other-nonce")
+
+	req := httptest.NewRequest(http.MethodGet, "/auth/oidc/callback?code=auth-code&state="+url.QueryEscape(params.Get("state")), nil)
+	req.AddCookie(rec.Result().Cookies()[0])
+
+	rec = httptest.NewRecorder()
+	provider.Callback(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestLoginStoreLimit(t *testing.T) {
+	store := newLoginStore()
+
+	for i := 0; i < maxPendingLogins; i++ {
+		require.NoError(t, store.add(fmt.Sprintf("state-%d", i), pendingLogin{verifier: "verifier"}))
+	}
+
+	assert.ErrorIs(t, store.add("state", pendingLogin{verifier: "verifier"}), errTooManyLogins)
+
+	// Expired logins free the store.
+	for state, login := range store.logins {
+		login.expiresAt = time.Now().Add(-time.Minute)
+		store.logins[state] = login
+	}
+
+	assert.NoError(t, store.add("state", pendingLogin{verifier: "verifier"}))
+	assert.Len(t, store.logins, 1)
+}
+
+func TestLoginDisabled(t *testing.T) {
+	provider := NewProvider(config.OIDCConfig{})
+
+	rec := httptest.NewRecorder()
+	provider.Login(rec, httptest.NewRequest(http.MethodGet, "/auth/oidc/login", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	_, _, err := provider.VerifyIdentity(context.Background(), "header.payload.signature")
+	assert.Error(t, err)
+}
+
+func TestValidateConfig(t *testing.T) {
+	assert.NoError(t, ValidateConfig(config.OIDCConfig{}))
+	assert.NoError(t, ValidateConfig(config.OIDCConfig{Issuer: "https://issuer", ClientID: "dblab"}))
+	assert.Error(t, ValidateConfig(config.OIDCConfig{Issuer: "https://issuer"}))
+	assert.Error(t, ValidateConfig(config.OIDCConfig{Issuer: "https://issuer", ClientID: "dblab", GroupRoles: map[string]string{"a": "root"}}))
+	assert.Error(t, ValidateConfig(config.OIDCConfig{Issuer: "https://issuer", ClientID: "dblab", DefaultRole: "root"}))
+}
@@ -14,12 +14,16 @@ import (
 	"github.com/jackc/pgtype/pgxtype"
 	"github.com/pkg/errors"
 
+	"gitlab.com/postgres-ai/database-lab/v3/internal/audit"
+	"gitlab.com/postgres-ai/database-lab/v3/internal/cloning"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/estimator"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/observer"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/retrieval/engine/postgres/tools/activity"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/srv/api"
+	"gitlab.com/postgres-ai/database-lab/v3/internal/srv/mw"
 	wsPackage "gitlab.com/postgres-ai/database-lab/v3/internal/srv/ws"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/telemetry"
+	"gitlab.com/postgres-ai/database-lab/v3/internal/webhooks"
 
 	"gitlab.com/postgres-ai/database-lab/v3/pkg/client/dblabapi/types"
 	"gitlab.com/postgres-ai/database-lab/v3/pkg/client/platform"
@@ -34,7 +38,11 @@ const (
 )
 
 func (s *Server) getInstanceStatus(w http.ResponseWriter, r *http.Request) {
-	if err := api.WriteJSON(w, http.StatusOK, s.instanceStatus()); err != nil {
+	instanceStatus := s.instanceStatus()
+	instanceStatus.Cloning.Quotas = s.Cloning.GetQuotas(mw.IdentityFromContext(r.Context()).Name)
+	instanceStatus.Cloning.Clones = ownedClones(r, instanceStatus.Cloning.Clones)
+
+	if err := api.WriteJSON(w, http.StatusOK, instanceStatus); err != nil {
 		api.SendError(w, r, err)
 		return
 	}
@@ -61,7 +69,7 @@ func (s *Server) retrievalState(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) jobActivity(ctx context.Context) *models.Activity {
-	currentJob := s.Retrieval.State.CurrentJob
+	currentJob := s.Retrieval.State.ActiveJob()
 	if currentJob == nil {
 		return nil
 	}
@@ -111,6 +119,177 @@ func (s *Server) getSnapshots(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (s *Server) createSnapshot(w http.ResponseWriter, r *http.Request) {
+	var snapshotRequest types.SnapshotCreateRequest
+	if err := api.ReadJSON(r, &snapshotRequest); err != nil {
+		api.SendBadRequestError(w, r, err.Error())
+		return
+	}
+
+	poolName := snapshotRequest.PoolName
+
+	if poolName == "" {
+		fsm := s.pm.First()
+		if fsm == nil {
+			api.SendBadRequestError(w, r, "no available pools")
+			return
+		}
+
+		poolName = fsm.Pool().Name
+	}
+
+	if err := s.Retrieval.TakeSnapshot(poolName); err != nil {
+		api.SendError(w, r, err)
+		return
+	}
+
+	response := types.SnapshotTakeResponse{
+		Status:  string(models.Snapshotting),
+		Message: "Taking a snapshot has been started, check the retrieval status and the list of snapshots for the result",
+	}
+
+	if err := api.WriteJSON(w, http.StatusAccepted, response); err != nil {
+		api.SendError(w, r, err)
+		return
+	}
+
+	log.Dbg(fmt.Sprintf("Taking a snapshot of pool %s has been started", poolName))
+}
+
+func (s *Server) createPITRSnapshot(w http.ResponseWriter, r *http.Request) {
+	var pitrRequest types.SnapshotPITRRequest
+	if err := api.ReadJSON(r, &pitrRequest); err != nil {
+		api.SendBadRequestError(w, r, err.Error())
+		return
+	}
+
+	targetTime, err := time.Parse(time.RFC3339, pitrRequest.TargetTime)
+	if err != nil {
+		api.SendBadRequestError(w, r, fmt.Sprintf("invalid target time, expected RFC 3339 format: %v", err))
+		return
+	}
+
+	name := pitrRequest.Name
+	if name == "" {
+		name = "point-in-time snapshot as of " + targetTime.Format(time.RFC3339)
+	}
+
+	onSnapshot := func(snapshotID, baseSnapshotID string) {
+		if _, err := s.Cloning.SetSnapshotOrigin(snapshotID, baseSnapshotID, name); err != nil {
+			log.Err(fmt.Sprintf("Failed to set the origin of snapshot %s: %v", snapshotID, err))
+			return
+		}
+
+		log.Dbg(fmt.Sprintf("Snapshot %s has been recovered to %s from snapshot %s", snapshotID, targetTime, baseSnapshotID))
+	}
+
+	if err := s.Retrieval.TakePITRSnapshot(targetTime, onSnapshot); err != nil {
+		api.SendError(w, r, err)
+		return
+	}
+
+	response := types.SnapshotTakeResponse{
+		Status:  string(models.Snapshotting),
+		Message: "Recovering the data to the target time has been started, check the retrieval status and the list of snapshots for the result",
+	}
+
+	if err := api.WriteJSON(w, http.StatusAccepted, response); err != nil {
+		api.SendError(w, r, err)
+		return
+	}
+}
+
+func (s *Server) destroySnapshot(w http.ResponseWriter, r *http.Request) {
+	snapshotID := mux.Vars(r)["id"]
+
+	if snapshotID == "" {
+		api.SendBadRequestError(w, r, "ID must not be empty")
+		return
+	}
+
+	if err := s.Cloning.DestroySnapshot(snapshotID); err != nil {
+		sendCloningError(w, r, errors.Wrap(err, "failed to destroy snapshot"))
+		return
+	}
+
+	log.Dbg(fmt.Sprintf("Snapshot %s has been destroyed", snapshotID))
+}
+
+func (s *Server) patchSnapshot(w http.ResponseWriter, r *http.Request) {
+	snapshotID := mux.Vars(r)["id"]
+
+	if snapshotID == "" {
+		api.SendBadRequestError(w, r, "ID must not be empty")
+		return
+	}
+
+	var patchSnapshot types.SnapshotUpdateRequest
+	if err := api.ReadJSON(r, &patchSnapshot); err != nil {
+		api.SendBadRequestError(w, r, err.Error())
+		return
+	}
+
+	snapshot, err := s.Cloning.ProtectSnapshot(snapshotID, patchSnapshot.Protected)
+	if err != nil {
+		sendCloningError(w, r, errors.Wrap(err, "failed to update snapshot"))
+		return
+	}
+
+	if err := api.WriteJSON(w, http.StatusOK, snapshot); err != nil {
+		api.SendError(w, r, err)
+		return
+	}
+}
+
+func (s *Server) getClones(w http.ResponseWriter, r *http.Request) {
+	selector, err := cloning.ParseLabelSelector(r.URL.Query()["label"])
+	if err != nil {
+		sendCloningError(w, r, err)
+		return
+	}
+
+	if err := api.WriteJSON(w, http.StatusOK, ownedClones(r, s.Cloning.GetClonesBySelector(selector))); err != nil {
+		api.SendError(w, r, err)
+		return
+	}
+}
+
+func (s *Server) destroyClones(w http.ResponseWriter, r *http.Request) {
+	selector, err := cloning.ParseLabelSelector(r.URL.Query()["label"])
+	if err != nil {
+		sendCloningError(w, r, err)
+		return
+	}
+
+	result, err := s.Cloning.DestroyClonesBySelector(selector, mw.IdentityFromContext(r.Context()).Owner())
+	if err != nil {
+		sendCloningError(w, r, errors.Wrap(err, "failed to destroy clones"))
+		return
+	}
+
+	actor := mw.IdentityFromContext(r.Context()).Name
+
+	for _, cloneID := range result.Destroyed {
+		s.Cloning.RecordCloneEvent(models.CloneEvent{
+			CloneID: cloneID,
+			Event:   models.CloneEventDestroyRequested,
+			Actor:   actor,
+			Message: "destroyed by the label selector " + r.URL.Query().Get("label"),
+		})
+		s.tm.SendEvent(context.Background(), telemetry.CloneDestroyedEvent, telemetry.CloneDestroyed{
+			ID: util.HashID(cloneID),
+		})
+		s.wh.Emit(webhooks.CloneDestroyedEvent, webhooks.CloneEvent{ID: cloneID})
+	}
+
+	if err := api.WriteJSON(w, http.StatusOK, result); err != nil {
+		api.SendError(w, r, err)
+		return
+	}
+
+	log.Dbg(fmt.Sprintf("Clones %v are being deleted", result.Destroyed))
+}
+
 func (s *Server) createClone(w http.ResponseWriter, r *http.Request) {
 	var cloneRequest *types.CloneCreateRequest
 	if err := api.ReadJSON(r, &cloneRequest); err != nil {
@@ -123,7 +302,7 @@ func (s *Server) createClone(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	newClone, err := s.Cloning.CreateClone(cloneRequest)
+	newClone, err := s.Cloning.CreateClone(cloneRequest, mw.IdentityFromContext(r.Context()).Name)
 	if err != nil {
 		var reqErr *models.Error
 		if errors.As(err, &reqErr) {
@@ -136,6 +315,8 @@ func (s *Server) createClone(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	audit.SetCloneID(r.Context(), newClone.ID)
+
 	if err := api.WriteJSON(w, http.StatusCreated, newClone); err != nil {
 		api.SendError(w, r, err)
 		return
@@ -146,6 +327,7 @@ func (s *Server) createClone(w http.ResponseWriter, r *http.Request) {
 		CloningTime: newClone.Metadata.CloningTime,
 		DSADiff:     util.GetDataFreshness(newClone.Snapshot.DataStateAt.Time),
 	})
+	s.wh.Emit(webhooks.CloneCreatedEvent, webhooks.NewCloneEvent(newClone, ""))
 
 	log.Dbg(fmt.Sprintf("Clone ID=%s is being created", newClone.ID))
 }
@@ -158,14 +340,27 @@ func (s *Server) destroyClone(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !s.authorizeClone(w, r, cloneID) {
+		return
+	}
+
+	s.Cloning.RecordCloneEvent(models.CloneEvent{
+		CloneID: cloneID,
+		Event:   models.CloneEventDestroyRequested,
+		Actor:   mw.IdentityFromContext(r.Context()).Name,
+	})
+
 	if err := s.Cloning.DestroyClone(cloneID); err != nil {
+		s.Cloning.RecordCloneEvent(models.CloneEvent{CloneID: cloneID, Event: models.CloneEventError, Message: err.Error()})
 		api.SendError(w, r, errors.Wrap(err, "failed to destroy clone"))
+
 		return
 	}
 
 	s.tm.SendEvent(context.Background(), telemetry.CloneDestroyedEvent, telemetry.CloneDestroyed{
 		ID: util.HashID(cloneID),
 	})
+	s.wh.Emit(webhooks.CloneDestroyedEvent, webhooks.CloneEvent{ID: cloneID})
 
 	log.Dbg(fmt.Sprintf("Clone ID=%s is being deleted", cloneID))
 }
@@ -178,6 +373,10 @@ func (s *Server) patchClone(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !s.authorizeClone(w, r, cloneID) {
+		return
+	}
+
 	var patchClone types.CloneUpdateRequest
 	if err := api.ReadJSON(r, &patchClone); err != nil {
 		api.SendBadRequestError(w, r, err.Error())
@@ -187,7 +386,7 @@ func (s *Server) patchClone(w http.ResponseWriter, r *http.Request) {
 
 	updatedClone, err := s.Cloning.UpdateClone(cloneID, patchClone)
 	if err != nil {
-		api.SendError(w, r, errors.Wrap(err, "failed to update clone"))
+		sendCloningError(w, r, errors.Wrap(err, "failed to update clone"))
 		return
 	}
 
@@ -211,12 +410,64 @@ func (s *Server) getClone(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !mw.IdentityFromContext(r.Context()).Owns(clone.Owner) {
+		api.SendForbiddenError(w, r)
+		return
+	}
+
 	if err := api.WriteJSON(w, http.StatusOK, clone); err != nil {
 		api.SendError(w, r, err)
 		return
 	}
 }
 
+func (s *Server) getCloneEvents(w http.ResponseWriter, r *http.Request) {
+	cloneID := mux.Vars(r)["id"]
+
+	if cloneID == "" {
+		api.SendBadRequestError(w, r, "ID must not be empty")
+		return
+	}
+
+	events, err := s.Cloning.GetCloneEvents(cloneID)
+	if err != nil {
+		api.SendError(w, r, errors.Wrap(err, "failed to get clone events"))
+		return
+	}
+
+	owner, found := cloneEventsOwner(events)
+
+	if clone, err := s.Cloning.GetClone(cloneID); err == nil {
+		owner, found = clone.Owner, true
+	}
+
+	if !found && len(events) == 0 {
+		api.SendNotFoundError(w, r)
+		return
+	}
+
+	if !mw.IdentityFromContext(r.Context()).Owns(owner) {
+		api.SendForbiddenError(w, r)
+		return
+	}
+
+	if err := api.WriteJSON(w, http.StatusOK, events); err != nil {
+		api.SendError(w, r, err)
+		return
+	}
+}
+
+// cloneEventsOwner finds the owner of a destroyed clone in its history.
+func cloneEventsOwner(events []models.CloneEvent) (string, bool) {
+	for _, event := range events {
+		if event.Event == models.CloneEventCreated {
+			return event.Actor, true
+		}
+	}
+
+	return "", false
+}
+
 func (s *Server) resetClone(w http.ResponseWriter, r *http.Request) {
 	cloneID := mux.Vars(r)["id"]
 
@@ -225,6 +476,10 @@ func (s *Server) resetClone(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !s.authorizeClone(w, r, cloneID) {
+		return
+	}
+
 	var resetOptions types.ResetCloneRequest
 
 	if r.Body != http.NoBody {
@@ -247,6 +502,87 @@ func (s *Server) resetClone(w http.ResponseWriter, r *http.Request) {
 	log.Dbg(fmt.Sprintf("Clone ID=%s is being reset", cloneID))
 }
 
+func (s *Server) createSnapshotFromClone(w http.ResponseWriter, r *http.Request) {
+	cloneID := mux.Vars(r)["id"]
+
+	if cloneID == "" {
+		api.SendBadRequestError(w, r, "ID must not be empty")
+		return
+	}
+
+	if !s.authorizeClone(w, r, cloneID) {
+		return
+	}
+
+	snapshot, err := s.Cloning.CreateSnapshotFromClone(cloneID)
+	if err != nil {
+		sendCloningError(w, r, errors.Wrap(err, "failed to create snapshot"))
+		return
+	}
+
+	audit.SetSnapshotID(r.Context(), snapshot.ID)
+
+	if err := api.WriteJSON(w, http.StatusCreated, snapshot); err != nil {
+		api.SendError(w, r, err)
+		return
+	}
+
+	s.tm.SendEvent(context.Background(), telemetry.SnapshotCreatedEvent, telemetry.SnapshotCreated{})
+	s.wh.Emit(webhooks.SnapshotCreatedEvent, webhooks.SnapshotEvent{IDs: []string{snapshot.ID}, Pool: snapshot.Pool})
+
+	log.Dbg(fmt.Sprintf("Snapshot %s has been created from clone ID=%s", snapshot.ID, cloneID))
+}
+
+// authorizeClone checks if the requester is allowed to manage the clone and sends an error otherwise.
+func (s *Server) authorizeClone(w http.ResponseWriter, r *http.Request, cloneID string) bool {
+	identity := mw.IdentityFromContext(r.Context())
+	if identity.IsAdmin() {
+		return true
+	}
+
+	clone, err := s.Cloning.GetClone(cloneID)
+	if err != nil {
+		api.SendNotFoundError(w, r)
+		return false
+	}
+
+	if !identity.Owns(clone.Owner) {
+		api.SendForbiddenError(w, r)
+		return false
+	}
+
+	return true
+}
+
+// ownedClones filters out clones the requester is not allowed to manage.
+func ownedClones(r *http.Request, clones []*models.Clone) []*models.Clone {
+	identity := mw.IdentityFromContext(r.Context())
+	if identity.IsAdmin() {
+		return clones
+	}
+
+	owned := make([]*models.Clone, 0, len(clones))
+
+	for _, clone := range clones {
+		if identity.Owns(clone.Owner) {
+			owned = append(owned, clone)
+		}
+	}
+
+	return owned
+}
+
+// sendCloningError keeps the code of request errors returned by the cloning service.
+func sendCloningError(w http.ResponseWriter, r *http.Request, err error) {
+	var reqErr *models.Error
+	if errors.As(err, &reqErr) {
+		api.SendError(w, r, *reqErr)
+		return
+	}
+
+	api.SendError(w, r, err)
+}
+
 func (s *Server) startEstimator(w http.ResponseWriter, r *http.Request) {
 	values := r.URL.Query()
 	cloneID := values.Get("clone_id")
@@ -379,6 +715,12 @@ func (s *Server) startObservation(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	audit.SetCloneID(r.Context(), observationRequest.CloneID)
+
+	if !s.authorizeClone(w, r, observationRequest.CloneID) {
+		return
+	}
+
 	clone, err := s.Cloning.GetClone(observationRequest.CloneID)
 	if err != nil {
 		api.SendNotFoundError(w, r)
@@ -428,6 +770,13 @@ func (s *Server) startObservation(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	s.Cloning.RecordCloneEvent(models.CloneEvent{
+		CloneID: clone.ID,
+		Event:   models.CloneEventObservationStarted,
+		Actor:   mw.IdentityFromContext(r.Context()).Name,
+		Message: fmt.Sprintf("observation session %d", platformResponse.SessionID),
+	})
+
 	go func() {
 		if err := observingClone.RunSession(); err != nil {
 			// TODO(akartasov): Update observation (add a request to Platform) with an error.
@@ -454,6 +803,12 @@ func (s *Server) stopObservation(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	audit.SetCloneID(r.Context(), observationRequest.CloneID)
+
+	if !s.authorizeClone(w, r, observationRequest.CloneID) {
+		return
+	}
+
 	observingClone, err := s.Observer.GetObservingClone(observationRequest.CloneID)
 	if err != nil {
 		api.SendNotFoundError(w, r)
@@ -504,6 +859,13 @@ func (s *Server) stopObservation(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	s.Cloning.RecordCloneEvent(models.CloneEvent{
+		CloneID: clone.ID,
+		Event:   models.CloneEventObservationStopped,
+		Actor:   mw.IdentityFromContext(r.Context()).Name,
+		Message: fmt.Sprintf("observation session %d", session.SessionID),
+	})
+
 	sessionID := strconv.FormatUint(session.SessionID, 10)
 
 	logs, err := s.Observer.GetCloneLog(context.TODO(), clone.DB.Port, observingClone)
@@ -542,6 +904,10 @@ func (s *Server) sessionSummaryObservation(w http.ResponseWriter, r *http.Reques
 
 	cloneID := vars["clone_id"]
 
+	if !s.authorizeClone(w, r, cloneID) {
+		return
+	}
+
 	sessionID, err := strconv.ParseUint(vars["session_id"], 10, 64)
 	if err != nil {
 		api.SendBadRequestError(w, r, fmt.Sprintf("invalid session_id: %v", sessionID))
@@ -583,6 +949,10 @@ func (s *Server) downloadArtifact(w http.ResponseWriter, r *http.Request) {
 
 	cloneID := values.Get("clone_id")
 
+	if !s.authorizeClone(w, r, cloneID) {
+		return
+	}
+
 	observingClone, err := s.Observer.GetObservingClone(cloneID)
 	if err != nil || !observingClone.IsExistArtifacts(sessionID) {
 		api.SendNotFoundError(w, r)
@@ -20,9 +20,11 @@ import (
 	"github.com/gorilla/websocket"
 	"github.com/pkg/errors"
 
+	"gitlab.com/postgres-ai/database-lab/v3/internal/audit"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/cloning"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/embeddedui"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/estimator"
+	"gitlab.com/postgres-ai/database-lab/v3/internal/events"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/observer"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/platform"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/provision"
@@ -31,9 +33,11 @@ import (
 	"gitlab.com/postgres-ai/database-lab/v3/internal/srv/api"
 	srvCfg "gitlab.com/postgres-ai/database-lab/v3/internal/srv/config"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/srv/mw"
+	"gitlab.com/postgres-ai/database-lab/v3/internal/srv/oidc"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/srv/ws"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/telemetry"
 	"gitlab.com/postgres-ai/database-lab/v3/internal/validator"
+	"gitlab.com/postgres-ai/database-lab/v3/internal/webhooks"
 	"gitlab.com/postgres-ai/database-lab/v3/pkg/config/global"
 	"gitlab.com/postgres-ai/database-lab/v3/pkg/log"
 	"gitlab.com/postgres-ai/database-lab/v3/pkg/models"
@@ -60,6 +64,11 @@ type Server struct {
 	docker      *client.Client
 	pm          *pool.Manager
 	tm          *telemetry.Agent
+	wh          *webhooks.Service
+	broker      *events.Broker
+	audit       *audit.Logger
+	tlsManager  *tlsManager
+	oidc        *oidc.Provider
 	startedAt   *models.LocalTime
 	re          *regexp.Regexp
 	reloadFn    func(server *Server) error
@@ -76,7 +85,8 @@ type WSService struct {
 func NewServer(cfg *srvCfg.Config, globalCfg *global.Config, engineProps global.EngineProps,
 	dockerClient *client.Client, cloning *cloning.Base, provisioner *provision.Provisioner,
 	retrievalSvc *retrieval.Retrieval, platform *platform.Service, observer *observer.Observer,
-	estimator *estimator.Estimator, pm *pool.Manager, tm *telemetry.Agent, tokenKeeper *ws.TokenKeeper,
+	estimator *estimator.Estimator, pm *pool.Manager, tm *telemetry.Agent, wh *webhooks.Service, broker *events.Broker,
+	auditLog *audit.Logger, tokenKeeper *ws.TokenKeeper,
 	uiManager *embeddedui.UIManager, reloadConfigFn func(server *Server) error) *Server {
 	server := &Server{
 		Config:      cfg,
@@ -93,11 +103,16 @@ func NewServer(cfg *srvCfg.Config, globalCfg *global.Config, engineProps global.
 			tokenKeeper: tokenKeeper,
 			uiManager:   uiManager,
 		},
-		docker:    dockerClient,
-		pm:        pm,
-		tm:        tm,
-		startedAt: &models.LocalTime{Time: time.Now().Truncate(time.Second)},
-		reloadFn:  reloadConfigFn,
+		docker:     dockerClient,
+		pm:         pm,
+		tm:         tm,
+		wh:         wh,
+		broker:     broker,
+		audit:      auditLog,
+		tlsManager: &tlsManager{},
+		oidc:       oidc.NewProvider(cfg.OIDC),
+		startedAt:  &models.LocalTime{Time: time.Now().Truncate(time.Second)},
+		reloadFn:   reloadConfigFn,
 	}
 	server.initLogRegExp()
 
@@ -186,42 +201,73 @@ func attachAPI(r *mux.Router) error {
 func (s *Server) Reload(cfg srvCfg.Config) {
 	*s.Config = cfg
 	s.initLogRegExp()
+	s.tlsManager.reload(cfg.TLS)
+	s.oidc.Reload(cfg.OIDC)
 }
 
 // InitHandlers initializes handler functions of the HTTP server.
 func (s *Server) InitHandlers() {
 	r := mux.NewRouter().StrictSlash(true)
 
-	authMW := mw.NewAuth(s.Config.VerificationToken, s.Platform)
-
-	r.HandleFunc("/status", authMW.Authorized(s.getInstanceStatus)).Methods(http.MethodGet)
-	r.HandleFunc("/snapshots", authMW.Authorized(s.getSnapshots)).Methods(http.MethodGet)
-	r.HandleFunc("/clone", authMW.Authorized(s.createClone)).Methods(http.MethodPost)
-	r.HandleFunc("/clone/{id}", authMW.Authorized(s.destroyClone)).Methods(http.MethodDelete)
-	r.HandleFunc("/clone/{id}", authMW.Authorized(s.patchClone)).Methods(http.MethodPatch)
-	r.HandleFunc("/clone/{id}", authMW.Authorized(s.getClone)).Methods(http.MethodGet)
-	r.HandleFunc("/clone/{id}/reset", authMW.Authorized(s.resetClone)).Methods(http.MethodPost)
-	r.HandleFunc("/observation/start", authMW.Authorized(s.startObservation)).Methods(http.MethodPost)
-	r.HandleFunc("/observation/stop", authMW.Authorized(s.stopObservation)).Methods(http.MethodPost)
-	r.HandleFunc("/observation/summary/{clone_id}/{session_id}", authMW.Authorized(s.sessionSummaryObservation)).Methods(http.MethodGet)
-	r.HandleFunc("/observation/download", authMW.Authorized(s.downloadArtifact)).Methods(http.MethodGet)
+	// ID tokens are accepted only if OpenID Connect is configured on start, so enabling it does not close open access silently.
+	var identityVerifier mw.IdentityVerifier
+	if s.Config.OIDC.Enabled() {
+		identityVerifier = s.oidc
+	}
+
+	authMW := mw.NewAuth(s.Config.VerificationToken, s.Config.Tokens, s.Platform, identityVerifier)
+
+	r.HandleFunc("/status", authMW.Require(mw.ScopeStatusRead, s.getInstanceStatus)).Methods(http.MethodGet)
+	r.HandleFunc("/snapshots", authMW.Require(mw.ScopeSnapshotsRead, s.getSnapshots)).Methods(http.MethodGet)
+	r.HandleFunc("/snapshot", s.audited(authMW.Require(mw.ScopeSnapshotsWrite, s.createSnapshot))).Methods(http.MethodPost)
+	r.HandleFunc("/snapshot/pitr", s.audited(authMW.Require(mw.ScopeSnapshotsWrite, s.createPITRSnapshot))).Methods(http.MethodPost)
+	r.HandleFunc("/snapshot/{id:.+}", s.audited(authMW.Require(mw.ScopeSnapshotsWrite, s.destroySnapshot))).Methods(http.MethodDelete)
+	r.HandleFunc("/snapshot/{id:.+}", s.audited(authMW.Require(mw.ScopeSnapshotsWrite, s.patchSnapshot))).Methods(http.MethodPatch)
+	r.HandleFunc("/clones", authMW.Require(mw.ScopeClonesRead, s.getClones)).Methods(http.MethodGet)
+	r.HandleFunc("/clones", s.audited(authMW.Require(mw.ScopeClonesWrite, s.destroyClones))).Methods(http.MethodDelete)
+	r.HandleFunc("/clone", s.audited(authMW.Require(mw.ScopeClonesWrite, s.createClone))).Methods(http.MethodPost)
+	r.HandleFunc("/clone/{id}", s.audited(authMW.Require(mw.ScopeClonesWrite, s.destroyClone))).Methods(http.MethodDelete)
+	r.HandleFunc("/clone/{id}", s.audited(authMW.Require(mw.ScopeClonesWrite, s.patchClone))).Methods(http.MethodPatch)
+	r.HandleFunc("/clone/{id}", authMW.Require(mw.ScopeClonesRead, s.getClone)).Methods(http.MethodGet)
+	r.HandleFunc("/clone/{id}/events", authMW.Require(mw.ScopeClonesRead, s.getCloneEvents)).Methods(http.MethodGet)
+	r.HandleFunc("/clone/{id}/reset", s.audited(authMW.Require(mw.ScopeClonesWrite, s.resetClone))).Methods(http.MethodPost)
+	r.HandleFunc("/clone/{id}/snapshot", s.audited(authMW.Require(mw.ScopeSnapshotsWrite, s.createSnapshotFromClone))).Methods(http.MethodPost)
+	r.HandleFunc("/branches", authMW.Require(mw.ScopeBranchesRead, s.getBranches)).Methods(http.MethodGet)
+	r.HandleFunc("/branch", s.audited(authMW.Require(mw.ScopeBranchesWrite, s.createBranch))).Methods(http.MethodPost)
+	r.HandleFunc("/branch/{name}", s.audited(authMW.Require(mw.ScopeBranchesWrite, s.deleteBranch))).Methods(http.MethodDelete)
+	r.HandleFunc("/branch/{name}/log", authMW.Require(mw.ScopeBranchesRead, s.getBranchLog)).Methods(http.MethodGet)
+	r.HandleFunc("/branch/{name}/commit", s.audited(authMW.Require(mw.ScopeBranchesWrite, s.commitBranch))).Methods(http.MethodPost)
+	r.HandleFunc("/branch/{name}/reset", s.audited(authMW.Require(mw.ScopeBranchesWrite, s.resetBranch))).Methods(http.MethodPost)
+	r.HandleFunc("/observation/start", s.audited(authMW.Require(mw.ScopeObservation, s.startObservation))).Methods(http.MethodPost)
+	r.HandleFunc("/observation/stop", s.audited(authMW.Require(mw.ScopeObservation, s.stopObservation))).Methods(http.MethodPost)
+	r.HandleFunc("/observation/summary/{clone_id}/{session_id}",
+		authMW.Require(mw.ScopeObservation, s.sessionSummaryObservation)).Methods(http.MethodGet)
+	r.HandleFunc("/observation/download", authMW.Require(mw.ScopeObservation, s.downloadArtifact)).Methods(http.MethodGet)
 	r.HandleFunc("/estimate", s.startEstimator).Methods(http.MethodGet)
-	r.HandleFunc("/instance/retrieval", authMW.Authorized(s.retrievalState)).Methods(http.MethodGet)
+	r.HandleFunc("/instance/retrieval", authMW.Require(mw.ScopeStatusRead, s.retrievalState)).Methods(http.MethodGet)
+	r.HandleFunc("/events", authMW.StreamMW(s.wsService.tokenKeeper, mw.ScopeStatusRead, s.streamEvents)).Methods(http.MethodGet)
 
 	// Sub-route /admin
 	adminR := r.PathPrefix("/admin").Subrouter()
-	adminR.Use(authMW.AdminMW)
-	adminR.HandleFunc("/ws-auth", s.websocketAuth).Methods(http.MethodGet)
-	adminR.HandleFunc("/config", s.getProjectedAdminConfig).Methods(http.MethodGet)
-	adminR.HandleFunc("/config.yaml", s.getAdminConfigYaml).Methods(http.MethodGet)
-	adminR.HandleFunc("/config", s.setProjectedAdminConfig).Methods(http.MethodPost)
-	adminR.HandleFunc("/test-db-source", s.testDBSource).Methods(http.MethodPost)
+	adminR.HandleFunc("/ws-auth", authMW.Require(mw.ScopeAdmin, s.websocketAuth)).Methods(http.MethodGet)
+	adminR.HandleFunc("/config", authMW.Require(mw.ScopeAdmin, s.getProjectedAdminConfig)).Methods(http.MethodGet)
+	adminR.HandleFunc("/config.yaml", authMW.Require(mw.ScopeAdmin, s.getAdminConfigYaml)).Methods(http.MethodGet)
+	adminR.HandleFunc("/config", s.audited(authMW.Require(mw.ScopeAdmin, s.setProjectedAdminConfig))).Methods(http.MethodPost)
+	adminR.HandleFunc("/test-db-source", authMW.Require(mw.ScopeAdmin, s.testDBSource)).Methods(http.MethodPost)
+	adminR.HandleFunc("/audit", authMW.Require(mw.ScopeAdmin, s.getAuditLog)).Methods(http.MethodGet)
 
 	r.HandleFunc("/instance/logs", authMW.WebSocketsMW(s.wsService.tokenKeeper, s.instanceLogs))
 
+	// OpenID Connect login of the UI.
+	r.HandleFunc("/auth/oidc/login", s.oidc.Login).Methods(http.MethodGet)
+	r.HandleFunc("/auth/oidc/callback", s.oidc.Callback).Methods(http.MethodGet)
+
 	// Health check.
 	r.HandleFunc("/healthz", s.healthCheck).Methods(http.MethodGet)
 
+	// Metrics in the Prometheus text format.
+	r.HandleFunc("/metrics", authMW.Require(mw.ScopeStatusRead, s.metricsHandler().ServeHTTP)).Methods(http.MethodGet)
+
 	// Show Swagger UI on index page.
 	if err := attachAPI(r); err != nil {
 		log.Err("Cannot load API description.")
@@ -240,8 +286,20 @@ func (s *Server) InitHandlers() {
 
 // Run starts HTTP server on specified port in configuration.
 func (s *Server) Run() error {
+	if !s.Config.TLS.Enabled() {
+		reportLaunching(s.Config)
+		return s.httpSrv.ListenAndServe()
+	}
+
+	if err := s.tlsManager.load(s.Config.TLS); err != nil {
+		return fmt.Errorf("failed to load TLS configuration: %w", err)
+	}
+
+	s.httpSrv.TLSConfig = s.tlsManager.serverConfig()
+
 	reportLaunching(s.Config)
-	return s.httpSrv.ListenAndServe()
+
+	return s.httpSrv.ListenAndServeTLS("", "")
 }
 
 // Shutdown gracefully shuts down the server without interrupting any active connections.
@@ -262,7 +320,12 @@ func (s *Server) Uptime() float64 {
 
 // reportLaunching reports the launch of the HTTP server.
 func reportLaunching(cfg *srvCfg.Config) {
-	log.Msg(fmt.Sprintf("API server started listening on %s:%d.", cfg.Host, cfg.Port))
+	scheme := "http"
+	if cfg.TLS.Enabled() {
+		scheme = "https"
+	}
+
+	log.Msg(fmt.Sprintf("API server started listening on %s://%s:%d.", scheme, cfg.Host, cfg.Port))
 }
 
 func (s *Server) initLogRegExp() {
@@ -278,6 +341,16 @@ func (s *Server) initLogRegExp() {
 		secretPatterns = append(secretPatterns, s.Config.VerificationToken)
 	}
 
+	for _, token := range s.Config.Tokens {
+		if len(token.Token) >= minTokenLength && !containsSpace(token.Token) {
+			secretPatterns = append(secretPatterns, regexp.QuoteMeta(token.Token))
+		}
+	}
+
+	if secret := s.Config.OIDC.ClientSecret; len(secret) >= minTokenLength && !containsSpace(secret) {
+		secretPatterns = append(secretPatterns, regexp.QuoteMeta(secret))
+	}
+
 	if accessToken := s.Platform.AccessToken(); len(accessToken) >= minTokenLength && !containsSpace(accessToken) {
 		secretPatterns = append(secretPatterns, accessToken)
 	}
@@ -0,0 +1,96 @@
+/*
+2022 © Postgres.ai
+*/
+
+package srv
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"gitlab.com/postgres-ai/database-lab/v3/internal/srv/api"
+	"gitlab.com/postgres-ai/database-lab/v3/internal/srv/mw"
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/log"
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/models"
+)
+
+// streamKeepAliveInterval defines how often comments are sent to keep idle connections open through proxies.
+const streamKeepAliveInterval = 30 * time.Second
+
+// streamEvents delivers changes of clones, snapshots, and retrieval as server-sent events.
+func (s *Server) streamEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		api.SendError(w, r, errors.New("streaming is not supported"))
+		return
+	}
+
+	identity := mw.IdentityFromContext(r.Context())
+
+	sub := s.broker.Subscribe()
+	defer s.broker.Unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	// Disable response buffering of nginx.
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ticker := time.NewTicker(streamKeepAliveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case event, ok := <-sub.Events():
+			if !ok {
+				// The subscriber has fallen behind, the client reconnects and gets the current state.
+				return
+			}
+
+			if !streamEventAllowed(identity, event) {
+				continue
+			}
+
+			data, err := json.Marshal(event)
+			if err != nil {
+				log.Err("Failed to encode a stream event:", err)
+				continue
+			}
+
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data); err != nil {
+				return
+			}
+
+			flusher.Flush()
+
+		case <-ticker.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+
+			flusher.Flush()
+
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// streamEventAllowed checks if the event may be delivered to the identity.
+func streamEventAllowed(identity *mw.Identity, event models.StreamEvent) bool {
+	switch {
+	case event.Clone != nil:
+		return identity.HasScope(mw.ScopeClonesRead) && identity.Owns(event.Clone.Owner)
+
+	case event.Snapshot != nil:
+		return identity.HasScope(mw.ScopeSnapshotsRead)
+
+	default:
+		return identity.HasScope(mw.ScopeStatusRead)
+	}
+}
@@ -0,0 +1,114 @@
+package srv
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/postgres-ai/database-lab/v3/internal/events"
+	"gitlab.com/postgres-ai/database-lab/v3/internal/srv/config"
+	"gitlab.com/postgres-ai/database-lab/v3/internal/srv/mw"
+	"gitlab.com/postgres-ai/database-lab/v3/internal/srv/ws"
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/models"
+)
+
+func TestStreamEvents(t *testing.T) {
+	broker := events.NewBroker()
+	s := &Server{broker: broker}
+
+	tokenKeeper, err := ws.NewTokenKeeper()
+	require.NoError(t, err)
+
+	auth := mw.NewAuth("", []config.TokenConfig{{Name: "ci", Token: "CloneUserToken", Role: "clone-user"}}, nil, nil)
+
+	srv := httptest.NewServer(auth.StreamMW(tokenKeeper, mw.ScopeStatusRead, s.streamEvents))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	require.NoError(t, err)
+	request.Header.Set(mw.VerificationTokenHeader, "CloneUserToken")
+
+	response, err := http.DefaultClient.Do(request)
+	require.NoError(t, err)
+
+	defer func() { _ = response.Body.Close() }()
+
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "text/event-stream", response.Header.Get("Content-Type"))
+
+	// Clones of other owners are not delivered to clone users.
+	broker.Publish(models.StreamEvent{Type: models.StreamCloneStatus, Clone: &models.Clone{ID: "foreign", Owner: "someone"}})
+	broker.Publish(models.StreamEvent{Type: models.StreamCloneStatus, Clone: &models.Clone{ID: "own", Owner: "ci"}})
+	broker.Publish(models.StreamEvent{Type: models.StreamRetrievalStatus, Retrieval: &models.Retrieving{Status: models.Refreshing}})
+
+	scanner := bufio.NewScanner(response.Body)
+
+	var received []models.StreamEvent
+
+	for len(received) < 2 && scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+
+		var event models.StreamEvent
+
+		require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event))
+
+		received = append(received, event)
+	}
+
+	require.Len(t, received, 2)
+	assert.Equal(t, "own", received[0].Clone.ID)
+	assert.Equal(t, models.Refreshing, received[1].Retrieval.Status)
+}
+
+func TestStreamEventsAuth(t *testing.T) {
+	s := &Server{broker: events.NewBroker()}
+
+	tokenKeeper, err := ws.NewTokenKeeper()
+	require.NoError(t, err)
+
+	auth := mw.NewAuth("secret", nil, nil, nil)
+
+	srv := httptest.NewServer(auth.StreamMW(tokenKeeper, mw.ScopeStatusRead, s.streamEvents))
+	defer srv.Close()
+
+	response, err := http.Get(srv.URL)
+	require.NoError(t, err)
+	require.NoError(t, response.Body.Close())
+	assert.Equal(t, http.StatusUnauthorized, response.StatusCode)
+
+	wsToken, err := tokenKeeper.IssueToken()
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"?token="+wsToken, nil)
+	require.NoError(t, err)
+
+	response, err = http.DefaultClient.Do(request)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+
+	cancel()
+	_ = response.Body.Close()
+
+	// The token is one-time.
+	response, err = http.Get(srv.URL + "?token=" + wsToken)
+	require.NoError(t, err)
+	require.NoError(t, response.Body.Close())
+	assert.Equal(t, http.StatusUnauthorized, response.StatusCode)
+}
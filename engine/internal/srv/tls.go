@@ -0,0 +1,95 @@
+/*
+2022 © Postgres.ai
+*/
+
+package srv
+
+import (
+	"crypto/tls"
+	"fmt"
+	"sync"
+
+	srvCfg "gitlab.com/postgres-ai/database-lab/v3/internal/srv/config"
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/log"
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/util/tlsutil"
+)
+
+// tlsManager keeps the TLS configuration of the API server, so certificates can be replaced without restarting the listener.
+type tlsManager struct {
+	mu      sync.RWMutex
+	config  *tls.Config
+	serving bool
+}
+
+// load reads the certificate, the key, and the optional client CA.
+// The previous configuration stays active if the files cannot be loaded.
+func (m *tlsManager) load(cfg srvCfg.TLSConfig) error {
+	if cfg.CertFile == "" || cfg.KeyFile == "" {
+		return fmt.Errorf(`both "server.tls.certFile" and "server.tls.keyFile" must be defined`)
+	}
+
+	cert, err := tlsutil.LoadKeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return err
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{*cert},
+	}
+
+	if cfg.ClientCAFile != "" {
+		clientCAs, err := tlsutil.LoadCertPool(cfg.ClientCAFile)
+		if err != nil {
+			return err
+		}
+
+		tlsConfig.ClientCAs = clientCAs
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	m.mu.Lock()
+	m.config = tlsConfig
+	m.mu.Unlock()
+
+	return nil
+}
+
+// serverConfig returns the TLS configuration of the listener that always uses the latest loaded configuration.
+func (m *tlsManager) serverConfig() *tls.Config {
+	m.mu.Lock()
+	m.serving = true
+	m.mu.Unlock()
+
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			m.mu.RLock()
+			defer m.mu.RUnlock()
+
+			return m.config, nil
+		},
+	}
+}
+
+// reload replaces the TLS configuration of the running listener.
+func (m *tlsManager) reload(cfg srvCfg.TLSConfig) {
+	m.mu.RLock()
+	serving := m.serving
+	m.mu.RUnlock()
+
+	if serving != cfg.Enabled() {
+		log.Warn("Enabling or disabling TLS of the API server requires a restart")
+	}
+
+	if !serving || !cfg.Enabled() {
+		return
+	}
+
+	if err := m.load(cfg); err != nil {
+		log.Err("Failed to reload TLS configuration of the API server, keep the previous one:", err)
+		return
+	}
+
+	log.Msg("TLS configuration of the API server has been reloaded")
+}
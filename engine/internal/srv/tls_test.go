@@ -0,0 +1,62 @@
+package srv
+
+import (
+	"crypto/tls"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	srvCfg "gitlab.com/postgres-ai/database-lab/v3/internal/srv/config"
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/util/tlsutil"
+)
+
+func writeTestCert(t *testing.T, dir, name string) (string, string) {
+	t.Helper()
+
+	certPEM, keyPEM, err := tlsutil.GenerateSelfSigned(name, []string{"localhost"}, time.Hour)
+	require.NoError(t, err)
+
+	certFile := path.Join(dir, name+".crt")
+	keyFile := path.Join(dir, name+".key")
+
+	require.NoError(t, os.WriteFile(certFile, certPEM, 0600))
+	require.NoError(t, os.WriteFile(keyFile, keyPEM, 0600))
+
+	return certFile, keyFile
+}
+
+func TestTLSManager(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCert(t, dir, "first")
+	caFile, _ := writeTestCert(t, dir, "client-ca")
+
+	m := &tlsManager{}
+
+	require.Error(t, m.load(srvCfg.TLSConfig{CertFile: certFile}))
+	require.NoError(t, m.load(srvCfg.TLSConfig{CertFile: certFile, KeyFile: keyFile, ClientCAFile: caFile}))
+
+	serverConfig := m.serverConfig()
+
+	current, err := serverConfig.GetConfigForClient(&tls.ClientHelloInfo{})
+	require.NoError(t, err)
+	assert.Equal(t, tls.RequireAndVerifyClientCert, current.ClientAuth)
+	require.Len(t, current.Certificates, 1)
+
+	secondCert, secondKey := writeTestCert(t, dir, "second")
+	m.reload(srvCfg.TLSConfig{CertFile: secondCert, KeyFile: secondKey})
+
+	reloaded, err := serverConfig.GetConfigForClient(&tls.ClientHelloInfo{})
+	require.NoError(t, err)
+	assert.Equal(t, tls.NoClientCert, reloaded.ClientAuth)
+	assert.NotEqual(t, current.Certificates[0].Certificate, reloaded.Certificates[0].Certificate)
+
+	m.reload(srvCfg.TLSConfig{CertFile: path.Join(dir, "missing.crt"), KeyFile: secondKey})
+
+	kept, err := serverConfig.GetConfigForClient(&tls.ClientHelloInfo{})
+	require.NoError(t, err)
+	assert.Equal(t, reloaded, kept)
+}
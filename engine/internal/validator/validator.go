@@ -29,5 +29,9 @@ func (v Service) ValidateCloneRequest(cloneRequest *types.CloneCreateRequest) er
 		return errors.New("missing DB password")
 	}
 
+	if cloneRequest.Snapshot != nil && cloneRequest.Branch != "" {
+		return errors.New("snapshot ID and branch must not be specified together")
+	}
+
 	return nil
 }
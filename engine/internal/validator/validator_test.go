@@ -43,6 +43,14 @@ func TestValidationCloneRequestErrors(t *testing.T) {
 			createRequest: types.CloneCreateRequest{DB: &types.DatabaseRequest{Password: "password"}},
 			error:         "missing DB username",
 		},
+		{
+			createRequest: types.CloneCreateRequest{
+				DB:       &types.DatabaseRequest{Username: "user", Password: "password"},
+				Snapshot: &types.SnapshotCloneFieldRequest{ID: "snapshot"},
+				Branch:   "main",
+			},
+			error: "snapshot ID and branch must not be specified together",
+		},
 	}
 
 	for _, tc := range testCases {
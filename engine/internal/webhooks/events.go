@@ -0,0 +1,76 @@
+/*
+2022 © Postgres.ai
+*/
+
+package webhooks
+
+import (
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/models"
+)
+
+const (
+	// CloneCreatedEvent describes the clone creation event.
+	CloneCreatedEvent = "clone_created"
+
+	// CloneResetEvent describes the clone reset event.
+	CloneResetEvent = "clone_reset"
+
+	// CloneDestroyedEvent describes the clone destruction event.
+	CloneDestroyedEvent = "clone_destroyed"
+
+	// CloneIdleDestroyedEvent describes the destruction of an idle clone.
+	CloneIdleDestroyedEvent = "clone_idle_destroyed"
+
+	// SnapshotCreatedEvent describes the snapshot creation event.
+	SnapshotCreatedEvent = "snapshot_created"
+
+	// SnapshotCleanedUpEvent describes the removal of snapshots exceeding the retention limit.
+	SnapshotCleanedUpEvent = "snapshot_cleaned_up"
+
+	// RetrievalFailedEvent describes a data retrieval failure.
+	RetrievalFailedEvent = "retrieval_failed"
+
+	// RetrievalFinishedEvent describes the completion of data retrieval.
+	RetrievalFinishedEvent = "retrieval_finished"
+
+	// PoolSwitchedEvent describes the activation of a refreshed pool.
+	PoolSwitchedEvent = "pool_switched"
+)
+
+// CloneEvent describes clone lifecycle events.
+type CloneEvent struct {
+	ID         string `json:"id"`
+	SnapshotID string `json:"snapshotId,omitempty"`
+	Pool       string `json:"pool,omitempty"`
+	Reason     string `json:"reason,omitempty"`
+}
+
+// NewCloneEvent builds the payload of a clone event.
+func NewCloneEvent(clone *models.Clone, reason string) CloneEvent {
+	event := CloneEvent{ID: clone.ID, Reason: reason}
+
+	if clone.Snapshot != nil {
+		event.SnapshotID = clone.Snapshot.ID
+		event.Pool = clone.Snapshot.Pool
+	}
+
+	return event
+}
+
+// SnapshotEvent describes snapshot lifecycle events.
+type SnapshotEvent struct {
+	IDs  []string `json:"ids,omitempty"`
+	Pool string   `json:"pool,omitempty"`
+}
+
+// RetrievalEvent describes data retrieval events.
+type RetrievalEvent struct {
+	Mode    models.RetrievalMode `json:"mode"`
+	Pool    string               `json:"pool,omitempty"`
+	Message string               `json:"message,omitempty"`
+}
+
+// PoolEvent describes pool events.
+type PoolEvent struct {
+	Pool string `json:"pool"`
+}
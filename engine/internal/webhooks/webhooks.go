@@ -0,0 +1,449 @@
+/*
+2022 © Postgres.ai
+*/
+
+// Package webhooks delivers notifications about engine events to external HTTP endpoints.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rs/xid"
+
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/log"
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/util"
+)
+
+const (
+	queueFilename = "webhooks.json"
+
+	// SignatureHeader contains the HMAC-SHA256 signature of the request body made with the hook secret.
+	SignatureHeader = "X-DBLab-Signature"
+	// EventHeader contains the event type.
+	EventHeader = "X-DBLab-Event"
+	// DeliveryHeader contains the delivery ID, which stays the same across retries.
+	DeliveryHeader = "X-DBLab-Delivery"
+
+	signaturePrefix = "sha256="
+
+	defaultMaxAttempts = 10
+	requestTimeout     = 10 * time.Second
+	initialBackoff     = 5 * time.Second
+	maxBackoff         = 30 * time.Minute
+	idleCheckInterval  = time.Minute
+)
+
+// Config defines webhooks configuration.
+type Config struct {
+	Hooks       []HookConfig `yaml:"hooks"`
+	MaxAttempts int          `yaml:"maxAttempts"`
+}
+
+// HookConfig defines an endpoint to deliver events to.
+type HookConfig struct {
+	URL    string   `yaml:"url"`
+	Secret string   `yaml:"secret"`
+	Events []string `yaml:"events"`
+}
+
+// accepts checks if the hook is subscribed to the event. An empty list means all events.
+func (h HookConfig) accepts(eventType string) bool {
+	if len(h.Events) == 0 {
+		return true
+	}
+
+	for _, event := range h.Events {
+		if event == eventType {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Event represents the body of a webhook request.
+type Event struct {
+	ID         string      `json:"id"`
+	Type       string      `json:"type"`
+	InstanceID string      `json:"instanceId"`
+	CreatedAt  time.Time   `json:"createdAt"`
+	Payload    interface{} `json:"payload"`
+}
+
+// delivery represents a pending delivery of an event to a hook.
+type delivery struct {
+	ID            string          `json:"id"`
+	URL           string          `json:"url"`
+	EventType     string          `json:"event_type"`
+	Body          json.RawMessage `json:"body"`
+	Attempts      int             `json:"attempts"`
+	NextAttemptAt time.Time       `json:"next_attempt_at"`
+	LastError     string          `json:"last_error,omitempty"`
+}
+
+// Service queues events and delivers them to the configured hooks.
+type Service struct {
+	mu         sync.Mutex
+	cfg        Config
+	instanceID string
+	queue      []*delivery
+	queuePath  string
+	client     *http.Client
+	wakeCh     chan struct{}
+}
+
+// NewService creates a new webhooks service and restores undelivered events.
+func NewService(cfg Config, instanceID string) (*Service, error) {
+	queuePath, err := util.GetMetaPath(queueFilename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get path of a webhooks queue file: %w", err)
+	}
+
+	s := newService(cfg, instanceID, queuePath)
+	s.loadQueue()
+
+	return s, nil
+}
+
+func newService(cfg Config, instanceID, queuePath string) *Service {
+	return &Service{
+		cfg:        cfg,
+		instanceID: instanceID,
+		queue:      []*delivery{},
+		queuePath:  queuePath,
+		client:     &http.Client{Timeout: requestTimeout},
+		wakeCh:     make(chan struct{}, 1),
+	}
+}
+
+// Reload reloads webhooks configuration.
+func (s *Service) Reload(cfg Config) {
+	s.mu.Lock()
+	s.cfg = cfg
+	s.mu.Unlock()
+
+	s.wake()
+}
+
+// Emit queues the event for all hooks subscribed to it. It is safe to call on a nil service.
+func (s *Service) Emit(eventType string, payload interface{}) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.cfg.Hooks) == 0 {
+		return
+	}
+
+	event := Event{
+		ID:         xid.New().String(),
+		Type:       eventType,
+		InstanceID: s.instanceID,
+		CreatedAt:  time.Now().UTC(),
+		Payload:    payload,
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		log.Err("Failed to encode webhook event", eventType, err)
+		return
+	}
+
+	queued := false
+
+	for _, hook := range s.cfg.Hooks {
+		if !hook.accepts(eventType) {
+			continue
+		}
+
+		s.queue = append(s.queue, &delivery{
+			ID:            xid.New().String(),
+			URL:           hook.URL,
+			EventType:     eventType,
+			Body:          body,
+			NextAttemptAt: event.CreatedAt,
+		})
+
+		queued = true
+	}
+
+	if !queued {
+		return
+	}
+
+	s.saveQueue()
+	s.wake()
+}
+
+// Run delivers queued events until the context is canceled.
+func (s *Service) Run(ctx context.Context) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-s.wakeCh:
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+
+		case <-timer.C:
+		}
+
+		s.deliverDue(ctx)
+
+		timer.Reset(s.nextAttemptIn(time.Now()))
+	}
+}
+
+func (s *Service) wake() {
+	select {
+	case s.wakeCh <- struct{}{}:
+	default:
+	}
+}
+
+// deliverDue sends all deliveries whose time has come.
+func (s *Service) deliverDue(ctx context.Context) {
+	for _, d := range s.dueDeliveries(time.Now()) {
+		if ctx.Err() != nil {
+			return
+		}
+
+		hook, ok := s.findHook(d.URL)
+		if !ok {
+			log.Msg(fmt.Sprintf("Drop webhook delivery %s: hook %s is not configured anymore", d.ID, d.URL))
+			s.complete(d)
+
+			continue
+		}
+
+		if err := s.send(ctx, hook, d); err != nil {
+			s.retry(d, err)
+			continue
+		}
+
+		log.Dbg(fmt.Sprintf("Webhook %s delivered to %s", d.EventType, d.URL))
+		s.complete(d)
+	}
+}
+
+// send makes a signed request to the hook.
+func (s *Service) send(ctx context.Context, hook HookConfig, d *delivery) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(d.Body))
+	if err != nil {
+		return fmt.Errorf("failed to make a request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, d.EventType)
+	req.Header.Set(DeliveryHeader, d.ID)
+
+	if hook.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(hook.Secret, d.Body))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("unexpected response status: %s", resp.Status)
+	}
+
+	return nil
+}
+
+// Sign returns the value of the signature header for the body.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = mac.Write(body)
+
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature checks the value of the signature header against the body.
+func VerifySignature(secret string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
+
+func (s *Service) dueDeliveries(now time.Time) []*delivery {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	due := []*delivery{}
+
+	for _, d := range s.queue {
+		if !d.NextAttemptAt.After(now) {
+			due = append(due, d)
+		}
+	}
+
+	return due
+}
+
+func (s *Service) findHook(url string) (HookConfig, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, hook := range s.cfg.Hooks {
+		if hook.URL == url {
+			return hook, true
+		}
+	}
+
+	return HookConfig{}, false
+}
+
+// retry schedules the next attempt with exponential backoff or drops the delivery if attempts are exhausted.
+func (s *Service) retry(d *delivery, deliveryErr error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d.Attempts++
+	d.LastError = deliveryErr.Error()
+
+	maxAttempts := s.cfg.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = defaultMaxAttempts
+	}
+
+	if d.Attempts >= maxAttempts {
+		log.Err(fmt.Sprintf("Failed to deliver webhook %s to %s after %d attempts: %v", d.EventType, d.URL, d.Attempts, deliveryErr))
+		s.removeDelivery(d)
+		s.saveQueue()
+
+		return
+	}
+
+	d.NextAttemptAt = time.Now().Add(backoff(d.Attempts))
+
+	log.Msg(fmt.Sprintf("Failed to deliver webhook %s to %s (attempt %d), retry at %s: %v",
+		d.EventType, d.URL, d.Attempts, d.NextAttemptAt.Format(time.RFC3339), deliveryErr))
+
+	s.saveQueue()
+}
+
+func (s *Service) complete(d *delivery) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.removeDelivery(d)
+	s.saveQueue()
+}
+
+// removeDelivery removes the delivery from the queue. It's not safe to invoke without locking.
+func (s *Service) removeDelivery(d *delivery) {
+	for i, queued := range s.queue {
+		if queued == d {
+			s.queue = append(s.queue[:i], s.queue[i+1:]...)
+			return
+		}
+	}
+}
+
+// nextAttemptIn returns the time until the earliest scheduled delivery.
+func (s *Service) nextAttemptIn(now time.Time) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	next := idleCheckInterval
+
+	for _, d := range s.queue {
+		if wait := d.NextAttemptAt.Sub(now); wait < next {
+			next = wait
+		}
+	}
+
+	if next < 0 {
+		return 0
+	}
+
+	return next
+}
+
+// backoff returns the delay before the next attempt.
+func backoff(attempts int) time.Duration {
+	delay := initialBackoff
+
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+
+		if delay >= maxBackoff {
+			return maxBackoff
+		}
+	}
+
+	return delay
+}
+
+// loadQueue restores undelivered events. A broken queue file does not prevent the engine from starting,
+// so the events are dropped in that case.
+func (s *Service) loadQueue() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := os.ReadFile(s.queuePath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Err("Failed to read webhooks queue, undelivered events are dropped:", err)
+		}
+
+		return
+	}
+
+	queue := []*delivery{}
+
+	if err := json.Unmarshal(data, &queue); err != nil {
+		log.Err("Failed to decode webhooks queue, undelivered events are dropped:", err)
+		return
+	}
+
+	s.queue = queue
+}
+
+// saveQueue writes the queue to disk. It's not safe to invoke without locking.
+// The queue is written to a temporary file first, so a crash cannot leave the queue file truncated.
+func (s *Service) saveQueue() {
+	data, err := json.Marshal(s.queue)
+	if err != nil {
+		log.Err("Failed to encode webhooks queue", err)
+		return
+	}
+
+	tmpPath := s.queuePath + ".tmp"
+
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		log.Err("Failed to save webhooks queue", err)
+		return
+	}
+
+	if err := os.Rename(tmpPath, s.queuePath); err != nil {
+		log.Err("Failed to save webhooks queue", err)
+	}
+}
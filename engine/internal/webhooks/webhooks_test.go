@@ -0,0 +1,166 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type receivedRequest struct {
+	header http.Header
+	body   []byte
+}
+
+func newTestServer(t *testing.T, status int) (*httptest.Server, chan receivedRequest) {
+	t.Helper()
+
+	requests := make(chan receivedRequest, 10)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		requests <- receivedRequest{header: r.Header, body: body}
+
+		w.WriteHeader(status)
+	}))
+
+	t.Cleanup(srv.Close)
+
+	return srv, requests
+}
+
+func TestSignature(t *testing.T) {
+	body := []byte(`{"type":"clone_created"}`)
+	signature := Sign("secret", body)
+
+	assert.Equal(t, "sha256=", signature[:len(signaturePrefix)])
+	assert.True(t, VerifySignature("secret", body, signature))
+	assert.False(t, VerifySignature("other", body, signature))
+	assert.False(t, VerifySignature("secret", []byte(`{}`), signature))
+}
+
+func TestHookAccepts(t *testing.T) {
+	assert.True(t, HookConfig{}.accepts(CloneCreatedEvent))
+	assert.True(t, HookConfig{Events: []string{RetrievalFailedEvent, CloneCreatedEvent}}.accepts(CloneCreatedEvent))
+	assert.False(t, HookConfig{Events: []string{RetrievalFailedEvent}}.accepts(CloneCreatedEvent))
+}
+
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, initialBackoff, backoff(1))
+	assert.Equal(t, 2*initialBackoff, backoff(2))
+	assert.Equal(t, 8*initialBackoff, backoff(4))
+	assert.Equal(t, maxBackoff, backoff(20))
+}
+
+func TestDelivery(t *testing.T) {
+	srv, requests := newTestServer(t, http.StatusOK)
+
+	s := newService(Config{Hooks: []HookConfig{
+		{URL: srv.URL, Secret: "secret"},
+		{URL: srv.URL + "/retrieval", Events: []string{RetrievalFailedEvent}},
+	}}, "instanceID", path.Join(t.TempDir(), queueFilename))
+
+	s.Emit(CloneCreatedEvent, CloneEvent{ID: "clone1"})
+	require.Len(t, s.queue, 1)
+
+	s.deliverDue(context.Background())
+	assert.Empty(t, s.queue)
+
+	req := <-requests
+	assert.Equal(t, CloneCreatedEvent, req.header.Get(EventHeader))
+	assert.NotEmpty(t, req.header.Get(DeliveryHeader))
+	assert.True(t, VerifySignature("secret", req.body, req.header.Get(SignatureHeader)))
+
+	var event struct {
+		Type       string     `json:"type"`
+		InstanceID string     `json:"instanceId"`
+		Payload    CloneEvent `json:"payload"`
+	}
+
+	require.NoError(t, json.Unmarshal(req.body, &event))
+	assert.Equal(t, CloneCreatedEvent, event.Type)
+	assert.Equal(t, "instanceID", event.InstanceID)
+	assert.Equal(t, "clone1", event.Payload.ID)
+}
+
+func TestRetryAndPersistence(t *testing.T) {
+	srv, requests := newTestServer(t, http.StatusInternalServerError)
+	queuePath := path.Join(t.TempDir(), queueFilename)
+	cfg := Config{Hooks: []HookConfig{{URL: srv.URL}}, MaxAttempts: 2}
+
+	s := newService(cfg, "instanceID", queuePath)
+
+	s.Emit(RetrievalFailedEvent, RetrievalEvent{Message: "failed"})
+	s.deliverDue(context.Background())
+	<-requests
+
+	require.Len(t, s.queue, 1)
+	assert.Equal(t, 1, s.queue[0].Attempts)
+	assert.Contains(t, s.queue[0].LastError, "500")
+	assert.True(t, s.queue[0].NextAttemptAt.After(time.Now()))
+	assert.Greater(t, s.nextAttemptIn(time.Now()), time.Duration(0))
+
+	// The delivery is not due yet.
+	s.deliverDue(context.Background())
+	assert.Len(t, requests, 0)
+
+	// Undelivered events survive restarts.
+	restored := newService(cfg, "instanceID", queuePath)
+	restored.loadQueue()
+	require.Len(t, restored.queue, 1)
+	assert.Equal(t, s.queue[0].ID, restored.queue[0].ID)
+
+	// The delivery is dropped once attempts are exhausted.
+	restored.queue[0].NextAttemptAt = time.Now()
+	restored.deliverDue(context.Background())
+	<-requests
+
+	assert.Empty(t, restored.queue)
+}
+
+func TestLoadBrokenQueue(t *testing.T) {
+	queuePath := path.Join(t.TempDir(), queueFilename)
+	require.NoError(t, os.WriteFile(queuePath, []byte(`[{"id": "truncated`), 0600))
+
+	cfg := Config{Hooks: []HookConfig{{URL: "http://127.0.0.1"}}}
+
+	s := newService(cfg, "instanceID", queuePath)
+	s.loadQueue()
+	assert.Empty(t, s.queue)
+
+	// The broken file is replaced once the queue changes.
+	s.Emit(CloneCreatedEvent, CloneEvent{ID: "clone1"})
+
+	restored := newService(cfg, "instanceID", queuePath)
+	restored.loadQueue()
+	assert.Len(t, restored.queue, 1)
+}
+
+func TestDropUnknownHook(t *testing.T) {
+	srv, requests := newTestServer(t, http.StatusOK)
+
+	s := newService(Config{Hooks: []HookConfig{{URL: srv.URL}}}, "instanceID", path.Join(t.TempDir(), queueFilename))
+	s.Emit(PoolSwitchedEvent, PoolEvent{Pool: "dblab_pool"})
+
+	s.Reload(Config{})
+	s.deliverDue(context.Background())
+
+	assert.Empty(t, s.queue)
+	assert.Len(t, requests, 0)
+}
+
+func TestEmitOnNilService(t *testing.T) {
+	var s *Service
+
+	assert.NotPanics(t, func() { s.Emit(CloneCreatedEvent, CloneEvent{ID: "clone1"}) })
+}
@@ -0,0 +1,143 @@
+/*
+2022 © Postgres.ai
+*/
+
+package dblabapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/pkg/errors"
+
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/client/dblabapi/types"
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/models"
+)
+
+// ListBranches provides a branch list.
+func (c *Client) ListBranches(ctx context.Context) ([]models.Branch, error) {
+	u := c.URL("/branches")
+
+	request, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to make a request")
+	}
+
+	response, err := c.Do(ctx, request)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get response")
+	}
+
+	defer func() { _ = response.Body.Close() }()
+
+	var branches []models.Branch
+
+	if err := json.NewDecoder(response.Body).Decode(&branches); err != nil {
+		return nil, errors.Wrap(err, "failed to decode a response body")
+	}
+
+	return branches, nil
+}
+
+// CreateBranch creates a new branch.
+func (c *Client) CreateBranch(ctx context.Context, branchRequest types.BranchCreateRequest) (*models.Branch, error) {
+	u := c.URL("/branch")
+
+	var branch models.Branch
+
+	if err := c.request(ctx, u, branchRequest, &branch); err != nil {
+		return nil, err
+	}
+
+	return &branch, nil
+}
+
+// DeleteBranch deletes a branch.
+func (c *Client) DeleteBranch(ctx context.Context, branchName string) error {
+	u := c.URL(fmt.Sprintf("/branch/%s", branchName))
+
+	request, err := http.NewRequest(http.MethodDelete, u.String(), nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to make a request")
+	}
+
+	response, err := c.Do(ctx, request)
+	if err != nil {
+		return errors.Wrap(err, "failed to get response")
+	}
+
+	defer func() { _ = response.Body.Close() }()
+
+	return nil
+}
+
+// BranchLog provides the chain of branch snapshots starting from the branch head.
+func (c *Client) BranchLog(ctx context.Context, branchName string) ([]models.Snapshot, error) {
+	u := c.URL(fmt.Sprintf("/branch/%s/log", branchName))
+
+	request, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to make a request")
+	}
+
+	response, err := c.Do(ctx, request)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get response")
+	}
+
+	defer func() { _ = response.Body.Close() }()
+
+	var snapshots []models.Snapshot
+
+	if err := json.NewDecoder(response.Body).Decode(&snapshots); err != nil {
+		return nil, errors.Wrap(err, "failed to decode a response body")
+	}
+
+	return snapshots, nil
+}
+
+// CommitBranch saves the clone state as a new snapshot and moves the branch head to it.
+func (c *Client) CommitBranch(ctx context.Context, branchName string, commitRequest types.BranchCommitRequest) (*models.Snapshot, error) {
+	u := c.URL(fmt.Sprintf("/branch/%s/commit", branchName))
+
+	var snapshot models.Snapshot
+
+	if err := c.request(ctx, u, commitRequest, &snapshot); err != nil {
+		return nil, err
+	}
+
+	return &snapshot, nil
+}
+
+// ResetBranch moves the branch head to the requested snapshot.
+func (c *Client) ResetBranch(ctx context.Context, branchName string, resetRequest types.BranchResetRequest) (*models.Branch, error) {
+	u := c.URL(fmt.Sprintf("/branch/%s/reset", branchName))
+
+	body := bytes.NewBuffer(nil)
+	if err := json.NewEncoder(body).Encode(resetRequest); err != nil {
+		return nil, errors.Wrap(err, "failed to encode BranchResetRequest")
+	}
+
+	request, err := http.NewRequest(http.MethodPost, u.String(), body)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to make a request")
+	}
+
+	response, err := c.Do(ctx, request)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get response")
+	}
+
+	defer func() { _ = response.Body.Close() }()
+
+	var branch models.Branch
+
+	if err := json.NewDecoder(response.Body).Decode(&branch); err != nil {
+		return nil, errors.Wrap(err, "failed to decode a response body")
+	}
+
+	return &branch, nil
+}
@@ -0,0 +1,100 @@
+package dblabapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/client/dblabapi/types"
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/models"
+)
+
+func TestClientListBranches(t *testing.T) {
+	expectedBranches := []models.Branch{{
+		Name:       "feature-x",
+		SnapshotID: "pool/dblab_clone_6000@snapshot_20200110000000",
+		CreatedAt:  &models.LocalTime{Time: time.Date(2020, 01, 10, 0, 0, 5, 0, time.UTC)},
+	}, {
+		Name:       "main",
+		SnapshotID: "pool@snapshot_20200109000000",
+		CreatedAt:  &models.LocalTime{Time: time.Date(2020, 01, 9, 0, 0, 5, 0, time.UTC)},
+	}}
+
+	mockClient := NewTestClient(func(req *http.Request) *http.Response {
+		assert.Equal(t, req.URL.String(), "https://example.com/branches")
+
+		// Prepare response.
+		body, err := json.Marshal(expectedBranches)
+		require.NoError(t, err)
+
+		return &http.Response{
+			StatusCode: 200,
+			Body:       io.NopCloser(bytes.NewBuffer(body)),
+			Header:     make(http.Header),
+		}
+	})
+
+	c, err := NewClient(Options{
+		Host:              "https://example.com/",
+		VerificationToken: "testVerify",
+	})
+	require.NoError(t, err)
+
+	c.client = mockClient
+
+	branches, err := c.ListBranches(context.Background())
+	require.NoError(t, err)
+
+	assert.EqualValues(t, expectedBranches, branches)
+}
+
+func TestClientCommitBranch(t *testing.T) {
+	expectedSnapshot := &models.Snapshot{
+		ID:      "pool/dblab_clone_6000@snapshot_20200110000000",
+		Pool:    "pool",
+		Parent:  "pool@snapshot_20200109000000",
+		CloneID: "testCloneID",
+		Message: "add users table",
+	}
+
+	mockClient := NewTestClient(func(req *http.Request) *http.Response {
+		assert.Equal(t, req.URL.String(), "https://example.com/branch/feature-x/commit")
+		assert.Equal(t, req.Method, http.MethodPost)
+
+		commitRequest := types.BranchCommitRequest{}
+		err := json.NewDecoder(req.Body).Decode(&commitRequest)
+		require.NoError(t, err)
+		assert.Equal(t, types.BranchCommitRequest{CloneID: "testCloneID", Message: "add users table"}, commitRequest)
+
+		// Prepare response.
+		body, err := json.Marshal(expectedSnapshot)
+		require.NoError(t, err)
+
+		return &http.Response{
+			StatusCode: 201,
+			Body:       io.NopCloser(bytes.NewBuffer(body)),
+			Header:     make(http.Header),
+		}
+	})
+
+	c, err := NewClient(Options{
+		Host:              "https://example.com/",
+		VerificationToken: "testVerify",
+	})
+	require.NoError(t, err)
+
+	c.client = mockClient
+
+	snapshot, err := c.CommitBranch(context.Background(), "feature-x",
+		types.BranchCommitRequest{CloneID: "testCloneID", Message: "add users table"})
+	require.NoError(t, err)
+
+	assert.EqualValues(t, expectedSnapshot, snapshot)
+}
@@ -18,6 +18,7 @@ import (
 
 	"gitlab.com/postgres-ai/database-lab/v3/internal/observer"
 	"gitlab.com/postgres-ai/database-lab/v3/pkg/client/dblabapi/types"
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/log"
 	"gitlab.com/postgres-ai/database-lab/v3/pkg/models"
 )
 
@@ -56,6 +57,42 @@ func (c *Client) ListClonesRaw(ctx context.Context) (io.ReadCloser, error) {
 	return response.Body, nil
 }
 
+// ListClonesBySelector provides a list of Database Lab clones matching the label selector, e.g. "pipeline=123,team=db".
+func (c *Client) ListClonesBySelector(ctx context.Context, selector string) ([]*models.Clone, error) {
+	body, err := c.ListClonesBySelectorRaw(ctx, selector)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get response")
+	}
+
+	defer func() { _ = body.Close() }()
+
+	var clones []*models.Clone
+
+	if err := json.NewDecoder(body).Decode(&clones); err != nil {
+		return nil, errors.Wrap(err, "failed to decode a response body")
+	}
+
+	return clones, nil
+}
+
+// ListClonesBySelectorRaw provides a raw list of Database Lab clones matching the label selector.
+func (c *Client) ListClonesBySelectorRaw(ctx context.Context, selector string) (io.ReadCloser, error) {
+	u := c.URL("/clones")
+	u.RawQuery = url.Values{"label": []string{selector}}.Encode()
+
+	request, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to make a request")
+	}
+
+	response, err := c.Do(ctx, request)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get response")
+	}
+
+	return response.Body, nil
+}
+
 // GetClone returns info about a Database Lab clone.
 func (c *Client) GetClone(ctx context.Context, cloneID string) (*models.Clone, error) {
 	body, err := c.GetCloneRaw(ctx, cloneID)
@@ -91,6 +128,41 @@ func (c *Client) GetCloneRaw(ctx context.Context, cloneID string) (io.ReadCloser
 	return response.Body, nil
 }
 
+// CloneEvents returns the history of a Database Lab clone including recently destroyed ones.
+func (c *Client) CloneEvents(ctx context.Context, cloneID string) ([]models.CloneEvent, error) {
+	body, err := c.CloneEventsRaw(ctx, cloneID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get response")
+	}
+
+	defer func() { _ = body.Close() }()
+
+	var events []models.CloneEvent
+
+	if err := json.NewDecoder(body).Decode(&events); err != nil {
+		return nil, errors.Wrap(err, "failed to decode a response body")
+	}
+
+	return events, nil
+}
+
+// CloneEventsRaw returns the raw history of a Database Lab clone.
+func (c *Client) CloneEventsRaw(ctx context.Context, cloneID string) (io.ReadCloser, error) {
+	u := c.URL(fmt.Sprintf("/clone/%s/events", cloneID))
+
+	request, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to make a request")
+	}
+
+	response, err := c.Do(ctx, request)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get response")
+	}
+
+	return response.Body, nil
+}
+
 // CreateClone creates a new Database Lab clone.
 func (c *Client) CreateClone(ctx context.Context, cloneRequest types.CloneCreateRequest) (*models.Clone, error) {
 	u := c.URL("/clone")
@@ -100,6 +172,11 @@ func (c *Client) CreateClone(ctx context.Context, cloneRequest types.CloneCreate
 		return nil, errors.Wrap(err, "failed to encode CloneCreateRequest")
 	}
 
+	stream := c.openEventStream(ctx)
+	if stream != nil {
+		defer func() { _ = stream.Close() }()
+	}
+
 	request, err := http.NewRequest(http.MethodPost, u.String(), body)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to make a request")
@@ -130,7 +207,7 @@ func (c *Client) CreateClone(ctx context.Context, cloneRequest types.CloneCreate
 		return nil, errors.Errorf("unexpected clone status given: %v", clone.Status)
 	}
 
-	clone, err = c.watchCloneStatus(ctx, clone.ID, clone.Status.Code)
+	clone, err = c.watchCloneStatus(ctx, stream, clone.ID, clone.Status.Code)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to watch the clone status")
 	}
@@ -142,11 +219,10 @@ func (c *Client) CreateClone(ctx context.Context, cloneRequest types.CloneCreate
 	return clone, nil
 }
 
-// watchCloneStatus checks the clone status for changing.
-func (c *Client) watchCloneStatus(ctx context.Context, cloneID string, initialStatusCode models.StatusCode) (*models.Clone, error) {
-	pollingTimer := time.NewTimer(c.pollingInterval)
-	defer pollingTimer.Stop()
-
+// watchCloneStatus waits for the clone status to change.
+// It listens to the event stream if it is available and polls the clone otherwise.
+func (c *Client) watchCloneStatus(ctx context.Context, stream *EventStream, cloneID string,
+	initialStatusCode models.StatusCode) (*models.Clone, error) {
 	var cancel context.CancelFunc
 
 	if _, ok := ctx.Deadline(); !ok {
@@ -154,6 +230,23 @@ func (c *Client) watchCloneStatus(ctx context.Context, cloneID string, initialSt
 		defer cancel()
 	}
 
+	if stream != nil {
+		clone, err := waitCloneStatus(ctx, stream, cloneID, initialStatusCode)
+		if !errors.Is(err, errStreamInterrupted) {
+			return clone, err
+		}
+
+		log.Dbg("Event stream interrupted, continue with polling:", stream.Err())
+	}
+
+	return c.pollCloneStatus(ctx, cloneID, initialStatusCode)
+}
+
+// pollCloneStatus checks the clone status for changing.
+func (c *Client) pollCloneStatus(ctx context.Context, cloneID string, initialStatusCode models.StatusCode) (*models.Clone, error) {
+	pollingTimer := time.NewTimer(c.pollingInterval)
+	defer pollingTimer.Stop()
+
 	for {
 		select {
 		case <-pollingTimer.C:
@@ -224,6 +317,11 @@ func (c *Client) ResetClone(ctx context.Context, cloneID string, params types.Re
 		return errors.Wrap(err, "failed to encode ResetClone parameters to JSON")
 	}
 
+	stream := c.openEventStream(ctx)
+	if stream != nil {
+		defer func() { _ = stream.Close() }()
+	}
+
 	request, err := http.NewRequest(http.MethodPost, u.String(), body)
 	if err != nil {
 		return errors.Wrap(err, "failed to make a request")
@@ -236,7 +334,7 @@ func (c *Client) ResetClone(ctx context.Context, cloneID string, params types.Re
 
 	defer func() { _ = response.Body.Close() }()
 
-	clone, err := c.watchCloneStatus(ctx, cloneID, models.StatusResetting)
+	clone, err := c.watchCloneStatus(ctx, stream, cloneID, models.StatusResetting)
 	if err != nil {
 		return errors.Wrap(err, "failed to watch the clone status")
 	}
@@ -276,6 +374,11 @@ func (c *Client) ResetCloneAsync(ctx context.Context, cloneID string, params typ
 func (c *Client) DestroyClone(ctx context.Context, cloneID string) error {
 	u := c.URL(fmt.Sprintf("/clone/%s", cloneID))
 
+	stream := c.openEventStream(ctx)
+	if stream != nil {
+		defer func() { _ = stream.Close() }()
+	}
+
 	request, err := http.NewRequest(http.MethodDelete, u.String(), nil)
 	if err != nil {
 		return errors.Wrap(err, "failed to make a request")
@@ -288,7 +391,7 @@ func (c *Client) DestroyClone(ctx context.Context, cloneID string) error {
 
 	defer func() { _ = response.Body.Close() }()
 
-	clone, err := c.watchCloneStatus(ctx, cloneID, models.StatusDeleting)
+	clone, err := c.watchCloneStatus(ctx, stream, cloneID, models.StatusDeleting)
 	if err != nil {
 		if err, ok := errors.Cause(err).(models.Error); ok && err.Code == models.ErrCodeNotFound {
 			return nil
@@ -323,6 +426,32 @@ func (c *Client) DestroyCloneAsync(ctx context.Context, cloneID string) error {
 	return nil
 }
 
+// DestroyClonesBySelector asynchronously destroys Database Lab clones matching the label selector.
+func (c *Client) DestroyClonesBySelector(ctx context.Context, selector string) (*models.ClonesDestroyResult, error) {
+	u := c.URL("/clones")
+	u.RawQuery = url.Values{"label": []string{selector}}.Encode()
+
+	request, err := http.NewRequest(http.MethodDelete, u.String(), nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to make a request")
+	}
+
+	response, err := c.Do(ctx, request)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get response")
+	}
+
+	defer func() { _ = response.Body.Close() }()
+
+	var result models.ClonesDestroyResult
+
+	if err := json.NewDecoder(response.Body).Decode(&result); err != nil {
+		return nil, errors.Wrap(err, "failed to decode a response body")
+	}
+
+	return &result, nil
+}
+
 // StartObservation starts a new clone observation.
 func (c *Client) StartObservation(ctx context.Context, startRequest types.StartObservationRequest) (*observer.Session, error) {
 	u := c.URL("/observation/start")
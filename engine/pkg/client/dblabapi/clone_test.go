@@ -89,6 +89,72 @@ func TestClientListClonesWithFailedRequest(t *testing.T) {
 	require.Nil(t, cloneList)
 }
 
+func TestClientListClonesBySelector(t *testing.T) {
+	expectedClones := []*models.Clone{{
+		ID:     "testCloneID",
+		Labels: map[string]string{"pipeline": "123"},
+	}}
+
+	mockClient := NewTestClient(func(req *http.Request) *http.Response {
+		assert.Equal(t, req.URL.String(), "https://example.com/clones?label=pipeline%3D123")
+		assert.Equal(t, req.Method, http.MethodGet)
+
+		body, err := json.Marshal(expectedClones)
+		require.NoError(t, err)
+
+		return &http.Response{
+			StatusCode: 200,
+			Body:       io.NopCloser(bytes.NewBuffer(body)),
+			Header:     make(http.Header),
+		}
+	})
+
+	c, err := NewClient(Options{
+		Host:              "https://example.com/",
+		VerificationToken: "token",
+	})
+	require.NoError(t, err)
+
+	c.client = mockClient
+
+	cloneList, err := c.ListClonesBySelector(context.Background(), "pipeline=123")
+	require.NoError(t, err)
+	require.EqualValues(t, expectedClones, cloneList)
+}
+
+func TestClientDestroyClonesBySelector(t *testing.T) {
+	expectedResult := &models.ClonesDestroyResult{
+		Destroyed: []string{"testCloneID"},
+		Failed:    map[string]string{"protectedCloneID": "clone is protected"},
+	}
+
+	mockClient := NewTestClient(func(req *http.Request) *http.Response {
+		assert.Equal(t, req.URL.String(), "https://example.com/clones?label=pipeline%3D123")
+		assert.Equal(t, req.Method, http.MethodDelete)
+
+		body, err := json.Marshal(expectedResult)
+		require.NoError(t, err)
+
+		return &http.Response{
+			StatusCode: 200,
+			Body:       io.NopCloser(bytes.NewBuffer(body)),
+			Header:     make(http.Header),
+		}
+	})
+
+	c, err := NewClient(Options{
+		Host:              "https://example.com/",
+		VerificationToken: "token",
+	})
+	require.NoError(t, err)
+
+	c.client = mockClient
+
+	result, err := c.DestroyClonesBySelector(context.Background(), "pipeline=123")
+	require.NoError(t, err)
+	require.EqualValues(t, expectedResult, result)
+}
+
 func TestClientCreateClone(t *testing.T) {
 	time.Local = time.UTC
 
@@ -311,6 +377,54 @@ func TestClientGetClone(t *testing.T) {
 	assert.EqualValues(t, expectedClone, clone)
 }
 
+func TestClientCloneEvents(t *testing.T) {
+	time.Local = time.UTC
+
+	expectedEvents := []models.CloneEvent{
+		{
+			CloneID:    "testCloneID",
+			Event:      models.CloneEventCreated,
+			Time:       &models.LocalTime{Time: time.Date(2020, 01, 10, 0, 0, 0, 0, time.UTC)},
+			SnapshotID: "testSnapshotID",
+			Actor:      "john",
+		},
+		{
+			CloneID: "testCloneID",
+			Event:   models.CloneEventIdleDestroyed,
+			Time:    &models.LocalTime{Time: time.Date(2020, 01, 10, 2, 0, 0, 0, time.UTC)},
+			Message: "no activity for more than 120 minutes",
+		},
+	}
+
+	mockClient := NewTestClient(func(r *http.Request) *http.Response {
+		assert.Equal(t, r.URL.String(), "https://example.com/clone/testCloneID/events")
+
+		// Prepare response.
+		responseBody, err := json.Marshal(expectedEvents)
+		require.NoError(t, err)
+
+		return &http.Response{
+			StatusCode: 200,
+			Body:       io.NopCloser(bytes.NewBuffer(responseBody)),
+			Header:     make(http.Header),
+		}
+	})
+
+	c, err := NewClient(Options{
+		Host:              "https://example.com/",
+		VerificationToken: "token",
+	})
+	require.NoError(t, err)
+
+	c.client = mockClient
+
+	// Send a request.
+	events, err := c.CloneEvents(context.Background(), "testCloneID")
+	require.NoError(t, err)
+
+	assert.EqualValues(t, expectedEvents, events)
+}
+
 func TestClientGetCloneWithFailedRequest(t *testing.T) {
 	mockClient := NewTestClient(func(req *http.Request) *http.Response {
 		return &http.Response{
@@ -366,7 +480,8 @@ func TestClientUpdateClone(t *testing.T) {
 		err = json.Unmarshal(requestBody, &updateRequest)
 		require.NoError(t, err)
 
-		cloneModel.Protected = updateRequest.Protected
+		require.NotNil(t, updateRequest.Protected)
+		cloneModel.Protected = *updateRequest.Protected
 
 		// Prepare response.
 		responseBody, err := json.Marshal(cloneModel)
@@ -388,8 +503,10 @@ func TestClientUpdateClone(t *testing.T) {
 	c.client = mockClient
 
 	// Send a request.
+	protected := false
+
 	newClone, err := c.UpdateClone(context.Background(), cloneModel.ID, types.CloneUpdateRequest{
-		Protected: false,
+		Protected: &protected,
 	})
 	require.NoError(t, err)
 
@@ -428,6 +545,10 @@ func TestClientUpdateCloneWithFailedRequest(t *testing.T) {
 
 func TestClientDestroyClone(t *testing.T) {
 	mockClient := NewTestClient(func(r *http.Request) *http.Response {
+		if r.URL.Path == "/events" {
+			return eventStreamNotFound()
+		}
+
 		assert.Equal(t, r.URL.String(), "https://example.com/clone/testCloneID")
 
 		var responseBody []byte
@@ -497,6 +618,10 @@ func TestClientDestroyCloneWithFailedRequest(t *testing.T) {
 		Message: "Requested object does not exist. Specify your request.",
 	}
 	mockClient := NewTestClient(func(req *http.Request) *http.Response {
+		if req.URL.Path == "/events" {
+			return eventStreamNotFound()
+		}
+
 		assert.Equal(t, req.URL.String(), "https://example.com/clone/testCloneID")
 
 		responseBody, err := json.Marshal(errorNotFound)
@@ -524,6 +649,10 @@ func TestClientDestroyCloneWithFailedRequest(t *testing.T) {
 
 func TestClientResetClone(t *testing.T) {
 	mockClient := NewTestClient(func(r *http.Request) *http.Response {
+		if r.URL.Path == "/events" {
+			return eventStreamNotFound()
+		}
+
 		var responseBody []byte
 
 		if r.Method == http.MethodPost {
@@ -595,6 +724,10 @@ func TestClientResetCloneWithFailedRequest(t *testing.T) {
 		Message: "Check your verification token.",
 	}
 	mockClient := NewTestClient(func(req *http.Request) *http.Response {
+		if req.URL.Path == "/events" {
+			return eventStreamNotFound()
+		}
+
 		assert.Equal(t, req.URL.String(), "https://example.com/clone/testCloneID/reset")
 
 		responseBody, err := json.Marshal(errorUnauthorized)
@@ -0,0 +1,172 @@
+/*
+2022 © Postgres.ai
+*/
+
+package dblabapi
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/log"
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/models"
+)
+
+const eventStreamContentType = "text/event-stream"
+
+// errStreamInterrupted means that the event stream has ended before the expected event came.
+var errStreamInterrupted = errors.New("event stream interrupted")
+
+// EventStream delivers changes of clones, snapshots, and retrieval sent by the server.
+type EventStream struct {
+	body      io.ReadCloser
+	events    chan models.StreamEvent
+	done      chan struct{}
+	closeOnce sync.Once
+	err       error
+}
+
+// SubscribeEvents opens the event stream of the instance.
+func (c *Client) SubscribeEvents(ctx context.Context) (*EventStream, error) {
+	u := c.URL("/events")
+
+	request, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to make a request")
+	}
+
+	request.Header.Set("Accept", eventStreamContentType)
+
+	response, err := c.Do(ctx, request)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get response")
+	}
+
+	if mediaType, _, _ := mime.ParseMediaType(response.Header.Get("Content-Type")); mediaType != eventStreamContentType {
+		_ = response.Body.Close()
+
+		return nil, errors.Errorf("unexpected content type of the event stream: %q", response.Header.Get("Content-Type"))
+	}
+
+	stream := &EventStream{
+		body:   response.Body,
+		events: make(chan models.StreamEvent),
+		done:   make(chan struct{}),
+	}
+
+	go stream.read()
+
+	return stream, nil
+}
+
+// Events returns the channel of received events. The channel is closed when the stream ends.
+func (s *EventStream) Events() <-chan models.StreamEvent {
+	return s.events
+}
+
+// Err returns the error that has ended the stream. It must be called after the channel of events is closed.
+func (s *EventStream) Err() error {
+	return s.err
+}
+
+// Close closes the stream.
+func (s *EventStream) Close() error {
+	var err error
+
+	s.closeOnce.Do(func() {
+		close(s.done)
+		err = s.body.Close()
+	})
+
+	return err
+}
+
+// read parses server-sent events until the stream ends.
+func (s *EventStream) read() {
+	defer close(s.events)
+
+	scanner := bufio.NewScanner(s.body)
+	data := bytes.Buffer{}
+
+	for scanner.Scan() {
+		line := scanner.Bytes()
+
+		switch {
+		case len(line) == 0:
+			if data.Len() == 0 {
+				continue
+			}
+
+			var event models.StreamEvent
+
+			if err := json.Unmarshal(data.Bytes(), &event); err != nil {
+				s.err = errors.Wrap(err, "failed to decode a stream event")
+				return
+			}
+
+			data.Reset()
+
+			select {
+			case s.events <- event:
+			case <-s.done:
+				return
+			}
+
+		case bytes.HasPrefix(line, []byte("data:")):
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+
+			data.Write(bytes.TrimPrefix(bytes.TrimPrefix(line, []byte("data:")), []byte(" ")))
+		}
+	}
+
+	s.err = scanner.Err()
+}
+
+// openEventStream subscribes to the event stream, so changes are not missed while a request is being made.
+// It returns nil if the server does not provide the stream, then clients fall back to polling.
+func (c *Client) openEventStream(ctx context.Context) *EventStream {
+	stream, err := c.SubscribeEvents(ctx)
+	if err != nil {
+		log.Dbg("Event stream is not available:", err)
+		return nil
+	}
+
+	return stream
+}
+
+// waitCloneStatus waits for the event that changes the clone status.
+func waitCloneStatus(ctx context.Context, stream *EventStream, cloneID string, initialStatusCode models.StatusCode) (*models.Clone, error) {
+	for {
+		select {
+		case event, ok := <-stream.Events():
+			if !ok {
+				return nil, errStreamInterrupted
+			}
+
+			if event.Clone == nil || event.Clone.ID != cloneID {
+				continue
+			}
+
+			if event.Type == models.StreamCloneDestroyed {
+				return nil, *models.New(models.ErrCodeNotFound, "clone not found")
+			}
+
+			if event.Clone.Status.Code != initialStatusCode {
+				return event.Clone, nil
+			}
+
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package dblabapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/client/dblabapi/types"
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/models"
+)
+
+func eventStreamNotFound() *http.Response {
+	return &http.Response{
+		StatusCode: http.StatusNotFound,
+		Body:       io.NopCloser(bytes.NewBufferString(`{"code":"NOT_FOUND","message":"Not found"}`)),
+		Header:     make(http.Header),
+	}
+}
+
+func eventStreamResponse(t *testing.T, events ...models.StreamEvent) *http.Response {
+	t.Helper()
+
+	body := bytes.NewBufferString(": keep-alive\n\n")
+
+	for _, event := range events {
+		data, err := json.Marshal(event)
+		require.NoError(t, err)
+
+		_, err = fmt.Fprintf(body, "event: %s\ndata: %s\n\n", event.Type, data)
+		require.NoError(t, err)
+	}
+
+	header := make(http.Header)
+	header.Set("Content-Type", "text/event-stream")
+
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(body), Header: header}
+}
+
+func TestClientSubscribeEvents(t *testing.T) {
+	mockClient := NewTestClient(func(r *http.Request) *http.Response {
+		assert.Equal(t, "https://example.com/events", r.URL.String())
+		assert.Equal(t, "token", r.Header.Get(verificationHeader))
+
+		return eventStreamResponse(t,
+			models.StreamEvent{Type: models.StreamSnapshotAdded, Snapshot: &models.Snapshot{ID: "pool@snapshot"}},
+			models.StreamEvent{Type: models.StreamRetrievalStatus, Retrieval: &models.Retrieving{Status: models.Finished}},
+		)
+	})
+
+	c, err := NewClient(Options{Host: "https://example.com/", VerificationToken: "token"})
+	require.NoError(t, err)
+
+	c.client = mockClient
+
+	stream, err := c.SubscribeEvents(context.Background())
+	require.NoError(t, err)
+
+	defer func() { _ = stream.Close() }()
+
+	event := <-stream.Events()
+	assert.Equal(t, models.StreamSnapshotAdded, event.Type)
+	assert.Equal(t, "pool@snapshot", event.Snapshot.ID)
+
+	event = <-stream.Events()
+	assert.Equal(t, models.StreamRetrievalStatus, event.Type)
+	assert.Equal(t, models.Finished, event.Retrieval.Status)
+
+	_, ok := <-stream.Events()
+	assert.False(t, ok)
+	assert.NoError(t, stream.Err())
+}
+
+func TestClientCreateCloneWithEventStream(t *testing.T) {
+	creating := models.Clone{ID: "testCloneID", Status: models.Status{Code: models.StatusCreating}}
+	ready := models.Clone{ID: "testCloneID", Status: models.Status{Code: models.StatusOK, Message: models.CloneMessageOK}}
+	other := models.Clone{ID: "otherCloneID", Status: models.Status{Code: models.StatusFatal}}
+
+	mockClient := NewTestClient(func(r *http.Request) *http.Response {
+		switch {
+		case r.URL.Path == "/events":
+			return eventStreamResponse(t,
+				models.StreamEvent{Type: models.StreamCloneStatus, Clone: &creating},
+				models.StreamEvent{Type: models.StreamCloneStatus, Clone: &other},
+				models.StreamEvent{Type: models.StreamCloneStatus, Clone: &ready},
+			)
+
+		case r.Method == http.MethodPost:
+			body, err := json.Marshal(creating)
+			require.NoError(t, err)
+
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewBuffer(body)), Header: make(http.Header)}
+		}
+
+		t.Errorf("unexpected request: %s %s", r.Method, r.URL)
+
+		return eventStreamNotFound()
+	})
+
+	c, err := NewClient(Options{Host: "https://example.com/", VerificationToken: "token"})
+	require.NoError(t, err)
+
+	c.client = mockClient
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	clone, err := c.CreateClone(ctx, types.CloneCreateRequest{ID: "testCloneID"})
+	require.NoError(t, err)
+	assert.Equal(t, ready, *clone)
+}
+
+func TestClientDestroyCloneWithEventStream(t *testing.T) {
+	deleting := models.Clone{ID: "testCloneID", Status: models.Status{Code: models.StatusDeleting}}
+
+	mockClient := NewTestClient(func(r *http.Request) *http.Response {
+		switch {
+		case r.URL.Path == "/events":
+			return eventStreamResponse(t,
+				models.StreamEvent{Type: models.StreamCloneStatus, Clone: &deleting},
+				models.StreamEvent{Type: models.StreamCloneDestroyed, Clone: &deleting},
+			)
+
+		case r.Method == http.MethodDelete:
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewBuffer(nil)), Header: make(http.Header)}
+		}
+
+		t.Errorf("unexpected request: %s %s", r.Method, r.URL)
+
+		return eventStreamNotFound()
+	})
+
+	c, err := NewClient(Options{Host: "https://example.com/", VerificationToken: "token"})
+	require.NoError(t, err)
+
+	c.client = mockClient
+
+	require.NoError(t, c.DestroyClone(context.Background(), "testCloneID"))
+}
+
+func TestClientResetCloneWithInterruptedEventStream(t *testing.T) {
+	resetting := models.Clone{ID: "testCloneID", Status: models.Status{Code: models.StatusResetting}}
+	ready := models.Clone{ID: "testCloneID", Status: models.Status{Code: models.StatusOK}}
+
+	mockClient := NewTestClient(func(r *http.Request) *http.Response {
+		var clone models.Clone
+
+		switch {
+		case r.URL.Path == "/events":
+			// The stream ends before the clone is ready, so the client falls back to polling.
+			return eventStreamResponse(t, models.StreamEvent{Type: models.StreamCloneStatus, Clone: &resetting})
+
+		case r.Method == http.MethodPost:
+			clone = resetting
+
+		case r.Method == http.MethodGet:
+			clone = ready
+		}
+
+		body, err := json.Marshal(clone)
+		require.NoError(t, err)
+
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewBuffer(body)), Header: make(http.Header)}
+	})
+
+	c, err := NewClient(Options{Host: "https://example.com/", VerificationToken: "token"})
+	require.NoError(t, err)
+
+	c.client = mockClient
+	c.pollingInterval = time.Millisecond
+
+	require.NoError(t, c.ResetClone(context.Background(), "testCloneID", types.ResetCloneRequest{}))
+}
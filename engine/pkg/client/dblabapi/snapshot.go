@@ -5,13 +5,16 @@
 package dblabapi
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 
 	"github.com/pkg/errors"
 
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/client/dblabapi/types"
 	"gitlab.com/postgres-ai/database-lab/v3/pkg/models"
 )
 
@@ -49,3 +52,104 @@ func (c *Client) ListSnapshotsRaw(ctx context.Context) (io.ReadCloser, error) {
 
 	return response.Body, nil
 }
+
+// CreateSnapshotFromClone creates a new snapshot from the current state of a clone.
+func (c *Client) CreateSnapshotFromClone(ctx context.Context, cloneID string) (*models.Snapshot, error) {
+	u := c.URL(fmt.Sprintf("/clone/%s/snapshot", cloneID))
+
+	request, err := http.NewRequest(http.MethodPost, u.String(), nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to make a request")
+	}
+
+	response, err := c.Do(ctx, request)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get response")
+	}
+
+	defer func() { _ = response.Body.Close() }()
+
+	var snapshot models.Snapshot
+
+	if err := json.NewDecoder(response.Body).Decode(&snapshot); err != nil {
+		return nil, errors.Wrap(err, "failed to decode a response body")
+	}
+
+	return &snapshot, nil
+}
+
+// CreateSnapshot starts taking a new snapshot of the pool data in the background.
+func (c *Client) CreateSnapshot(ctx context.Context, snapshotRequest types.SnapshotCreateRequest) (*types.SnapshotTakeResponse, error) {
+	u := c.URL("/snapshot")
+
+	var response types.SnapshotTakeResponse
+
+	if err := c.request(ctx, u, snapshotRequest, &response); err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+// CreatePITRSnapshot starts taking a new snapshot of the data recovered to the target time from the WAL archive.
+func (c *Client) CreatePITRSnapshot(ctx context.Context, pitrRequest types.SnapshotPITRRequest) (*types.SnapshotTakeResponse, error) {
+	u := c.URL("/snapshot/pitr")
+
+	var response types.SnapshotTakeResponse
+
+	if err := c.request(ctx, u, pitrRequest, &response); err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+// DestroySnapshot destroys a snapshot.
+func (c *Client) DestroySnapshot(ctx context.Context, snapshotID string) error {
+	u := c.URL(fmt.Sprintf("/snapshot/%s", snapshotID))
+
+	request, err := http.NewRequest(http.MethodDelete, u.String(), nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to make a request")
+	}
+
+	response, err := c.Do(ctx, request)
+	if err != nil {
+		return errors.Wrap(err, "failed to get response")
+	}
+
+	defer func() { _ = response.Body.Close() }()
+
+	return nil
+}
+
+// UpdateSnapshot updates an existing snapshot.
+func (c *Client) UpdateSnapshot(ctx context.Context, snapshotID string,
+	updateRequest types.SnapshotUpdateRequest) (*models.Snapshot, error) {
+	u := c.URL(fmt.Sprintf("/snapshot/%s", snapshotID))
+
+	body := bytes.NewBuffer(nil)
+	if err := json.NewEncoder(body).Encode(updateRequest); err != nil {
+		return nil, errors.Wrap(err, "failed to encode SnapshotUpdateRequest")
+	}
+
+	request, err := http.NewRequest(http.MethodPatch, u.String(), body)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to make a request")
+	}
+
+	response, err := c.Do(ctx, request)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get response")
+	}
+
+	defer func() { _ = response.Body.Close() }()
+
+	var snapshot models.Snapshot
+
+	if err := json.NewDecoder(response.Body).Decode(&snapshot); err != nil {
+		return nil, errors.Wrap(err, "failed to decode a response body")
+	}
+
+	return &snapshot, nil
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"gitlab.com/postgres-ai/database-lab/v3/pkg/client/dblabapi/types"
 	"gitlab.com/postgres-ai/database-lab/v3/pkg/models"
 )
 
@@ -76,3 +77,156 @@ func TestClientListSnapshotsWithFailedRequest(t *testing.T) {
 	require.EqualError(t, err, "failed to get response: EOF")
 	require.Nil(t, snapshots)
 }
+
+func TestClientCreateSnapshotFromClone(t *testing.T) {
+	expectedSnapshot := &models.Snapshot{
+		ID:          "pool/dblab_clone_6000@snapshot_20200110000000",
+		CreatedAt:   &models.LocalTime{Time: time.Date(2020, 01, 10, 0, 0, 5, 0, time.UTC)},
+		DataStateAt: &models.LocalTime{Time: time.Date(2020, 01, 10, 0, 0, 0, 0, time.UTC)},
+		Pool:        "pool",
+		Parent:      "pool@snapshot_20200109000000",
+		CloneID:     "testCloneID",
+	}
+
+	mockClient := NewTestClient(func(req *http.Request) *http.Response {
+		assert.Equal(t, req.URL.String(), "https://example.com/clone/testCloneID/snapshot")
+		assert.Equal(t, req.Method, http.MethodPost)
+
+		// Prepare response.
+		body, err := json.Marshal(expectedSnapshot)
+		require.NoError(t, err)
+
+		return &http.Response{
+			StatusCode: 201,
+			Body:       io.NopCloser(bytes.NewBuffer(body)),
+			Header:     make(http.Header),
+		}
+	})
+
+	c, err := NewClient(Options{
+		Host:              "https://example.com/",
+		VerificationToken: "testVerify",
+	})
+	require.NoError(t, err)
+
+	c.client = mockClient
+
+	snapshot, err := c.CreateSnapshotFromClone(context.Background(), "testCloneID")
+	require.NoError(t, err)
+
+	assert.EqualValues(t, expectedSnapshot, snapshot)
+}
+
+func TestClientCreatePITRSnapshot(t *testing.T) {
+	expectedResponse := &types.SnapshotTakeResponse{
+		Status:  "snapshotting",
+		Message: "Recovering the data to the target time has been started",
+	}
+
+	mockClient := NewTestClient(func(req *http.Request) *http.Response {
+		assert.Equal(t, req.URL.String(), "https://example.com/snapshot/pitr")
+		assert.Equal(t, req.Method, http.MethodPost)
+
+		var pitrRequest types.SnapshotPITRRequest
+		require.NoError(t, json.NewDecoder(req.Body).Decode(&pitrRequest))
+		assert.Equal(t, types.SnapshotPITRRequest{TargetTime: "2020-01-09T14:03:27Z", Name: "incident"}, pitrRequest)
+
+		// Prepare response.
+		body, err := json.Marshal(expectedResponse)
+		require.NoError(t, err)
+
+		return &http.Response{
+			StatusCode: 202,
+			Body:       io.NopCloser(bytes.NewBuffer(body)),
+			Header:     make(http.Header),
+		}
+	})
+
+	c, err := NewClient(Options{
+		Host:              "https://example.com/",
+		VerificationToken: "testVerify",
+	})
+	require.NoError(t, err)
+
+	c.client = mockClient
+
+	response, err := c.CreatePITRSnapshot(context.Background(),
+		types.SnapshotPITRRequest{TargetTime: "2020-01-09T14:03:27Z", Name: "incident"})
+	require.NoError(t, err)
+
+	assert.EqualValues(t, expectedResponse, response)
+}
+
+func TestClientUpdateSnapshot(t *testing.T) {
+	expectedSnapshot := &models.Snapshot{
+		ID:          "pool@snapshot_20200110000000",
+		CreatedAt:   &models.LocalTime{Time: time.Date(2020, 01, 10, 0, 0, 5, 0, time.UTC)},
+		DataStateAt: &models.LocalTime{Time: time.Date(2020, 01, 10, 0, 0, 0, 0, time.UTC)},
+		Pool:        "pool",
+		Protected:   true,
+	}
+
+	mockClient := NewTestClient(func(req *http.Request) *http.Response {
+		assert.Equal(t, req.URL.String(), "https://example.com/snapshot/pool@snapshot_20200110000000")
+		assert.Equal(t, req.Method, http.MethodPatch)
+
+		var updateRequest types.SnapshotUpdateRequest
+		require.NoError(t, json.NewDecoder(req.Body).Decode(&updateRequest))
+		assert.True(t, updateRequest.Protected)
+
+		// Prepare response.
+		body, err := json.Marshal(expectedSnapshot)
+		require.NoError(t, err)
+
+		return &http.Response{
+			StatusCode: 200,
+			Body:       io.NopCloser(bytes.NewBuffer(body)),
+			Header:     make(http.Header),
+		}
+	})
+
+	c, err := NewClient(Options{
+		Host:              "https://example.com/",
+		VerificationToken: "testVerify",
+	})
+	require.NoError(t, err)
+
+	c.client = mockClient
+
+	snapshot, err := c.UpdateSnapshot(context.Background(), "pool@snapshot_20200110000000", types.SnapshotUpdateRequest{Protected: true})
+	require.NoError(t, err)
+
+	assert.EqualValues(t, expectedSnapshot, snapshot)
+}
+
+func TestClientDestroySnapshotWithFailedRequest(t *testing.T) {
+	mockClient := NewTestClient(func(r *http.Request) *http.Response {
+		assert.Equal(t, r.Method, http.MethodDelete)
+
+		errorBadRequest := models.Error{
+			Code:    "BAD_REQUEST",
+			Message: "snapshot has dependent clones",
+		}
+
+		body, err := json.Marshal(errorBadRequest)
+		require.NoError(t, err)
+
+		return &http.Response{
+			StatusCode: 400,
+			Body:       io.NopCloser(bytes.NewBuffer(body)),
+			Header:     make(http.Header),
+		}
+	})
+
+	c, err := NewClient(Options{
+		Host:              "https://example.com/",
+		VerificationToken: "testVerify",
+	})
+	require.NoError(t, err)
+
+	c.client = mockClient
+
+	err = c.DestroySnapshot(context.Background(), "pool@snapshot_20200110000000")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "snapshot has dependent clones")
+}
@@ -0,0 +1,23 @@
+/*
+2022 © Postgres.ai
+*/
+
+package types
+
+// BranchCreateRequest describes params of a branch create request.
+type BranchCreateRequest struct {
+	Name       string `json:"name"`
+	BaseBranch string `json:"baseBranch"`
+	SnapshotID string `json:"snapshotId"`
+}
+
+// BranchCommitRequest describes params of a request to commit the clone state to a branch.
+type BranchCommitRequest struct {
+	CloneID string `json:"cloneId"`
+	Message string `json:"message"`
+}
+
+// BranchResetRequest describes params of a request to move a branch head.
+type BranchResetRequest struct {
+	SnapshotID string `json:"snapshotId"`
+}
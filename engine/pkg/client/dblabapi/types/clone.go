@@ -5,18 +5,32 @@
 // Package types provides request structures for Database Lab HTTP API.
 package types
 
+import (
+	"time"
+)
+
 // CloneCreateRequest represents clone params of a create request.
 type CloneCreateRequest struct {
 	ID        string                     `json:"id"`
 	Protected bool                       `json:"protected"`
 	DB        *DatabaseRequest           `json:"db"`
 	Snapshot  *SnapshotCloneFieldRequest `json:"snapshot"`
+	Branch    string                     `json:"branch"`
 	ExtraConf map[string]string          `json:"extra_conf"`
+	Resources *ResourcesRequest          `json:"resources"`
+	TTL       string                     `json:"ttl"`
+	ExpiresAt *time.Time                 `json:"expires_at"`
+	Labels    map[string]string          `json:"labels"`
 }
 
 // CloneUpdateRequest represents params of an update request.
+// TTL or ExpiresAt reschedule the clone deletion, zero TTL cancels it.
+// Protected and Labels change the clone only if specified, Labels replace the clone labels.
 type CloneUpdateRequest struct {
-	Protected bool `json:"protected"`
+	Protected *bool             `json:"protected,omitempty"`
+	TTL       string            `json:"ttl,omitempty"`
+	ExpiresAt *time.Time        `json:"expires_at,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
 }
 
 // DatabaseRequest represents database params of a clone request.
@@ -27,6 +41,15 @@ type DatabaseRequest struct {
 	DBName     string `json:"db_name"`
 }
 
+// ResourcesRequest represents container resources of a create request.
+// Memory and shared memory sizes are accepted in the Docker format, for example, "512m" or "2g".
+type ResourcesRequest struct {
+	CPUs        float64 `json:"cpus"`
+	Memory      string  `json:"memory"`
+	ShmSize     string  `json:"shm_size"`
+	BlkioWeight uint16  `json:"blkio_weight"`
+}
+
 // SnapshotCloneFieldRequest represents snapshot params of a create request.
 type SnapshotCloneFieldRequest struct {
 	ID string `json:"id"`
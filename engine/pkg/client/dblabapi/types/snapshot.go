@@ -0,0 +1,27 @@
+/*
+2022 © Postgres.ai
+*/
+
+package types
+
+// SnapshotCreateRequest describes params of a snapshot create request.
+type SnapshotCreateRequest struct {
+	PoolName string `json:"poolName"`
+}
+
+// SnapshotTakeResponse describes the response to a request to take a snapshot in the background.
+type SnapshotTakeResponse struct {
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+// SnapshotUpdateRequest describes params of a snapshot update request.
+type SnapshotUpdateRequest struct {
+	Protected bool `json:"protected"`
+}
+
+// SnapshotPITRRequest describes params of a point-in-time snapshot request.
+type SnapshotPITRRequest struct {
+	TargetTime string `json:"targetTime"`
+	Name       string `json:"name"`
+}
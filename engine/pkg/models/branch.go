@@ -0,0 +1,12 @@
+/*
+2022 © Postgres.ai
+*/
+
+package models
+
+// Branch defines a named chain of snapshots.
+type Branch struct {
+	Name       string     `json:"name"`
+	SnapshotID string     `json:"snapshotId"`
+	CreatedAt  *LocalTime `json:"createdAt"`
+}
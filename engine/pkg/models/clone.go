@@ -6,22 +6,41 @@ package models
 
 // Clone defines a clone model.
 type Clone struct {
-	ID        string        `json:"id"`
-	Snapshot  *Snapshot     `json:"snapshot"`
-	Protected bool          `json:"protected"`
-	DeleteAt  *LocalTime    `json:"deleteAt"`
-	CreatedAt *LocalTime    `json:"createdAt"`
-	Status    Status        `json:"status"`
-	DB        Database      `json:"db"`
-	Metadata  CloneMetadata `json:"metadata"`
+	ID        string            `json:"id"`
+	Snapshot  *Snapshot         `json:"snapshot"`
+	Branch    string            `json:"branch,omitempty"`
+	Protected bool              `json:"protected"`
+	DeleteAt  *LocalTime        `json:"deleteAt"`
+	CreatedAt *LocalTime        `json:"createdAt"`
+	Status    Status            `json:"status"`
+	DB        Database          `json:"db"`
+	Metadata  CloneMetadata     `json:"metadata"`
+	Resources *Resources        `json:"resources,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Owner     string            `json:"owner,omitempty"`
+}
+
+// ClonesDestroyResult represents the result of destroying clones by a label selector.
+type ClonesDestroyResult struct {
+	Destroyed []string          `json:"destroyed"`
+	Failed    map[string]string `json:"failed,omitempty"`
+}
+
+// Resources defines container resources of a clone.
+type Resources struct {
+	CPUs        float64 `json:"cpus,omitempty"`
+	Memory      string  `json:"memory,omitempty"`
+	ShmSize     string  `json:"shmSize,omitempty"`
+	BlkioWeight uint16  `json:"blkioWeight,omitempty"`
 }
 
 // CloneMetadata contains fields describing a clone model.
 type CloneMetadata struct {
-	CloneDiffSize  uint64  `json:"cloneDiffSize"`
-	LogicalSize    uint64  `json:"logicalSize"`
-	CloningTime    float64 `json:"cloningTime"`
-	MaxIdleMinutes uint    `json:"maxIdleMinutes"`
+	CloneDiffSize    uint64  `json:"cloneDiffSize"`
+	LogicalSize      uint64  `json:"logicalSize"`
+	CloningTime      float64 `json:"cloningTime"`
+	MaxIdleMinutes   uint    `json:"maxIdleMinutes"`
+	ProxyConnections int64   `json:"proxyConnections"`
 }
 
 // CloneView represents a view of clone model.
@@ -37,3 +56,29 @@ type CloneMetadataView struct {
 	CloneDiffSize Size `json:"cloneDiffSize"`
 	LogicalSize   Size `json:"logicalSize"`
 }
+
+// CloneEvent describes an event in the history of a clone.
+type CloneEvent struct {
+	CloneID            string     `json:"cloneId"`
+	Event              string     `json:"event"`
+	Time               *LocalTime `json:"time"`
+	Status             StatusCode `json:"status,omitempty"`
+	SnapshotID         string     `json:"snapshotId,omitempty"`
+	PreviousSnapshotID string     `json:"previousSnapshotId,omitempty"`
+	Actor              string     `json:"actor,omitempty"`
+	Message            string     `json:"message,omitempty"`
+}
+
+// Events of the clone history.
+const (
+	CloneEventCreated            = "created"
+	CloneEventStatusChanged      = "status_changed"
+	CloneEventReset              = "reset"
+	CloneEventObservationStarted = "observation_started"
+	CloneEventObservationStopped = "observation_stopped"
+	CloneEventDestroyRequested   = "destroy_requested"
+	CloneEventIdleDestroyed      = "idle_destroyed"
+	CloneEventExpired            = "expired"
+	CloneEventDestroyed          = "destroyed"
+	CloneEventError              = "error"
+)
@@ -12,4 +12,5 @@ type Database struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
 	DBName   string `json:"dbName"`
+	SSLMode  string `json:"sslMode,omitempty"`
 }
@@ -13,6 +13,7 @@ const (
 	ErrCodeInternal     ErrorCode = "INTERNAL_ERROR"
 	ErrCodeBadRequest   ErrorCode = "BAD_REQUEST"
 	ErrCodeUnauthorized ErrorCode = "UNAUTHORIZED"
+	ErrCodeForbidden    ErrorCode = "FORBIDDEN"
 	ErrCodeNotFound     ErrorCode = "NOT_FOUND"
 )
 
@@ -40,6 +40,16 @@ type Cloning struct {
 	ExpectedCloningTime float64  `json:"expectedCloningTime"`
 	NumClones           uint64   `json:"numClones"`
 	Clones              []*Clone `json:"clones"`
+	Quotas              *Quotas  `json:"quotas,omitempty"`
+}
+
+// Quotas represents clone quotas and their usage. Zero limits mean no limits.
+type Quotas struct {
+	MaxClonesPerToken     uint64 `json:"maxClonesPerToken"`
+	TokenClones           uint64 `json:"tokenClones"`
+	MaxClonesPerSnapshot  uint64 `json:"maxClonesPerSnapshot"`
+	MaxTotalCloneDiffSize uint64 `json:"maxTotalCloneDiffSize"`
+	TotalCloneDiffSize    uint64 `json:"totalCloneDiffSize"`
 }
 
 // Engine represents info about Database Lab Engine instance.
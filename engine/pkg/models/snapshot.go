@@ -13,6 +13,10 @@ type Snapshot struct {
 	LogicalSize  uint64     `json:"logicalSize"`
 	Pool         string     `json:"pool"`
 	NumClones    int        `json:"numClones"`
+	Protected    bool       `json:"protected"`
+	Parent       string     `json:"parent,omitempty"`
+	CloneID      string     `json:"cloneId,omitempty"`
+	Message      string     `json:"message,omitempty"`
 }
 
 // SnapshotView represents a view of snapshot.
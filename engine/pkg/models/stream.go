@@ -0,0 +1,22 @@
+/*
+2022 © Postgres.ai
+*/
+
+package models
+
+// StreamEvent describes a change of the instance state delivered via the event stream.
+type StreamEvent struct {
+	Type      string      `json:"type"`
+	Time      *LocalTime  `json:"time"`
+	Clone     *Clone      `json:"clone,omitempty"`
+	Snapshot  *Snapshot   `json:"snapshot,omitempty"`
+	Retrieval *Retrieving `json:"retrieval,omitempty"`
+}
+
+// Types of stream events.
+const (
+	StreamCloneStatus     = "clone_status"
+	StreamCloneDestroyed  = "clone_destroyed"
+	StreamSnapshotAdded   = "snapshot_added"
+	StreamRetrievalStatus = "retrieval_status"
+)
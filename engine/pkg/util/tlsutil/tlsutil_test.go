@@ -0,0 +1,45 @@
+package tlsutil
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateSelfSigned(t *testing.T) {
+	certPEM, keyPEM, err := GenerateSelfSigned("dblab", []string{"localhost", "127.0.0.1"}, time.Hour)
+	require.NoError(t, err)
+
+	block, _ := pem.Decode(certPEM)
+	require.NotNil(t, block)
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+	require.NoError(t, err)
+	assert.Equal(t, "dblab", cert.Subject.CommonName)
+	assert.Equal(t, []string{"localhost"}, cert.DNSNames)
+	require.Len(t, cert.IPAddresses, 1)
+	assert.Equal(t, "127.0.0.1", cert.IPAddresses[0].String())
+
+	dir := t.TempDir()
+	certFile := path.Join(dir, "server.crt")
+	keyFile := path.Join(dir, "server.key")
+
+	require.NoError(t, os.WriteFile(certFile, certPEM, 0600))
+	require.NoError(t, os.WriteFile(keyFile, keyPEM, 0600))
+
+	_, err = LoadKeyPair(certFile, keyFile)
+	require.NoError(t, err)
+
+	pool, err := LoadCertPool(certFile)
+	require.NoError(t, err)
+	assert.NotNil(t, pool)
+
+	_, err = LoadCertPool(keyFile)
+	assert.Error(t, err)
+}
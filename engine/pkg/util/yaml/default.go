@@ -4,6 +4,7 @@ package yaml
 func DefaultConfigMask() *Mask {
 	sensitive := []string{
 		"server.verificationToken",
+		"server.tokens.*.token",
 		"platform.accessToken",
 		"retrieval.spec.logicalDump.options.source.connection.password",
 	}
@@ -7,7 +7,10 @@ import (
 	"gopkg.in/yaml.v3"
 )
 
-const maskValue = "****"
+const (
+	maskValue        = "****"
+	sequenceWildcard = "*"
+)
 
 // Mask is a YAML masking utility
 type Mask struct {
@@ -29,16 +32,38 @@ func NewMask(paths []string) *Mask {
 // Yaml copies node values
 func (c *Mask) Yaml(node *yaml.Node) {
 	for i := 0; i < len(c.paths); i++ {
-		child, found := FindNodeAtPath(node, c.paths[i])
-		if !found {
+		maskAtPath(node, c.paths[i])
+	}
+}
+
+// maskAtPath masks scalar values at the path. The wildcard segment matches all items of a sequence.
+func maskAtPath(node *yaml.Node, path []string) {
+	for i, segment := range path {
+		if segment != sequenceWildcard {
 			continue
 		}
 
-		if child.Kind != yaml.ScalarNode {
-			continue
+		parent, found := FindNodeAtPath(node, path[:i])
+		if !found || parent.Kind != yaml.SequenceNode {
+			return
+		}
+
+		for _, item := range parent.Content {
+			maskAtPath(item, path[i+1:])
 		}
 
-		child.Value = maskValue
-		child.Tag = "!!str"
+		return
+	}
+
+	child, found := FindNodeAtPath(node, path)
+	if !found {
+		return
 	}
+
+	if child.Kind != yaml.ScalarNode {
+		return
+	}
+
+	child.Value = maskValue
+	child.Tag = "!!str"
 }
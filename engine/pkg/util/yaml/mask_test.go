@@ -31,3 +31,37 @@ func TestMask(t *testing.T) {
 	r.NotNil(nonSensitive)
 	r.Equal("123", nonSensitive.Value)
 }
+
+const yamlSequenceStr = `
+root:
+  items:
+    - name: first
+      secret: "firstValue"
+    - name: second
+      secret: "secondValue"
+`
+
+func TestMaskSequence(t *testing.T) {
+	r := require.New(t)
+	node := &yaml.Node{}
+
+	err := yaml.Unmarshal([]byte(yamlSequenceStr), node)
+	r.NoError(err)
+
+	mask := NewMask([]string{"root.items.*.secret"})
+	mask.Yaml(node)
+
+	items, found := FindNodeAtPathString(node, "root.items")
+	r.True(found)
+	r.Len(items.Content, 2)
+
+	for _, item := range items.Content {
+		secret, _ := FindNodeAtPathString(item, "secret")
+		r.NotNil(secret)
+		r.Equal(maskValue, secret.Value)
+
+		name, _ := FindNodeAtPathString(item, "name")
+		r.NotNil(name)
+		r.NotEqual(maskValue, name.Value)
+	}
+}
@@ -95,11 +95,10 @@ source "${DIR}/_zfs.file.sh"
 configDir="$HOME/.dblab/engine/configs"
 metaDir="$HOME/.dblab/engine/meta"
 
-# Copy the contents of configuration example 
+# Copy the contents of configuration example from the checkout, so the test matches the engine being built
 mkdir -p "${configDir}"
 
-curl https://gitlab.com/postgres-ai/database-lab/-/raw/"${TAG:-master}"/engine/configs/config.example.physical_generic.yml \
- --output "${configDir}/server.yml"
+cp "${DIR}/../configs/config.example.physical_pgbasebackup.yml" "${configDir}/server.yml"
 
 # Edit the following options
 yq eval -i '
@@ -111,16 +110,16 @@ yq eval -i '
   .provision.portPool.from = env(DLE_PORT_POOL_FROM) |
   .provision.portPool.to = env(DLE_PORT_POOL_TO) |
   .databaseContainer.dockerImage = "postgresai/extended-postgres:" + strenv(POSTGRES_VERSION) |
-  .retrieval.spec.physicalRestore.options.envs.PGUSER = strenv(SOURCE_USERNAME) |
   .retrieval.spec.physicalRestore.options.envs.PGPASSWORD = strenv(SOURCE_PASSWORD) |
-  .retrieval.spec.physicalRestore.options.envs.PGHOST = strenv(SOURCE_HOST) |
-  .retrieval.spec.physicalRestore.options.envs.PGPORT = env(SOURCE_PORT) |
-  .retrieval.spec.physicalRestore.options.customTool.command = "pg_basebackup -X stream -D " + strenv(DLE_TEST_MOUNT_DIR) + "/" + strenv(DLE_TEST_POOL_NAME) + "/data"
+  .retrieval.spec.physicalRestore.options.pgbasebackup.connection.host = strenv(SOURCE_HOST) |
+  .retrieval.spec.physicalRestore.options.pgbasebackup.connection.port = env(SOURCE_PORT) |
+  .retrieval.spec.physicalRestore.options.pgbasebackup.connection.username = strenv(SOURCE_USERNAME)
 ' "${configDir}/server.yml"
 
 # Edit the following options for PostgreSQL 9.6
 if [ "${POSTGRES_VERSION}" = "9.6" ]; then
   yq eval -i '
+  .retrieval.spec.physicalRestore.options.pgbasebackup.slot = "" |
   .databaseConfigs.configs.shared_preload_libraries = "pg_stat_statements, auto_explain" |
   .databaseConfigs.configs.log_directory = "log" |
   .retrieval.spec.physicalRestore.options.sync.configs.log_directory = "log" |